@@ -141,8 +141,57 @@ EOF
                 "wafv2:GetWebACL",
                 "wafv2:ListResourcesForWebACL",
                 "cloudwatch:GetMetricStatistics",
+                "cloudwatch:GetMetricData",
                 "cloudwatch:ListMetrics",
-                "logs:FilterLogEvents"
+                "logs:FilterLogEvents",
+                "logs:StartQuery",
+                "logs:GetQueryResults",
+                "tag:GetResources",
+                "ssm:GetParameter",
+                "secretsmanager:GetSecretValue",
+                "sqs:GetQueueUrl",
+                "sqs:GetQueueAttributes",
+                "cloudwatch:DescribeAlarms",
+                "ce:GetCostAndUsage",
+                "ecs:DescribeServices",
+                "rds:DescribeDBInstances",
+                "guardduty:ListDetectors",
+                "guardduty:ListFindings",
+                "guardduty:GetFindings",
+                "wafv2:GetSampledRequests",
+                "autoscaling:DescribeAutoScalingGroups",
+                "autoscaling:DescribeScalingActivities",
+                "states:ListExecutions",
+                "events:ListTargetsByRule",
+                "s3:GetObject",
+                "s3:PutObject",
+                "ssm:PutParameter",
+                "logs:DescribeMetricFilters",
+                "logs:PutMetricFilter",
+                "ses:SendEmail",
+                "sns:Publish",
+                "health:DescribeEvents",
+                "health:DescribeAffectedEntities",
+                "ec2:DescribeInstanceStatus",
+                "pi:DescribeDimensionKeys",
+                "rds:DescribePendingMaintenanceActions",
+                "rds:DescribeDBSnapshots",
+                "rds:DescribeDBClusterSnapshots",
+                "ec2:DescribeVpnConnections",
+                "ecr:DescribeRepositories",
+                "ecr:DescribeImages",
+                "elasticbeanstalk:DescribeEnvironmentHealth",
+                "xray:GetServiceGraph",
+                "securityhub:GetFindings",
+                "iam:GenerateCredentialReport",
+                "iam:GetCredentialReport",
+                "iam:ListRoles",
+                "s3:GetBucketPublicAccessBlock",
+                "s3:GetBucketPolicyStatus",
+                "s3:GetBucketAcl",
+                "s3:GetEncryptionConfiguration",
+                "s3:ListAllMyBuckets",
+                "shield:ListAttacks"
             ],
             "Resource": "*"
         },
@@ -151,6 +200,9 @@ EOF
             "Action": [
                 "dynamodb:DescribeTable",
                 "dynamodb:GetItem",
+                "dynamodb:BatchGetItem",
+                "dynamodb:PutItem",
+                "dynamodb:DeleteItem",
                 "dynamodb:Query",
                 "dynamodb:Scan"
             ],
@@ -1,115 +1,788 @@
 package config
 
 import (
-	_ "embed"
+	"embed"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
 )
 
-//go:embed config.json
-var configData []byte
+// Matches the template too, so images built without a config.json still
+// compile and take their config from the environment
+//
+//go:embed config*.json
+var configFiles embed.FS
 
 func LoadEmbeddedConfig() (*Config, error) {
+	data, err := configFiles.ReadFile("config.json")
+	if err != nil {
+		return nil, fmt.Errorf("no embedded config.json, set %s or %s", ConfigEnv, ConfigParameterEnv)
+	}
+	return parseConfig(data, "embedded")
+}
+
+func parseConfig(data []byte, source string) (*Config, error) {
 	var config Config
-	if err := json.Unmarshal(configData, &config); err != nil {
-		return nil, fmt.Errorf("error parsing embedded config JSON: %v", err)
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s config JSON: %v", source, err)
 	}
 
 	if err := validateConfig(&config); err != nil {
-		return nil, fmt.Errorf("embedded config validation failed: %v", err)
+		return nil, fmt.Errorf("%s config validation failed: %v", source, err)
 	}
 
 	return &config, nil
 }
 
 type TelegramConfig struct {
-	BotToken string `json:"botToken"`
-	ChatID   string `json:"chatId"`
+	BotToken          string            `json:"botToken"`
+	ChatID            ChatIDs           `json:"chatId"`            // Chats receiving the full report
+	BotTokenSecretArn string            `json:"botTokenSecretArn"` // Used when botToken is empty
+	ChatIDSecretArn   string            `json:"chatIdSecretArn"`   // Used when chatId is empty
+	ParseMode         string            `json:"parseMode"`         // "MarkdownV2" (default), "Markdown", "HTML" or "None"
+	Template          string            `json:"template"`          // "s3://bucket/key", "ssm:name" or a config/templates file, empty = built-in layout
+	Routes            []ChatRouteConfig `json:"routes"`
+	Webhook           WebhookConfig     `json:"webhook"`
+	FallbackTopicARN  string            `json:"fallbackTopicArn"` // SNS topic getting the reports Telegram failed to deliver
+}
+
+// Telegram can be left out when a push channel delivers the report instead
+func (t TelegramConfig) Configured() bool {
+	return t.BotToken != "" || t.BotTokenSecretArn != ""
+}
+
+// Drill-down buttons under the full report. Taps reach the function through
+// the bot webhook (a Lambda function URL).
+type WebhookConfig struct {
+	Enabled     bool           `json:"enabled"`
+	SecretToken string         `json:"secretToken"` // Sent back by Telegram in every webhook request
+	Buttons     []ButtonConfig `json:"buttons"`
+}
+
+// Ad-hoc run sent to the chat the button was tapped in, eg: "EC2 details"
+// with services ["ec2"], or "Last 1h" with periodHours 1
+type ButtonConfig struct {
+	Text         string   `json:"text"`
+	Services     []string `json:"services"`     // Metrics keys, empty = every enabled service
+	PeriodHours  int      `json:"periodHours"`  // Window length, 0 = schedule
+	ErrorSamples int      `json:"errorSamples"` // CloudWatch Logs error samples, 0 = errorSamples
 }
 
+// Invocation running the button drill-down for a chat
+func (b ButtonConfig) Invocation(chatID string) Invocation {
+	return Invocation{
+		PeriodHours:  b.PeriodHours,
+		Services:     b.Services,
+		ErrorSamples: b.ErrorSamples,
+		ChatIDs:      []string{chatID},
+	}
+}
+
+// Chats receiving only the sections of the listed services
+type ChatRouteConfig struct {
+	ChatID   string   `json:"chatId"`
+	Services []string `json:"services"` // Metrics keys, eg: "waf", "rds", "dynamodb"
+}
+
+// Accepts either a single chat ID or a list of chat IDs
+type ChatIDs []string
+
+func (c *ChatIDs) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*c = nil
+		if single != "" {
+			*c = ChatIDs{single}
+		}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("chatId must be a string or an array of strings")
+	}
+	*c = list
+	return nil
+}
+
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeMarkdown   = "Markdown"
+	ParseModeHTML       = "HTML"
+	ParseModeNone       = "None"
+)
+
 type DeploymentConfig struct {
 	LambdaFunctionName   string `json:"lambdaFunctionName"`
 	LambdaCronExpression string `json:"lambdaCronExpression"`
+	HealthAddress        string `json:"healthAddress"` // Daemon mode health endpoint, default ":8080"
 }
 
 type MonitoringConfig struct {
-	Timezone        string `json:"timezone"`
-	DefaultPeriod   int    `json:"defaultPeriod"`   // Hours (0 = disabled)
-	DailyReportHour int    `json:"dailyReportHour"` // Hour of day (0-23)
+	Timezone      string `json:"timezone"`
+	DefaultPeriod int    `json:"defaultPeriod"` // Hours (0 = disabled)
+	// Overrides defaultPeriod for sub-hourly runs, eg: 15 with the function
+	// scheduled every 15 minutes. Must divide an hour or be whole hours.
+	DefaultPeriodMinutes int               `json:"defaultPeriodMinutes"`
+	DailyReportHour      int               `json:"dailyReportHour"` // Hour of day (0-23)
+	Mode                 string            `json:"mode"`            // "full" (default) or "alertsOnly"
+	Thresholds           []ThresholdConfig `json:"thresholds"`
+	Charts               bool              `json:"charts"` // Attach charts to the daily report
+	// Seconds a collector (or one of its resources) may take, default 60.
+	// On Lambda it is also bounded by the remaining invocation time.
+	CollectorTimeout   int                       `json:"collectorTimeout"`
+	WeeklyReport       WeeklyReportConfig        `json:"weeklyReport"`
+	MonthlyReport      MonthlyReportConfig       `json:"monthlyReport"`
+	Schedules          []ScheduleConfig          `json:"schedules"`
+	QuietHours         QuietHoursConfig          `json:"quietHours"`
+	MaintenanceWindows []MaintenanceWindowConfig `json:"maintenanceWindows"`
+	Severity           SeverityConfig            `json:"severity"`
+	StatusIndicators   StatusIndicatorsConfig    `json:"statusIndicators"`
+}
+
+// Prefixes each section with 🟢/🟡/🔴 from the rules of its service
+type StatusIndicatorsConfig struct {
+	Enabled bool               `json:"enabled"`
+	Rules   []StatusRuleConfig `json:"rules"`
+}
+
+// Warning and critical levels of a collected metric, eg: EC2
+// CPUUtilization_Maximum over 70 (warning) and 90 (critical)
+type StatusRuleConfig struct {
+	Service  string   `json:"service"`  // Metrics key, eg: "ec2", "alb", "cloudwatchLogs"
+	Resource string   `json:"resource"` // Per-resource services only (empty = any resource)
+	Metric   string   `json:"metric"`   // Collected metric name, eg: "HTTPCode_ELB_5XX_Count"
+	Operator string   `json:"operator"` // ">" (default) or "<"
+	Warning  *float64 `json:"warning"`  // Required, pointers tell an omitted level from 0
+	Critical *float64 `json:"critical"` // Required, beyond warning in the operator direction
+}
+
+// Routine reports are sent silently, the ones with breached thresholds,
+// anomalies or too many log errors loudly behind a severity banner
+type SeverityConfig struct {
+	Enabled    bool `json:"enabled"`
+	ErrorCount int  `json:"errorCount"` // CloudWatch Logs errors making a report critical, 0 = ignored
+}
+
+// Scheduled reports during quiet hours and maintenance windows are sent
+// without a notification sound ("silent") or not at all ("suppress")
+const (
+	QuietSilent   = "silent"
+	QuietSuppress = "suppress"
+)
+
+// Daily quiet period, eg: 23:00 to 07:00
+type QuietHoursConfig struct {
+	Start string `json:"start"` // "HH:MM", report timezone. Empty = disabled
+	End   string `json:"end"`   // Earlier than start for overnight quiet hours
+	Mode  string `json:"mode"`  // "silent" (default) or "suppress"
+}
+
+// Planned maintenance, eg: a deployment
+type MaintenanceWindowConfig struct {
+	Start  time.Time `json:"start"` // RFC3339, eg: "2024-06-01T22:00:00Z"
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason"`
+	Mode   string    `json:"mode"` // "silent" (default) or "suppress"
+}
+
+// An additional report with its own window, services and chats, sent after
+// the default one at the given hours
+type ScheduleConfig struct {
+	Name        string   `json:"name"`        // Unique, names its history and archive
+	Hours       []int    `json:"hours"`       // Hours of day (0-23, report timezone), empty = every run
+	PeriodHours int      `json:"periodHours"` // Window length, default 1
+	Services    []string `json:"services"`    // Metrics keys, empty = every enabled service
+	ChatIDs     []string `json:"chatIds"`     // Telegram chats, empty = telegram chatId and routes
+	Daily       bool     `json:"daily"`       // Includes the daily-only services and is always sent
+}
+
+// Invocation running the schedule
+func (s ScheduleConfig) Invocation() Invocation {
+	return Invocation{
+		Schedule:    s.Name,
+		PeriodHours: s.PeriodHours,
+		Services:    s.Services,
+		Daily:       &s.Daily,
+		ChatIDs:     s.ChatIDs,
+	}
 }
 
+// Weekly digest covering the last 7 days, sent instead of the report of its hour
+type WeeklyReportConfig struct {
+	Enabled bool   `json:"enabled"`
+	Weekday string `json:"weekday"` // "monday" (default) to "sunday"
+	Hour    int    `json:"hour"`    // Hour of day (0-23)
+}
+
+// Monthly digest covering the last month, sent instead of the report of its hour
+type MonthlyReportConfig struct {
+	Enabled bool `json:"enabled"`
+	Day     int  `json:"day"`  // Day of month (1-28), default 1
+	Hour    int  `json:"hour"` // Hour of day (0-23)
+}
+
+// Rollup reports, compared with the previous rollup of the same kind
+const (
+	RollupWeekly  = "weekly"
+	RollupMonthly = "monthly"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// A threshold is breached when the collected metric compares true against value
+type ThresholdConfig struct {
+	Service  string  `json:"service"`  // Metrics key, eg: "ec2", "dynamodb"
+	Resource string  `json:"resource"` // Per-resource services only (empty = any resource)
+	Metric   string  `json:"metric"`   // Collected metric name, eg: "CPUUtilization_Maximum"
+	Operator string  `json:"operator"` // ">" or "<"
+	Value    float64 `json:"value"`
+}
+
+// How log levels are counted: paging through FilterLogEvents, a single Logs
+// Insights stats query, or metric filters created on the log groups
+const (
+	LogsCountFilter        = "filter"
+	LogsCountInsights      = "insights"
+	LogsCountMetricFilters = "metricFilters"
+)
+
+const (
+	ModeFull       = "full"
+	ModeAlertsOnly = "alertsOnly"
+)
+
+type DiscoveryConfig struct {
+	TagKey   string `json:"tagKey"`   // Empty = discovery disabled
+	TagValue string `json:"tagValue"` // Empty = any value
+}
+
+// A CloudWatch metric collected as is, shown as "label: value unit"
+type CustomMetricConfig struct {
+	Label      string            `json:"label"` // Unique, also the resource name in thresholds
+	Namespace  string            `json:"namespace"`
+	MetricName string            `json:"metricName"`
+	Dimensions map[string]string `json:"dimensions"`
+	Statistic  string            `json:"statistic"` // Default Average
+	Unit       string            `json:"unit"`      // Display only, eg: "ms"
+}
+
+type WebACLConfig struct {
+	WebACLID       string `json:"webACLId"`
+	WebACLName     string `json:"webACLName"`
+	Scope          string `json:"scope"`          // "REGIONAL" (default) or "CLOUDFRONT"
+	DistributionID string `json:"distributionId"` // CLOUDFRONT scope, empty = services.cloudfront distribution
+}
+
+type HistoryConfig struct {
+	TableName      string   `json:"tableName"`      // Empty = no trends
+	Baselines      bool     `json:"baselines"`      // Keep same-hour windows for anomaly detection
+	AnomalyFactor  float64  `json:"anomalyFactor"`  // Flag metrics this many times their 7-day average, default 3
+	AnomalyMetrics []string `json:"anomalyMetrics"` // Metric names, empty = 5xx, error and throttle counts
+}
+
+type ArchiveConfig struct {
+	BucketName string `json:"bucketName"` // Empty = no archive
+	Prefix     string `json:"prefix"`     // Default "reports"
+}
+
+// Alerts when the scheduled report stops being produced. The check runs from
+// its own schedule, with {"watchdog": true} as the invocation payload.
+type WatchdogConfig struct {
+	MissedReports  int    `json:"missedReports"`  // Alert after this many reports are missed, 0 = disabled
+	CronExpression string `json:"cronExpression"` // Schedule of the check, created by deploy
+	HeartbeatURL   string `json:"heartbeatUrl"`   // Pinged after every report, for external dead man's switches
+}
+
+// Discovery results (ALB and CWAgent dimensions, WAF web ACLs, DynamoDB tables)
+// are cached in memory across warm invocations
+type CacheConfig struct {
+	TTLMinutes   int    `json:"ttlMinutes"`   // Default 60
+	SSMParameter string `json:"ssmParameter"` // Also persisted here for cold starts, empty = memory only
+}
+
+// Retryer of the shared AWS clients
+type SDKConfig struct {
+	RetryMode   string `json:"retryMode"`   // "standard" or "adaptive", empty = SDK default
+	MaxAttempts int    `json:"maxAttempts"` // 0 = SDK default (3)
+}
+
+const (
+	RetryModeStandard = "standard"
+	RetryModeAdaptive = "adaptive"
+)
+
 type GlobalConfig struct {
 	Telegram   TelegramConfig   `json:"telegram"`
 	Deployment DeploymentConfig `json:"deployment"`
 	Monitoring MonitoringConfig `json:"monitoring"`
+	Discovery  DiscoveryConfig  `json:"discovery"`
+	History    HistoryConfig    `json:"history"`
+	Archive    ArchiveConfig    `json:"archive"`
+	Watchdog   WatchdogConfig   `json:"watchdog"`
+	SDK        SDKConfig        `json:"sdk"`
+	Cache      CacheConfig      `json:"cache"`
 }
 
 type ServiceConfig struct {
 	EC2 struct {
-		Enabled    bool   `json:"enabled"`
-		InstanceID string `json:"instanceId"`
+		Enabled            bool    `json:"enabled"`
+		Region             string  `json:"region"`
+		InstanceID         string  `json:"instanceId"`
+		CPUCreditThreshold float64 `json:"cpuCreditThreshold"` // Alert below this CPUCreditBalance, 0 = off
 	} `json:"ec2"`
 
 	S3 struct {
-		Enabled    bool   `json:"enabled"`
-		BucketName string `json:"bucketName"`
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		BucketNames     []string          `json:"bucketNames"`
+		BucketName      string            `json:"bucketName"`   // Deprecated, appended to bucketNames
+		ObjectDeltas    bool              `json:"objectDeltas"` // Object count change vs yesterday, needs the history
+		// Filter ID of the request metrics enabled on the buckets, eg: "EntireBucket", empty = off
+		RequestMetricsFilter string `json:"requestMetricsFilter"`
 	} `json:"s3"`
 
+	StorageLens struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"` // Home region of the configuration
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		ConfigurationID string            `json:"configurationId"`
+		BucketNames     []string          `json:"bucketNames"` // Empty = account totals
+	} `json:"storageLens"`
+
+	S3Audit struct {
+		Enabled    bool `json:"enabled"`
+		AllBuckets bool `json:"allBuckets"` // Every bucket of the account instead of the s3 bucketNames
+	} `json:"s3Audit"`
+
 	ALB struct {
-		Enabled bool   `json:"enabled"`
-		ALBName string `json:"albName"`
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		ALBNames        []string          `json:"albNames"`
+		ALBName         string            `json:"albName"`      // Deprecated, appended to albNames
+		TargetGroups    bool              `json:"targetGroups"` // Per-target-group breakdown
 	} `json:"alb"`
 
+	NLB struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		NLBNames        []string          `json:"nlbNames"`
+	} `json:"nlb"`
+
 	CloudFront struct {
 		Enabled        bool   `json:"enabled"`
 		DistributionID string `json:"distributionId"`
 	} `json:"cloudfront"`
 
+	Route53 struct {
+		Enabled        bool     `json:"enabled"`
+		HealthCheckIDs []string `json:"healthCheckIds"`
+	} `json:"route53"`
+
+	Uptime struct {
+		Enabled        bool     `json:"enabled"`
+		URLs           []string `json:"urls"`
+		ExpectedStatus int      `json:"expectedStatus"` // 0 = any 2xx or 3xx
+		TimeoutSeconds int      `json:"timeoutSeconds"` // Default 10
+		SlowMs         float64  `json:"slowMs"`         // Responses slower than this are flagged, 0 = never
+		TLSExpiryDays  int      `json:"tlsExpiryDays"`  // Certificates expiring sooner are flagged, default 14
+	} `json:"uptime"`
+
+	TLS struct {
+		Enabled        bool     `json:"enabled"`
+		Hostnames      []string `json:"hostnames"`      // host or host:port, 443 by default
+		ExpiryDays     int      `json:"expiryDays"`     // Certificates expiring sooner are flagged, default 14
+		TimeoutSeconds int      `json:"timeoutSeconds"` // Default 10
+	} `json:"tls"`
+
 	CloudWatchAgent struct {
-		Enabled    bool   `json:"enabled"`
-		InstanceID string `json:"instanceId"`
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		InstanceIDs     []string          `json:"instanceIds"` // Empty = the EC2 instance
+		InstanceID      string            `json:"instanceId"`  // Deprecated, appended to instanceIds
+		// Names of the procstat processes (exe or pattern in the agent config)
+		Processes []string `json:"processes"`
 	} `json:"cloudwatchAgent"`
 
 	CloudWatchLogs struct {
-		Enabled       bool     `json:"enabled"`
-		LogGroupNames []string `json:"logGroupNames"`
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		LogGroupNames   []string          `json:"logGroupNames"`
+		ErrorSamples    int               `json:"errorSamples"` // Recent error lines per log group, 0 = none
+		CountMode       string            `json:"countMode"`    // "filter" (default), "insights" or "metricFilters"
 	} `json:"cloudwatchLogs"`
 
 	WAF struct {
-		Enabled    bool   `json:"enabled"`
-		WebACLID   string `json:"webACLId"`
-		WebACLName string `json:"webACLName"`
-		Scope      string `json:"scope"` // "REGIONAL" or "CLOUDFRONT"
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`          // REGIONAL scope only, empty = default region
+		ResourceRegions map[string]string `json:"resourceRegions"` // Keyed by webACLName
+		WebACLs         []WebACLConfig    `json:"webACLs"`
+		WebACLID        string            `json:"webACLId"`   // Deprecated, single web ACL appended to webACLs
+		WebACLName      string            `json:"webACLName"` // Deprecated
+		Scope           string            `json:"scope"`      // Deprecated
+		TopBlocked      int               `json:"topBlocked"` // Rules, IPs and countries listed, default 5
 	} `json:"waf"`
 
 	DynamoDB struct {
-		Enabled    bool     `json:"enabled"`
-		TableNames []string `json:"tableNames"`
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		TableNames      []string          `json:"tableNames"`
+		// Alert above this consumed/provisioned capacity of provisioned tables (%), 0 = off
+		UtilizationThreshold float64 `json:"utilizationThreshold"`
 	} `json:"dynamodb"`
 
 	RDS struct {
 		Enabled              bool   `json:"enabled"`
+		Region               string `json:"region"`
 		ClusterID            string `json:"clusterId"`
 		DBInstanceIdentifier string `json:"dbInstanceIdentifier"`
+		// Writer and readers of the cluster, shown as a per-instance table
+		DBInstanceIdentifiers []string `json:"dbInstanceIdentifiers"`
+		Engine                string   `json:"engine"`     // "aurora" or "standard", empty = auto-detect
+		TopQueries            int      `json:"topQueries"` // Daily top SQL statements by load (Performance Insights), 0 = off
 	} `json:"rds"`
+
+	VPCFlowLogs struct {
+		Enabled      bool   `json:"enabled"`
+		Region       string `json:"region"`
+		LogGroupName string `json:"logGroupName"`
+		VPCCidr      string `json:"vpcCidr"`
+		TopTalkers   int    `json:"topTalkers"` // Default 5
+	} `json:"vpcFlowLogs"`
+
+	CloudTrail struct {
+		Enabled      bool   `json:"enabled"`
+		Region       string `json:"region"`
+		LogGroupName string `json:"logGroupName"` // Log group the trail delivers to
+		MaxEvents    int    `json:"maxEvents"`    // Default 10
+	} `json:"cloudTrail"`
+
+	VPN struct {
+		Enabled          bool              `json:"enabled"`
+		Region           string            `json:"region"`
+		ResourceRegions  map[string]string `json:"resourceRegions"`
+		VPNConnectionIDs []string          `json:"vpnConnectionIds"`
+	} `json:"vpn"`
+
+	TransitGateway struct {
+		Enabled           bool              `json:"enabled"`
+		Region            string            `json:"region"`
+		ResourceRegions   map[string]string `json:"resourceRegions"`
+		TransitGatewayIDs []string          `json:"transitGatewayIds"`
+	} `json:"transitGateway"`
+
+	Lambda struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		FunctionNames   []string          `json:"functionNames"`
+	} `json:"lambda"`
+
+	AppSync struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		APIIDs          []string          `json:"apiIds"`
+	} `json:"appsync"`
+
+	SQS struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		Queues          []string          `json:"queues"` // Queue names or URLs
+	} `json:"sqs"`
+
+	Alarms struct {
+		Enabled         bool   `json:"enabled"`
+		Region          string `json:"region"`
+		AlarmNamePrefix string `json:"alarmNamePrefix"` // Empty = all alarms
+	} `json:"alarms"`
+
+	Cost struct {
+		Enabled     bool `json:"enabled"`
+		TopServices int  `json:"topServices"` // Default 5
+	} `json:"cost"`
+
+	ECS struct {
+		Enabled      bool     `json:"enabled"`
+		Region       string   `json:"region"`
+		ClusterName  string   `json:"clusterName"`
+		ServiceNames []string `json:"serviceNames"`
+	} `json:"ecs"`
+
+	ECR struct {
+		Enabled         bool     `json:"enabled"`
+		Region          string   `json:"region"`
+		RepositoryNames []string `json:"repositoryNames"` // Empty = all repositories
+	} `json:"ecr"`
+
+	EKS struct {
+		Enabled     bool   `json:"enabled"`
+		Region      string `json:"region"`
+		ClusterName string `json:"clusterName"`
+	} `json:"eks"`
+
+	Beanstalk struct {
+		Enabled          bool              `json:"enabled"`
+		Region           string            `json:"region"`
+		ResourceRegions  map[string]string `json:"resourceRegions"`
+		EnvironmentNames []string          `json:"environmentNames"`
+	} `json:"beanstalk"`
+
+	OpenSearch struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		DomainNames     []string          `json:"domainNames"`
+	} `json:"opensearch"`
+
+	ElastiCache struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		CacheClusterIDs []string          `json:"cacheClusterIds"` // Node IDs, eg: "my-redis-001"
+	} `json:"elasticache"`
+
+	ASG struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		GroupNames      []string          `json:"groupNames"`
+	} `json:"asg"`
+
+	SES struct {
+		Enabled           bool     `json:"enabled"`
+		Region            string   `json:"region"`
+		ConfigurationSets []string `json:"configurationSets"` // Optional, per configuration set counts
+	} `json:"ses"`
+
+	StepFunctions struct {
+		Enabled          bool     `json:"enabled"`
+		StateMachineArns []string `json:"stateMachineArns"` // Region is taken from the ARN
+	} `json:"stepFunctions"`
+
+	Kinesis struct {
+		Enabled                bool              `json:"enabled"`
+		Region                 string            `json:"region"`
+		ResourceRegions        map[string]string `json:"resourceRegions"`
+		StreamNames            []string          `json:"streamNames"`
+		IteratorAgeThresholdMs float64           `json:"iteratorAgeThresholdMs"` // Alert above this consumer lag, 0 = off
+	} `json:"kinesis"`
+
+	MSK struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		ClusterNames    []string          `json:"clusterNames"`
+		ConsumerLag     bool              `json:"consumerLag"` // Max offset lag per consumer group
+	} `json:"msk"`
+
+	Cognito struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		UserPoolIDs     []string          `json:"userPoolIds"`
+	} `json:"cognito"`
+
+	EventBridge struct {
+		Enabled         bool              `json:"enabled"`
+		Region          string            `json:"region"`
+		ResourceRegions map[string]string `json:"resourceRegions"`
+		EventBusName    string            `json:"eventBusName"` // Empty = default bus
+		RuleNames       []string          `json:"ruleNames"`
+	} `json:"eventBridge"`
+
+	GuardDuty struct {
+		Enabled     bool   `json:"enabled"`
+		Region      string `json:"region"`
+		DetectorID  string `json:"detectorId"`  // Empty = the detector of the region
+		TopFindings int    `json:"topFindings"` // Default 5
+	} `json:"guardduty"`
+
+	SecurityHub struct {
+		Enabled     bool   `json:"enabled"`
+		Region      string `json:"region"`      // Aggregation region to include the linked regions
+		TopFindings int    `json:"topFindings"` // Default 5
+	} `json:"securityHub"`
+
+	Shield struct {
+		Enabled bool `json:"enabled"`
+	} `json:"shield"`
+
+	IAM struct {
+		Enabled             bool `json:"enabled"`
+		AccessKeyMaxAgeDays int  `json:"accessKeyMaxAgeDays"` // Default 90
+	} `json:"iam"`
+
+	XRay struct {
+		Enabled     bool   `json:"enabled"`
+		Region      string `json:"region"`
+		GroupName   string `json:"groupName"`   // Empty = default group
+		TopServices int    `json:"topServices"` // Default 5
+	} `json:"xray"`
+
+	Health struct {
+		Enabled   bool     `json:"enabled"`
+		Regions   []string `json:"regions"`   // Empty = region of the function, global events are always included
+		TopEvents int      `json:"topEvents"` // Default 10
+	} `json:"health"`
+
+	CustomMetrics struct {
+		Enabled         bool                 `json:"enabled"`
+		Region          string               `json:"region"`
+		ResourceRegions map[string]string    `json:"resourceRegions"` // Keyed by label
+		Metrics         []CustomMetricConfig `json:"metrics"`
+	} `json:"customMetrics"`
+}
+
+type NotifiersConfig struct {
+	Slack struct {
+		Enabled    bool   `json:"enabled"`
+		WebhookURL string `json:"webhookUrl"`
+	} `json:"slack"`
+	Prometheus struct {
+		Enabled        bool              `json:"enabled"`
+		RemoteWriteURL string            `json:"remoteWriteUrl"`
+		BearerToken    string            `json:"bearerToken"`
+		Username       string            `json:"username"`
+		Password       string            `json:"password"`
+		Labels         map[string]string `json:"labels"` // Added to every series, eg: {"job": "telegraws"}
+	} `json:"prometheus"`
+	Email struct {
+		Enabled bool     `json:"enabled"`
+		From    string   `json:"from"` // Verified SES identity
+		To      []string `json:"to"`
+		Region  string   `json:"region"` // SES region, the function region by default
+	} `json:"email"`
+	PagerDuty struct {
+		Enabled    bool   `json:"enabled"`
+		RoutingKey string `json:"routingKey"` // Events API v2 integration key
+	} `json:"pagerDuty"`
+	Webhook struct {
+		Enabled     bool   `json:"enabled"`
+		URL         string `json:"url"`
+		Secret      string `json:"secret"`      // HMAC-SHA256 signing key, empty = unsigned
+		IncludeText bool   `json:"includeText"` // Adds the plain text report
+	} `json:"webhook"`
+	Ntfy struct {
+		Enabled bool   `json:"enabled"`
+		Server  string `json:"server"` // Defaults to https://ntfy.sh
+		Topic   string `json:"topic"`
+		Token   string `json:"token"` // Access token of protected topics
+	} `json:"ntfy"`
+	Pushover struct {
+		Enabled  bool   `json:"enabled"`
+		AppToken string `json:"appToken"`
+		UserKey  string `json:"userKey"` // User or group key
+	} `json:"pushover"`
 }
 
 type Config struct {
-	Global   GlobalConfig  `json:"global"`
-	Services ServiceConfig `json:"services"`
+	Global    GlobalConfig    `json:"global"`
+	Services  ServiceConfig   `json:"services"`
+	Notifiers NotifiersConfig `json:"notifiers"`
+}
+
+// Checks the service lists of routes, buttons and schedules against
+// metricsKeys, taken from the collectors which config can't import
+func (c *Config) ValidateServiceNames(metricsKeys []string) error {
+	for i, route := range c.Global.Telegram.Routes {
+		if err := checkServiceNames(route.Services, metricsKeys); err != nil {
+			return fmt.Errorf("telegram route %d %v", i, err)
+		}
+	}
+	for i, button := range c.Global.Telegram.Webhook.Buttons {
+		if err := checkServiceNames(button.Services, metricsKeys); err != nil {
+			return fmt.Errorf("telegram webhook button %d %v", i, err)
+		}
+	}
+	for _, schedule := range c.Global.Monitoring.Schedules {
+		if err := checkServiceNames(schedule.Services, metricsKeys); err != nil {
+			return fmt.Errorf("schedule '%s' %v", schedule.Name, err)
+		}
+	}
+	return nil
+}
+
+func checkServiceNames(services []string, metricsKeys []string) error {
+	for _, service := range services {
+		if !slices.Contains(metricsKeys, service) {
+			return fmt.Errorf("service '%s' is unknown", service)
+		}
+	}
+	return nil
 }
 
 func validateConfig(config *Config) error {
-	if config.Global.Telegram.BotToken == "" {
-		return fmt.Errorf("telegram botToken is required")
+	pushChannel := config.Notifiers.Ntfy.Enabled || config.Notifiers.Pushover.Enabled
+	if !config.Global.Telegram.Configured() && !pushChannel {
+		return fmt.Errorf("telegram botToken or botTokenSecretArn is required, unless ntfy or pushover is enabled")
 	}
-	if config.Global.Telegram.ChatID == "" {
-		return fmt.Errorf("telegram chatId is required")
+	if config.Global.Telegram.Configured() && len(config.Global.Telegram.ChatID) == 0 && config.Global.Telegram.ChatIDSecretArn == "" {
+		return fmt.Errorf("telegram chatId or chatIdSecretArn is required")
+	}
+	if topicARN := config.Global.Telegram.FallbackTopicARN; topicARN != "" {
+		if _, err := arn.Parse(topicARN); err != nil {
+			return fmt.Errorf("telegram fallbackTopicArn '%s' is invalid: %v", topicARN, err)
+		}
+	}
+	for i, route := range config.Global.Telegram.Routes {
+		if route.ChatID == "" || len(route.Services) == 0 {
+			return fmt.Errorf("telegram route %d requires chatId and services", i)
+		}
+	}
+	if webhook := config.Global.Telegram.Webhook; webhook.Enabled {
+		if !config.Global.Telegram.Configured() {
+			return fmt.Errorf("telegram webhook requires a botToken or botTokenSecretArn")
+		}
+		if webhook.SecretToken == "" {
+			return fmt.Errorf("telegram webhook requires a secretToken")
+		}
+		if len(webhook.Buttons) == 0 {
+			return fmt.Errorf("telegram webhook is enabled but buttons array is empty")
+		}
+		for i, button := range webhook.Buttons {
+			if button.Text == "" {
+				return fmt.Errorf("telegram webhook button %d requires text", i)
+			}
+			if button.PeriodHours < 0 || button.ErrorSamples < 0 {
+				return fmt.Errorf("telegram webhook button %d periodHours and errorSamples must be >= 0", i)
+			}
+		}
+	}
+	switch config.Global.Telegram.ParseMode {
+	case "":
+		config.Global.Telegram.ParseMode = ParseModeMarkdownV2
+	case ParseModeMarkdownV2, ParseModeMarkdown, ParseModeHTML, ParseModeNone:
+	default:
+		return fmt.Errorf("telegram parseMode must be either 'MarkdownV2', 'Markdown', 'HTML', 'None' or empty (default to MarkdownV2)")
 	}
 	if config.Global.Deployment.LambdaFunctionName == "" {
 		return fmt.Errorf("deployment lambdaFunctionName is required")
 	}
+	if config.Global.Deployment.HealthAddress == "" {
+		config.Global.Deployment.HealthAddress = ":8080"
+	}
 	if config.Global.Monitoring.Timezone == "" {
 		return fmt.Errorf("monitoring timezone is required")
 	}
@@ -122,83 +795,718 @@ func validateConfig(config *Config) error {
 	if config.Global.Monitoring.DefaultPeriod < 0 {
 		return fmt.Errorf("defaultPeriod must be >= 0")
 	}
+	if minutes := config.Global.Monitoring.DefaultPeriodMinutes; minutes < 0 || minutes > 0 && 60%minutes != 0 && minutes%60 != 0 {
+		return fmt.Errorf("defaultPeriodMinutes must divide 60 or be a multiple of it")
+	}
+	if config.Global.Monitoring.CollectorTimeout < 0 {
+		return fmt.Errorf("collectorTimeout must be >= 0")
+	}
+	if config.Global.Monitoring.CollectorTimeout == 0 {
+		config.Global.Monitoring.CollectorTimeout = 60
+	}
+	if quiet := &config.Global.Monitoring.QuietHours; quiet.Start != "" || quiet.End != "" {
+		if _, err := parseClock(quiet.Start); err != nil {
+			return fmt.Errorf("quietHours start: %v", err)
+		}
+		if _, err := parseClock(quiet.End); err != nil {
+			return fmt.Errorf("quietHours end: %v", err)
+		}
+		if err := defaultQuietMode(&quiet.Mode); err != nil {
+			return fmt.Errorf("quietHours %v", err)
+		}
+	}
+	for i := range config.Global.Monitoring.MaintenanceWindows {
+		window := &config.Global.Monitoring.MaintenanceWindows[i]
+		if window.Start.IsZero() || !window.End.After(window.Start) {
+			return fmt.Errorf("maintenanceWindows[%d] needs a start and an end after it", i)
+		}
+		if err := defaultQuietMode(&window.Mode); err != nil {
+			return fmt.Errorf("maintenanceWindows[%d] %v", i, err)
+		}
+	}
+	if config.Global.Monitoring.Severity.ErrorCount < 0 {
+		return fmt.Errorf("severity errorCount must be >= 0")
+	}
+	scheduleNames := map[string]bool{}
+	for i := range config.Global.Monitoring.Schedules {
+		schedule := &config.Global.Monitoring.Schedules[i]
+		if schedule.Name == "" {
+			return fmt.Errorf("schedules[%d] name is required", i)
+		}
+		if scheduleNames[schedule.Name] {
+			return fmt.Errorf("schedule name '%s' is used more than once", schedule.Name)
+		}
+		scheduleNames[schedule.Name] = true
+		for _, hour := range schedule.Hours {
+			if hour < 0 || hour > 23 {
+				return fmt.Errorf("schedule '%s' hours must be between 0 and 23", schedule.Name)
+			}
+		}
+		if schedule.PeriodHours < 0 {
+			return fmt.Errorf("schedule '%s' periodHours must be >= 0", schedule.Name)
+		}
+		if schedule.PeriodHours == 0 {
+			schedule.PeriodHours = 1
+		}
+	}
+	if weekly := &config.Global.Monitoring.WeeklyReport; weekly.Enabled {
+		if weekly.Weekday == "" {
+			weekly.Weekday = "monday"
+		}
+		weekly.Weekday = strings.ToLower(weekly.Weekday)
+		if _, exists := weekdays[weekly.Weekday]; !exists {
+			return fmt.Errorf("weeklyReport weekday '%s' is invalid", weekly.Weekday)
+		}
+		if weekly.Hour < 0 || weekly.Hour > 23 {
+			return fmt.Errorf("weeklyReport hour must be between 0 and 23")
+		}
+	}
+	if monthly := &config.Global.Monitoring.MonthlyReport; monthly.Enabled {
+		if monthly.Day == 0 {
+			monthly.Day = 1
+		}
+		// Every month has a 28th
+		if monthly.Day < 1 || monthly.Day > 28 {
+			return fmt.Errorf("monthlyReport day must be between 1 and 28")
+		}
+		if monthly.Hour < 0 || monthly.Hour > 23 {
+			return fmt.Errorf("monthlyReport hour must be between 0 and 23")
+		}
+	}
+	if config.Services.Kinesis.Enabled && config.Services.Kinesis.IteratorAgeThresholdMs > 0 {
+		config.Global.Monitoring.Thresholds = append(config.Global.Monitoring.Thresholds, ThresholdConfig{
+			Service:  "kinesis",
+			Metric:   "IteratorAgeMilliseconds",
+			Operator: ">",
+			Value:    config.Services.Kinesis.IteratorAgeThresholdMs,
+		})
+	}
+	// On-demand tables don't report utilization, so these never fire for them
+	if config.Services.DynamoDB.Enabled && config.Services.DynamoDB.UtilizationThreshold > 0 {
+		for _, metric := range []string{"ReadUtilization", "WriteUtilization"} {
+			config.Global.Monitoring.Thresholds = append(config.Global.Monitoring.Thresholds, ThresholdConfig{
+				Service:  "dynamodb",
+				Metric:   metric,
+				Operator: ">",
+				Value:    config.Services.DynamoDB.UtilizationThreshold,
+			})
+		}
+	}
+	// Non-burstable instances don't report credits, so this threshold never fires for them
+	if config.Services.EC2.Enabled && config.Services.EC2.CPUCreditThreshold > 0 {
+		config.Global.Monitoring.Thresholds = append(config.Global.Monitoring.Thresholds, ThresholdConfig{
+			Service:  "ec2",
+			Metric:   "CPUCreditBalance",
+			Operator: "<",
+			Value:    config.Services.EC2.CPUCreditThreshold,
+		})
+	}
+	switch config.Global.Monitoring.Mode {
+	case "", ModeFull:
+	case ModeAlertsOnly:
+		if len(config.Global.Monitoring.Thresholds) == 0 {
+			return fmt.Errorf("mode is alertsOnly but thresholds array is empty")
+		}
+	default:
+		return fmt.Errorf("mode must be either 'full', 'alertsOnly' or empty (default to full)")
+	}
+	for i, threshold := range config.Global.Monitoring.Thresholds {
+		if threshold.Service == "" || threshold.Metric == "" {
+			return fmt.Errorf("threshold %d requires service and metric", i)
+		}
+		if threshold.Operator != ">" && threshold.Operator != "<" {
+			return fmt.Errorf("threshold %d operator must be either '>' or '<'", i)
+		}
+	}
+	for i := range config.Global.Monitoring.StatusIndicators.Rules {
+		rule := &config.Global.Monitoring.StatusIndicators.Rules[i]
+		if rule.Service == "" || rule.Metric == "" {
+			return fmt.Errorf("status rule %d requires service and metric", i)
+		}
+		if rule.Operator == "" {
+			rule.Operator = ">"
+		}
+		if rule.Operator != ">" && rule.Operator != "<" {
+			return fmt.Errorf("status rule %d operator must be either '>' or '<'", i)
+		}
+		if rule.Warning == nil || rule.Critical == nil {
+			return fmt.Errorf("status rule %d requires warning and critical", i)
+		}
+		if (rule.Operator == ">" && *rule.Critical <= *rule.Warning) || (rule.Operator == "<" && *rule.Critical >= *rule.Warning) {
+			return fmt.Errorf("status rule %d critical must be beyond warning for operator '%s'", i, rule.Operator)
+		}
+	}
+	if config.Services.Lambda.Enabled && len(config.Services.Lambda.FunctionNames) == 0 {
+		return fmt.Errorf("Lambda is enabled but functionNames array is empty")
+	}
+	if config.Services.SQS.Enabled && len(config.Services.SQS.Queues) == 0 {
+		return fmt.Errorf("SQS is enabled but queues array is empty")
+	}
+	if config.Services.Cost.TopServices < 0 {
+		return fmt.Errorf("Cost topServices must be >= 0")
+	}
+	if config.Services.ECS.Enabled {
+		if config.Services.ECS.ClusterName == "" {
+			return fmt.Errorf("ECS is enabled but clusterName is empty")
+		}
+		if len(config.Services.ECS.ServiceNames) == 0 {
+			return fmt.Errorf("ECS is enabled but serviceNames array is empty")
+		}
+	}
+	if config.Services.ElastiCache.Enabled && len(config.Services.ElastiCache.CacheClusterIDs) == 0 {
+		return fmt.Errorf("ElastiCache is enabled but cacheClusterIds array is empty")
+	}
+	if config.Services.ASG.Enabled && len(config.Services.ASG.GroupNames) == 0 {
+		return fmt.Errorf("ASG is enabled but groupNames array is empty")
+	}
+	if config.Services.Kinesis.Enabled {
+		if len(config.Services.Kinesis.StreamNames) == 0 {
+			return fmt.Errorf("Kinesis is enabled but streamNames array is empty")
+		}
+		if config.Services.Kinesis.IteratorAgeThresholdMs < 0 {
+			return fmt.Errorf("Kinesis iteratorAgeThresholdMs must be >= 0")
+		}
+	}
+	if config.Services.EventBridge.Enabled && len(config.Services.EventBridge.RuleNames) == 0 {
+		return fmt.Errorf("EventBridge is enabled but ruleNames array is empty")
+	}
+	if config.Services.StepFunctions.Enabled {
+		if len(config.Services.StepFunctions.StateMachineArns) == 0 {
+			return fmt.Errorf("Step Functions is enabled but stateMachineArns array is empty")
+		}
+		for _, stateMachineArn := range config.Services.StepFunctions.StateMachineArns {
+			if _, err := arn.Parse(stateMachineArn); err != nil {
+				return fmt.Errorf("Step Functions state machine ARN '%s' is invalid: %v", stateMachineArn, err)
+			}
+		}
+	}
+	if config.Services.RDS.TopQueries < 0 || config.Services.RDS.TopQueries > 25 {
+		return fmt.Errorf("RDS topQueries must be between 0 and 25")
+	}
+	if config.Services.GuardDuty.TopFindings < 0 {
+		return fmt.Errorf("GuardDuty topFindings must be >= 0")
+	}
+	if config.Services.SecurityHub.TopFindings < 0 {
+		return fmt.Errorf("Security Hub topFindings must be >= 0")
+	}
+	if config.Services.IAM.AccessKeyMaxAgeDays < 0 {
+		return fmt.Errorf("IAM accessKeyMaxAgeDays must be >= 0")
+	}
+	if config.Services.XRay.TopServices < 0 {
+		return fmt.Errorf("X-Ray topServices must be >= 0")
+	}
+	if config.Services.Health.TopEvents < 0 {
+		return fmt.Errorf("Health topEvents must be >= 0")
+	}
+	if config.Services.CustomMetrics.Enabled {
+		if len(config.Services.CustomMetrics.Metrics) == 0 {
+			return fmt.Errorf("Custom Metrics is enabled but metrics array is empty")
+		}
+		labels := map[string]bool{}
+		for i := range config.Services.CustomMetrics.Metrics {
+			metric := &config.Services.CustomMetrics.Metrics[i]
+			if metric.Label == "" || metric.Namespace == "" || metric.MetricName == "" {
+				return fmt.Errorf("custom metric %d requires label, namespace and metricName", i)
+			}
+			if labels[metric.Label] {
+				return fmt.Errorf("custom metric label '%s' is duplicated", metric.Label)
+			}
+			labels[metric.Label] = true
+			if metric.Statistic == "" {
+				metric.Statistic = "Average"
+			}
+		}
+	}
+	if config.Global.Discovery.TagValue != "" && config.Global.Discovery.TagKey == "" {
+		return fmt.Errorf("discovery tagValue is set but tagKey is empty")
+	}
+	if config.Global.History.Baselines && config.Global.History.TableName == "" {
+		return fmt.Errorf("history baselines are enabled but tableName is empty")
+	}
+	if config.Global.History.AnomalyFactor == 0 {
+		config.Global.History.AnomalyFactor = 3
+	}
+	if config.Global.Cache.TTLMinutes < 0 {
+		return fmt.Errorf("cache ttlMinutes must be >= 0")
+	}
+	switch config.Global.SDK.RetryMode {
+	case "", RetryModeStandard, RetryModeAdaptive:
+	default:
+		return fmt.Errorf("sdk retryMode must be either 'standard', 'adaptive' or empty")
+	}
+	if config.Global.SDK.MaxAttempts < 0 {
+		return fmt.Errorf("sdk maxAttempts must be >= 0")
+	}
+	if config.Global.Watchdog.MissedReports < 0 {
+		return fmt.Errorf("watchdog missedReports must be >= 0")
+	}
+	if config.Global.Watchdog.MissedReports > 0 && config.Global.History.TableName == "" {
+		return fmt.Errorf("watchdog is enabled but history tableName is empty")
+	}
+	if config.Global.Archive.Prefix == "" {
+		config.Global.Archive.Prefix = "reports"
+	}
+	if config.Notifiers.Slack.Enabled && config.Notifiers.Slack.WebhookURL == "" {
+		return fmt.Errorf("Slack notifier is enabled but webhookUrl is empty")
+	}
+	if config.Notifiers.Prometheus.Enabled && config.Notifiers.Prometheus.RemoteWriteURL == "" {
+		return fmt.Errorf("Prometheus notifier is enabled but remoteWriteUrl is empty")
+	}
+	if config.Notifiers.Email.Enabled && (config.Notifiers.Email.From == "" || len(config.Notifiers.Email.To) == 0) {
+		return fmt.Errorf("Email notifier is enabled but from or to is empty")
+	}
+	if config.Notifiers.PagerDuty.Enabled {
+		if config.Notifiers.PagerDuty.RoutingKey == "" {
+			return fmt.Errorf("PagerDuty notifier is enabled but routingKey is empty")
+		}
+		// Only critical reports page
+		if !config.Global.Monitoring.Severity.Enabled {
+			return fmt.Errorf("PagerDuty notifier requires monitoring severity to be enabled")
+		}
+	}
+	if config.Notifiers.Webhook.Enabled && config.Notifiers.Webhook.URL == "" {
+		return fmt.Errorf("Webhook notifier is enabled but url is empty")
+	}
+	if config.Notifiers.Ntfy.Enabled {
+		if config.Notifiers.Ntfy.Topic == "" {
+			return fmt.Errorf("ntfy notifier is enabled but topic is empty")
+		}
+		if config.Notifiers.Ntfy.Server == "" {
+			config.Notifiers.Ntfy.Server = "https://ntfy.sh"
+		}
+	}
+	if config.Notifiers.Pushover.Enabled && (config.Notifiers.Pushover.AppToken == "" || config.Notifiers.Pushover.UserKey == "") {
+		return fmt.Errorf("Pushover notifier is enabled but appToken or userKey is empty")
+	}
 
-	if config.Services.EC2.Enabled && config.Services.EC2.InstanceID == "" {
-		return fmt.Errorf("EC2 is enabled but instanceId is empty")
+	if config.Services.EC2.Enabled {
+		if config.Services.EC2.InstanceID == "" {
+			return fmt.Errorf("EC2 is enabled but instanceId is empty")
+		}
+		if config.Services.EC2.CPUCreditThreshold < 0 {
+			return fmt.Errorf("EC2 cpuCreditThreshold must be >= 0")
+		}
+	}
+	if config.Services.S3.BucketName != "" && !slices.Contains(config.Services.S3.BucketNames, config.Services.S3.BucketName) {
+		config.Services.S3.BucketNames = append(config.Services.S3.BucketNames, config.Services.S3.BucketName)
+	}
+	if config.Services.S3.Enabled && len(config.Services.S3.BucketNames) == 0 {
+		return fmt.Errorf("S3 is enabled but bucketNames array is empty")
+	}
+	if config.Services.S3Audit.Enabled && !config.Services.S3Audit.AllBuckets && len(config.Services.S3.BucketNames) == 0 {
+		return fmt.Errorf("S3 Audit is enabled but s3 bucketNames array is empty, set allBuckets to audit every bucket")
 	}
-	if config.Services.S3.Enabled && config.Services.S3.BucketName == "" {
-		return fmt.Errorf("S3 is enabled but bucketName is empty")
+	if config.Services.StorageLens.Enabled && config.Services.StorageLens.ConfigurationID == "" {
+		return fmt.Errorf("Storage Lens is enabled but configurationId is empty")
 	}
-	if config.Services.ALB.Enabled && config.Services.ALB.ALBName == "" {
-		return fmt.Errorf("ALB is enabled but albName is empty")
+	if config.Services.ALB.ALBName != "" && !slices.Contains(config.Services.ALB.ALBNames, config.Services.ALB.ALBName) {
+		config.Services.ALB.ALBNames = append(config.Services.ALB.ALBNames, config.Services.ALB.ALBName)
+	}
+	if config.Services.ALB.Enabled && len(config.Services.ALB.ALBNames) == 0 {
+		return fmt.Errorf("ALB is enabled but albNames array is empty")
+	}
+	if config.Services.NLB.Enabled && len(config.Services.NLB.NLBNames) == 0 {
+		return fmt.Errorf("NLB is enabled but nlbNames array is empty")
 	}
 	if config.Services.CloudFront.Enabled && config.Services.CloudFront.DistributionID == "" {
 		return fmt.Errorf("CloudFront is enabled but distributionId is empty")
 	}
-	if config.Services.CloudWatchAgent.Enabled && config.Services.CloudWatchAgent.InstanceID == "" {
-		return fmt.Errorf("CloudWatch Agent is enabled but instanceId is empty")
+	if config.Services.Route53.Enabled && len(config.Services.Route53.HealthCheckIDs) == 0 {
+		return fmt.Errorf("Route53 is enabled but healthCheckIds array is empty")
+	}
+	if config.Services.Uptime.Enabled {
+		if len(config.Services.Uptime.URLs) == 0 {
+			return fmt.Errorf("Uptime is enabled but urls array is empty")
+		}
+		for _, endpoint := range config.Services.Uptime.URLs {
+			if parsed, err := url.Parse(endpoint); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return fmt.Errorf("Uptime url '%s' must be an absolute http(s) URL", endpoint)
+			}
+		}
+		if config.Services.Uptime.TimeoutSeconds < 0 || config.Services.Uptime.SlowMs < 0 || config.Services.Uptime.TLSExpiryDays < 0 {
+			return fmt.Errorf("Uptime timeoutSeconds, slowMs and tlsExpiryDays must be >= 0")
+		}
+		if config.Services.Uptime.TimeoutSeconds == 0 {
+			config.Services.Uptime.TimeoutSeconds = 10
+		}
+		if config.Services.Uptime.TLSExpiryDays == 0 {
+			config.Services.Uptime.TLSExpiryDays = 14
+		}
+	}
+	if config.Services.TLS.Enabled {
+		if len(config.Services.TLS.Hostnames) == 0 {
+			return fmt.Errorf("TLS is enabled but hostnames array is empty")
+		}
+		if config.Services.TLS.ExpiryDays < 0 || config.Services.TLS.TimeoutSeconds < 0 {
+			return fmt.Errorf("TLS expiryDays and timeoutSeconds must be >= 0")
+		}
+		if config.Services.TLS.ExpiryDays == 0 {
+			config.Services.TLS.ExpiryDays = 14
+		}
+		if config.Services.TLS.TimeoutSeconds == 0 {
+			config.Services.TLS.TimeoutSeconds = 10
+		}
+	}
+	if config.Services.CloudWatchAgent.InstanceID != "" && !slices.Contains(config.Services.CloudWatchAgent.InstanceIDs, config.Services.CloudWatchAgent.InstanceID) {
+		config.Services.CloudWatchAgent.InstanceIDs = append(config.Services.CloudWatchAgent.InstanceIDs, config.Services.CloudWatchAgent.InstanceID)
+	}
+	if config.Services.CloudWatchAgent.Enabled && len(config.Services.CloudWatchAgent.InstanceIDs) == 0 {
+		// Inherits the monitored instance, in its region
+		if !config.Services.EC2.Enabled {
+			return fmt.Errorf("CloudWatch Agent is enabled but instanceIds array is empty")
+		}
+		config.Services.CloudWatchAgent.InstanceIDs = []string{config.Services.EC2.InstanceID}
+		if config.Services.CloudWatchAgent.Region == "" {
+			config.Services.CloudWatchAgent.Region = config.Services.EC2.Region
+		}
+	}
+	if config.Services.CloudWatchLogs.Enabled {
+		if len(config.Services.CloudWatchLogs.LogGroupNames) == 0 {
+			return fmt.Errorf("CloudWatch Logs is enabled but logGroupNames array is empty")
+		}
+		if config.Services.CloudWatchLogs.ErrorSamples < 0 {
+			return fmt.Errorf("CloudWatch Logs errorSamples must be >= 0")
+		}
+		switch config.Services.CloudWatchLogs.CountMode {
+		case "":
+			config.Services.CloudWatchLogs.CountMode = LogsCountFilter
+		case LogsCountFilter, LogsCountInsights, LogsCountMetricFilters:
+		default:
+			return fmt.Errorf("CloudWatch Logs countMode must be '%s', '%s' or '%s'", LogsCountFilter, LogsCountInsights, LogsCountMetricFilters)
+		}
 	}
-	if config.Services.CloudWatchLogs.Enabled && len(config.Services.CloudWatchLogs.LogGroupNames) == 0 {
-		return fmt.Errorf("CloudWatch Logs is enabled but logGroupNames array is empty")
+	if config.Services.WAF.WebACLID != "" || config.Services.WAF.WebACLName != "" {
+		config.Services.WAF.WebACLs = append(config.Services.WAF.WebACLs, WebACLConfig{
+			WebACLID:   config.Services.WAF.WebACLID,
+			WebACLName: config.Services.WAF.WebACLName,
+			Scope:      config.Services.WAF.Scope,
+		})
 	}
 	if config.Services.WAF.Enabled {
-		if config.Services.WAF.WebACLID == "" {
-			return fmt.Errorf("WAF is enabled but webACLId is empty")
+		if len(config.Services.WAF.WebACLs) == 0 {
+			return fmt.Errorf("WAF is enabled but webACLs array is empty")
 		}
-		if config.Services.WAF.WebACLName == "" {
-			return fmt.Errorf("WAF is enabled but webACLName is empty")
+		for i := range config.Services.WAF.WebACLs {
+			webACL := &config.Services.WAF.WebACLs[i]
+			if webACL.WebACLID == "" || webACL.WebACLName == "" {
+				return fmt.Errorf("WAF web ACL %d requires webACLId and webACLName", i)
+			}
+			switch webACL.Scope {
+			case "":
+				webACL.Scope = "REGIONAL"
+			case "REGIONAL", "CLOUDFRONT":
+			default:
+				return fmt.Errorf("WAF scope must be either 'REGIONAL', 'CLOUDFRONT' or empty (default to REGIONAL)")
+			}
 		}
-		if config.Services.WAF.Scope != "REGIONAL" && config.Services.WAF.Scope != "CLOUDFRONT" && config.Services.WAF.Scope != "" {
-			return fmt.Errorf("WAF scope must be either 'REGIONAL', 'CLOUDFRONT' or empty (default to REGIONAL)")
+		if config.Services.WAF.TopBlocked < 0 {
+			return fmt.Errorf("WAF topBlocked must be >= 0")
 		}
 	}
 	if config.Services.DynamoDB.Enabled && len(config.Services.DynamoDB.TableNames) == 0 {
 		return fmt.Errorf("DynamoDB is enabled but tableNames array is empty")
 	}
+	if config.Services.DynamoDB.UtilizationThreshold < 0 {
+		return fmt.Errorf("DynamoDB utilizationThreshold must be >= 0")
+	}
 	if config.Services.RDS.Enabled {
-		if config.Services.RDS.ClusterID == "" && config.Services.RDS.DBInstanceIdentifier == "" {
-			return fmt.Errorf("RDS is enabled but both clusterId and dbInstanceIdentifier are empty - at least one is required")
+		if config.Services.RDS.ClusterID == "" && config.Services.RDS.DBInstanceIdentifier == "" && len(config.Services.RDS.DBInstanceIdentifiers) == 0 {
+			return fmt.Errorf("RDS is enabled but clusterId, dbInstanceIdentifier and dbInstanceIdentifiers are empty - at least one is required")
+		}
+		if config.Services.RDS.Engine != "aurora" && config.Services.RDS.Engine != "standard" && config.Services.RDS.Engine != "" {
+			return fmt.Errorf("RDS engine must be either 'aurora', 'standard' or empty (auto-detect)")
+		}
+	}
+	if config.Services.EKS.Enabled && config.Services.EKS.ClusterName == "" {
+		return fmt.Errorf("EKS is enabled but clusterName is empty")
+	}
+	if config.Services.Beanstalk.Enabled && len(config.Services.Beanstalk.EnvironmentNames) == 0 {
+		return fmt.Errorf("Beanstalk is enabled but environmentNames array is empty")
+	}
+	if config.Services.OpenSearch.Enabled && len(config.Services.OpenSearch.DomainNames) == 0 {
+		return fmt.Errorf("OpenSearch is enabled but domainNames array is empty")
+	}
+	if config.Services.MSK.Enabled && len(config.Services.MSK.ClusterNames) == 0 {
+		return fmt.Errorf("MSK is enabled but clusterNames array is empty")
+	}
+	if config.Services.Cognito.Enabled && len(config.Services.Cognito.UserPoolIDs) == 0 {
+		return fmt.Errorf("Cognito is enabled but userPoolIds array is empty")
+	}
+	if config.Services.AppSync.Enabled && len(config.Services.AppSync.APIIDs) == 0 {
+		return fmt.Errorf("AppSync is enabled but apiIds array is empty")
+	}
+	if config.Services.VPN.Enabled && len(config.Services.VPN.VPNConnectionIDs) == 0 {
+		return fmt.Errorf("VPN is enabled but vpnConnectionIds array is empty")
+	}
+	if config.Services.TransitGateway.Enabled && len(config.Services.TransitGateway.TransitGatewayIDs) == 0 {
+		return fmt.Errorf("Transit Gateway is enabled but transitGatewayIds array is empty")
+	}
+	if config.Services.VPCFlowLogs.Enabled {
+		if config.Services.VPCFlowLogs.LogGroupName == "" {
+			return fmt.Errorf("VPC Flow Logs is enabled but logGroupName is empty")
+		}
+		if _, _, err := net.ParseCIDR(config.Services.VPCFlowLogs.VPCCidr); err != nil {
+			return fmt.Errorf("VPC Flow Logs is enabled but vpcCidr '%s' is invalid: %v", config.Services.VPCFlowLogs.VPCCidr, err)
+		}
+		if config.Services.VPCFlowLogs.TopTalkers < 0 {
+			return fmt.Errorf("VPC Flow Logs topTalkers must be >= 0")
+		}
+	}
+	if config.Services.CloudTrail.Enabled {
+		if config.Services.CloudTrail.LogGroupName == "" {
+			return fmt.Errorf("CloudTrail is enabled but logGroupName is empty")
+		}
+		if config.Services.CloudTrail.MaxEvents < 0 {
+			return fmt.Errorf("CloudTrail maxEvents must be >= 0")
 		}
 	}
 
 	return nil
 }
 
+// Minutes since midnight of a "HH:MM" time of day
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a HH:MM time", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+func defaultQuietMode(mode *string) error {
+	switch *mode {
+	case "":
+		*mode = QuietSilent
+	case QuietSilent, QuietSuppress:
+	default:
+		return fmt.Errorf("mode must be '%s' or '%s'", QuietSilent, QuietSuppress)
+	}
+	return nil
+}
+
+// Region of a resource in a list: its resourceRegions override, otherwise the
+// service block region. Empty = default SDK region.
+func ResourceRegion(region string, resourceRegions map[string]string, resource string) string {
+	if resourceRegion, exists := resourceRegions[resource]; exists && resourceRegion != "" {
+		return resourceRegion
+	}
+	return region
+}
+
 type TimeParams struct {
 	StartTime     time.Time
 	EndTime       time.Time
-	IsDailyReport bool
+	IsDailyReport bool   // Also set for rollups, which include the daily-only services
+	Rollup        string // RollupWeekly or RollupMonthly, empty otherwise
+	Schedule      string // Additional schedule name, empty for the default schedule
 	Location      *time.Location
 }
 
-func (c *Config) GetTimeParams() (*TimeParams, error) {
+// Kind of report, each compared with the previous report of the same kind:
+// "schedule#<name>", "weekly", "monthly", "daily" or "scheduled"
+func (t *TimeParams) ReportKind() string {
+	switch {
+	case t.Schedule != "":
+		return "schedule#" + t.Schedule
+	case t.Rollup != "":
+		return t.Rollup
+	case t.IsDailyReport:
+		return "daily"
+	}
+	return "scheduled"
+}
+
+// Ad-hoc run overrides sent as the Lambda event payload, eg:
+// {"periodHours": 6, "services": ["ec2", "alb"], "daily": true}.
+// Scheduled EventBridge events carry none of these fields.
+type Invocation struct {
+	PeriodHours int      `json:"periodHours"` // Window length, 0 = schedule
+	Services    []string `json:"services"`    // Metrics keys, empty = every enabled service
+	Daily       *bool    `json:"daily"`       // Forces (or prevents) the daily report
+	Rollup      string   `json:"rollup"`      // Forces the "weekly" or "monthly" report
+	Watchdog    bool     `json:"watchdog"`    // Only checks that reports are still being produced
+	Schedule    string   `json:"schedule"`    // Runs the named additional schedule now
+	// Overrides used by drill-down buttons
+	ErrorSamples int      `json:"errorSamples"` // CloudWatch Logs error samples
+	ChatIDs      []string `json:"chatIds"`      // Only these Telegram chats, without routes
+}
+
+// Ad-hoc runs always send the full report, regardless of the schedule and mode.
+// Additional schedule runs aren't ad-hoc, they keep their own history.
+func (i Invocation) IsAdHoc() bool {
+	if i.Schedule != "" {
+		return false
+	}
+	return i.PeriodHours > 0 || len(i.Services) > 0 || i.Daily != nil || i.Rollup != "" || i.ErrorSamples > 0 || len(i.ChatIDs) > 0
+}
+
+// Whether the service is part of this run
+func (i Invocation) Includes(service string) bool {
+	return len(i.Services) == 0 || slices.Contains(i.Services, service)
+}
+
+// Rejects services missing from metricsKeys, the names an invocation can run
+func (i Invocation) ValidateServices(metricsKeys []string) error {
+	return checkServiceNames(i.Services, metricsKeys)
+}
+
+// Window of regular reports: defaultPeriodMinutes, otherwise defaultPeriod.
+// 0 when only daily reports are sent.
+func (c *Config) ReportPeriod() time.Duration {
+	if minutes := c.Global.Monitoring.DefaultPeriodMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return time.Duration(c.Global.Monitoring.DefaultPeriod) * time.Hour
+}
+
+// Expected time between reports: the report period, or a day with daily reports only
+func (c *Config) ReportInterval() time.Duration {
+	if period := c.ReportPeriod(); period > 0 {
+		return period
+	}
+	return 24 * time.Hour
+}
+
+// Hour-based reports (daily, rollups, schedule hours) are only sent by the
+// first run of the hour, the others of a sub-hourly schedule skip them
+func (c *Config) firstRunOfHour(now time.Time) bool {
+	period := c.ReportPeriod()
+	if period == 0 || period >= time.Hour {
+		return true
+	}
+	sinceHour := time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	return sinceHour < period
+}
+
+func (c *Config) GetTimeParams(invocation Invocation) (*TimeParams, error) {
 	loc, err := time.LoadLocation(c.Global.Monitoring.Timezone)
 	if err != nil {
 		return nil, err
 	}
 
 	now := time.Now().In(loc)
-	isDailyReport := now.Hour() == c.Global.Monitoring.DailyReportHour
+	firstRun := c.firstRunOfHour(now)
+	isDailyReport := firstRun && now.Hour() == c.Global.Monitoring.DailyReportHour
+	if invocation.Daily != nil {
+		isDailyReport = *invocation.Daily
+	}
+
+	// Rollups replace the default schedule report only
+	var rollup string
+	if invocation.Schedule == "" && firstRun {
+		rollup = c.rollupAt(now)
+	}
+	if invocation.PeriodHours < 0 {
+		return nil, fmt.Errorf("invalid periodHours %d, expected >= 0", invocation.PeriodHours)
+	}
+	switch invocation.Rollup {
+	case "":
+	case RollupWeekly, RollupMonthly:
+		rollup = invocation.Rollup
+	default:
+		return nil, fmt.Errorf("invalid rollup '%s', expected '%s' or '%s'", invocation.Rollup, RollupWeekly, RollupMonthly)
+	}
+	if rollup != "" {
+		isDailyReport = true
+	}
 
 	// Exit early if no defaultPeriod is set and it's not daily report hour
-	if c.Global.Monitoring.DefaultPeriod == 0 && !isDailyReport {
+	if c.ReportPeriod() == 0 && !isDailyReport && !invocation.IsAdHoc() && invocation.Schedule == "" {
 		return nil, nil
 	}
 
 	var startTime time.Time
-	if isDailyReport {
+	if invocation.PeriodHours > 0 {
+		startTime = now.Add(-time.Duration(invocation.PeriodHours) * time.Hour)
+	} else if rollup == RollupWeekly {
+		startTime = now.AddDate(0, 0, -7)
+	} else if rollup == RollupMonthly {
+		startTime = now.AddDate(0, -1, 0)
+	} else if isDailyReport {
 		// Daily report: look back 24 hours
 		startTime = now.Add(-24 * time.Hour)
 	} else {
-		// Regular report: use configured period
-		startTime = now.Add(-time.Duration(c.Global.Monitoring.DefaultPeriod) * time.Hour)
-
+		// Regular report: use configured period, an hour for ad-hoc runs without one
+		period := c.ReportPeriod()
+		if period == 0 {
+			period = time.Hour
+		}
+		startTime = now.Add(-period)
 	}
 
 	return &TimeParams{
 		StartTime:     startTime,
 		EndTime:       now,
 		IsDailyReport: isDailyReport,
+		Rollup:        rollup,
+		Schedule:      invocation.Schedule,
 		Location:      loc,
 	}, nil
 }
+
+// Additional schedule by name, nil when there is none
+func (c *Config) Schedule(name string) *ScheduleConfig {
+	for i := range c.Global.Monitoring.Schedules {
+		if name != "" && c.Global.Monitoring.Schedules[i].Name == name {
+			return &c.Global.Monitoring.Schedules[i]
+		}
+	}
+	return nil
+}
+
+// Additional schedules due at the current hour (report timezone)
+func (c *Config) DueSchedules() ([]ScheduleConfig, error) {
+	loc, err := time.LoadLocation(c.Global.Monitoring.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(loc)
+	var due []ScheduleConfig
+	for _, schedule := range c.Global.Monitoring.Schedules {
+		if len(schedule.Hours) == 0 || c.firstRunOfHour(now) && slices.Contains(schedule.Hours, now.Hour()) {
+			due = append(due, schedule)
+		}
+	}
+	return due, nil
+}
+
+// Quiet mode at now and why: the first maintenance window covering it,
+// otherwise the quiet hours. Empty when reports are sent normally.
+func (c *Config) QuietMode(now time.Time) (string, string) {
+	for _, window := range c.Global.Monitoring.MaintenanceWindows {
+		if !now.Before(window.Start) && now.Before(window.End) {
+			return window.Mode, window.Reason
+		}
+	}
+
+	quiet := c.Global.Monitoring.QuietHours
+	if quiet.Start == "" {
+		return "", ""
+	}
+	start, _ := parseClock(quiet.Start)
+	end, _ := parseClock(quiet.End)
+	minute := now.Hour()*60 + now.Minute()
+
+	inside := start <= minute && minute < end
+	if start > end {
+		inside = minute >= start || minute < end
+	}
+	if inside {
+		return quiet.Mode, "quiet hours"
+	}
+	return "", ""
+}
+
+// Rollup due at now (report timezone), the monthly one winning over the weekly
+func (c *Config) rollupAt(now time.Time) string {
+	monthly := c.Global.Monitoring.MonthlyReport
+	if monthly.Enabled && now.Day() == monthly.Day && now.Hour() == monthly.Hour {
+		return RollupMonthly
+	}
+	weekly := c.Global.Monitoring.WeeklyReport
+	if weekly.Enabled && now.Weekday() == weekdays[weekly.Weekday] && now.Hour() == weekly.Hour {
+		return RollupWeekly
+	}
+	return ""
+}
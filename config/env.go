@@ -0,0 +1,24 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variable holding the full JSON config, or the path of a mounted
+// config file
+const ConfigEnv = "TELEGRAWS_CONFIG"
+
+func LoadEnvConfig(value string) (*Config, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return parseConfig([]byte(value), "environment")
+	}
+
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file '%s': %v", value, err)
+	}
+
+	return parseConfig(data, "file")
+}
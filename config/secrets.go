@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Secrets are cached across warm Lambda invocations
+var (
+	secretCache   = map[string]string{}
+	secretCacheMu sync.Mutex
+)
+
+// Fills botToken/chatId from Secrets Manager when only the secret ARN is configured
+func (c *Config) ResolveTelegramSecrets(ctx context.Context, smClient *secretsmanager.Client) error {
+	telegram := &c.Global.Telegram
+	if !telegram.Configured() {
+		return nil
+	}
+
+	if telegram.BotToken == "" {
+		botToken, err := getSecretValue(ctx, smClient, telegram.BotTokenSecretArn, "botToken")
+		if err != nil {
+			return fmt.Errorf("failed to resolve botTokenSecretArn: %v", err)
+		}
+		telegram.BotToken = botToken
+	}
+
+	if len(telegram.ChatID) == 0 {
+		chatID, err := getSecretValue(ctx, smClient, telegram.ChatIDSecretArn, "chatId")
+		if err != nil {
+			return fmt.Errorf("failed to resolve chatIdSecretArn: %v", err)
+		}
+		telegram.ChatID = ChatIDs{chatID}
+	}
+
+	return nil
+}
+
+// The secret may be a plain string or a JSON object containing the given key
+func getSecretValue(ctx context.Context, smClient *secretsmanager.Client, secretArn string, jsonKey string) (string, error) {
+	secretCacheMu.Lock()
+	defer secretCacheMu.Unlock()
+
+	secretString, cached := secretCache[secretArn]
+	if !cached {
+		output, err := smClient.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+			SecretId: aws.String(secretArn),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error getting secret '%s': %v", secretArn, err)
+		}
+		if output.SecretString == nil {
+			return "", fmt.Errorf("secret '%s' has no string value", secretArn)
+		}
+		secretString = *output.SecretString
+		secretCache[secretArn] = secretString
+	}
+
+	var secretJSON map[string]string
+	if err := json.Unmarshal([]byte(secretString), &secretJSON); err == nil {
+		value, exists := secretJSON[jsonKey]
+		if !exists || value == "" {
+			return "", fmt.Errorf("secret '%s' has no '%s' key", secretArn, jsonKey)
+		}
+		return value, nil
+	}
+
+	if secretString == "" {
+		return "", fmt.Errorf("secret '%s' is empty", secretArn)
+	}
+	return secretString, nil
+}
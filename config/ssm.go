@@ -0,0 +1,27 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// Environment variable holding the SSM parameter name with the full JSON config
+const ConfigParameterEnv = "TELEGRAWS_CONFIG_PARAMETER"
+
+func LoadSSMConfig(ctx context.Context, ssmClient *ssm.Client, parameterName string) (*Config, error) {
+	output, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(parameterName),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting SSM parameter '%s': %v", parameterName, err)
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return nil, fmt.Errorf("SSM parameter '%s' has no value", parameterName)
+	}
+
+	return parseConfig([]byte(*output.Parameter.Value), "SSM")
+}
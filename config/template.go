@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// Loads the text of the report template from its location:
+// "s3://bucket/key", "ssm:parameter-name" or the name of a file in config/templates
+func LoadTemplate(ctx context.Context, s3Client *s3.Client, ssmClient *ssm.Client, location string) (string, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+		output, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error getting template '%s': %v", location, err)
+		}
+		defer output.Body.Close()
+
+		text, err := io.ReadAll(output.Body)
+		if err != nil {
+			return "", fmt.Errorf("error reading template '%s': %v", location, err)
+		}
+		return string(text), nil
+
+	case strings.HasPrefix(location, "ssm:"):
+		parameterName := strings.TrimPrefix(location, "ssm:")
+		output, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{
+			Name: aws.String(parameterName),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error getting SSM parameter '%s': %v", parameterName, err)
+		}
+		if output.Parameter == nil || output.Parameter.Value == nil {
+			return "", fmt.Errorf("SSM parameter '%s' has no value", parameterName)
+		}
+		return *output.Parameter.Value, nil
+
+	default:
+		text, err := templateFiles.ReadFile("templates/" + location)
+		if err != nil {
+			return "", fmt.Errorf("error reading embedded template '%s': %v", location, err)
+		}
+		return string(text), nil
+	}
+}
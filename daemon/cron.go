@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventBridge cron expression (Minutes Hours Day-of-month Month Day-of-week Year),
+// evaluated in UTC like EventBridge does. L, W and # are not supported.
+type Schedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool // nil when day-of-month is "?"
+	months   map[int]bool
+	weekdays map[int]bool // 1 = SUN ... 7 = SAT, nil when day-of-week is "?"
+	years    map[int]bool
+}
+
+var monthNames = map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}
+
+var weekdayNames = map[string]int{
+	"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7,
+}
+
+// Upper bound when looking for the next run, past it the schedule never fires
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+func ParseCron(expression string) (*Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("cron expression %q must have 6 fields (Minutes Hours Day-of-month Month Day-of-week Year)", expression)
+	}
+	if (fields[2] == "?") == (fields[4] == "?") {
+		return nil, fmt.Errorf("cron expression %q must set exactly one of day-of-month and day-of-week to '?'", expression)
+	}
+
+	schedule := &Schedule{}
+	var err error
+	if schedule.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("error parsing cron minutes: %v", err)
+	}
+	if schedule.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("error parsing cron hours: %v", err)
+	}
+	if fields[2] != "?" {
+		if schedule.days, err = parseField(fields[2], 1, 31, nil); err != nil {
+			return nil, fmt.Errorf("error parsing cron day-of-month: %v", err)
+		}
+	}
+	if schedule.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("error parsing cron month: %v", err)
+	}
+	if fields[4] != "?" {
+		if schedule.weekdays, err = parseField(fields[4], 1, 7, weekdayNames); err != nil {
+			return nil, fmt.Errorf("error parsing cron day-of-week: %v", err)
+		}
+	}
+	if schedule.years, err = parseField(fields[5], 1970, 2199, nil); err != nil {
+		return nil, fmt.Errorf("error parsing cron year: %v", err)
+	}
+
+	return schedule, nil
+}
+
+// Comma separated values, ranges (a-b) and steps (*/n, a/n, a-b/n)
+func parseField(field string, min int, max int, names map[string]int) (map[int]bool, error) {
+	values := map[int]bool{}
+
+	value := func(text string) (int, error) {
+		if number, exists := names[strings.ToUpper(text)]; exists {
+			return number, nil
+		}
+		number, err := strconv.Atoi(text)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", text)
+		}
+		if number < min || number > max {
+			return 0, fmt.Errorf("value %d out of range %d-%d", number, min, max)
+		}
+		return number, nil
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+		}
+
+		start, end := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = value(from); err != nil {
+				return nil, err
+			}
+			if end, err = value(to); err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("invalid range %q", rangePart)
+			}
+		default:
+			var err error
+			if start, err = value(rangePart); err != nil {
+				return nil, err
+			}
+			if !hasStep {
+				end = start
+			}
+		}
+
+		for number := start; number <= end; number += step {
+			values[number] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (s *Schedule) Matches(t time.Time) bool {
+	t = t.UTC()
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] || !s.years[t.Year()] {
+		return false
+	}
+	if s.days != nil {
+		return s.days[t.Day()]
+	}
+	return s.weekdays[int(t.Weekday())+1]
+}
+
+// First minute strictly after t matching the schedule, zero when there is none
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	for limit := next.Add(maxSearch); next.Before(limit); next = next.Add(time.Minute) {
+		if s.Matches(next) {
+			return next
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"telegraws/utils"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// Reported by the health endpoint
+type health struct {
+	mu        sync.Mutex
+	Status    string    `json:"status"` // "ok", or "failing" when the last run returned an error
+	Running   bool      `json:"running"`
+	LastRun   time.Time `json:"lastRun,omitzero"`
+	LastError string    `json:"lastError,omitempty"`
+	NextRun   time.Time `json:"nextRun,omitzero"`
+}
+
+// Always answers 200 while the process is alive, so a failing notifier doesn't
+// get the task restarted. The last run result is in the body.
+func (h *health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h); err != nil {
+		utils.Logger.Error("Failed to write health response", zap.Error(err))
+	}
+}
+
+// Runs run on every schedule tick and serves GET /health (and GET /metrics when
+// metrics isn't nil) on address until ctx is cancelled. Runs never overlap,
+// ticks missed while running are skipped.
+func Run(ctx context.Context, schedule *Schedule, address string, metrics http.Handler, run func(context.Context) error) error {
+	state := &health{Status: "ok"}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /health", state)
+	if metrics != nil {
+		mux.Handle("GET /metrics", metrics)
+	}
+	server := &http.Server{Addr: address, Handler: mux}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	utils.Logger.Info("Daemon started", zap.String("healthAddress", address))
+
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("cron expression never fires")
+		}
+
+		state.mu.Lock()
+		state.NextRun = next
+		state.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			utils.Logger.Info("Daemon stopped")
+			return nil
+		case err := <-serverErr:
+			timer.Stop()
+			return fmt.Errorf("error serving health endpoint: %v", err)
+		case <-timer.C:
+		}
+
+		state.mu.Lock()
+		state.Running = true
+		state.mu.Unlock()
+
+		err := run(ctx)
+		if err != nil {
+			utils.Logger.Error("Scheduled run failed", zap.Error(err))
+		}
+
+		state.mu.Lock()
+		state.Running = false
+		state.LastRun = next
+		state.Status, state.LastError = "ok", ""
+		if err != nil {
+			state.Status, state.LastError = "failing", err.Error()
+		}
+		state.mu.Unlock()
+	}
+}
@@ -0,0 +1,360 @@
+package deploy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"telegraws/config"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventsTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+const (
+	buildDir        = "bin"
+	functionTimeout = 120 // s
+	// IAM roles take a few seconds before Lambda can assume them
+	roleAttempts = 6
+	roleBackoff  = 5 * time.Second
+)
+
+const lambdaTrustPolicy = `{
+    "Version": "2012-10-17",
+    "Statement": [
+        {
+            "Effect": "Allow",
+            "Principal": {
+                "Service": "lambda.amazonaws.com"
+            },
+            "Action": "sts:AssumeRole"
+        }
+    ]
+}`
+
+// Resource names derived from deployment.lambdaFunctionName, same as build.sh
+func FunctionName(cfg *config.Config) string {
+	return "telegraws-" + cfg.Global.Deployment.LambdaFunctionName
+}
+
+func roleName(cfg *config.Config) string {
+	return FunctionName(cfg) + "-role"
+}
+
+func ruleName(cfg *config.Config) string {
+	return FunctionName(cfg) + "-schedule"
+}
+
+// Builds the Lambda binary (linux/arm64) and zips it as bootstrap
+func Package(ctx context.Context) ([]byte, error) {
+	if err := os.MkdirAll(buildDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating build directory: %v", err)
+	}
+	binaryPath := filepath.Join(buildDir, "bootstrap")
+
+	build := exec.CommandContext(ctx, "go", "build", "-o", binaryPath, ".")
+	build.Env = append(os.Environ(), "GOOS=linux", "GOARCH=arm64", "CGO_ENABLED=0")
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		return nil, fmt.Errorf("error building Lambda binary: %v", err)
+	}
+
+	binary, err := os.ReadFile(binaryPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading Lambda binary: %v", err)
+	}
+
+	archive := bytes.Buffer{}
+	writer := zip.NewWriter(&archive)
+	header := &zip.FileHeader{Name: "bootstrap", Method: zip.Deflate}
+	header.SetMode(0o755)
+	file, err := writer.CreateHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Lambda zip: %v", err)
+	}
+	if _, err := file.Write(binary); err != nil {
+		return nil, fmt.Errorf("error writing Lambda zip: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error closing Lambda zip: %v", err)
+	}
+
+	return archive.Bytes(), nil
+}
+
+// Creates the role when missing and always replaces its inline policy, so
+// enabling a service only grants what it needs
+func ensureRole(ctx context.Context, iamClient *iam.Client, cfg *config.Config, target Target) (string, error) {
+	var roleArn string
+
+	output, err := iamClient.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName(cfg))})
+	var notFound *iamTypes.NoSuchEntityException
+	switch {
+	case err == nil:
+		roleArn = aws.ToString(output.Role.Arn)
+	case errors.As(err, &notFound):
+		fmt.Printf("🔐 Creating IAM role: %s\n", roleName(cfg))
+		created, err := iamClient.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 aws.String(roleName(cfg)),
+			AssumeRolePolicyDocument: aws.String(lambdaTrustPolicy),
+			Description:              aws.String("Role for Telegraws " + cfg.Global.Deployment.LambdaFunctionName + " Lambda function"),
+		})
+		if err != nil {
+			return "", fmt.Errorf("error creating IAM role: %v", err)
+		}
+		roleArn = aws.ToString(created.Role.Arn)
+	default:
+		return "", fmt.Errorf("error getting IAM role: %v", err)
+	}
+
+	policy, err := json.Marshal(Policy(cfg, target))
+	if err != nil {
+		return "", fmt.Errorf("error marshaling IAM policy: %v", err)
+	}
+
+	_, err = iamClient.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName(cfg)),
+		PolicyName:     aws.String(FunctionName(cfg) + "-policy"),
+		PolicyDocument: aws.String(string(policy)),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error putting IAM role policy: %v", err)
+	}
+
+	return roleArn, nil
+}
+
+// Updates the function code when it exists, creates it otherwise
+func ensureFunction(ctx context.Context, lambdaClient *lambda.Client, cfg *config.Config, target Target, roleArn string, zipFile []byte) (string, error) {
+	output, err := lambdaClient.GetFunction(ctx, &lambda.GetFunctionInput{FunctionName: aws.String(target.FunctionName)})
+	var notFound *lambdaTypes.ResourceNotFoundException
+	switch {
+	case err == nil:
+		fmt.Printf("📦 Updating Lambda function: %s\n", target.FunctionName)
+		_, err := lambdaClient.UpdateFunctionCode(ctx, &lambda.UpdateFunctionCodeInput{
+			FunctionName: aws.String(target.FunctionName),
+			ZipFile:      zipFile,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error updating Lambda function code: %v", err)
+		}
+		return aws.ToString(output.Configuration.FunctionArn), nil
+	case !errors.As(err, &notFound):
+		return "", fmt.Errorf("error getting Lambda function: %v", err)
+	}
+
+	fmt.Printf("🚀 Creating Lambda function: %s\n", target.FunctionName)
+
+	input := &lambda.CreateFunctionInput{
+		FunctionName:  aws.String(target.FunctionName),
+		Runtime:       lambdaTypes.RuntimeProvidedal2023,
+		Role:          aws.String(roleArn),
+		Handler:       aws.String("bootstrap"),
+		Code:          &lambdaTypes.FunctionCode{ZipFile: zipFile},
+		Timeout:       aws.Int32(functionTimeout),
+		Architectures: []lambdaTypes.Architecture{lambdaTypes.ArchitectureArm64},
+		Description:   aws.String("Telegraws monitoring function"),
+	}
+	if target.ConfigParameter != "" {
+		input.Environment = &lambdaTypes.Environment{
+			Variables: map[string]string{config.ConfigParameterEnv: target.ConfigParameter},
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		created, err := lambdaClient.CreateFunction(ctx, input)
+		if err == nil {
+			return aws.ToString(created.FunctionArn), nil
+		}
+
+		var invalidParameter *lambdaTypes.InvalidParameterValueException
+		if !errors.As(err, &invalidParameter) || !strings.Contains(err.Error(), "cannot be assumed") || attempt == roleAttempts {
+			return "", fmt.Errorf("error creating Lambda function: %v", err)
+		}
+
+		fmt.Println("⏳ Waiting for IAM role to be available...")
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(roleBackoff):
+		}
+	}
+}
+
+func watchdogRuleName(cfg *config.Config) string {
+	return FunctionName(cfg) + "-watchdog"
+}
+
+// Payload of the watchdog schedule, see config.Invocation
+const watchdogInput = `{"watchdog": true}`
+
+// Creates (or updates) a schedule rule targeting the function, input is the
+// event payload (empty = the scheduled event)
+func ensureSchedule(ctx context.Context, eventsClient *eventbridge.Client, lambdaClient *lambda.Client, cfg *config.Config, functionArn string, name string, cronExpression string, input string) error {
+	fmt.Printf("📅 Scheduling %s: cron(%s)\n", name, cronExpression)
+
+	rule, err := eventsClient.PutRule(ctx, &eventbridge.PutRuleInput{
+		Name:               aws.String(name),
+		ScheduleExpression: aws.String("cron(" + cronExpression + ")"),
+		Description:        aws.String("Schedule for Telegraws " + cfg.Global.Deployment.LambdaFunctionName),
+		State:              eventsTypes.RuleStateEnabled,
+	})
+	if err != nil {
+		return fmt.Errorf("error putting EventBridge rule (AWS cron syntax: Minutes Hours Day-of-month Month Day-of-week Year): %v", err)
+	}
+
+	// The report schedule keeps the statement id used by build.sh
+	statementID := name + "-permission"
+	if name == ruleName(cfg) {
+		statementID = FunctionName(cfg) + "-eventbridge-permission"
+	}
+
+	_, err = lambdaClient.AddPermission(ctx, &lambda.AddPermissionInput{
+		FunctionName: aws.String(functionArn),
+		StatementId:  aws.String(statementID),
+		Action:       aws.String("lambda:InvokeFunction"),
+		Principal:    aws.String("events.amazonaws.com"),
+		SourceArn:    rule.RuleArn,
+	})
+	var conflict *lambdaTypes.ResourceConflictException
+	if err != nil && !errors.As(err, &conflict) {
+		return fmt.Errorf("error adding EventBridge invoke permission: %v", err)
+	}
+
+	target := eventsTypes.Target{Id: aws.String("1"), Arn: aws.String(functionArn)}
+	if input != "" {
+		target.Input = aws.String(input)
+	}
+	_, err = eventsClient.PutTargets(ctx, &eventbridge.PutTargetsInput{
+		Rule:    aws.String(name),
+		Targets: []eventsTypes.Target{target},
+	})
+	if err != nil {
+		return fmt.Errorf("error putting EventBridge target: %v", err)
+	}
+
+	return nil
+}
+
+// Creates the public function URL receiving the Telegram webhook, requests are
+// authenticated by the webhook secret token. Returns the URL.
+func ensureFunctionURL(ctx context.Context, lambdaClient *lambda.Client, cfg *config.Config, functionName string) (string, error) {
+	output, err := lambdaClient.GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{FunctionName: aws.String(functionName)})
+	var notFound *lambdaTypes.ResourceNotFoundException
+	switch {
+	case err == nil:
+		return aws.ToString(output.FunctionUrl), nil
+	case !errors.As(err, &notFound):
+		return "", fmt.Errorf("error getting Lambda function URL: %v", err)
+	}
+
+	fmt.Println("🔗 Creating Lambda function URL for the Telegram webhook")
+	created, err := lambdaClient.CreateFunctionUrlConfig(ctx, &lambda.CreateFunctionUrlConfigInput{
+		FunctionName: aws.String(functionName),
+		AuthType:     lambdaTypes.FunctionUrlAuthTypeNone,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating Lambda function URL: %v", err)
+	}
+
+	// Public URLs need both permissions, InvokeFunction only through the URL
+	permissions := []*lambda.AddPermissionInput{
+		{
+			FunctionName:        aws.String(functionName),
+			StatementId:         aws.String(FunctionName(cfg) + "-url-permission"),
+			Action:              aws.String("lambda:InvokeFunctionUrl"),
+			Principal:           aws.String("*"),
+			FunctionUrlAuthType: lambdaTypes.FunctionUrlAuthTypeNone,
+		},
+		{
+			FunctionName:          aws.String(functionName),
+			StatementId:           aws.String(FunctionName(cfg) + "-url-invoke-permission"),
+			Action:                aws.String("lambda:InvokeFunction"),
+			Principal:             aws.String("*"),
+			InvokedViaFunctionUrl: aws.Bool(true),
+		},
+	}
+	for _, permission := range permissions {
+		_, err := lambdaClient.AddPermission(ctx, permission)
+		var conflict *lambdaTypes.ResourceConflictException
+		if err != nil && !errors.As(err, &conflict) {
+			return "", fmt.Errorf("error adding function URL permission: %v", err)
+		}
+	}
+
+	return aws.ToString(created.FunctionUrl), nil
+}
+
+// URL of the deployed function, receiving the Telegram webhook
+func FunctionURL(ctx context.Context, awsCfg aws.Config, functionName string) (string, error) {
+	output, err := lambda.NewFromConfig(awsCfg).GetFunctionUrlConfig(ctx, &lambda.GetFunctionUrlConfigInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error getting Lambda function URL: %v", err)
+	}
+	return aws.ToString(output.FunctionUrl), nil
+}
+
+// Packages the binary and creates or updates the role, the function and its
+// schedule in the default region of awsCfg
+func Run(ctx context.Context, awsCfg aws.Config, cfg *config.Config, target Target) error {
+	if cfg.Global.Deployment.LambdaCronExpression == "" {
+		return fmt.Errorf("deployment lambdaCronExpression is required to deploy")
+	}
+	if target.Region == "" {
+		return fmt.Errorf("no AWS region configured, set AWS_REGION or a profile region")
+	}
+
+	fmt.Println("🔨 Building for AWS Lambda...")
+	zipFile, err := Package(ctx)
+	if err != nil {
+		return err
+	}
+
+	roleArn, err := ensureRole(ctx, iam.NewFromConfig(awsCfg), cfg, target)
+	if err != nil {
+		return err
+	}
+
+	lambdaClient := lambda.NewFromConfig(awsCfg)
+	functionArn, err := ensureFunction(ctx, lambdaClient, cfg, target, roleArn, zipFile)
+	if err != nil {
+		return err
+	}
+
+	eventsClient := eventbridge.NewFromConfig(awsCfg)
+	if err := ensureSchedule(ctx, eventsClient, lambdaClient, cfg, functionArn, ruleName(cfg), cfg.Global.Deployment.LambdaCronExpression, ""); err != nil {
+		return err
+	}
+
+	if cronExpression := watchdogCronExpression(cfg); cronExpression != "" {
+		if err := ensureSchedule(ctx, eventsClient, lambdaClient, cfg, functionArn, watchdogRuleName(cfg), cronExpression, watchdogInput); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Global.Telegram.Webhook.Enabled {
+		url, err := ensureFunctionURL(ctx, lambdaClient, cfg, target.FunctionName)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🔗 Webhook URL: %s\n", url)
+	}
+
+	fmt.Printf("🎉 Deployed %s (%s)\n", target.FunctionName, target.Region)
+	return nil
+}
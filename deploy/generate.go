@@ -0,0 +1,331 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"telegraws/config"
+	"text/template"
+)
+
+// Placeholders resolved by the IaC tool, so the generated code isn't tied to
+// the account and region it was generated from
+const (
+	terraformAccountID = "${data.aws_caller_identity.current.account_id}"
+	terraformRegion    = "${data.aws_region.current.id}"
+	samAccountID       = "${AWS::AccountId}"
+	samRegion          = "${AWS::Region}"
+)
+
+var terraformTemplate = template.Must(template.New("terraform").Parse(`# Generated by telegraws generate terraform, regenerate after changing the enabled services
+
+terraform {
+  required_providers {
+    aws = {
+      source = "hashicorp/aws"
+    }
+  }
+}
+
+data "aws_caller_identity" "current" {}
+data "aws_region" "current" {}
+
+resource "aws_iam_role" "telegraws" {
+  name               = "{{.RoleName}}"
+  assume_role_policy = <<EOF
+{{.TrustPolicy}}
+EOF
+}
+
+resource "aws_iam_role_policy" "telegraws" {
+  name   = "{{.PolicyName}}"
+  role   = aws_iam_role.telegraws.id
+  policy = <<EOF
+{{.Policy}}
+EOF
+}
+
+resource "aws_lambda_function" "telegraws" {
+  function_name    = "{{.FunctionName}}"
+  description      = "Telegraws monitoring function"
+  role             = aws_iam_role.telegraws.arn
+  runtime          = "provided.al2023"
+  handler          = "bootstrap"
+  architectures    = ["arm64"]
+  timeout          = {{.Timeout}}
+  filename         = "${path.module}/{{.ZipFile}}"
+  source_code_hash = filebase64sha256("${path.module}/{{.ZipFile}}")
+{{- if .ConfigParameter}}
+
+  environment {
+    variables = {
+      {{.ConfigParameterEnv}} = "{{.ConfigParameter}}"
+    }
+  }
+{{- end}}
+}
+
+resource "aws_cloudwatch_event_rule" "telegraws" {
+  name                = "{{.RuleName}}"
+  description         = "Schedule for Telegraws {{.Name}}"
+  schedule_expression = "cron({{.CronExpression}})"
+}
+
+resource "aws_cloudwatch_event_target" "telegraws" {
+  rule      = aws_cloudwatch_event_rule.telegraws.name
+  target_id = "1"
+  arn       = aws_lambda_function.telegraws.arn
+}
+
+resource "aws_lambda_permission" "telegraws" {
+  statement_id  = "{{.FunctionName}}-eventbridge-permission"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.telegraws.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.telegraws.arn
+}
+{{- if .WatchdogCronExpression}}
+
+resource "aws_cloudwatch_event_rule" "telegraws_watchdog" {
+  name                = "{{.WatchdogRuleName}}"
+  description         = "Schedule for Telegraws {{.Name}}"
+  schedule_expression = "cron({{.WatchdogCronExpression}})"
+}
+
+resource "aws_cloudwatch_event_target" "telegraws_watchdog" {
+  rule      = aws_cloudwatch_event_rule.telegraws_watchdog.name
+  target_id = "1"
+  arn       = aws_lambda_function.telegraws.arn
+  input     = jsonencode({ watchdog = true })
+}
+
+resource "aws_lambda_permission" "telegraws_watchdog" {
+  statement_id  = "{{.WatchdogRuleName}}-permission"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.telegraws.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.telegraws_watchdog.arn
+}
+{{- end}}
+{{- if .Webhook}}
+
+resource "aws_lambda_function_url" "telegraws" {
+  function_name      = aws_lambda_function.telegraws.function_name
+  authorization_type = "NONE"
+}
+
+resource "aws_lambda_permission" "telegraws_url" {
+  statement_id           = "{{.FunctionName}}-url-permission"
+  action                 = "lambda:InvokeFunctionUrl"
+  function_name          = aws_lambda_function.telegraws.function_name
+  principal              = "*"
+  function_url_auth_type = "NONE"
+}
+
+# Public URLs need both permissions, InvokeFunction only through the URL
+resource "aws_lambda_permission" "telegraws_url_invoke" {
+  statement_id             = "{{.FunctionName}}-url-invoke-permission"
+  action                   = "lambda:InvokeFunction"
+  function_name            = aws_lambda_function.telegraws.function_name
+  principal                = "*"
+  invoked_via_function_url = true
+}
+
+output "webhook_url" {
+  value = aws_lambda_function_url.telegraws.function_url
+}
+{{- end}}
+`))
+
+// Schedule of the watchdog check, empty when it isn't enabled
+func watchdogCronExpression(cfg *config.Config) string {
+	if cfg.Global.Watchdog.MissedReports == 0 {
+		return ""
+	}
+	return cfg.Global.Watchdog.CronExpression
+}
+
+// Generates the Terraform configuration of the function, its role, policy and
+// schedule. The zip is expected next to the generated file.
+func Terraform(cfg *config.Config, target Target, zipFile string) (string, error) {
+	target.AccountID = terraformAccountID
+	target.Region = terraformRegion
+
+	policy, err := json.MarshalIndent(Policy(cfg, target), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling IAM policy: %v", err)
+	}
+
+	output := bytes.Buffer{}
+	err = terraformTemplate.Execute(&output, map[string]any{
+		"Name":                   cfg.Global.Deployment.LambdaFunctionName,
+		"FunctionName":           target.FunctionName,
+		"RoleName":               roleName(cfg),
+		"PolicyName":             FunctionName(cfg) + "-policy",
+		"RuleName":               ruleName(cfg),
+		"TrustPolicy":            lambdaTrustPolicy,
+		"Policy":                 string(policy),
+		"Timeout":                functionTimeout,
+		"ZipFile":                zipFile,
+		"ConfigParameterEnv":     config.ConfigParameterEnv,
+		"ConfigParameter":        target.ConfigParameter,
+		"CronExpression":         cfg.Global.Deployment.LambdaCronExpression,
+		"WatchdogRuleName":       watchdogRuleName(cfg),
+		"WatchdogCronExpression": watchdogCronExpression(cfg),
+		"Webhook":                cfg.Global.Telegram.Webhook.Enabled,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error rendering Terraform template: %v", err)
+	}
+
+	return output.String(), nil
+}
+
+// Generates the SAM template (JSON) of the function, its policy and schedule.
+// SAM creates the role and the invoke permission itself.
+func SAM(cfg *config.Config, target Target, zipFile string) (string, error) {
+	target.AccountID = samAccountID
+	target.Region = samRegion
+
+	// Resources with placeholders must go through Fn::Sub
+	var statements []map[string]any
+	for _, statement := range Policy(cfg, target).Statement {
+		var resources []any
+		for _, resource := range statement.Resource {
+			if strings.Contains(resource, "${") {
+				resources = append(resources, map[string]string{"Fn::Sub": resource})
+			} else {
+				resources = append(resources, resource)
+			}
+		}
+		statements = append(statements, map[string]any{
+			"Effect":   statement.Effect,
+			"Action":   statement.Action,
+			"Resource": resources,
+		})
+	}
+
+	function := map[string]any{
+		"FunctionName":  target.FunctionName,
+		"Description":   "Telegraws monitoring function",
+		"CodeUri":       zipFile,
+		"Handler":       "bootstrap",
+		"Runtime":       "provided.al2023",
+		"Architectures": []string{"arm64"},
+		"Timeout":       functionTimeout,
+		"Policies": []any{
+			map[string]any{"Version": "2012-10-17", "Statement": statements},
+		},
+		"Events": map[string]any{
+			"Schedule": map[string]any{
+				"Type": "Schedule",
+				"Properties": map[string]any{
+					"Name":        ruleName(cfg),
+					"Description": "Schedule for Telegraws " + cfg.Global.Deployment.LambdaFunctionName,
+					"Schedule":    "cron(" + cfg.Global.Deployment.LambdaCronExpression + ")",
+				},
+			},
+		},
+	}
+	if cronExpression := watchdogCronExpression(cfg); cronExpression != "" {
+		function["Events"].(map[string]any)["Watchdog"] = map[string]any{
+			"Type": "Schedule",
+			"Properties": map[string]any{
+				"Name":        watchdogRuleName(cfg),
+				"Description": "Schedule for Telegraws " + cfg.Global.Deployment.LambdaFunctionName,
+				"Schedule":    "cron(" + cronExpression + ")",
+				"Input":       watchdogInput,
+			},
+		}
+	}
+	if target.ConfigParameter != "" {
+		function["Environment"] = map[string]any{
+			"Variables": map[string]string{config.ConfigParameterEnv: target.ConfigParameter},
+		}
+	}
+	if cfg.Global.Telegram.Webhook.Enabled {
+		function["FunctionUrlConfig"] = map[string]any{"AuthType": "NONE"}
+	}
+
+	document := map[string]any{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Transform":                "AWS::Serverless-2016-10-31",
+		"Description":              "Generated by telegraws generate sam, regenerate after changing the enabled services",
+		"Resources": map[string]any{
+			"TelegrawsFunction": map[string]any{
+				"Type":       "AWS::Serverless::Function",
+				"Properties": function,
+			},
+		},
+	}
+
+	// SAM names the generated URL resource after the function. It only adds
+	// the InvokeFunctionUrl permission, public URLs also need InvokeFunction
+	// through the URL.
+	if cfg.Global.Telegram.Webhook.Enabled {
+		document["Resources"].(map[string]any)["TelegrawsFunctionUrlInvokePermission"] = map[string]any{
+			"Type": "AWS::Lambda::Permission",
+			"Properties": map[string]any{
+				"Action":                "lambda:InvokeFunction",
+				"FunctionName":          map[string]string{"Ref": "TelegrawsFunction"},
+				"Principal":             "*",
+				"InvokedViaFunctionUrl": true,
+			},
+		}
+		document["Outputs"] = map[string]any{
+			"WebhookUrl": map[string]any{"Value": map[string]string{"Fn::GetAtt": "TelegrawsFunctionUrl.FunctionUrl"}},
+		}
+	}
+
+	output, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling SAM template: %v", err)
+	}
+
+	return string(output) + "\n", nil
+}
+
+// Packages the binary and writes it with the generated code for format
+// ("terraform" or "sam") to the build directory. Returns the generated file.
+func Generate(ctx context.Context, cfg *config.Config, target Target, format string) (string, error) {
+	if cfg.Global.Deployment.LambdaCronExpression == "" {
+		return "", fmt.Errorf("deployment lambdaCronExpression is required to generate a schedule")
+	}
+
+	var generate func(*config.Config, Target, string) (string, error)
+	var fileName string
+	switch format {
+	case "terraform":
+		generate, fileName = Terraform, "telegraws.tf"
+	case "sam":
+		generate, fileName = SAM, "template.json"
+	default:
+		return "", fmt.Errorf("unknown format %q, expected terraform or sam", format)
+	}
+
+	zipFile := target.FunctionName + ".zip"
+	code, err := generate(cfg, target, zipFile)
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Println("🔨 Building for AWS Lambda...")
+	archive, err := Package(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(buildDir, zipFile), archive, 0o644); err != nil {
+		return "", fmt.Errorf("error writing Lambda zip: %v", err)
+	}
+
+	outputPath := filepath.Join(buildDir, fileName)
+	if err := os.WriteFile(outputPath, []byte(code), 0o644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", fileName, err)
+	}
+
+	return outputPath, nil
+}
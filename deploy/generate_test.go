@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"encoding/json"
+	"strings"
+	"telegraws/config"
+	"testing"
+)
+
+func webhookConfig() *config.Config {
+	cfg := &config.Config{}
+	cfg.Global.Deployment.LambdaFunctionName = "test"
+	cfg.Global.Deployment.LambdaCronExpression = "0 * * * ? *"
+	cfg.Global.Telegram.Webhook.Enabled = true
+	return cfg
+}
+
+func TestTerraformWebhookPermissions(t *testing.T) {
+	code, err := Terraform(webhookConfig(), Target{FunctionName: "telegraws-test"}, "telegraws-test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, statement := range []string{
+		`action                 = "lambda:InvokeFunctionUrl"`,
+		`action                   = "lambda:InvokeFunction"`,
+		`invoked_via_function_url = true`,
+	} {
+		if !strings.Contains(code, statement) {
+			t.Errorf("function URL permission missing %q", statement)
+		}
+	}
+}
+
+func TestSAMWebhookPermissions(t *testing.T) {
+	code, err := SAM(webhookConfig(), Target{FunctionName: "telegraws-test"}, "telegraws-test.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		Resources map[string]struct {
+			Type       string
+			Properties map[string]any
+		}
+	}
+	if err := json.Unmarshal([]byte(code), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	// SAM adds InvokeFunctionUrl from FunctionUrlConfig
+	function := document.Resources["TelegrawsFunction"].Properties
+	if urlConfig, _ := function["FunctionUrlConfig"].(map[string]any); urlConfig["AuthType"] != "NONE" {
+		t.Errorf("FunctionUrlConfig = %v, want AuthType NONE", function["FunctionUrlConfig"])
+	}
+
+	permission := document.Resources["TelegrawsFunctionUrlInvokePermission"]
+	if permission.Type != "AWS::Lambda::Permission" ||
+		permission.Properties["Action"] != "lambda:InvokeFunction" ||
+		permission.Properties["Principal"] != "*" ||
+		permission.Properties["InvokedViaFunctionUrl"] != true {
+		t.Errorf("InvokeFunction permission = %+v", permission)
+	}
+}
@@ -0,0 +1,284 @@
+package deploy
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"telegraws/config"
+)
+
+type PolicyStatement struct {
+	Effect   string   `json:"Effect"`
+	Action   []string `json:"Action"`
+	Resource []string `json:"Resource"`
+}
+
+type PolicyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// Where the function runs, used to scope resource ARNs
+type Target struct {
+	FunctionName    string // Full Lambda function name (telegraws-<name>)
+	Region          string
+	AccountID       string
+	ConfigParameter string // SSM parameter holding the config, empty = embedded config
+}
+
+// Builds the function policy from the enabled services only. Actions without
+// resource-level permissions are granted on "*", the others on the configured
+// resources.
+func Policy(cfg *config.Config, target Target) PolicyDocument {
+	policy := PolicyDocument{Version: "2012-10-17"}
+
+	allow := func(actions []string, resources ...string) {
+		if len(resources) == 0 {
+			return
+		}
+		policy.Statement = append(policy.Statement, PolicyStatement{
+			Effect:   "Allow",
+			Action:   actions,
+			Resource: resources,
+		})
+	}
+
+	// Resource ARN in the service (or resource) region, the function region by default
+	arn := func(service string, region string, resource string) string {
+		if service == "s3" {
+			return "arn:aws:s3:::" + resource
+		}
+		if region == "" {
+			region = target.Region
+		}
+		return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, region, target.AccountID, resource)
+	}
+
+	services := &cfg.Services
+
+	// Function logs
+	logGroup := "log-group:/aws/lambda/" + target.FunctionName
+	allow([]string{"logs:CreateLogGroup", "logs:CreateLogStream", "logs:PutLogEvents"},
+		arn("logs", "", logGroup), arn("logs", "", logGroup+":*"))
+
+	// Drill-down buttons invoke the function asynchronously
+	if cfg.Global.Telegram.Webhook.Enabled {
+		allow([]string{"lambda:InvokeFunction"}, arn("lambda", "", "function:"+target.FunctionName))
+	}
+
+	// Every metric based service, charts and discovered resources
+	allow([]string{"cloudwatch:GetMetricData", "cloudwatch:ListMetrics"}, "*")
+
+	if services.S3Audit.Enabled {
+		auditActions := []string{"s3:GetBucketPublicAccessBlock", "s3:GetBucketPolicyStatus", "s3:GetBucketAcl", "s3:GetEncryptionConfiguration"}
+		if services.S3Audit.AllBuckets {
+			allow(append(auditActions, "s3:ListAllMyBuckets"), "*")
+		} else {
+			var buckets []string
+			for _, bucketName := range services.S3.BucketNames {
+				buckets = append(buckets, arn("s3", "", bucketName))
+			}
+			allow(auditActions, buckets...)
+		}
+	}
+
+	if services.CloudWatchLogs.Enabled {
+		var logGroups []string
+		for _, logGroupName := range services.CloudWatchLogs.LogGroupNames {
+			region := config.ResourceRegion(services.CloudWatchLogs.Region, services.CloudWatchLogs.ResourceRegions, logGroupName)
+			logGroups = append(logGroups, arn("logs", region, "log-group:"+logGroupName+":*"))
+		}
+		switch services.CloudWatchLogs.CountMode {
+		case config.LogsCountInsights:
+			allow([]string{"logs:StartQuery"}, logGroups...)
+		case config.LogsCountMetricFilters:
+			// Insights for the error samples
+			allow([]string{"logs:StartQuery", "logs:DescribeMetricFilters", "logs:PutMetricFilter"}, logGroups...)
+		default:
+			allow([]string{"logs:FilterLogEvents"}, logGroups...)
+		}
+	}
+
+	if services.VPCFlowLogs.Enabled {
+		allow([]string{"logs:StartQuery"}, arn("logs", services.VPCFlowLogs.Region, "log-group:"+services.VPCFlowLogs.LogGroupName+":*"))
+	}
+
+	if services.CloudTrail.Enabled {
+		allow([]string{"logs:StartQuery"}, arn("logs", services.CloudTrail.Region, "log-group:"+services.CloudTrail.LogGroupName+":*"))
+	}
+
+	insightsLogs := services.CloudWatchLogs.Enabled && services.CloudWatchLogs.CountMode != "" && services.CloudWatchLogs.CountMode != config.LogsCountFilter
+	if services.VPCFlowLogs.Enabled || services.CloudTrail.Enabled || insightsLogs {
+		allow([]string{"logs:GetQueryResults"}, "*")
+	}
+
+	if services.EC2.Enabled || cfg.Global.Discovery.TagKey != "" {
+		allow([]string{"ec2:DescribeInstanceStatus"}, "*")
+	}
+
+	if services.VPN.Enabled {
+		allow([]string{"ec2:DescribeVpnConnections"}, "*")
+	}
+
+	if services.WAF.Enabled {
+		allow([]string{"wafv2:GetWebACL", "wafv2:GetSampledRequests", "wafv2:ListResourcesForWebACL"}, "*")
+	}
+
+	if services.DynamoDB.Enabled {
+		var tables []string
+		for _, tableName := range services.DynamoDB.TableNames {
+			region := config.ResourceRegion(services.DynamoDB.Region, services.DynamoDB.ResourceRegions, tableName)
+			tables = append(tables, arn("dynamodb", region, "table/"+tableName))
+		}
+		allow([]string{"dynamodb:DescribeTable"}, tables...)
+	}
+
+	if services.RDS.Enabled || cfg.Global.Discovery.TagKey != "" {
+		allow([]string{"rds:DescribeDBInstances"}, "*")
+	}
+
+	if services.RDS.Enabled {
+		allow([]string{"rds:DescribePendingMaintenanceActions", "rds:DescribeDBSnapshots", "rds:DescribeDBClusterSnapshots"}, "*")
+	}
+
+	if services.RDS.Enabled && services.RDS.TopQueries > 0 {
+		allow([]string{"pi:DescribeDimensionKeys"}, arn("pi", services.RDS.Region, "metrics/rds/*"))
+	}
+
+	if services.SQS.Enabled {
+		var queues []string
+		for _, queue := range services.SQS.Queues {
+			region := config.ResourceRegion(services.SQS.Region, services.SQS.ResourceRegions, queue)
+			queues = append(queues, arn("sqs", region, path.Base(queue)))
+		}
+		allow([]string{"sqs:GetQueueUrl", "sqs:GetQueueAttributes"}, queues...)
+	}
+
+	if services.ECS.Enabled {
+		var ecsServices []string
+		for _, serviceName := range services.ECS.ServiceNames {
+			ecsServices = append(ecsServices, arn("ecs", services.ECS.Region, "service/"+services.ECS.ClusterName+"/"+serviceName))
+		}
+		allow([]string{"ecs:DescribeServices"}, ecsServices...)
+	}
+
+	if services.Beanstalk.Enabled {
+		allow([]string{"elasticbeanstalk:DescribeEnvironmentHealth"}, "*")
+	}
+
+	if services.ECR.Enabled {
+		repositories := []string{"*"}
+		if len(services.ECR.RepositoryNames) > 0 {
+			repositories = nil
+			for _, repositoryName := range services.ECR.RepositoryNames {
+				repositories = append(repositories, arn("ecr", services.ECR.Region, "repository/"+repositoryName))
+			}
+		}
+		allow([]string{"ecr:DescribeRepositories", "ecr:DescribeImages"}, repositories...)
+	}
+
+	if services.ASG.Enabled {
+		allow([]string{"autoscaling:DescribeAutoScalingGroups", "autoscaling:DescribeScalingActivities"}, "*")
+	}
+
+	if services.StepFunctions.Enabled {
+		allow([]string{"states:ListExecutions"}, services.StepFunctions.StateMachineArns...)
+	}
+
+	if services.EventBridge.Enabled {
+		var rules []string
+		for _, ruleName := range services.EventBridge.RuleNames {
+			region := config.ResourceRegion(services.EventBridge.Region, services.EventBridge.ResourceRegions, ruleName)
+			rule := "rule/" + ruleName
+			if busName := services.EventBridge.EventBusName; busName != "" && busName != "default" {
+				rule = "rule/" + busName + "/" + ruleName
+			}
+			rules = append(rules, arn("events", region, rule))
+		}
+		allow([]string{"events:ListTargetsByRule"}, rules...)
+	}
+
+	if services.Alarms.Enabled {
+		allow([]string{"cloudwatch:DescribeAlarms"}, "*")
+	}
+
+	if services.Cost.Enabled {
+		allow([]string{"ce:GetCostAndUsage"}, "*")
+	}
+
+	if services.GuardDuty.Enabled {
+		allow([]string{"guardduty:ListDetectors", "guardduty:ListFindings", "guardduty:GetFindings"}, "*")
+	}
+
+	if services.SecurityHub.Enabled {
+		allow([]string{"securityhub:GetFindings"}, "*")
+	}
+	if services.Shield.Enabled {
+		allow([]string{"shield:ListAttacks"}, "*")
+	}
+	if services.IAM.Enabled {
+		allow([]string{"iam:GenerateCredentialReport", "iam:GetCredentialReport", "iam:ListRoles"}, "*")
+	}
+	if services.XRay.Enabled {
+		allow([]string{"xray:GetServiceGraph"}, "*")
+	}
+	if services.Health.Enabled {
+		allow([]string{"health:DescribeEvents", "health:DescribeAffectedEntities"}, "*")
+	}
+
+	if cfg.Global.Discovery.TagKey != "" {
+		allow([]string{"tag:GetResources"}, "*")
+		allow([]string{"dynamodb:DescribeTable"}, arn("dynamodb", "", "table/*"))
+	}
+
+	telegram := cfg.Global.Telegram
+	var secrets []string
+	for _, secretArn := range []string{telegram.BotTokenSecretArn, telegram.ChatIDSecretArn} {
+		if secretArn != "" && !slices.Contains(secrets, secretArn) {
+			secrets = append(secrets, secretArn)
+		}
+	}
+	if len(secrets) > 0 {
+		allow([]string{"secretsmanager:GetSecretValue"}, secrets...)
+	}
+
+	if target.ConfigParameter != "" {
+		allow([]string{"ssm:GetParameter"}, arn("ssm", "", "parameter/"+strings.TrimPrefix(target.ConfigParameter, "/")))
+	}
+
+	switch {
+	case strings.HasPrefix(telegram.Template, "s3://"):
+		allow([]string{"s3:GetObject"}, arn("s3", "", strings.TrimPrefix(telegram.Template, "s3://")))
+	case strings.HasPrefix(telegram.Template, "ssm:"):
+		allow([]string{"ssm:GetParameter"}, arn("ssm", "", "parameter/"+strings.TrimPrefix(strings.TrimPrefix(telegram.Template, "ssm:"), "/")))
+	}
+
+	if parameter := cfg.Global.Cache.SSMParameter; parameter != "" {
+		allow([]string{"ssm:GetParameter", "ssm:PutParameter"}, arn("ssm", "", "parameter/"+strings.TrimPrefix(parameter, "/")))
+	}
+
+	if tableName := cfg.Global.History.TableName; tableName != "" {
+		actions := []string{"dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:BatchGetItem"}
+		// The fallbacks record is deleted once a report reaches Telegram again
+		if telegram.FallbackTopicARN != "" {
+			actions = append(actions, "dynamodb:DeleteItem")
+		}
+		allow(actions, arn("dynamodb", "", "table/"+tableName))
+	}
+
+	if telegram.FallbackTopicARN != "" {
+		allow([]string{"sns:Publish"}, telegram.FallbackTopicARN)
+	}
+
+	if archive := cfg.Global.Archive; archive.BucketName != "" {
+		allow([]string{"s3:PutObject"}, arn("s3", "", archive.BucketName+"/"+archive.Prefix+"/*"))
+	}
+
+	// SES authorizes the sender identity and, in the sandbox, the recipients
+	if email := cfg.Notifiers.Email; email.Enabled {
+		allow([]string{"ses:SendEmail"}, arn("ses", email.Region, "identity/*"))
+	}
+
+	return policy
+}
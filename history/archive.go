@@ -0,0 +1,55 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Archived report, the metrics keep the collected results as is
+type ReportArchive struct {
+	Timestamp     time.Time      `json:"timestamp"`
+	IsDailyReport bool           `json:"isDailyReport"`
+	Rollup        string         `json:"rollup,omitempty"`
+	Schedule      string         `json:"schedule,omitempty"` // Additional schedule name
+	Breaches      []utils.Breach `json:"breaches"`
+	Metrics       map[string]any `json:"metrics"`
+	Message       string         `json:"message"` // As sent to Telegram
+}
+
+// One object per report hour, eg: reports/2024/06/01/07.json. Periods under an
+// hour add the minutes so each window keeps its own, eg: reports/2024/06/01/07-15.json
+func archiveKey(prefix string, timestamp time.Time, period time.Duration) string {
+	layout := "2006/01/02/15"
+	if period > 0 && period < time.Hour {
+		layout += "-04"
+	}
+	return path.Join(prefix, timestamp.Format(layout)+".json")
+}
+
+// Writes the report to the archive bucket under its timestamp (report timezone),
+// period being the report period
+func ArchiveReport(ctx context.Context, s3Client *s3.Client, bucketName string, prefix string, period time.Duration, archive ReportArchive) error {
+	jsonData, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("error marshaling report archive: %v", err)
+	}
+
+	_, err = s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(archiveKey(prefix, archive.Timestamp, period)),
+		Body:        bytes.NewReader(jsonData),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("error archiving report: %v", err)
+	}
+	return nil
+}
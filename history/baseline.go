@@ -0,0 +1,96 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Same-hour windows compared against the current one
+	baselineWindows = 7
+	// Windows expire through DynamoDB TTL (expiresAt) once out of the baseline
+	windowRetention = (baselineWindows + 1) * 24 * time.Hour
+)
+
+// One item per report window (same hour, report timezone), eg:
+// window#scheduled#2024-06-01T07. Periods under an hour add the minutes, eg:
+// window#scheduled#2024-06-01T07:15
+func windowID(kind string, timestamp time.Time, period time.Duration) string {
+	layout := "2006-01-02T15"
+	if period > 0 && period < time.Hour {
+		layout += ":04"
+	}
+	return fmt.Sprintf("window#%s#%s", kind, timestamp.Format(layout))
+}
+
+// Stores the flattened metrics of this report window, period being the report period
+func SaveWindowMetrics(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, kind string, timestamp time.Time, period time.Duration, metrics map[string]float64) error {
+	jsonData, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("error marshaling window metrics: %v", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: windowID(kind, timestamp, period)},
+			"timestamp": &types.AttributeValueMemberS{Value: timestamp.UTC().Format(time.RFC3339)},
+			"metrics":   &types.AttributeValueMemberS{Value: string(jsonData)},
+			"expiresAt": &types.AttributeValueMemberN{Value: strconv.FormatInt(timestamp.Add(windowRetention).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error saving window metrics: %v", err)
+	}
+	return nil
+}
+
+// Loads the metrics of the same window on each of the previous 7 days, index 0
+// = yesterday and index 6 = one week ago. Missing windows are nil.
+func LoadBaseline(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, kind string, timestamp time.Time, period time.Duration) ([]map[string]float64, error) {
+	ids := make([]string, baselineWindows)
+	keys := make([]map[string]types.AttributeValue, baselineWindows)
+	for i := range baselineWindows {
+		ids[i] = windowID(kind, timestamp.AddDate(0, 0, -(i+1)), period)
+		keys[i] = map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: ids[i]},
+		}
+	}
+
+	output, err := dynamoClient.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+		RequestItems: map[string]types.KeysAndAttributes{
+			tableName: {Keys: keys},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting baseline windows: %v", err)
+	}
+
+	windows := make(map[string]map[string]float64)
+	for _, item := range output.Responses[tableName] {
+		id, _ := item["id"].(*types.AttributeValueMemberS)
+		attribute, exists := item["metrics"].(*types.AttributeValueMemberS)
+		if id == nil || !exists {
+			continue
+		}
+
+		var metrics map[string]float64
+		if err := json.Unmarshal([]byte(attribute.Value), &metrics); err != nil {
+			return nil, fmt.Errorf("error parsing baseline window %s: %v", id.Value, err)
+		}
+		windows[id.Value] = metrics
+	}
+
+	baseline := make([]map[string]float64, baselineWindows)
+	for i, id := range ids {
+		baseline[i] = windows[id]
+	}
+	return baseline, nil
+}
@@ -0,0 +1,72 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Reports published to the SNS fallback since the last one reaching Telegram
+const fallbacksID = "fallbacks"
+
+// Times of the reports that went to the fallback, nil when there are none
+func LoadFallbacks(ctx context.Context, dynamoClient *dynamodb.Client, tableName string) ([]time.Time, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: fallbacksID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting fallbacks: %v", err)
+	}
+
+	attribute, exists := output.Item["reports"].(*types.AttributeValueMemberS)
+	if !exists {
+		return nil, nil
+	}
+
+	var fallbacks []time.Time
+	if err := json.Unmarshal([]byte(attribute.Value), &fallbacks); err != nil {
+		return nil, fmt.Errorf("error parsing fallbacks: %v", err)
+	}
+	return fallbacks, nil
+}
+
+// Replaces the recorded fallbacks, none once a report reached Telegram again
+func SaveFallbacks(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, fallbacks []time.Time) error {
+	if len(fallbacks) == 0 {
+		_, err := dynamoClient.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: aws.String(tableName),
+			Key: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: fallbacksID},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error deleting fallbacks: %v", err)
+		}
+		return nil
+	}
+
+	jsonData, err := json.Marshal(fallbacks)
+	if err != nil {
+		return fmt.Errorf("error marshaling fallbacks: %v", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"id":      &types.AttributeValueMemberS{Value: fallbacksID},
+			"reports": &types.AttributeValueMemberS{Value: string(jsonData)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error saving fallbacks: %v", err)
+	}
+	return nil
+}
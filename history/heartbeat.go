@@ -0,0 +1,52 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Written after every report, read by the watchdog
+const heartbeatID = "heartbeat"
+
+func SaveHeartbeat(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, timestamp time.Time) error {
+	_, err := dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: heartbeatID},
+			"timestamp": &types.AttributeValueMemberS{Value: timestamp.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error saving heartbeat: %v", err)
+	}
+	return nil
+}
+
+// Time of the last report, zero when no report was recorded yet
+func LoadHeartbeat(ctx context.Context, dynamoClient *dynamodb.Client, tableName string) (time.Time, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: heartbeatID},
+		},
+	})
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error getting heartbeat: %v", err)
+	}
+
+	attribute, exists := output.Item["timestamp"].(*types.AttributeValueMemberS)
+	if !exists {
+		return time.Time{}, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, attribute.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("error parsing heartbeat: %v", err)
+	}
+	return timestamp, nil
+}
@@ -0,0 +1,63 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Scheduled, daily and rollup reports cover different windows, so each is
+// compared with the previous report of the same kind (config.TimeParams.ReportKind)
+func lastReportID(kind string) string {
+	return "report#" + kind
+}
+
+// Loads the flattened metrics of the previous report. Returns nil if there is none.
+func LoadLastMetrics(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, kind string) (map[string]float64, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: lastReportID(kind)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting last report metrics: %v", err)
+	}
+
+	attribute, exists := output.Item["metrics"].(*types.AttributeValueMemberS)
+	if !exists {
+		return nil, nil
+	}
+
+	var metrics map[string]float64
+	if err := json.Unmarshal([]byte(attribute.Value), &metrics); err != nil {
+		return nil, fmt.Errorf("error parsing last report metrics: %v", err)
+	}
+	return metrics, nil
+}
+
+// Stores the flattened metrics of this report for the next run to compare against
+func SaveLastMetrics(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, kind string, timestamp time.Time, metrics map[string]float64) error {
+	jsonData, err := json.Marshal(metrics)
+	if err != nil {
+		return fmt.Errorf("error marshaling report metrics: %v", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"id":        &types.AttributeValueMemberS{Value: lastReportID(kind)},
+			"timestamp": &types.AttributeValueMemberS{Value: timestamp.UTC().Format(time.RFC3339)},
+			"metrics":   &types.AttributeValueMemberS{Value: string(jsonData)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error saving report metrics: %v", err)
+	}
+	return nil
+}
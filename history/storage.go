@@ -0,0 +1,77 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// Bucket sizes of the daily reports, to compare against the last month
+	storageSizesID = "storage#s3"
+	// Days of sizes kept, the longest growth period reported
+	storageRetentionDays = 30
+)
+
+// Bucket sizes (MB) by report date (2006-01-02, report timezone), then by
+// bucket. Returns nil if there are none.
+func LoadStorageSizes(ctx context.Context, dynamoClient *dynamodb.Client, tableName string) (map[string]map[string]float64, error) {
+	output, err := dynamoClient.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(tableName),
+		Key: map[string]types.AttributeValue{
+			"id": &types.AttributeValueMemberS{Value: storageSizesID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage sizes: %v", err)
+	}
+
+	attribute, exists := output.Item["sizes"].(*types.AttributeValueMemberS)
+	if !exists {
+		return nil, nil
+	}
+
+	var sizes map[string]map[string]float64
+	if err := json.Unmarshal([]byte(attribute.Value), &sizes); err != nil {
+		return nil, fmt.Errorf("error parsing storage sizes: %v", err)
+	}
+	return sizes, nil
+}
+
+// Adds the sizes of the report date and drops the dates older than 30 days
+func SaveStorageSizes(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, sizes map[string]map[string]float64, date time.Time, current map[string]float64) error {
+	if sizes == nil {
+		sizes = make(map[string]map[string]float64)
+	}
+	sizes[date.Format(time.DateOnly)] = current
+
+	oldest := date.AddDate(0, 0, -storageRetentionDays).Format(time.DateOnly)
+	for day := range sizes {
+		// ISO dates sort as strings
+		if day < oldest {
+			delete(sizes, day)
+		}
+	}
+
+	jsonData, err := json.Marshal(sizes)
+	if err != nil {
+		return fmt.Errorf("error marshaling storage sizes: %v", err)
+	}
+
+	_, err = dynamoClient.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(tableName),
+		Item: map[string]types.AttributeValue{
+			"id":    &types.AttributeValueMemberS{Value: storageSizesID},
+			"sizes": &types.AttributeValueMemberS{Value: string(jsonData)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error saving storage sizes: %v", err)
+	}
+	return nil
+}
@@ -2,27 +2,145 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"text/template"
 	"time"
 
 	"telegraws/config"
+	"telegraws/daemon"
+	"telegraws/deploy"
+	"telegraws/history"
 	"telegraws/services"
 	"telegraws/utils"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	lambdaService "github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
-	"github.com/aws/aws-sdk-go-v2/service/wafv2"
 
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
+const (
+	// Upper bound of collectors (and per-resource collections) running at once
+	maxConcurrentCollectors = 8
+	// Left before the Lambda deadline to build and send the report
+	reportReserve = 15 * time.Second
+	// Given to collections to return once their context expired
+	collectionGrace = 2 * time.Second
+)
+
+// Last report metrics served on /metrics, daemon mode only
+var metricsExporter *utils.PrometheusExporter
+
+// AWS config and clients are shared across warm Lambda invocations (and daemon
+// runs), LoadDefaultConfig and client setup only run on a cold start
+var (
+	sharedAWSMu     sync.Mutex
+	sharedAWSConfig *aws.Config
+	sharedClients   *awsClients
+)
+
+type awsClients struct {
+	Config    aws.Config // With the retryer of the sdk config block
+	AccountID string
+	Services  *services.Clients
+	Tagging   *resourcegroupstaggingapi.Client
+	S3        *s3.Client
+}
+
+// Default config used to load the app config and its secrets
+func loadAWSConfig(ctx context.Context) (aws.Config, error) {
+	sharedAWSMu.Lock()
+	defer sharedAWSMu.Unlock()
+
+	if sharedAWSConfig == nil {
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("unable to load SDK config: %v", err)
+		}
+		awsCfg.APIOptions = append(awsCfg.APIOptions, services.RecordAPICalls)
+		sharedAWSConfig = &awsCfg
+	}
+
+	return *sharedAWSConfig, nil
+}
+
+// Applies the sdk config block, unset fields keep the SDK defaults (which honor
+// AWS_RETRY_MODE and AWS_MAX_ATTEMPTS)
+func withRetryer(awsCfg aws.Config, sdk config.SDKConfig) aws.Config {
+	if sdk.RetryMode == "" && sdk.MaxAttempts == 0 {
+		return awsCfg
+	}
+
+	awsCfg.Retryer = func() aws.Retryer {
+		var retryer aws.Retryer = retry.NewStandard()
+		if sdk.RetryMode == config.RetryModeAdaptive {
+			retryer = retry.NewAdaptiveMode()
+		}
+		if sdk.MaxAttempts > 0 {
+			retryer = retry.AddWithMaxAttempts(retryer, sdk.MaxAttempts)
+		}
+		return retryer
+	}
+	return awsCfg
+}
+
+// Built on the first run, with the sdk settings of its config. Changing them
+// takes a cold start.
+func getAWSClients(ctx context.Context, awsCfg aws.Config, appConfig *config.Config) (*awsClients, error) {
+	sharedAWSMu.Lock()
+	defer sharedAWSMu.Unlock()
+
+	if sharedClients == nil {
+		awsCfg = withRetryer(awsCfg, appConfig.Global.SDK)
+
+		accountID, err := getAccountID(ctx, awsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve AWS account ID: %w", err)
+		}
+
+		sharedClients = &awsClients{
+			Config:    awsCfg,
+			AccountID: accountID,
+			Services:  services.NewClients(awsCfg, accountID),
+			Tagging:   resourcegroupstaggingapi.NewFromConfig(awsCfg),
+			S3:        s3.NewFromConfig(awsCfg),
+		}
+	}
+
+	return sharedClients, nil
+}
+
 func getAccountID(ctx context.Context, cfg aws.Config) (string, error) {
 	if acct := os.Getenv("AWS_ACCOUNT_ID"); acct != "" {
 		return acct, nil
@@ -37,13 +155,304 @@ func getAccountID(ctx context.Context, cfg aws.Config) (string, error) {
 	return *output.Account, nil
 }
 
-func logic(ctx context.Context) error {
-	appConfig, err := config.LoadEmbeddedConfig()
+// Collects metrics for tagged resources not already configured explicitly
+func collectDiscoveredMetrics(
+	ctx context.Context,
+	appConfig *config.Config,
+	discovered *services.DiscoveredResources,
+	cwClient *cloudwatch.Client,
+	ec2Client *ec2.Client,
+	dynamoClient *dynamodb.Client,
+	rdsClient *rds.Client,
+	timeParams *config.TimeParams,
+	timeParamsMap map[string]time.Time,
+) map[string]map[string]any {
+	var (
+		discoveredMetrics = make(map[string]map[string]any)
+		discoveredMu      sync.Mutex
+	)
+
+	g := new(errgroup.Group)
+	g.SetLimit(maxConcurrentCollectors)
+
+	add := func(service string, resource string, result utils.Result, err error) {
+		discoveredMu.Lock()
+		defer discoveredMu.Unlock()
+		if err != nil {
+			utils.Logger.Error("Failed to get discovered resource metrics",
+				zap.Error(err),
+				zap.String("service", service),
+				zap.String("resource", resource),
+			)
+			return
+		}
+		if discoveredMetrics[service] == nil {
+			discoveredMetrics[service] = make(map[string]any)
+		}
+		discoveredMetrics[service][resource] = result
+	}
+
+	for _, instanceID := range discovered.EC2InstanceIDs {
+		if appConfig.Services.EC2.Enabled && instanceID == appConfig.Services.EC2.InstanceID {
+			continue
+		}
+		g.Go(func() error {
+			metrics, err := services.EC2Metrics(ctx, cwClient, ec2Client, instanceID, timeParamsMap, timeParams.Location)
+			add("ec2", instanceID, metrics, err)
+			return nil
+		})
+	}
+
+	if timeParams.IsDailyReport {
+		for _, bucketName := range discovered.S3Buckets {
+			if appConfig.Services.S3.Enabled && slices.Contains(appConfig.Services.S3.BucketNames, bucketName) {
+				continue
+			}
+			g.Go(func() error {
+				metrics, err := services.S3Metrics(ctx, cwClient, bucketName, timeParamsMap)
+				add("s3", bucketName, metrics, err)
+				return nil
+			})
+		}
+	}
+
+	for _, albName := range discovered.ALBNames {
+		if appConfig.Services.ALB.Enabled && slices.ContainsFunc(appConfig.Services.ALB.ALBNames, func(name string) bool {
+			return strings.Contains(albName, name)
+		}) {
+			continue
+		}
+		g.Go(func() error {
+			metrics, err := services.ALBMetrics(ctx, cwClient, albName, timeParamsMap)
+			add("alb", albName, metrics, err)
+			return nil
+		})
+	}
+
+	for _, tableName := range discovered.DynamoDBTables {
+		if appConfig.Services.DynamoDB.Enabled && slices.Contains(appConfig.Services.DynamoDB.TableNames, tableName) {
+			continue
+		}
+		g.Go(func() error {
+			metrics, err := services.DynamoDBMetrics(ctx, cwClient, dynamoClient, timeParamsMap, tableName)
+			if err == nil {
+				metrics.IsDailyReport = timeParams.IsDailyReport
+			}
+			add("dynamodb", tableName, metrics, err)
+			return nil
+		})
+	}
+
+	for _, clusterID := range discovered.RDSClusterIDs {
+		if appConfig.Services.RDS.Enabled && clusterID == appConfig.Services.RDS.ClusterID {
+			continue
+		}
+		g.Go(func() error {
+			metrics, err := services.RDSMetrics(ctx, cwClient, rdsClient, clusterID, "", nil, "", timeParamsMap)
+			add("rdsCluster", clusterID, metrics, err)
+			return nil
+		})
+	}
+
+	for _, instanceID := range discovered.RDSInstanceIDs {
+		if appConfig.Services.RDS.Enabled && instanceID == appConfig.Services.RDS.DBInstanceIdentifier {
+			continue
+		}
+		g.Go(func() error {
+			metrics, err := services.RDSMetrics(ctx, cwClient, rdsClient, "", instanceID, nil, "", timeParamsMap)
+			add("rdsInstance", instanceID, metrics, err)
+			return nil
+		})
+	}
+
+	g.Wait()
+
+	return discoveredMetrics
+}
+
+// Loads the user report template, nil = built-in layout
+func loadReportTemplate(ctx context.Context, awsCfg aws.Config, appConfig *config.Config) *template.Template {
+	location := appConfig.Global.Telegram.Template
+	if location == "" {
+		return nil
+	}
+
+	text, err := config.LoadTemplate(ctx, s3.NewFromConfig(awsCfg), ssm.NewFromConfig(awsCfg), location)
+	if err == nil {
+		var tmpl *template.Template
+		if tmpl, err = utils.ParseReportTemplate(text, appConfig.Global.Telegram.ParseMode); err == nil {
+			return tmpl
+		}
+	}
+
+	utils.Logger.Warn("Failed to load report template, using the built-in layout",
+		zap.Error(err),
+		zap.String("template", location),
+	)
+	return nil
+}
+
+// Telegram receives the report unless left out for a push channel, other
+// notifiers when enabled
+func buildNotifiers(awsCfg aws.Config, appConfig *config.Config, telegram *utils.TelegramNotifier) []utils.Notifier {
+	var notifiers []utils.Notifier
+	if appConfig.Global.Telegram.Configured() {
+		notifiers = append(notifiers, telegram)
+	}
+
+	if appConfig.Notifiers.Slack.Enabled {
+		notifiers = append(notifiers, &utils.SlackNotifier{WebhookURL: appConfig.Notifiers.Slack.WebhookURL})
+	}
+
+	if email := appConfig.Notifiers.Email; email.Enabled {
+		sesCfg := awsCfg.Copy()
+		if email.Region != "" {
+			sesCfg.Region = email.Region
+		}
+		notifiers = append(notifiers, &utils.EmailNotifier{
+			Client: sesv2.NewFromConfig(sesCfg),
+			From:   email.From,
+			To:     email.To,
+		})
+	}
+
+	if pagerDuty := appConfig.Notifiers.PagerDuty; pagerDuty.Enabled {
+		notifiers = append(notifiers, &utils.PagerDutyNotifier{
+			RoutingKey: pagerDuty.RoutingKey,
+			Source:     deploy.FunctionName(appConfig),
+		})
+	}
+
+	if webhook := appConfig.Notifiers.Webhook; webhook.Enabled {
+		notifiers = append(notifiers, &utils.WebhookNotifier{
+			URL:         webhook.URL,
+			Secret:      webhook.Secret,
+			IncludeText: webhook.IncludeText,
+		})
+	}
+
+	if ntfy := appConfig.Notifiers.Ntfy; ntfy.Enabled {
+		notifiers = append(notifiers, &utils.NtfyNotifier{Server: ntfy.Server, Topic: ntfy.Topic, Token: ntfy.Token})
+	}
+
+	if pushover := appConfig.Notifiers.Pushover; pushover.Enabled {
+		notifiers = append(notifiers, &utils.PushoverNotifier{AppToken: pushover.AppToken, UserKey: pushover.UserKey})
+	}
+
+	return notifiers
+}
+
+// Metric sinks feed dashboards, so they receive every report, even the ones
+// alertsOnly mode keeps silent
+func buildMetricSinks(appConfig *config.Config) []utils.Notifier {
+	var sinks []utils.Notifier
+
+	if prometheus := appConfig.Notifiers.Prometheus; prometheus.Enabled {
+		sinks = append(sinks, &utils.PrometheusNotifier{
+			RemoteWriteURL: prometheus.RemoteWriteURL,
+			BearerToken:    prometheus.BearerToken,
+			Username:       prometheus.Username,
+			Password:       prometheus.Password,
+			Labels:         prometheus.Labels,
+		})
+	}
+
+	if metricsExporter != nil {
+		sinks = append(sinks, metricsExporter)
+	}
+
+	return sinks
+}
+
+// Loads the config from TELEGRAWS_CONFIG or SSM when configured, falling back
+// to the embedded config
+func loadAppConfig(ctx context.Context, awsCfg aws.Config) (*config.Config, error) {
+	if value := os.Getenv(config.ConfigEnv); value != "" {
+		return checkServiceNames(config.LoadEnvConfig(value))
+	}
+
+	parameterName := os.Getenv(config.ConfigParameterEnv)
+	if parameterName == "" {
+		return checkServiceNames(config.LoadEmbeddedConfig())
+	}
+
+	appConfig, err := checkServiceNames(config.LoadSSMConfig(ctx, ssm.NewFromConfig(awsCfg), parameterName))
+	if err != nil {
+		utils.Logger.Warn("Failed to load SSM config, falling back to embedded config",
+			zap.Error(err),
+			zap.String("parameter", parameterName),
+		)
+		return checkServiceNames(config.LoadEmbeddedConfig())
+	}
+
+	return appConfig, nil
+}
+
+// Service lists are validated against the collectors once the config is loaded
+func checkServiceNames(appConfig *config.Config, err error) (*config.Config, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := appConfig.ValidateServiceNames(services.MetricsKeys()); err != nil {
+		return nil, fmt.Errorf("config validation failed: %v", err)
+	}
+	return appConfig, nil
+}
+
+// Sends the default schedule report followed by the additional schedules due,
+// or only the ad-hoc run or named schedule of invocation
+func logic(ctx context.Context, invocation config.Invocation) error {
+	awsCfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
 	if err != nil {
 		return fmt.Errorf("failed to load app config: %v", err)
 	}
 
-	timeParams, err := appConfig.GetTimeParams()
+	if err := appConfig.ResolveTelegramSecrets(ctx, secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		return fmt.Errorf("failed to resolve Telegram secrets: %v", err)
+	}
+
+	if invocation.Watchdog {
+		return watchdogCheck(ctx, awsCfg, appConfig)
+	}
+
+	if err := invocation.ValidateServices(services.MetricsKeys()); err != nil {
+		return fmt.Errorf("invalid invocation: %v", err)
+	}
+
+	if invocation.Schedule != "" {
+		schedule := appConfig.Schedule(invocation.Schedule)
+		if schedule == nil {
+			return fmt.Errorf("unknown schedule '%s'", invocation.Schedule)
+		}
+		return runReport(ctx, awsCfg, appConfig, schedule.Invocation())
+	}
+
+	err = runReport(ctx, awsCfg, appConfig, invocation)
+	if invocation.IsAdHoc() {
+		return err
+	}
+
+	// Additional schedules due this hour run one after the other, each with its
+	// own collection
+	schedules, scheduleErr := appConfig.DueSchedules()
+	errs := []error{err, scheduleErr}
+	for _, schedule := range schedules {
+		if err := runReport(ctx, awsCfg, appConfig, schedule.Invocation()); err != nil {
+			errs = append(errs, fmt.Errorf("error running schedule '%s': %v", schedule.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Collects and sends one report. invocation holds the overrides of ad-hoc runs
+// or the additional schedule being run, empty for the default schedule.
+func runReport(ctx context.Context, awsCfg aws.Config, appConfig *config.Config, invocation config.Invocation) error {
+	timeParams, err := appConfig.GetTimeParams(invocation)
 	if err != nil {
 		return fmt.Errorf("failed to calculate time parameters: %v", err)
 	}
@@ -51,170 +460,704 @@ func logic(ctx context.Context) error {
 		utils.Logger.Info("Skipping execution: outside of daily report hour and no defaultPeriod configured")
 		return nil
 	}
+	if invocation.ErrorSamples > 0 {
+		appConfig.Services.CloudWatchLogs.ErrorSamples = invocation.ErrorSamples
+	}
 
-	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	shared, err := getAWSClients(ctx, awsCfg, appConfig)
 	if err != nil {
-		return fmt.Errorf("unable to load SDK config: %v", err)
+		return err
 	}
+	clients := shared.Services
+	taggingClient := shared.Tagging
 
-	logsClient := cloudwatchlogs.NewFromConfig(awsCfg)
-	cwClient := cloudwatch.NewFromConfig(awsCfg)
-	wafClient := wafv2.NewFromConfig(awsCfg)
-	dynamoClient := dynamodb.NewFromConfig(awsCfg)
-
-	// CloudFront requires us-east-1 clients
-	cfCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion("us-east-1"))
-	if err != nil {
-		return fmt.Errorf("unable to load SDK config for us-east-1: %v", err)
+	cacheConfig := appConfig.Global.Cache
+	services.Discovery.SetTTL(time.Duration(cacheConfig.TTLMinutes) * time.Minute)
+	if cacheConfig.SSMParameter != "" {
+		if err := services.Discovery.Load(ctx, ssm.NewFromConfig(shared.Config), cacheConfig.SSMParameter); err != nil {
+			utils.Logger.Warn("Failed to load discovery cache", zap.Error(err), zap.String("parameter", cacheConfig.SSMParameter))
+		}
 	}
-	cwCfClient := cloudwatch.NewFromConfig(cfCfg)
-	wafCfClient := wafv2.NewFromConfig(cfCfg)
 
-	// Resolve AWS account ID
-	accountID, err := getAccountID(ctx, awsCfg)
-	if err != nil {
-		return fmt.Errorf("failed to resolve AWS account ID: %w", err)
+	var (
+		allMetrics = make(map[string]any)
+		metricsMu  sync.Mutex
+		// Collections still running, and the ones that timed out
+		pending  = make(map[string]bool)
+		timedOut []string
+		// Set once the report is built, late results are dropped
+		collectionClosed bool
+	)
+
+	setMetrics := func(key string, value any) {
+		metricsMu.Lock()
+		defer metricsMu.Unlock()
+		if !collectionClosed {
+			allMetrics[key] = value
+		}
 	}
 
-	allMetrics := make(map[string]any)
+	// Per-resource metrics (tables, log groups) are grouped under the service key
+	setNestedMetrics := func(key string, resource string, value any) {
+		metricsMu.Lock()
+		defer metricsMu.Unlock()
+		if collectionClosed {
+			return
+		}
+		nested, exists := allMetrics[key].(map[string]any)
+		if !exists {
+			nested = make(map[string]any)
+			allMetrics[key] = nested
+		}
+		nested[resource] = value
+	}
 
 	timeParamsMap := map[string]time.Time{
 		"startTime": timeParams.StartTime,
 		"endTime":   timeParams.EndTime,
 	}
 
-	if appConfig.Services.EC2.Enabled {
-		ec2Metrics, err := services.EC2Metrics(ctx, cwClient, appConfig.Services.EC2.InstanceID, timeParamsMap)
-		if err != nil {
-			utils.Logger.Error("Failed to get EC2 metrics", zap.Error(err))
-		} else {
-			allMetrics["ec2"] = ec2Metrics
+	// On Lambda, collection stops early enough to still send what completed
+	collectionDeadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		collectionDeadline = collectionDeadline.Add(-reportReserve)
+	}
+
+	// Collectors log their own errors so one failing service never cancels the others
+	g := new(errgroup.Group)
+	g.SetLimit(maxConcurrentCollectors)
+
+	// Runs a collection with its own timeout, bounded by the collection deadline.
+	// label names it in the partial report note when it times out.
+	collect := func(label string, run func(ctx context.Context) error) {
+		metricsMu.Lock()
+		pending[label] = true
+		metricsMu.Unlock()
+
+		g.Go(func() error {
+			timeout := time.Duration(appConfig.Global.Monitoring.CollectorTimeout) * time.Second
+			if hasDeadline {
+				timeout = min(timeout, time.Until(collectionDeadline))
+			}
+			collectCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			err := run(collectCtx)
+
+			metricsMu.Lock()
+			defer metricsMu.Unlock()
+			delete(pending, label)
+			if err != nil && collectCtx.Err() != nil && !collectionClosed {
+				timedOut = append(timedOut, label)
+			}
+			return nil
+		})
+	}
+
+	// Chart series are only collected for the daily report, one slice per service keeps the order stable
+	collectCharts := appConfig.Global.Monitoring.Charts && timeParams.IsDailyReport && timeParams.Rollup == ""
+	var ec2Charts, albCharts []services.ChartSeries
+
+	if collectCharts && appConfig.Services.EC2.Enabled && invocation.Includes("ec2") {
+		collect("ec2 charts", func(ctx context.Context) error {
+			charts, err := services.EC2ChartSeries(ctx, clients.CloudWatch.Get(appConfig.Services.EC2.Region), appConfig.Services.EC2.InstanceID, timeParamsMap)
+			if err != nil {
+				utils.Logger.Error("Failed to get EC2 chart series", zap.Error(err))
+				return err
+			}
+			metricsMu.Lock()
+			defer metricsMu.Unlock()
+			if !collectionClosed {
+				ec2Charts = charts
+			}
+			return nil
+		})
+	}
+
+	if collectCharts && appConfig.Services.ALB.Enabled && invocation.Includes("alb") {
+		collect("alb charts", func(ctx context.Context) error {
+			var charts []services.ChartSeries
+			for _, albName := range appConfig.Services.ALB.ALBNames {
+				region := config.ResourceRegion(appConfig.Services.ALB.Region, appConfig.Services.ALB.ResourceRegions, albName)
+				series, err := services.ALBChartSeries(ctx, clients.CloudWatch.Get(region), albName, timeParamsMap)
+				if err != nil {
+					utils.Logger.Error("Failed to get ALB chart series", zap.Error(err), zap.String("albName", albName))
+					if ctx.Err() != nil {
+						return err
+					}
+					continue
+				}
+				charts = append(charts, series...)
+			}
+			metricsMu.Lock()
+			defer metricsMu.Unlock()
+			if !collectionClosed {
+				albCharts = charts
+			}
+			return nil
+		})
+	}
+
+	var reportServices []utils.ReportService
+	for _, collector := range services.Collectors {
+		if !collector.Enabled(appConfig, timeParams) || !invocation.Includes(collector.Name()) {
+			continue
+		}
+
+		name := collector.Name()
+		resources := collector.Resources(appConfig)
+		reportServices = append(reportServices, utils.ReportService{Name: name, Resources: resources})
+
+		if resources == nil {
+			collect(name, func(ctx context.Context) error {
+				result, err := collector.Collect(ctx, appConfig, clients, timeParams, "")
+				if err != nil {
+					utils.Logger.Error("Failed to get service metrics", zap.Error(err), zap.String("service", name))
+					return err
+				}
+				setMetrics(name, result)
+				return nil
+			})
+			continue
+		}
+
+		for _, resource := range resources {
+			collect(name+"/"+resource, func(ctx context.Context) error {
+				result, err := collector.Collect(ctx, appConfig, clients, timeParams, resource)
+				if err != nil {
+					utils.Logger.Error("Failed to get service metrics",
+						zap.Error(err),
+						zap.String("service", name),
+						zap.String("resource", resource),
+					)
+					return err
+				}
+				setNestedMetrics(name, resource, result)
+				return nil
+			})
 		}
 	}
 
-	if appConfig.Services.S3.Enabled && timeParams.IsDailyReport {
-		s3Metrics, err := services.S3Metrics(ctx, cwClient, appConfig.Services.S3.BucketName, timeParamsMap)
-		if err != nil {
-			utils.Logger.Error("Failed to get S3 metrics", zap.Error(err))
-		} else {
-			allMetrics["s3"] = s3Metrics
+	if appConfig.Global.Discovery.TagKey != "" && invocation.Includes("discovered") {
+		collect("discovered", func(ctx context.Context) error {
+			discovered, err := services.DiscoverResources(ctx, taggingClient, appConfig.Global.Discovery.TagKey, appConfig.Global.Discovery.TagValue)
+			if err != nil {
+				utils.Logger.Error("Failed to discover tagged resources", zap.Error(err))
+				return err
+			}
+			setMetrics("discovered", collectDiscoveredMetrics(ctx, appConfig, discovered, clients.CloudWatch.Get(""), clients.EC2.Get(""), clients.DynamoDB.Get(""), clients.RDS.Get(""), timeParams, timeParamsMap))
+			return ctx.Err()
+		})
+	}
+
+	// Without a deadline every collection ends with its own timeout
+	collected := make(chan struct{})
+	go func() {
+		g.Wait()
+		close(collected)
+	}()
+	var deadlineReached <-chan time.Time
+	if hasDeadline {
+		deadlineReached = time.After(time.Until(collectionDeadline) + collectionGrace)
+	}
+	select {
+	case <-collected:
+	case <-deadlineReached:
+		utils.Logger.Warn("Collection deadline reached, sending a partial report")
+	}
+
+	metricsMu.Lock()
+	collectionClosed = true
+	for label := range pending {
+		timedOut = append(timedOut, label)
+	}
+	metricsMu.Unlock()
+
+	if cacheConfig.SSMParameter != "" {
+		if err := services.Discovery.Save(ctx, ssm.NewFromConfig(shared.Config), cacheConfig.SSMParameter); err != nil {
+			utils.Logger.Warn("Failed to save discovery cache", zap.Error(err), zap.String("parameter", cacheConfig.SSMParameter))
 		}
 	}
 
-	if appConfig.Services.ALB.Enabled {
-		albMetrics, err := services.ALBMetrics(ctx, cwClient, appConfig.Services.ALB.ALBName, timeParamsMap)
+	// Previous report metrics, used to render trends
+	var previousMetrics map[string]float64
+	historyTable := appConfig.Global.History.TableName
+	if historyTable != "" {
+		previousMetrics, err = history.LoadLastMetrics(ctx, clients.DynamoDB.Get(""), historyTable, timeParams.ReportKind())
 		if err != nil {
-			utils.Logger.Error("Failed to get ALB metrics", zap.Error(err))
-		} else {
-			allMetrics["alb"] = albMetrics
+			utils.Logger.Warn("Failed to load previous report metrics", zap.Error(err), zap.String("tableName", historyTable))
 		}
 	}
 
-	if appConfig.Services.CloudFront.Enabled {
-		cloudFrontMetrics, err := services.CloudFrontMetrics(ctx, cwCfClient, appConfig.Services.CloudFront.DistributionID, timeParamsMap)
+	// Same-hour windows of the previous days, used to flag anomalies. Rollups
+	// have a single window per week or month, too few for a baseline.
+	baselines := appConfig.Global.History.Baselines && timeParams.Rollup == ""
+	var baseline []map[string]float64
+	if historyTable != "" && baselines {
+		baseline, err = history.LoadBaseline(ctx, clients.DynamoDB.Get(""), historyTable, timeParams.ReportKind(), timeParams.EndTime, appConfig.ReportPeriod())
 		if err != nil {
-			utils.Logger.Error("Failed to get CloudFront metrics", zap.Error(err))
-		} else {
-			allMetrics["cloudfront"] = cloudFrontMetrics
+			utils.Logger.Warn("Failed to load metric baselines", zap.Error(err), zap.String("tableName", historyTable))
 		}
 	}
 
-	if appConfig.Services.CloudWatchAgent.Enabled {
-		cwAgentMetrics, err := services.CWAgentMetrics(ctx, cwClient, appConfig.Services.CloudWatchAgent.InstanceID, timeParamsMap)
-		if err != nil {
-			utils.Logger.Error("Failed to get CloudWatch Agent metrics", zap.Error(err))
-		} else {
-			allMetrics["cloudwatchAgent"] = cwAgentMetrics
+	// Bucket sizes of the previous daily reports, for the growth of each bucket
+	if historyTable != "" && timeParams.IsDailyReport && timeParams.Rollup == "" {
+		if buckets, exists := allMetrics["s3"].(map[string]any); exists {
+			recordStorageGrowth(ctx, clients.DynamoDB.Get(""), historyTable, buckets, timeParams.EndTime.In(timeParams.Location), invocation.IsAdHoc())
 		}
 	}
 
-	if appConfig.Services.CloudWatchLogs.Enabled {
-		logMetrics := make(map[string]any)
-		for _, logGroupName := range appConfig.Services.CloudWatchLogs.LogGroupNames {
-			logCounts, err := services.CWLogs(ctx, logsClient, logGroupName, timeParamsMap)
+	report := utils.BuildReport(appConfig, timeParams, reportServices, allMetrics, previousMetrics, baseline)
+	if len(timedOut) > 0 {
+		report.MarkPartial(timedOut)
+	}
+
+	// Ad-hoc windows and service subsets would skew the next trends and baselines
+	if historyTable != "" && !invocation.IsAdHoc() {
+		flatMetrics := utils.FlattenMetrics(allMetrics)
+
+		err := history.SaveLastMetrics(ctx, clients.DynamoDB.Get(""), historyTable, timeParams.ReportKind(), timeParams.EndTime, flatMetrics)
+		if err != nil {
+			utils.Logger.Error("Failed to save report metrics", zap.Error(err), zap.String("tableName", historyTable))
+		}
+
+		if baselines {
+			err := history.SaveWindowMetrics(ctx, clients.DynamoDB.Get(""), historyTable, timeParams.ReportKind(), timeParams.EndTime, appConfig.ReportPeriod(), flatMetrics)
 			if err != nil {
-				utils.Logger.Error("Failed to get CloudWatch Logs metrics",
-					zap.Error(err),
-					zap.String("logGroup", logGroupName),
-				)
-				continue
+				utils.Logger.Error("Failed to save window metrics", zap.Error(err), zap.String("tableName", historyTable))
 			}
-			logMetrics[logGroupName] = logCounts
 		}
-		if len(logMetrics) > 0 {
-			allMetrics["cloudwatchLogs"] = logMetrics
+	}
+
+	// Metric sinks only get the default schedule without overrides, windows and
+	// services stay consistent
+	var sinkErr error
+	if invocation.Schedule == "" && !invocation.IsAdHoc() {
+		sinkErr = utils.NotifyAll(ctx, buildMetricSinks(appConfig), report)
+	}
+
+	// Alert-only mode: scheduled runs stay silent unless a threshold is breached
+	alertsOnly := appConfig.Global.Monitoring.Mode == config.ModeAlertsOnly && !timeParams.IsDailyReport && !invocation.IsAdHoc()
+	if alertsOnly && len(report.Breaches) == 0 && len(report.Anomalies) == 0 {
+		utils.Logger.Info("Skipping notification: no thresholds breached or anomalies in alertsOnly mode")
+		recordHeartbeat(ctx, appConfig, clients.DynamoDB.Get(""), timeParams.EndTime)
+		return sinkErr
+	}
+
+	// Quiet hours and maintenance windows mute scheduled reports, or skip them
+	if !invocation.IsAdHoc() {
+		switch quiet, reason := appConfig.QuietMode(timeParams.EndTime); quiet {
+		case config.QuietSuppress:
+			utils.Logger.Info("Skipping notification: suppressed", zap.String("reason", reason))
+			recordHeartbeat(ctx, appConfig, clients.DynamoDB.Get(""), timeParams.EndTime)
+			return sinkErr
+		case config.QuietSilent:
+			report.Mute()
 		}
 	}
 
-	if appConfig.Services.WAF.Enabled {
-		scope := appConfig.Services.WAF.Scope
-		if scope == "" {
-			scope = "REGIONAL"
+	var photos []utils.TelegramPhoto
+	for _, series := range slices.Concat(ec2Charts, albCharts) {
+		png, err := utils.RenderChart(series.Title, series.Timestamps, series.Values, timeParams.Location)
+		if err != nil {
+			utils.Logger.Error("Failed to render chart", zap.Error(err))
+			continue
 		}
+		photos = append(photos, utils.TelegramPhoto{Caption: series.Title, PNG: png})
+	}
 
-		var wafClientToUse *wafv2.Client
-		var cwClientToUse *cloudwatch.Client
+	telegram := &utils.TelegramNotifier{
+		BotToken:   appConfig.Global.Telegram.BotToken,
+		ChatIDs:    appConfig.Global.Telegram.ChatID,
+		ParseMode:  appConfig.Global.Telegram.ParseMode,
+		Template:   loadReportTemplate(ctx, shared.Config, appConfig),
+		Routes:     appConfig.Global.Telegram.Routes,
+		AlertsOnly: alertsOnly,
+		Photos:     photos,
+	}
+	// Schedules and drill-downs with their own chats skip the routes
+	if len(invocation.ChatIDs) > 0 {
+		telegram.ChatIDs = invocation.ChatIDs
+		telegram.Routes = nil
+	}
+	if webhook := appConfig.Global.Telegram.Webhook; webhook.Enabled && !invocation.IsAdHoc() {
+		telegram.Buttons = utils.ButtonsMarkup(webhook.Buttons)
+	}
 
-		if scope == "CLOUDFRONT" {
-			wafClientToUse = wafCfClient
-			cwClientToUse = cwCfClient // 🔑 use us-east-1 CW client
-		} else {
-			wafClientToUse = wafClient
-			cwClientToUse = cwClient
+	// Reports Telegram fails to deliver go to the SNS topic, in its region
+	if topicARN := appConfig.Global.Telegram.FallbackTopicARN; topicARN != "" && appConfig.Global.Telegram.Configured() {
+		snsCfg := shared.Config.Copy()
+		if topic, err := arn.Parse(topicARN); err == nil {
+			snsCfg.Region = topic.Region
+		}
+		snsClient := sns.NewFromConfig(snsCfg)
+		telegram.Fallback = func(ctx context.Context, report utils.Report) error {
+			return utils.PublishToSNS(ctx, snsClient, topicARN, report)
 		}
+		telegram.Fallbacks = loadFallbacks(ctx, appConfig, clients.DynamoDB.Get(""))
+	}
+
+	sendErr := utils.NotifyAll(ctx, buildNotifiers(awsCfg, appConfig, telegram), report)
+	if telegram.Fallback != nil {
+		recordFallbacks(ctx, appConfig, clients.DynamoDB.Get(""), telegram, timeParams.EndTime)
+	}
 
-		if wafMetrics, err := services.WAFMetrics(
-			ctx,
-			wafClientToUse,
-			cwClientToUse, // 🔑 now correct per scope
-			appConfig.Services.WAF.WebACLID,
-			appConfig.Services.WAF.WebACLName,
-			scope,
-			timeParamsMap,
-			accountID,
-			appConfig.Services.CloudFront.DistributionID,
-		); err != nil {
-			utils.Logger.Error("Failed to get WAF metrics", zap.Error(err))
-		} else {
-			allMetrics["waf"] = wafMetrics
+	// The archive is written even when a notifier failed, the report was still built.
+	// Ad-hoc runs would overwrite the scheduled report of the hour.
+	if archive := appConfig.Global.Archive; archive.BucketName != "" && !invocation.IsAdHoc() {
+		// Schedules are archived under their name, eg: reports/on-call/2024/06/01/07.json
+		prefix := path.Join(archive.Prefix, invocation.Schedule)
+		err := history.ArchiveReport(ctx, shared.S3, archive.BucketName, prefix, appConfig.ReportPeriod(), history.ReportArchive{
+			Timestamp:     timeParams.EndTime,
+			IsDailyReport: timeParams.IsDailyReport,
+			Rollup:        timeParams.Rollup,
+			Schedule:      invocation.Schedule,
+			Breaches:      report.Breaches,
+			Metrics:       allMetrics,
+			Message:       telegram.Render(report),
+		})
+		if err != nil {
+			utils.Logger.Error("Failed to archive report", zap.Error(err), zap.String("bucketName", archive.BucketName))
 		}
 	}
 
-	if appConfig.Services.DynamoDB.Enabled {
-		dynamoMetrics := make(map[string]any)
-		for _, tableName := range appConfig.Services.DynamoDB.TableNames {
-			tableMetrics, err := services.DynamoDBMetrics(ctx, cwClient, dynamoClient, timeParamsMap, tableName)
-			if err != nil {
-				utils.Logger.Error("Failed to get DynamoDB metrics",
-					zap.Error(err),
-					zap.String("tableName", tableName),
-				)
-				continue
-			}
-			dynamoMetrics[tableName] = tableMetrics
+	if sendErr == nil && !invocation.IsAdHoc() {
+		recordHeartbeat(ctx, appConfig, clients.DynamoDB.Get(""), timeParams.EndTime)
+	}
+
+	return errors.Join(sinkErr, sendErr)
+}
+
+// Reports sent to the SNS fallback since the last one reaching Telegram, kept
+// in memory (warm containers, daemon) when there is no history table
+var pendingFallbacks []time.Time
+
+func loadFallbacks(ctx context.Context, appConfig *config.Config, dynamoClient *dynamodb.Client) []time.Time {
+	tableName := appConfig.Global.History.TableName
+	if tableName == "" {
+		return pendingFallbacks
+	}
+
+	fallbacks, err := history.LoadFallbacks(ctx, dynamoClient, tableName)
+	if err != nil {
+		utils.Logger.Error("Failed to load fallbacks", zap.Error(err))
+	}
+	return fallbacks
+}
+
+// Sets the growth of the buckets and records their sizes for the next daily
+// reports, unless the run is ad-hoc
+func recordStorageGrowth(ctx context.Context, dynamoClient *dynamodb.Client, tableName string, buckets map[string]any, date time.Time, adHoc bool) {
+	sizes, err := history.LoadStorageSizes(ctx, dynamoClient, tableName)
+	if err != nil {
+		utils.Logger.Warn("Failed to load storage sizes", zap.Error(err), zap.String("tableName", tableName))
+		return
+	}
+
+	current := services.ApplyS3Growth(buckets, sizes, date)
+	if adHoc || len(current) == 0 {
+		return
+	}
+	if err := history.SaveStorageSizes(ctx, dynamoClient, tableName, sizes, date, current); err != nil {
+		utils.Logger.Error("Failed to save storage sizes", zap.Error(err), zap.String("tableName", tableName))
+	}
+}
+
+// Adds the report to the fallbacks when Telegram failed, clears them once a
+// report (and the note about them) reached Telegram again
+func recordFallbacks(ctx context.Context, appConfig *config.Config, dynamoClient *dynamodb.Client, telegram *utils.TelegramNotifier, timestamp time.Time) {
+	fallbacks := telegram.Fallbacks
+	switch {
+	case telegram.FellBack():
+		fallbacks = append(fallbacks, timestamp)
+	case telegram.Delivered() && len(fallbacks) > 0:
+		fallbacks = nil
+	default:
+		return
+	}
+
+	pendingFallbacks = fallbacks
+	if tableName := appConfig.Global.History.TableName; tableName != "" {
+		if err := history.SaveFallbacks(ctx, dynamoClient, tableName, fallbacks); err != nil {
+			utils.Logger.Error("Failed to save fallbacks", zap.Error(err))
 		}
-		if len(dynamoMetrics) > 0 {
-			allMetrics["dynamodb"] = dynamoMetrics
+	}
+}
+
+// Handles the Telegram updates posted to the function URL. A tapped button is
+// answered right away and its drill-down runs in an asynchronous invocation,
+// so Telegram never waits for the collection (and never retries the update).
+func telegramWebhook(ctx context.Context, request events.LambdaFunctionURLRequest) (events.LambdaFunctionURLResponse, error) {
+	awsCfg, err := loadAWSConfig(ctx)
+	if err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
+	if err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to load app config: %v", err)
+	}
+
+	// Function URL headers are lowercased
+	webhook := appConfig.Global.Telegram.Webhook
+	secretToken := request.Headers["x-telegram-bot-api-secret-token"]
+	if !webhook.Enabled || subtle.ConstantTimeCompare([]byte(secretToken), []byte(webhook.SecretToken)) != 1 {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusUnauthorized}, nil
+	}
+
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(request.Body); err != nil {
+			return events.LambdaFunctionURLResponse{StatusCode: http.StatusBadRequest}, nil
 		}
 	}
+	var update utils.TelegramUpdate
+	if err := json.Unmarshal(body, &update); err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusBadRequest}, nil
+	}
 
-	if appConfig.Services.RDS.Enabled {
-		rdsMetrics, err := services.RDSMetrics(ctx, cwClient, appConfig.Services.RDS.ClusterID, appConfig.Services.RDS.DBInstanceIdentifier, timeParamsMap)
-		if err != nil {
-			utils.Logger.Error("Failed to get RDS metrics", zap.Error(err))
-		} else {
-			allMetrics["rds"] = rdsMetrics
+	query := update.CallbackQuery
+	if query == nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	if err := appConfig.ResolveTelegramSecrets(ctx, secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("failed to resolve Telegram secrets: %v", err)
+	}
+	botToken := appConfig.Global.Telegram.BotToken
+
+	// Only the chats receiving the full report can trigger drill-downs
+	index, valid := query.ButtonIndex(webhook.Buttons)
+	chatID := query.ChatID()
+	if !valid || !slices.Contains(appConfig.Global.Telegram.ChatID, chatID) {
+		utils.Logger.Warn("Ignoring Telegram callback", zap.String("data", query.Data), zap.String("chatId", chatID))
+		if err := utils.AnswerCallbackQuery(ctx, botToken, query.ID, "Unknown action"); err != nil {
+			utils.Logger.Error("Failed to answer Telegram callback", zap.Error(err))
+		}
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusOK}, nil
+	}
+
+	button := webhook.Buttons[index]
+	payload, err := json.Marshal(button.Invocation(chatID))
+	if err != nil {
+		return events.LambdaFunctionURLResponse{StatusCode: http.StatusInternalServerError}, fmt.Errorf("error marshaling drill-down invocation: %v", err)
+	}
+
+	answer := "⏳ " + button.Text
+	_, err = lambdaService.NewFromConfig(awsCfg).Invoke(ctx, &lambdaService.InvokeInput{
+		FunctionName:   aws.String(os.Getenv("AWS_LAMBDA_FUNCTION_NAME")),
+		InvocationType: lambdaTypes.InvocationTypeEvent,
+		Payload:        payload,
+	})
+	if err != nil {
+		utils.Logger.Error("Failed to invoke drill-down", zap.Error(err), zap.String("button", button.Text))
+		answer = "Failed to run " + button.Text
+	}
+
+	if err := utils.AnswerCallbackQuery(ctx, botToken, query.ID, answer); err != nil {
+		utils.Logger.Error("Failed to answer Telegram callback", zap.Error(err))
+	}
+	return events.LambdaFunctionURLResponse{StatusCode: http.StatusOK}, nil
+}
+
+// Records that a report was produced, for the watchdog and external dead
+// man's switches (healthchecks.io, Cronitor...)
+func recordHeartbeat(ctx context.Context, appConfig *config.Config, dynamoClient *dynamodb.Client, timestamp time.Time) {
+	watchdog := appConfig.Global.Watchdog
+
+	if watchdog.MissedReports > 0 {
+		if err := history.SaveHeartbeat(ctx, dynamoClient, appConfig.Global.History.TableName, timestamp); err != nil {
+			utils.Logger.Error("Failed to save heartbeat", zap.Error(err))
+		}
+	}
+
+	if watchdog.HeartbeatURL != "" {
+		if err := pingHeartbeat(ctx, watchdog.HeartbeatURL); err != nil {
+			utils.Logger.Error("Failed to ping heartbeat URL", zap.Error(err))
 		}
 	}
+}
+
+func pingHeartbeat(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating heartbeat request: %v", err)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending heartbeat: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("heartbeat URL returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Alerts the chats when no report was produced for watchdog.missedReports
+// intervals, catching a silent scheduler or permission failures
+func watchdogCheck(ctx context.Context, awsCfg aws.Config, appConfig *config.Config) error {
+	watchdog := appConfig.Global.Watchdog
+	if watchdog.MissedReports == 0 {
+		utils.Logger.Info("Skipping watchdog check: watchdog missedReports is not set")
+		return nil
+	}
+
+	lastReport, err := history.LoadHeartbeat(ctx, dynamodb.NewFromConfig(awsCfg), appConfig.Global.History.TableName)
+	if err != nil {
+		return fmt.Errorf("failed to load heartbeat: %v", err)
+	}
+	if lastReport.IsZero() {
+		utils.Logger.Info("Skipping watchdog check: no report recorded yet")
+		return nil
+	}
 
-	message := utils.BuildMessage(appConfig, timeParams, allMetrics)
+	missed := int(time.Since(lastReport) / appConfig.ReportInterval())
+	if missed < watchdog.MissedReports {
+		return nil
+	}
 
-	err = utils.SendToTelegram(ctx, message, appConfig.Global.Telegram.BotToken, appConfig.Global.Telegram.ChatID)
+	loc, err := time.LoadLocation(appConfig.Global.Monitoring.Timezone)
 	if err != nil {
-		utils.Logger.Error("Failed to send Telegram message", zap.Error(err))
 		return err
 	}
 
+	utils.Logger.Warn("Reports missed", zap.Time("lastReport", lastReport), zap.Int("missed", missed))
+
+	section := utils.Section{Service: "watchdog", Title: "WATCHDOG"}
+	section.AddLine("No report since %s (%d missed)", lastReport.In(loc).Format("02/01/2006 15:04"), missed)
+	section.AddLine("Check the function logs, its schedule and permissions")
+
+	report := utils.Report{
+		Timestamp: time.Now().In(loc),
+		Sections:  []utils.Section{section},
+		Metrics:   map[string]any{},
+	}
+
+	telegram := &utils.TelegramNotifier{
+		BotToken:  appConfig.Global.Telegram.BotToken,
+		ChatIDs:   appConfig.Global.Telegram.ChatID,
+		ParseMode: appConfig.Global.Telegram.ParseMode,
+	}
+
+	return utils.NotifyAll(ctx, buildNotifiers(awsCfg, appConfig, telegram), report)
+}
+
+// telegraws deploy: creates or updates the function, its role and schedule
+func deployCommand(ctx context.Context) error {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to load app config: %v", err)
+	}
+
+	accountID, err := getAccountID(ctx, awsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to resolve AWS account ID: %w", err)
+	}
+
+	err = deploy.Run(ctx, awsCfg, appConfig, deploy.Target{
+		FunctionName:    deploy.FunctionName(appConfig),
+		Region:          awsCfg.Region,
+		AccountID:       accountID,
+		ConfigParameter: os.Getenv(config.ConfigParameterEnv),
+	})
+	if err != nil || !appConfig.Global.Telegram.Webhook.Enabled {
+		return err
+	}
+
+	return webhookCommand(ctx, "")
+}
+
+// telegraws generate <terraform|sam>: writes the infrastructure code and the
+// packaged function to bin/ for teams deploying through their own pipelines
+func generateCommand(ctx context.Context, format string) error {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to load app config: %v", err)
+	}
+
+	outputPath, err := deploy.Generate(ctx, appConfig, deploy.Target{
+		FunctionName:    deploy.FunctionName(appConfig),
+		ConfigParameter: os.Getenv(config.ConfigParameterEnv),
+	}, format)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Generated %s\n", outputPath)
+	return nil
+}
+
+// telegraws daemon: long-running mode for containers, schedules the reports
+// from lambdaCronExpression instead of EventBridge
+func daemonCommand(ctx context.Context) error {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to load app config: %v", err)
+	}
+
+	schedule, err := daemon.ParseCron(appConfig.Global.Deployment.LambdaCronExpression)
+	if err != nil {
+		return fmt.Errorf("invalid lambdaCronExpression: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	metricsExporter = &utils.PrometheusExporter{Labels: appConfig.Notifiers.Prometheus.Labels}
+
+	return daemon.Run(ctx, schedule, appConfig.Global.Deployment.HealthAddress, metricsExporter, func(ctx context.Context) error {
+		return logic(ctx, config.Invocation{})
+	})
+}
+
+// Registers the bot webhook: url, or the function URL of the deployed function
+func webhookCommand(ctx context.Context, url string) error {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to load SDK config: %v", err)
+	}
+
+	appConfig, err := loadAppConfig(ctx, awsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to load app config: %v", err)
+	}
+	if !appConfig.Global.Telegram.Webhook.Enabled {
+		return fmt.Errorf("telegram webhook is not enabled")
+	}
+
+	if err := appConfig.ResolveTelegramSecrets(ctx, secretsmanager.NewFromConfig(awsCfg)); err != nil {
+		return fmt.Errorf("failed to resolve Telegram secrets: %v", err)
+	}
+
+	if url == "" {
+		if url, err = deploy.FunctionURL(ctx, awsCfg, deploy.FunctionName(appConfig)); err != nil {
+			return err
+		}
+	}
+
+	if err := utils.SetTelegramWebhook(ctx, appConfig.Global.Telegram.BotToken, url, appConfig.Global.Telegram.Webhook.SecretToken); err != nil {
+		return fmt.Errorf("error setting Telegram webhook: %v", err)
+	}
+	fmt.Printf("🔗 Telegram webhook set to %s\n", url)
 	return nil
 }
 
@@ -222,12 +1165,62 @@ func main() {
 	ctx := context.Background()
 	defer utils.Logger.Sync()
 
+	if len(os.Args) > 1 && os.Args[1] == "deploy" {
+		if err := deployCommand(ctx); err != nil {
+			log.Fatalf("Deploy failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "daemon" {
+		if err := daemonCommand(ctx); err != nil {
+			log.Fatalf("Daemon failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "webhook" {
+		url := ""
+		if len(os.Args) > 2 {
+			url = os.Args[2]
+		}
+		if err := webhookCommand(ctx, url); err != nil {
+			log.Fatalf("Webhook failed: %v", err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "generate" {
+		if len(os.Args) < 3 {
+			log.Fatalf("Usage: telegraws generate <terraform|sam>")
+		}
+		if err := generateCommand(ctx, os.Args[2]); err != nil {
+			log.Fatalf("Generate failed: %v", err)
+		}
+		return
+	}
+
 	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
-		lambda.Start(func(ctx context.Context) error {
-			return logic(ctx)
+		lambda.Start(func(ctx context.Context, payload json.RawMessage) (any, error) {
+			// Telegram webhook requests come through the function URL
+			var request events.LambdaFunctionURLRequest
+			if json.Unmarshal(payload, &request) == nil && request.RequestContext.HTTP.Method != "" {
+				return telegramWebhook(ctx, request)
+			}
+
+			var invocation config.Invocation
+			if err := json.Unmarshal(payload, &invocation); err != nil {
+				return nil, fmt.Errorf("error parsing invocation: %v", err)
+			}
+
+			// Self-telemetry as EMF logs, extracted by CloudWatch as metrics
+			telemetry := utils.NewTelemetry()
+			err := logic(utils.WithTelemetry(ctx, telemetry), invocation)
+			telemetry.Flush(os.Getenv("AWS_LAMBDA_FUNCTION_NAME"), err)
+			return nil, err
 		})
 	} else {
-		if err := logic(ctx); err != nil {
+		if err := logic(ctx, config.Invocation{}); err != nil {
 			log.Printf("Error executing logic: %v", err)
 		}
 	}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"telegraws/config"
+	"telegraws/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Current state of metric and composite alarms
+type AlarmsResult struct {
+	AlarmNamePrefix  string
+	InAlarm          float64
+	InsufficientData float64
+	OK               float64
+	// Names of the alarms in ALARM state
+	FiringAlarms []string
+}
+
+func (r *AlarmsResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"InAlarm":          r.InAlarm,
+		"InsufficientData": r.InsufficientData,
+		"OK":               r.OK,
+	}
+}
+
+func (r *AlarmsResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "alarms", Title: "CloudWatch Alarms", Subtitle: r.AlarmNamePrefix}
+	section.AddLine("In Alarm: %.0f", r.InAlarm)
+	section.AddLine("Insufficient Data: %.0f", r.InsufficientData)
+	section.AddLine("OK: %.0f", r.OK)
+
+	if len(r.FiringAlarms) > 0 {
+		section.AddLine("Firing:")
+		for _, alarmName := range r.FiringAlarms {
+			section.AddLine("%s", alarmName)
+		}
+	}
+	return section
+}
+
+func AlarmsMetrics(ctx context.Context, cwClient *cloudwatch.Client, alarmNamePrefix string) (*AlarmsResult, error) {
+	input := &cloudwatch.DescribeAlarmsInput{
+		AlarmTypes: []types.AlarmType{types.AlarmTypeMetricAlarm, types.AlarmTypeCompositeAlarm},
+	}
+	if alarmNamePrefix != "" {
+		input.AlarmNamePrefix = aws.String(alarmNamePrefix)
+	}
+
+	counts := map[types.StateValue]float64{}
+	firingAlarms := []string{}
+
+	record := func(name *string, state types.StateValue) {
+		counts[state]++
+		if state == types.StateValueAlarm && name != nil {
+			firingAlarms = append(firingAlarms, *name)
+		}
+	}
+
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(cwClient, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error describing alarms: %v", err)
+		}
+
+		for _, alarm := range output.MetricAlarms {
+			record(alarm.AlarmName, alarm.StateValue)
+		}
+		for _, alarm := range output.CompositeAlarms {
+			record(alarm.AlarmName, alarm.StateValue)
+		}
+	}
+
+	sort.Strings(firingAlarms)
+
+	return &AlarmsResult{
+		AlarmNamePrefix:  alarmNamePrefix,
+		InAlarm:          counts[types.StateValueAlarm],
+		InsufficientData: counts[types.StateValueInsufficientData],
+		OK:               counts[types.StateValueOk],
+		FiringAlarms:     firingAlarms,
+	}, nil
+}
+
+type alarmsCollector struct{}
+
+func (alarmsCollector) Name() string { return "alarms" }
+
+func (alarmsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Alarms.Enabled
+}
+
+func (alarmsCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (alarmsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return AlarmsMetrics(ctx, clients.CloudWatch.Get(cfg.Services.Alarms.Region), cfg.Services.Alarms.AlarmNamePrefix)
+}
@@ -3,55 +3,167 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"go.uber.org/zap"
 )
 
-func ALBMetrics(ctx context.Context, cwClient *cloudwatch.Client, albName string, timeParams map[string]time.Time) (map[string]float64, error) {
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
+// Elastic Load Balancing flavour, both publish LoadBalancer and TargetGroup
+// dimensions in their own namespace
+type loadBalancerType struct {
+	Name       string // Cache key prefix and error label
+	Namespace  string
+	MetricName string // Always published, used to find the load balancers
+	Prefix     string // Of the LoadBalancer dimension (app/name/id)
+}
+
+var (
+	albType = loadBalancerType{Name: "ALB", Namespace: "AWS/ApplicationELB", MetricName: "RequestCount", Prefix: "app/"}
+	nlbType = loadBalancerType{Name: "NLB", Namespace: "AWS/NetworkELB", MetricName: "ActiveFlowCount", Prefix: "net/"}
+)
+
+// Resolves the full LoadBalancer dimension (app/name/id) of an ALB
+func resolveALBDimension(ctx context.Context, cwClient *cloudwatch.Client, albName string) (string, error) {
+	return resolveLoadBalancerDimension(ctx, cwClient, albType, albName)
+}
+
+func resolveLoadBalancerDimension(ctx context.Context, cwClient *cloudwatch.Client, lbType loadBalancerType, name string) (string, error) {
+	// Already the full LoadBalancer identifier
+	if strings.HasPrefix(name, lbType.Prefix) {
+		return name, nil
 	}
 
-	// If albName doesn't start with "app/", assume it's just the name and we need to find the full identifier
-	var loadBalancerDimension string
-	if strings.HasPrefix(albName, "app/") {
-		// Already the full LoadBalancer identifier
-		loadBalancerDimension = albName
-	} else {
-		// Need to find the full identifier by listing metrics
+	// Need to find the full identifier by listing metrics, cached between runs
+	return cached(strings.ToLower(lbType.Name)+"/"+cwClient.Options().Region+"/"+name, func() (string, error) {
 		listInput := &cloudwatch.ListMetricsInput{
-			Namespace:  aws.String("AWS/ApplicationELB"),
-			MetricName: aws.String("RequestCount"),
+			Namespace:  aws.String(lbType.Namespace),
+			MetricName: aws.String(lbType.MetricName),
 		}
 
 		listResult, err := cwClient.ListMetrics(ctx, listInput)
 		if err != nil {
-			return nil, fmt.Errorf("error listing ALB metrics: %v", err)
+			return "", fmt.Errorf("error listing %s metrics: %v", lbType.Name, err)
 		}
 
-		// Find the LoadBalancer dimension that contains our ALB name
+		// Find the LoadBalancer dimension that contains our load balancer name
 		for _, metric := range listResult.Metrics {
 			for _, dimension := range metric.Dimensions {
 				if *dimension.Name == "LoadBalancer" &&
-					strings.Contains(*dimension.Value, albName) {
-					loadBalancerDimension = *dimension.Value
-					break
+					strings.Contains(*dimension.Value, name) {
+					return *dimension.Value, nil
 				}
 			}
-			if loadBalancerDimension != "" {
-				break
-			}
 		}
 
-		if loadBalancerDimension == "" {
-			return nil, fmt.Errorf("could not find LoadBalancer dimension for ALB: %s", albName)
-		}
+		return "", fmt.Errorf("could not find LoadBalancer dimension for %s: %s", lbType.Name, name)
+	})
+}
+
+type ALBTargetGroup struct {
+	Name               string
+	RequestCount       float64
+	TargetResponseTime float64 // s
+	Target5XXCount     float64
+	HealthyHostCount   float64
+	UnHealthyHostCount float64
+}
+
+type ALBResult struct {
+	ALBName            string
+	RequestCount       float64
+	TargetResponseTime float64 // s
+	Target2XXCount     float64
+	Target4XXCount     float64
+	Target5XXCount     float64
+	ELB4XXCount        float64
+	ELB5XXCount        float64
+	HealthyHostCount   float64
+	UnHealthyHostCount float64
+	// Concurrent connections per load balancer node, averaged
+	ActiveConnectionCount float64
+	NewConnectionCount    float64
+	// Connections refused once the load balancer reached its maximum
+	RejectedConnectionCount        float64
+	ClientTLSNegotiationErrorCount float64
+	TargetTLSNegotiationErrorCount float64
+	// Only collected when enabled in the config, sorted by name
+	TargetGroups []ALBTargetGroup
+}
+
+// Target groups are flattened as TargetGroup_<name>_<metric>
+func (r *ALBResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"RequestCount":              r.RequestCount,
+		"TargetResponseTime":        r.TargetResponseTime,
+		"HTTPCode_Target_2XX_Count": r.Target2XXCount,
+		"HTTPCode_Target_4XX_Count": r.Target4XXCount,
+		"HTTPCode_Target_5XX_Count": r.Target5XXCount,
+		"HTTPCode_ELB_4XX_Count":    r.ELB4XXCount,
+		"HTTPCode_ELB_5XX_Count":    r.ELB5XXCount,
+		"HealthyHostCount":          r.HealthyHostCount,
+		"UnHealthyHostCount":        r.UnHealthyHostCount,
+
+		"ActiveConnectionCount":          r.ActiveConnectionCount,
+		"NewConnectionCount":             r.NewConnectionCount,
+		"RejectedConnectionCount":        r.RejectedConnectionCount,
+		"ClientTLSNegotiationErrorCount": r.ClientTLSNegotiationErrorCount,
+		"TargetTLSNegotiationErrorCount": r.TargetTLSNegotiationErrorCount,
+	}
+	for _, targetGroup := range r.TargetGroups {
+		prefix := "TargetGroup_" + targetGroup.Name + "_"
+		metrics[prefix+"RequestCount"] = targetGroup.RequestCount
+		metrics[prefix+"TargetResponseTime"] = targetGroup.TargetResponseTime
+		metrics[prefix+"HTTPCode_Target_5XX_Count"] = targetGroup.Target5XXCount
+		metrics[prefix+"HealthyHostCount"] = targetGroup.HealthyHostCount
+		metrics[prefix+"UnHealthyHostCount"] = targetGroup.UnHealthyHostCount
+	}
+	return metrics
+}
+
+func (r *ALBResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "alb", Title: "ALB", Subtitle: r.ALBName}
+	section.AddLine("Requests: %.0f%s", r.RequestCount, trend("RequestCount", r.RequestCount))
+	section.AddLine("Response Time: %.3f s", r.TargetResponseTime)
+	section.AddLine("2xx: %.0f, 4xx: %.0f, 5xx: %.0f%s",
+		r.Target2XXCount,
+		r.Target4XXCount,
+		r.Target5XXCount,
+		trend("HTTPCode_Target_5XX_Count", r.Target5XXCount))
+	section.AddLine("Healthy: %.0f, Unhealthy: %.0f", r.HealthyHostCount, r.UnHealthyHostCount)
+	section.AddLine("ALB Errors: %.0f", r.ELB4XXCount+r.ELB5XXCount)
+	section.AddLine("Connections: %.0f active, %.0f new, %.0f rejected%s",
+		r.ActiveConnectionCount,
+		r.NewConnectionCount,
+		r.RejectedConnectionCount,
+		trend("RejectedConnectionCount", r.RejectedConnectionCount))
+	section.AddLine("TLS Errors: %.0f client, %.0f target", r.ClientTLSNegotiationErrorCount, r.TargetTLSNegotiationErrorCount)
+
+	// One line per target group so a failing backend stands out
+	for _, targetGroup := range r.TargetGroups {
+		section.AddLine("TG %s: 5xx: %.0f, Response: %.3f s, Healthy: %.0f, Unhealthy: %.0f",
+			targetGroup.Name,
+			targetGroup.Target5XXCount,
+			targetGroup.TargetResponseTime,
+			targetGroup.HealthyHostCount,
+			targetGroup.UnHealthyHostCount)
+	}
+	return section
+}
+
+func ALBMetrics(ctx context.Context, cwClient *cloudwatch.Client, albName string, timeParams map[string]time.Time) (*ALBResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	loadBalancerDimension, err := resolveALBDimension(ctx, cwClient, albName)
+	if err != nil {
+		return nil, err
 	}
 
 	albMetrics := []struct {
@@ -68,48 +180,194 @@ func ALBMetrics(ctx context.Context, cwClient *cloudwatch.Client, albName string
 		{"HTTPCode_ELB_5XX_Count", "Sum", "Count"},
 		{"HealthyHostCount", "Average", "Count"},
 		{"UnHealthyHostCount", "Average", "Count"},
+		{"ActiveConnectionCount", "Average", "Count"},
+		{"NewConnectionCount", "Sum", "Count"},
+		{"RejectedConnectionCount", "Sum", "Count"},
+		{"ClientTLSNegotiationErrorCount", "Sum", "Count"},
+		{"TargetTLSNegotiationErrorCount", "Sum", "Count"},
 	}
 
+	var queries []metricQuery
 	for _, metric := range albMetrics {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/ApplicationELB"),
-			MetricName: aws.String(metric.Name),
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/ApplicationELB",
+			MetricName: metric.Name,
 			Dimensions: []types.Dimension{
 				{
 					Name:  aws.String("LoadBalancer"),
 					Value: aws.String(loadBalancerDimension),
 				},
 			},
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
+			Statistic: metric.Statistic,
+			Unit:      metric.Unit,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ALB metrics: %v", err)
+	}
+
+	return &ALBResult{
+		ALBName:            albName,
+		RequestCount:       aggregateValues("Sum", results["RequestCount"]),
+		TargetResponseTime: aggregateValues("Average", results["TargetResponseTime"]),
+		Target2XXCount:     aggregateValues("Sum", results["HTTPCode_Target_2XX_Count"]),
+		Target4XXCount:     aggregateValues("Sum", results["HTTPCode_Target_4XX_Count"]),
+		Target5XXCount:     aggregateValues("Sum", results["HTTPCode_Target_5XX_Count"]),
+		ELB4XXCount:        aggregateValues("Sum", results["HTTPCode_ELB_4XX_Count"]),
+		ELB5XXCount:        aggregateValues("Sum", results["HTTPCode_ELB_5XX_Count"]),
+		HealthyHostCount:   aggregateValues("Average", results["HealthyHostCount"]),
+		UnHealthyHostCount: aggregateValues("Average", results["UnHealthyHostCount"]),
+
+		ActiveConnectionCount:          aggregateValues("Average", results["ActiveConnectionCount"]),
+		NewConnectionCount:             aggregateValues("Sum", results["NewConnectionCount"]),
+		RejectedConnectionCount:        aggregateValues("Sum", results["RejectedConnectionCount"]),
+		ClientTLSNegotiationErrorCount: aggregateValues("Sum", results["ClientTLSNegotiationErrorCount"]),
+		TargetTLSNegotiationErrorCount: aggregateValues("Sum", results["TargetTLSNegotiationErrorCount"]),
+	}, nil
+}
+
+// Helper function to list the target groups (targetgroup/name/id) behind a
+// load balancer
+func listTargetGroupDimensions(ctx context.Context, cwClient *cloudwatch.Client, lbType loadBalancerType, loadBalancerDimension string) ([]string, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String(lbType.Namespace),
+		MetricName: aws.String("HealthyHostCount"),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String("LoadBalancer"),
+				Value: aws.String(loadBalancerDimension),
+			},
+		},
+	})
+
+	var targetGroups []string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing target group metrics: %v", err)
 		}
 
-		if metric.Unit != "" {
-			input.Unit = types.StandardUnit(metric.Unit)
+		// Skip the per-AvailabilityZone variants
+		for _, metric := range output.Metrics {
+			if len(metric.Dimensions) != 2 {
+				continue
+			}
+			for _, dimension := range metric.Dimensions {
+				if *dimension.Name == "TargetGroup" && !slices.Contains(targetGroups, *dimension.Value) {
+					targetGroups = append(targetGroups, *dimension.Value)
+				}
+			}
 		}
+	}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("error getting %s: %v", metric.Name, err)
+	return targetGroups, nil
+}
+
+// Per-target-group metrics of an ALB, sorted by target group name
+func ALBTargetGroupMetrics(ctx context.Context, cwClient *cloudwatch.Client, albName string, timeParams map[string]time.Time) ([]ALBTargetGroup, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	loadBalancerDimension, err := resolveALBDimension(ctx, cwClient, albName)
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroups, err := listTargetGroupDimensions(ctx, cwClient, albType, loadBalancerDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroupMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"RequestCount", "Sum"},
+		{"TargetResponseTime", "Average"},
+		{"HTTPCode_Target_5XX_Count", "Sum"},
+		{"HealthyHostCount", "Average"},
+		{"UnHealthyHostCount", "Average"},
+	}
+
+	var queries []metricQuery
+	for _, targetGroup := range targetGroups {
+		for _, metric := range targetGroupMetrics {
+			queries = append(queries, metricQuery{
+				Key:        targetGroup + "/" + metric.Name,
+				Namespace:  "AWS/ApplicationELB",
+				MetricName: metric.Name,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("TargetGroup"),
+						Value: aws.String(targetGroup),
+					},
+					{
+						Name:  aws.String("LoadBalancer"),
+						Value: aws.String(loadBalancerDimension),
+					},
+				},
+				Statistic: metric.Statistic,
+			})
 		}
+	}
 
-		metricKey := metric.Name
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ALB target group metrics: %v", err)
+	}
 
-		if len(result.Datapoints) > 0 {
-			var value float64
-			switch metric.Statistic {
-			case "Average":
-				value = *result.Datapoints[0].Average
-			case "Sum":
-				value = *result.Datapoints[0].Sum
-			}
-			metrics[metricKey] = value
-		} else {
-			metrics[metricKey] = 0.0
+	groups := make([]ALBTargetGroup, 0, len(targetGroups))
+	for _, targetGroup := range targetGroups {
+		prefix := targetGroup + "/"
+		groups = append(groups, ALBTargetGroup{
+			// targetgroup/name/id
+			Name:               strings.Split(targetGroup, "/")[1],
+			RequestCount:       aggregateValues("Sum", results[prefix+"RequestCount"]),
+			TargetResponseTime: aggregateValues("Average", results[prefix+"TargetResponseTime"]),
+			Target5XXCount:     aggregateValues("Sum", results[prefix+"HTTPCode_Target_5XX_Count"]),
+			HealthyHostCount:   aggregateValues("Average", results[prefix+"HealthyHostCount"]),
+			UnHealthyHostCount: aggregateValues("Average", results[prefix+"UnHealthyHostCount"]),
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups, nil
+}
+
+type albCollector struct{}
+
+func (albCollector) Name() string { return "alb" }
+
+func (albCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.ALB.Enabled
+}
+
+func (albCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.ALB.ALBNames
+}
+
+func (albCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, albName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.ALB.Region, cfg.Services.ALB.ResourceRegions, albName)
+	result, err := ALBMetrics(ctx, clients.CloudWatch.Get(region), albName, windowTimes(window))
+	if err != nil {
+		return nil, err
+	}
+
+	// The ALB section is still reported when its target groups fail
+	if cfg.Services.ALB.TargetGroups {
+		targetGroups, err := ALBTargetGroupMetrics(ctx, clients.CloudWatch.Get(region), albName, windowTimes(window))
+		if err != nil {
+			utils.Logger.Error("Failed to get ALB target group metrics",
+				zap.Error(err),
+				zap.String("albName", albName),
+			)
 		}
+		result.TargetGroups = targetGroups
 	}
 
-	return metrics, nil
+	return result, nil
 }
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Resolvers listed with their errors, the ones failing most
+const maxAppSyncResolvers = 5
+
+type AppSyncResolver struct {
+	Name   string // Type.field, eg: "Query.getUser"
+	Errors float64
+}
+
+type AppSyncResult struct {
+	APIID     string
+	Errors4xx float64
+	Errors5xx float64
+	Latency   float64 // ms
+	// Resolver errors need enhanced metrics on the API, empty without them
+	ResolverErrors float64
+	Resolvers      []AppSyncResolver
+}
+
+func (r *AppSyncResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"4XXError":     r.Errors4xx,
+		"5XXError":     r.Errors5xx,
+		"Latency":      r.Latency,
+		"GraphQLError": r.ResolverErrors,
+	}
+	for _, resolver := range r.Resolvers {
+		metrics["Resolver_"+resolver.Name+"_GraphQLError"] = resolver.Errors
+	}
+	return metrics
+}
+
+func (r *AppSyncResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "appsync", Title: "AppSync", Subtitle: r.APIID}
+	section.AddLine("4xx Errors: %.0f", r.Errors4xx)
+	section.AddLine("5xx Errors: %.0f%s", r.Errors5xx, trend("5XXError", r.Errors5xx))
+	section.AddLine("Latency: %.0f ms", r.Latency)
+	section.AddLine("Resolver Errors: %.0f", r.ResolverErrors)
+	for _, resolver := range r.Resolvers {
+		section.AddLine("%s: %.0f errors", resolver.Name, resolver.Errors)
+	}
+	return section
+}
+
+// Resolvers (Type.field) publishing enhanced metrics for the API
+func listAppSyncResolvers(ctx context.Context, cwClient *cloudwatch.Client, apiID string) ([]string, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/AppSync"),
+		MetricName: aws.String("GraphQLError"),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String("GraphQLAPIId"),
+				Value: aws.String(apiID),
+			},
+			{
+				Name: aws.String("Resolver"),
+			},
+		},
+	})
+
+	var resolvers []string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing AppSync resolver metrics: %v", err)
+		}
+		for _, metric := range output.Metrics {
+			if len(metric.Dimensions) != 2 {
+				continue
+			}
+			for _, dimension := range metric.Dimensions {
+				if aws.ToString(dimension.Name) == "Resolver" {
+					resolvers = append(resolvers, aws.ToString(dimension.Value))
+				}
+			}
+		}
+	}
+	return resolvers, nil
+}
+
+func AppSyncMetrics(ctx context.Context, cwClient *cloudwatch.Client, apiID string, timeParams map[string]time.Time) (*AppSyncResult, error) {
+	apiDimension := types.Dimension{
+		Name:  aws.String("GraphQLAPIId"),
+		Value: aws.String(apiID),
+	}
+
+	queries := []metricQuery{
+		{Key: "4XXError", Namespace: "AWS/AppSync", MetricName: "4XXError", Dimensions: []types.Dimension{apiDimension}, Statistic: "Sum"},
+		{Key: "5XXError", Namespace: "AWS/AppSync", MetricName: "5XXError", Dimensions: []types.Dimension{apiDimension}, Statistic: "Sum"},
+		{Key: "Latency", Namespace: "AWS/AppSync", MetricName: "Latency", Dimensions: []types.Dimension{apiDimension}, Statistic: "Average"},
+	}
+
+	resolvers, err := listAppSyncResolvers(ctx, cwClient, apiID)
+	if err != nil {
+		return nil, err
+	}
+	for _, resolver := range resolvers {
+		queries = append(queries, metricQuery{
+			Key:        "resolver/" + resolver,
+			Namespace:  "AWS/AppSync",
+			MetricName: "GraphQLError",
+			Dimensions: []types.Dimension{
+				apiDimension,
+				{Name: aws.String("Resolver"), Value: aws.String(resolver)},
+			},
+			Statistic: "Sum",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting AppSync metrics: %v", err)
+	}
+
+	result := &AppSyncResult{
+		APIID:     apiID,
+		Errors4xx: aggregateValues("Sum", results["4XXError"]),
+		Errors5xx: aggregateValues("Sum", results["5XXError"]),
+		Latency:   aggregateValues("Average", results["Latency"]),
+	}
+	for _, resolver := range resolvers {
+		errors := aggregateValues("Sum", results["resolver/"+resolver])
+		if errors == 0 {
+			continue
+		}
+		result.ResolverErrors += errors
+		result.Resolvers = append(result.Resolvers, AppSyncResolver{Name: resolver, Errors: errors})
+	}
+	sort.Slice(result.Resolvers, func(i, j int) bool {
+		return result.Resolvers[i].Errors > result.Resolvers[j].Errors
+	})
+	if len(result.Resolvers) > maxAppSyncResolvers {
+		result.Resolvers = result.Resolvers[:maxAppSyncResolvers]
+	}
+	return result, nil
+}
+
+type appSyncCollector struct{}
+
+func (appSyncCollector) Name() string { return "appsync" }
+
+func (appSyncCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.AppSync.Enabled
+}
+
+func (appSyncCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.AppSync.APIIDs
+}
+
+func (appSyncCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, apiID string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.AppSync.Region, cfg.Services.AppSync.ResourceRegions, apiID)
+	return AppSyncMetrics(ctx, clients.CloudWatch.Get(region), apiID, windowTimes(window))
+}
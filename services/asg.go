@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+)
+
+// Most recent scaling activities listed per group
+const maxScalingActivities = 5
+
+// Current capacity of an Auto Scaling group and its scaling activities in the window
+type ASGResult struct {
+	GroupName               string
+	GroupDesiredCapacity    float64
+	GroupMinSize            float64
+	GroupMaxSize            float64
+	GroupInServiceInstances float64
+	GroupTotalInstances     float64
+	UnhealthyInstances      float64
+	Activities              float64
+	FailedActivities        float64
+	// Most recent activities, as "description (status)"
+	ScalingActivities []string
+}
+
+func (r *ASGResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"GroupDesiredCapacity":    r.GroupDesiredCapacity,
+		"GroupMinSize":            r.GroupMinSize,
+		"GroupMaxSize":            r.GroupMaxSize,
+		"GroupInServiceInstances": r.GroupInServiceInstances,
+		"GroupTotalInstances":     r.GroupTotalInstances,
+		"UnhealthyInstances":      r.UnhealthyInstances,
+		"Activities":              r.Activities,
+		"FailedActivities":        r.FailedActivities,
+	}
+}
+
+func (r *ASGResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "asg", Title: "Auto Scaling", Subtitle: r.GroupName}
+
+	instancesLine := fmt.Sprintf("Instances: %.0f/%.0f in service (min %.0f, max %.0f)",
+		r.GroupInServiceInstances,
+		r.GroupDesiredCapacity,
+		r.GroupMinSize,
+		r.GroupMaxSize)
+	if r.GroupInServiceInstances < r.GroupDesiredCapacity {
+		instancesLine += " (BELOW DESIRED)"
+	}
+	section.AddLine("%s", instancesLine)
+	section.AddLine("Total: %.0f, Unhealthy: %.0f", r.GroupTotalInstances, r.UnhealthyInstances)
+	section.AddLine("Scaling Activities: %.0f (%.0f failed)", r.Activities, r.FailedActivities)
+	for _, activity := range r.ScalingActivities {
+		section.AddLine("%s", activity)
+	}
+	return section
+}
+
+// Capacity comes from DescribeAutoScalingGroups, so group metrics collection
+// doesn't need to be enabled
+func ASGMetrics(ctx context.Context, asgClient *autoscaling.Client, groupName string, timeParams map[string]time.Time) (*ASGResult, error) {
+	output, err := asgClient.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []string{groupName},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing Auto Scaling group: %v", err)
+	}
+	if len(output.AutoScalingGroups) == 0 {
+		return nil, fmt.Errorf("Auto Scaling group %s not found", groupName)
+	}
+
+	group := output.AutoScalingGroups[0]
+	var inService, unhealthy float64
+	for _, instance := range group.Instances {
+		if instance.LifecycleState == types.LifecycleStateInService {
+			inService++
+		}
+		if aws.ToString(instance.HealthStatus) == "Unhealthy" {
+			unhealthy++
+		}
+	}
+
+	// Newest first, stops at the first activity older than the window
+	activities := []string{}
+	var activityCount, failedActivities float64
+	input := &autoscaling.DescribeScalingActivitiesInput{
+		AutoScalingGroupName: aws.String(groupName),
+	}
+	paginator := autoscaling.NewDescribeScalingActivitiesPaginator(asgClient, input)
+pages:
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error describing scaling activities: %v", err)
+		}
+
+		for _, activity := range page.Activities {
+			if activity.StartTime == nil || activity.StartTime.Before(timeParams["startTime"]) {
+				break pages
+			}
+			activityCount++
+			if activity.StatusCode == types.ScalingActivityStatusCodeFailed {
+				failedActivities++
+			}
+			if len(activities) < maxScalingActivities {
+				activities = append(activities, fmt.Sprintf("%s (%s)", aws.ToString(activity.Description), activity.StatusCode))
+			}
+		}
+	}
+
+	return &ASGResult{
+		GroupName:               groupName,
+		GroupDesiredCapacity:    float64(aws.ToInt32(group.DesiredCapacity)),
+		GroupMinSize:            float64(aws.ToInt32(group.MinSize)),
+		GroupMaxSize:            float64(aws.ToInt32(group.MaxSize)),
+		GroupInServiceInstances: inService,
+		GroupTotalInstances:     float64(len(group.Instances)),
+		UnhealthyInstances:      unhealthy,
+		Activities:              activityCount,
+		FailedActivities:        failedActivities,
+		ScalingActivities:       activities,
+	}, nil
+}
+
+type asgCollector struct{}
+
+func (asgCollector) Name() string { return "asg" }
+
+func (asgCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.ASG.Enabled
+}
+
+func (asgCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.ASG.GroupNames
+}
+
+func (asgCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, groupName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.ASG.Region, cfg.Services.ASG.ResourceRegions, groupName)
+	return ASGMetrics(ctx, clients.AutoScaling.Get(region), groupName, windowTimes(window))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk"
+	beanstalkTypes "github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk/types"
+)
+
+type BeanstalkResult struct {
+	EnvironmentName string
+	// Current enhanced health: status (Ok, Warning, Degraded, Severe...), its
+	// color (Green, Yellow, Red, Grey) and why it isn't Ok
+	HealthStatus string
+	Color        string
+	Causes       []string
+	// Over the window, from the environment metrics published to CloudWatch
+	Requests          float64
+	Requests5xx       float64
+	InstancesSevere   float64 // Peak
+	InstancesDegraded float64 // Peak
+}
+
+func (r *BeanstalkResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"ApplicationRequestsTotal": r.Requests,
+		"ApplicationRequests5xx":   r.Requests5xx,
+		"InstancesSevere":          r.InstancesSevere,
+		"InstancesDegraded":        r.InstancesDegraded,
+	}
+}
+
+func (r *BeanstalkResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "beanstalk", Title: "Elastic Beanstalk", Subtitle: r.EnvironmentName}
+	section.AddLine("Health: %s (%s)", r.HealthStatus, r.Color)
+	for _, cause := range r.Causes {
+		section.AddLine("- %s", cause)
+	}
+	section.AddLine("Requests: %.0f, 5xx: %.0f%s", r.Requests, r.Requests5xx, trend("ApplicationRequests5xx", r.Requests5xx))
+	section.AddLine("Instances Severe: %.0f, Degraded: %.0f", r.InstancesSevere, r.InstancesDegraded)
+	return section
+}
+
+// The section icon follows the health color, Grey (unknown) has none
+func (r *BeanstalkResult) Status() string {
+	switch beanstalkTypes.EnvironmentHealth(r.Color) {
+	case beanstalkTypes.EnvironmentHealthRed:
+		return utils.StatusCritical
+	case beanstalkTypes.EnvironmentHealthYellow:
+		return utils.StatusWarning
+	case beanstalkTypes.EnvironmentHealthGreen:
+		return utils.StatusHealthy
+	}
+	return ""
+}
+
+func (r *BeanstalkResult) Failures() []string {
+	if beanstalkTypes.EnvironmentHealth(r.Color) != beanstalkTypes.EnvironmentHealthRed {
+		return nil
+	}
+	failure := fmt.Sprintf("Beanstalk %s: %s", r.EnvironmentName, r.HealthStatus)
+	if len(r.Causes) > 0 {
+		failure += ", " + r.Causes[0]
+	}
+	return []string{failure}
+}
+
+// Needs enhanced health reporting on the environment. The CloudWatch metrics
+// are only published for the ones enabled in its health configuration.
+func BeanstalkMetrics(ctx context.Context, cwClient *cloudwatch.Client, ebClient *elasticbeanstalk.Client, environmentName string, timeParams map[string]time.Time) (*BeanstalkResult, error) {
+	health, err := ebClient.DescribeEnvironmentHealth(ctx, &elasticbeanstalk.DescribeEnvironmentHealthInput{
+		EnvironmentName: aws.String(environmentName),
+		AttributeNames: []beanstalkTypes.EnvironmentHealthAttribute{
+			beanstalkTypes.EnvironmentHealthAttributeHealthStatus,
+			beanstalkTypes.EnvironmentHealthAttributeColor,
+			beanstalkTypes.EnvironmentHealthAttributeCauses,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing Beanstalk environment health: %v", err)
+	}
+
+	result := &BeanstalkResult{
+		EnvironmentName: environmentName,
+		HealthStatus:    aws.ToString(health.HealthStatus),
+		Color:           aws.ToString(health.Color),
+	}
+	for _, cause := range health.Causes {
+		result.Causes = append(result.Causes, strings.TrimSpace(cause))
+	}
+
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("EnvironmentName"),
+			Value: aws.String(environmentName),
+		},
+	}
+	results, err := getMetricData(ctx, cwClient, []metricQuery{
+		{Key: "ApplicationRequestsTotal", Namespace: "AWS/ElasticBeanstalk", MetricName: "ApplicationRequestsTotal", Dimensions: dimensions, Statistic: "Sum"},
+		{Key: "ApplicationRequests5xx", Namespace: "AWS/ElasticBeanstalk", MetricName: "ApplicationRequests5xx", Dimensions: dimensions, Statistic: "Sum"},
+		{Key: "InstancesSevere", Namespace: "AWS/ElasticBeanstalk", MetricName: "InstancesSevere", Dimensions: dimensions, Statistic: "Maximum"},
+		{Key: "InstancesDegraded", Namespace: "AWS/ElasticBeanstalk", MetricName: "InstancesDegraded", Dimensions: dimensions, Statistic: "Maximum"},
+	}, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting Beanstalk metrics: %v", err)
+	}
+
+	result.Requests = aggregateValues("Sum", results["ApplicationRequestsTotal"])
+	result.Requests5xx = aggregateValues("Sum", results["ApplicationRequests5xx"])
+	result.InstancesSevere = aggregateValues("Maximum", results["InstancesSevere"])
+	result.InstancesDegraded = aggregateValues("Maximum", results["InstancesDegraded"])
+	return result, nil
+}
+
+type beanstalkCollector struct{}
+
+func (beanstalkCollector) Name() string { return "beanstalk" }
+
+func (beanstalkCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Beanstalk.Enabled
+}
+
+func (beanstalkCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Beanstalk.EnvironmentNames
+}
+
+func (beanstalkCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, environmentName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.Beanstalk.Region, cfg.Services.Beanstalk.ResourceRegions, environmentName)
+	return BeanstalkMetrics(ctx, clients.CloudWatch.Get(region), clients.Beanstalk.Get(region), environmentName, windowTimes(window))
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+const defaultCacheTTL = time.Hour
+
+type cacheEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires"`
+}
+
+// Results of discovery calls (ALB dimensions, CWAgent disk dimensions, WAF web
+// ACLs, DynamoDB table descriptions) kept across warm invocations and
+// optionally persisted to SSM, so cold starts skip them too
+type DiscoveryCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	dirty   bool // Changed since loaded from SSM
+	loaded  bool
+}
+
+var Discovery = &DiscoveryCache{ttl: defaultCacheTTL, entries: map[string]cacheEntry{}}
+
+// ttl <= 0 keeps the default of an hour
+func (c *DiscoveryCache) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = defaultCacheTTL
+	if ttl > 0 {
+		c.ttl = ttl
+	}
+}
+
+func (c *DiscoveryCache) get(key string, value any) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, exists := c.entries[key]
+	if !exists || time.Now().After(entry.Expires) {
+		return false
+	}
+	return json.Unmarshal(entry.Value, value) == nil
+}
+
+func (c *DiscoveryCache) set(key string, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{Value: data, Expires: time.Now().Add(c.ttl)}
+	c.dirty = true
+}
+
+// Returns the cached value of key, calling load (and caching its result) when
+// missing or expired. Errors are never cached.
+func cached[T any](key string, load func() (T, error)) (T, error) {
+	var value T
+	if Discovery.get(key, &value) {
+		return value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+	Discovery.set(key, value)
+	return value, nil
+}
+
+// Loads the entries persisted in the SSM parameter, only once per container.
+// A missing parameter is an empty cache.
+func (c *DiscoveryCache) Load(ctx context.Context, ssmClient *ssm.Client, parameterName string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.loaded {
+		return nil
+	}
+
+	output, err := ssmClient.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(parameterName)})
+	var notFound *ssmTypes.ParameterNotFound
+	if errors.As(err, &notFound) {
+		c.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting cache parameter '%s': %v", parameterName, err)
+	}
+
+	entries := map[string]cacheEntry{}
+	if err := json.Unmarshal([]byte(aws.ToString(output.Parameter.Value)), &entries); err != nil {
+		return fmt.Errorf("error parsing cache parameter '%s': %v", parameterName, err)
+	}
+	// Entries cached before loading (none on a cold start) take precedence
+	for key, entry := range c.entries {
+		entries[key] = entry
+	}
+	c.entries = entries
+	c.loaded = true
+	return nil
+}
+
+// Persists the unexpired entries to the SSM parameter when they changed
+func (c *DiscoveryCache) Save(ctx context.Context, ssmClient *ssm.Client, parameterName string) error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	entries := map[string]cacheEntry{}
+	for key, entry := range c.entries {
+		if time.Now().Before(entry.Expires) {
+			entries[key] = entry
+		}
+	}
+	c.dirty = false
+	c.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("error marshaling cache: %v", err)
+	}
+
+	_, err = ssmClient.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:      aws.String(parameterName),
+		Value:     aws.String(string(data)),
+		Type:      ssmTypes.ParameterTypeString,
+		Tier:      ssmTypes.ParameterTierIntelligentTiering,
+		Overwrite: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error putting cache parameter '%s': %v", parameterName, err)
+	}
+	return nil
+}
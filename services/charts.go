@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Charts use 15 minute datapoints so spikes within the window stay visible
+const chartPeriod = 900
+
+// Time series of a single metric, oldest datapoint first
+type ChartSeries struct {
+	Title      string
+	Timestamps []time.Time
+	Values     []float64
+}
+
+// Helper function to fetch the queries as chart series, in query order
+func getChartSeries(ctx context.Context, cwClient *cloudwatch.Client, queries []metricQuery, titles map[string]string, timeParams map[string]time.Time) ([]ChartSeries, error) {
+	results, err := getMetricSeries(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], chartPeriod)
+	if err != nil {
+		return nil, err
+	}
+
+	var charts []ChartSeries
+	for _, query := range queries {
+		series := results[query.Key]
+		if len(series.Values) < 2 {
+			continue // Nothing to draw
+		}
+
+		// Datapoints are returned newest first
+		timestamps := slices.Clone(series.Timestamps)
+		values := slices.Clone(series.Values)
+		slices.Reverse(timestamps)
+		slices.Reverse(values)
+
+		charts = append(charts, ChartSeries{
+			Title:      titles[query.Key],
+			Timestamps: timestamps,
+			Values:     values,
+		})
+	}
+	return charts, nil
+}
+
+func EC2ChartSeries(ctx context.Context, cwClient *cloudwatch.Client, instanceID string, timeParams map[string]time.Time) ([]ChartSeries, error) {
+	queries := []metricQuery{
+		{
+			Key:        "CPUUtilization",
+			Namespace:  "AWS/EC2",
+			MetricName: "CPUUtilization",
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("InstanceId"),
+					Value: aws.String(instanceID),
+				},
+			},
+			Statistic: "Maximum",
+		},
+	}
+	titles := map[string]string{
+		"CPUUtilization": fmt.Sprintf("EC2 %s CPU (%%, max)", instanceID),
+	}
+
+	charts, err := getChartSeries(ctx, cwClient, queries, titles, timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error getting EC2 chart series: %v", err)
+	}
+	return charts, nil
+}
+
+func ALBChartSeries(ctx context.Context, cwClient *cloudwatch.Client, albName string, timeParams map[string]time.Time) ([]ChartSeries, error) {
+	loadBalancerDimension, err := resolveALBDimension(ctx, cwClient, albName)
+	if err != nil {
+		return nil, err
+	}
+
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("LoadBalancer"),
+			Value: aws.String(loadBalancerDimension),
+		},
+	}
+	queries := []metricQuery{
+		{
+			Key:        "RequestCount",
+			Namespace:  "AWS/ApplicationELB",
+			MetricName: "RequestCount",
+			Dimensions: dimensions,
+			Statistic:  "Sum",
+		},
+		{
+			Key:        "HTTPCode_Target_5XX_Count",
+			Namespace:  "AWS/ApplicationELB",
+			MetricName: "HTTPCode_Target_5XX_Count",
+			Dimensions: dimensions,
+			Statistic:  "Sum",
+		},
+	}
+	titles := map[string]string{
+		"RequestCount":              fmt.Sprintf("ALB %s Requests", albName),
+		"HTTPCode_Target_5XX_Count": fmt.Sprintf("ALB %s 5xx", albName),
+	}
+
+	charts, err := getChartSeries(ctx, cwClient, queries, titles, timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ALB chart series: %v", err)
+	}
+	return charts, nil
+}
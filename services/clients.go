@@ -0,0 +1,127 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/elasticbeanstalk"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/health"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/pi"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/shield"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/wafv2"
+	"github.com/aws/aws-sdk-go-v2/service/xray"
+)
+
+// CloudFront, CLOUDFRONT-scoped WAF, Cost Explorer, the Health API and Shield
+// Advanced are only available in us-east-1
+const globalRegion = "us-east-1"
+
+// AWS clients per region, created on first use. Empty region = default SDK region.
+type RegionalClients[T any] struct {
+	mu        sync.Mutex
+	awsCfg    aws.Config
+	newClient func(aws.Config) T
+	clients   map[string]T
+}
+
+func newRegionalClients[T any](awsCfg aws.Config, newClient func(aws.Config) T) *RegionalClients[T] {
+	return &RegionalClients[T]{
+		awsCfg:    awsCfg,
+		newClient: newClient,
+		clients:   make(map[string]T),
+	}
+}
+
+func (r *RegionalClients[T]) Get(region string) T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, exists := r.clients[region]; exists {
+		return client
+	}
+
+	cfg := r.awsCfg.Copy()
+	if region != "" {
+		cfg.Region = region
+	}
+	client := r.newClient(cfg)
+	r.clients[region] = client
+	return client
+}
+
+// AWS clients shared by the collectors, scoped to the region of each service
+// (or resource)
+type Clients struct {
+	AccountID     string
+	Region        string // Default SDK region, the one of the function
+	CloudWatch    *RegionalClients[*cloudwatch.Client]
+	EC2           *RegionalClients[*ec2.Client]
+	Logs          *RegionalClients[*cloudwatchlogs.Client]
+	WAF           *RegionalClients[*wafv2.Client]
+	DynamoDB      *RegionalClients[*dynamodb.Client]
+	SQS           *RegionalClients[*sqs.Client]
+	RDS           *RegionalClients[*rds.Client]
+	PI            *RegionalClients[*pi.Client]
+	AutoScaling   *RegionalClients[*autoscaling.Client]
+	StepFunctions *RegionalClients[*sfn.Client]
+	EventBridge   *RegionalClients[*eventbridge.Client]
+	GuardDuty     *RegionalClients[*guardduty.Client]
+	SecurityHub   *RegionalClients[*securityhub.Client]
+	S3            *RegionalClients[*s3.Client]
+	ECS           *RegionalClients[*ecs.Client]
+	ECR           *RegionalClients[*ecr.Client]
+	Beanstalk     *RegionalClients[*elasticbeanstalk.Client]
+	XRay          *RegionalClients[*xray.Client]
+	CostExplorer  *costexplorer.Client
+	Health        *health.Client
+	IAM           *iam.Client
+	Shield        *shield.Client
+}
+
+func NewClients(awsCfg aws.Config, accountID string) *Clients {
+	ceCfg := awsCfg.Copy()
+	ceCfg.Region = globalRegion
+
+	return &Clients{
+		AccountID:     accountID,
+		Region:        awsCfg.Region,
+		CloudWatch:    newRegionalClients(awsCfg, func(cfg aws.Config) *cloudwatch.Client { return cloudwatch.NewFromConfig(cfg) }),
+		EC2:           newRegionalClients(awsCfg, func(cfg aws.Config) *ec2.Client { return ec2.NewFromConfig(cfg) }),
+		Logs:          newRegionalClients(awsCfg, func(cfg aws.Config) *cloudwatchlogs.Client { return cloudwatchlogs.NewFromConfig(cfg) }),
+		WAF:           newRegionalClients(awsCfg, func(cfg aws.Config) *wafv2.Client { return wafv2.NewFromConfig(cfg) }),
+		DynamoDB:      newRegionalClients(awsCfg, func(cfg aws.Config) *dynamodb.Client { return dynamodb.NewFromConfig(cfg) }),
+		SQS:           newRegionalClients(awsCfg, func(cfg aws.Config) *sqs.Client { return sqs.NewFromConfig(cfg) }),
+		RDS:           newRegionalClients(awsCfg, func(cfg aws.Config) *rds.Client { return rds.NewFromConfig(cfg) }),
+		PI:            newRegionalClients(awsCfg, func(cfg aws.Config) *pi.Client { return pi.NewFromConfig(cfg) }),
+		AutoScaling:   newRegionalClients(awsCfg, func(cfg aws.Config) *autoscaling.Client { return autoscaling.NewFromConfig(cfg) }),
+		StepFunctions: newRegionalClients(awsCfg, func(cfg aws.Config) *sfn.Client { return sfn.NewFromConfig(cfg) }),
+		EventBridge:   newRegionalClients(awsCfg, func(cfg aws.Config) *eventbridge.Client { return eventbridge.NewFromConfig(cfg) }),
+		GuardDuty:     newRegionalClients(awsCfg, func(cfg aws.Config) *guardduty.Client { return guardduty.NewFromConfig(cfg) }),
+		SecurityHub:   newRegionalClients(awsCfg, func(cfg aws.Config) *securityhub.Client { return securityhub.NewFromConfig(cfg) }),
+		S3:            newRegionalClients(awsCfg, func(cfg aws.Config) *s3.Client { return s3.NewFromConfig(cfg) }),
+		ECS:           newRegionalClients(awsCfg, func(cfg aws.Config) *ecs.Client { return ecs.NewFromConfig(cfg) }),
+		ECR:           newRegionalClients(awsCfg, func(cfg aws.Config) *ecr.Client { return ecr.NewFromConfig(cfg) }),
+		Beanstalk:     newRegionalClients(awsCfg, func(cfg aws.Config) *elasticbeanstalk.Client { return elasticbeanstalk.NewFromConfig(cfg) }),
+		XRay:          newRegionalClients(awsCfg, func(cfg aws.Config) *xray.Client { return xray.NewFromConfig(cfg) }),
+		CostExplorer:  costexplorer.NewFromConfig(ceCfg),
+		Health:        health.NewFromConfig(ceCfg),
+		IAM:           iam.NewFromConfig(awsCfg),
+		Shield:        shield.NewFromConfig(ceCfg),
+	}
+}
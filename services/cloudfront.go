@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,12 +12,37 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
-func CloudFrontMetrics(ctx context.Context, cwClient *cloudwatch.Client, distributionID string, timeParams map[string]time.Time) (map[string]float64, error) {
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
+type CloudFrontResult struct {
+	DistributionID  string
+	Requests        float64
+	ErrorRate4xx    float64 // %
+	ErrorRate5xx    float64 // %
+	BytesUploaded   float64 // MB
+	BytesDownloaded float64 // MB
+}
+
+func (r *CloudFrontResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Requests":        r.Requests,
+		"4xxErrorRate":    r.ErrorRate4xx,
+		"5xxErrorRate":    r.ErrorRate5xx,
+		"BytesUploaded":   r.BytesUploaded,
+		"BytesDownloaded": r.BytesDownloaded,
 	}
+}
+
+func (r *CloudFrontResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cloudfront", Title: "CloudFront", Subtitle: r.DistributionID}
+	section.AddLine("Requests: %.0f%s", r.Requests, trend("Requests", r.Requests))
+	section.AddLine("4xx Error Rate: %.2f%%", r.ErrorRate4xx)
+	section.AddLine("5xx Error Rate: %.2f%%", r.ErrorRate5xx)
+	section.AddLine("Uploaded: %.2f MB", r.BytesUploaded)
+	section.AddLine("Downloaded: %.2f MB", r.BytesDownloaded)
+	return section
+}
+
+func CloudFrontMetrics(ctx context.Context, cwClient *cloudwatch.Client, distributionID string, timeParams map[string]time.Time) (*CloudFrontResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
 
 	cloudFrontMetrics := []struct {
 		Name      string
@@ -29,49 +56,47 @@ func CloudFrontMetrics(ctx context.Context, cwClient *cloudwatch.Client, distrib
 		{"BytesDownloaded", "Sum", "Bytes"},
 	}
 
+	var queries []metricQuery
 	for _, metric := range cloudFrontMetrics {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/CloudFront"),
-			MetricName: aws.String(metric.Name),
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/CloudFront",
+			MetricName: metric.Name,
 			Dimensions: []types.Dimension{
 				{Name: aws.String("DistributionId"), Value: aws.String(distributionID)},
 				{Name: aws.String("Region"), Value: aws.String("Global")},
 			},
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
-		}
+			Statistic: metric.Statistic,
+		})
+	}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("error getting %s: %v", metric.Name, err)
-		}
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting CloudFront metrics: %v", err)
+	}
+
+	return &CloudFrontResult{
+		DistributionID:  distributionID,
+		Requests:        aggregateValues("Sum", results["Requests"]),
+		ErrorRate4xx:    aggregateValues("Average", results["4xxErrorRate"]),
+		ErrorRate5xx:    aggregateValues("Average", results["5xxErrorRate"]),
+		BytesUploaded:   aggregateValues("Sum", results["BytesUploaded"]) / (1024.0 * 1024.0), // MB
+		BytesDownloaded: aggregateValues("Sum", results["BytesDownloaded"]) / (1024.0 * 1024.0),
+	}, nil
+}
 
-		if len(result.Datapoints) > 0 {
-			var value float64
-			switch metric.Statistic {
-			case "Average":
-				var sum float64
-				for _, dp := range result.Datapoints {
-					sum += *dp.Average
-				}
-				value = sum / float64(len(result.Datapoints))
-			case "Sum":
-				for _, dp := range result.Datapoints {
-					value += *dp.Sum
-				}
-				if metric.Name == "BytesDownloaded" || metric.Name == "BytesUploaded" {
-					value = value / (1024.0 * 1024.0) // MB
-				}
-			}
+type cloudFrontCollector struct{}
 
-			metrics[metric.Name] = value
-		} else {
-			metrics[metric.Name] = 0.0
-		}
+func (cloudFrontCollector) Name() string { return "cloudfront" }
 
-	}
+func (cloudFrontCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.CloudFront.Enabled
+}
+
+func (cloudFrontCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
 
-	return metrics, nil
+func (cloudFrontCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return CloudFrontMetrics(ctx, clients.CloudWatch.Get(globalRegion), cfg.Services.CloudFront.DistributionID, windowTimes(window))
 }
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// Categories of notable events, in the order they are listed
+const (
+	cloudTrailRootUsage            = "Root Usage"
+	cloudTrailLoginFailures        = "Console Login Failures"
+	cloudTrailPolicyChanges        = "IAM Policy Changes"
+	cloudTrailSecurityGroupChanges = "Security Group Ingress Changes"
+)
+
+var cloudTrailCategories = []string{
+	cloudTrailRootUsage,
+	cloudTrailLoginFailures,
+	cloudTrailPolicyChanges,
+	cloudTrailSecurityGroupChanges,
+}
+
+// Same events as the CIS benchmark CloudTrail metric filters
+var cloudTrailPolicyEvents = []string{
+	"PutGroupPolicy", "PutRolePolicy", "PutUserPolicy",
+	"DeleteGroupPolicy", "DeleteRolePolicy", "DeleteUserPolicy",
+	"CreatePolicy", "DeletePolicy", "CreatePolicyVersion", "DeletePolicyVersion", "SetDefaultPolicyVersion",
+	"AttachGroupPolicy", "AttachRolePolicy", "AttachUserPolicy",
+	"DetachGroupPolicy", "DetachRolePolicy", "DetachUserPolicy",
+}
+
+var cloudTrailSecurityGroupEvents = []string{
+	"AuthorizeSecurityGroupIngress",
+	"RevokeSecurityGroupIngress",
+	"ModifySecurityGroupRules",
+}
+
+// Events sharing a name, principal and source address
+type CloudTrailEvent struct {
+	Category  string
+	EventName string
+	Principal string // ARN, or user name for failed logins
+	SourceIP  string
+	Count     float64
+}
+
+type CloudTrailResult struct {
+	LogGroupName string
+	Counts       map[string]float64 // By category
+	Events       []CloudTrailEvent  // By category, then most frequent
+}
+
+func (r *CloudTrailResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"RootUsage":            r.Counts[cloudTrailRootUsage],
+		"ConsoleLoginFailures": r.Counts[cloudTrailLoginFailures],
+		"IAMPolicyChanges":     r.Counts[cloudTrailPolicyChanges],
+		"SecurityGroupChanges": r.Counts[cloudTrailSecurityGroupChanges],
+	}
+}
+
+func (r *CloudTrailResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cloudtrail", Title: "CloudTrail", Subtitle: r.LogGroupName}
+	for _, category := range cloudTrailCategories {
+		section.AddLine("%s: %.0f", category, r.Counts[category])
+	}
+
+	if len(r.Events) > 0 {
+		section.AddLine("Events:")
+		for _, event := range r.Events {
+			section.AddLine("%s by %s from %s (x%.0f)", event.EventName, event.Principal, event.SourceIP, event.Count)
+		}
+	}
+	return section
+}
+
+func (r *CloudTrailResult) Failures() []string {
+	if r.Counts[cloudTrailRootUsage] == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("CloudTrail: root account used in %.0f events", r.Counts[cloudTrailRootUsage])}
+}
+
+func quotedEventNames(eventNames []string) string {
+	quoted := make([]string, len(eventNames))
+	for i, eventName := range eventNames {
+		quoted[i] = `"` + eventName + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// Runs one Logs Insights query against the log group the trail delivers to.
+// Root usage leaves out the calls AWS services make on behalf of the root user.
+func CloudTrailMetrics(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, maxEvents int, timeParams map[string]time.Time) (*CloudTrailResult, error) {
+	query := fmt.Sprintf(`filter (userIdentity.type = "Root" and not ispresent(userIdentity.invokedBy) and eventType != "AwsServiceEvent")
+	or (eventName = "ConsoleLogin" and responseElements.ConsoleLogin = "Failure")
+	or eventName in [%s]
+	or eventName in [%s]
+| fields coalesce(userIdentity.arn, userIdentity.userName) as principal, userIdentity.type as identityType, responseElements.ConsoleLogin as loginResult
+| stats count(*) as events by eventName, identityType, loginResult, principal, sourceIPAddress
+| sort events desc`, quotedEventNames(cloudTrailPolicyEvents), quotedEventNames(cloudTrailSecurityGroupEvents))
+
+	rows, err := runInsightsQuery(ctx, logsClient, logGroupName, query, timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error querying CloudTrail events: %v", err)
+	}
+
+	result := &CloudTrailResult{LogGroupName: logGroupName, Counts: map[string]float64{}}
+	for _, row := range rows {
+		event := CloudTrailEvent{
+			EventName: row["eventName"],
+			Principal: row["principal"],
+			SourceIP:  row["sourceIPAddress"],
+			Count:     parseInsightsFloat(row["events"]),
+		}
+		switch {
+		case row["loginResult"] == "Failure":
+			event.Category = cloudTrailLoginFailures
+		case row["identityType"] == "Root":
+			event.Category = cloudTrailRootUsage
+		case slices.Contains(cloudTrailPolicyEvents, event.EventName):
+			event.Category = cloudTrailPolicyChanges
+		default:
+			event.Category = cloudTrailSecurityGroupChanges
+		}
+		result.Counts[event.Category] += event.Count
+		result.Events = append(result.Events, event)
+	}
+
+	sort.SliceStable(result.Events, func(i, j int) bool {
+		return slices.Index(cloudTrailCategories, result.Events[i].Category) < slices.Index(cloudTrailCategories, result.Events[j].Category)
+	})
+	if len(result.Events) > maxEvents {
+		result.Events = result.Events[:maxEvents]
+	}
+	return result, nil
+}
+
+type cloudTrailCollector struct{}
+
+func (cloudTrailCollector) Name() string { return "cloudtrail" }
+
+func (cloudTrailCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.CloudTrail.Enabled
+}
+
+func (cloudTrailCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (cloudTrailCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	maxEvents := cfg.Services.CloudTrail.MaxEvents
+	if maxEvents == 0 {
+		maxEvents = 10
+	}
+
+	return CloudTrailMetrics(ctx, clients.Logs.Get(cfg.Services.CloudTrail.Region), cfg.Services.CloudTrail.LogGroupName, maxEvents, windowTimes(window))
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Request metrics are published per app client (and identity provider), risk
+// metrics per operation and risk level, so each is summed over its variants
+var cognitoMetrics = []struct {
+	Name      string
+	Dimension string // Dimension holding the user pool ID
+}{
+	{"SignUpSuccesses", "UserPool"},
+	{"SignInSuccesses", "UserPool"},
+	{"TokenRefreshSuccesses", "UserPool"},
+	{"FederationSuccesses", "UserPool"},
+	{"CompromisedCredentialsRisk", "UserPoolId"},
+	{"AccountTakeOverRisk", "UserPoolId"},
+}
+
+// Cognito publishes successes as a 0/1 per request: the sum is the successful
+// requests and the sample count all of them
+type CognitoRequests struct {
+	Successes float64
+	Requests  float64
+}
+
+func (r CognitoRequests) Failures() float64 {
+	return r.Requests - r.Successes
+}
+
+type CognitoResult struct {
+	UserPoolID     string
+	SignUps        CognitoRequests
+	SignIns        CognitoRequests
+	TokenRefreshes CognitoRequests
+	Federations    CognitoRequests
+	// Risk events of advanced security (threat protection), 0 without it
+	CompromisedCredentials float64
+	AccountTakeOver        float64
+}
+
+func (r *CognitoResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"SignUpSuccesses":            r.SignUps.Successes,
+		"SignInSuccesses":            r.SignIns.Successes,
+		"SignInFailures":             r.SignIns.Failures(),
+		"TokenRefreshSuccesses":      r.TokenRefreshes.Successes,
+		"TokenRefreshFailures":       r.TokenRefreshes.Failures(),
+		"FederationSuccesses":        r.Federations.Successes,
+		"CompromisedCredentialsRisk": r.CompromisedCredentials,
+		"AccountTakeOverRisk":        r.AccountTakeOver,
+	}
+}
+
+func (r *CognitoResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cognito", Title: "Cognito", Subtitle: r.UserPoolID}
+	section.AddLine("Sign-ups: %.0f%s", r.SignUps.Successes, trend("SignUpSuccesses", r.SignUps.Successes))
+	section.AddLine("Sign-ins: %.0f (%.0f failed)%s", r.SignIns.Successes, r.SignIns.Failures(), trend("SignInSuccesses", r.SignIns.Successes))
+	section.AddLine("Federation Sign-ins: %.0f", r.Federations.Successes)
+	section.AddLine("Token Refresh Failures: %.0f of %.0f", r.TokenRefreshes.Failures(), r.TokenRefreshes.Requests)
+	section.AddLine("Risk Events: %.0f compromised credentials, %.0f account takeover", r.CompromisedCredentials, r.AccountTakeOver)
+	return section
+}
+
+// Dimension sets a metric is published with for the user pool
+func listCognitoMetrics(ctx context.Context, cwClient *cloudwatch.Client, metricName string, dimensionName string, userPoolID string) ([][]types.Dimension, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/Cognito"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String(dimensionName),
+				Value: aws.String(userPoolID),
+			},
+		},
+	})
+
+	var dimensionSets [][]types.Dimension
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Cognito %s metrics: %v", metricName, err)
+		}
+		for _, metric := range output.Metrics {
+			dimensionSets = append(dimensionSets, metric.Dimensions)
+		}
+	}
+	return dimensionSets, nil
+}
+
+func CognitoMetrics(ctx context.Context, cwClient *cloudwatch.Client, userPoolID string, timeParams map[string]time.Time) (*CognitoResult, error) {
+	var queries []metricQuery
+	for _, metric := range cognitoMetrics {
+		dimensionSets, err := listCognitoMetrics(ctx, cwClient, metric.Name, metric.Dimension, userPoolID)
+		if err != nil {
+			return nil, err
+		}
+		for i, dimensions := range dimensionSets {
+			for _, statistic := range []string{"Sum", "SampleCount"} {
+				queries = append(queries, metricQuery{
+					Key:        fmt.Sprintf("%s/%d/%s", metric.Name, i, statistic),
+					Namespace:  "AWS/Cognito",
+					MetricName: metric.Name,
+					Dimensions: dimensions,
+					Statistic:  statistic,
+				})
+			}
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting Cognito metrics: %v", err)
+	}
+
+	requests := map[string]CognitoRequests{}
+	for _, query := range queries {
+		total := requests[query.MetricName]
+		switch query.Statistic {
+		case "Sum":
+			total.Successes += aggregateValues("Sum", results[query.Key])
+		case "SampleCount":
+			total.Requests += aggregateValues("SampleCount", results[query.Key])
+		}
+		requests[query.MetricName] = total
+	}
+
+	return &CognitoResult{
+		UserPoolID:             userPoolID,
+		SignUps:                requests["SignUpSuccesses"],
+		SignIns:                requests["SignInSuccesses"],
+		TokenRefreshes:         requests["TokenRefreshSuccesses"],
+		Federations:            requests["FederationSuccesses"],
+		CompromisedCredentials: requests["CompromisedCredentialsRisk"].Successes,
+		AccountTakeOver:        requests["AccountTakeOverRisk"].Successes,
+	}, nil
+}
+
+type cognitoCollector struct{}
+
+func (cognitoCollector) Name() string { return "cognito" }
+
+func (cognitoCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Cognito.Enabled && window.IsDailyReport
+}
+
+func (cognitoCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Cognito.UserPoolIDs
+}
+
+func (cognitoCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, userPoolID string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.Cognito.Region, cfg.Services.Cognito.ResourceRegions, userPoolID)
+	return CognitoMetrics(ctx, clients.CloudWatch.Get(region), userPoolID, windowTimes(window))
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+)
+
+// A monitored AWS service. Services with several resources (tables, queues...)
+// are collected one resource at a time so they run concurrently.
+type Collector interface {
+	// Metrics key of the service, also used by thresholds, routes and the history
+	Name() string
+	Enabled(cfg *config.Config, window *config.TimeParams) bool
+	// Resources in report order, nil when the service is collected as a whole
+	Resources(cfg *config.Config) []string
+	// Collects the service, or one of its resources
+	Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, resource string) (utils.Result, error)
+}
+
+// Registered collectors, in report order. Adding a service only takes its
+// collector here, main and the report iterate this list.
+var Collectors = []Collector{
+	ec2Collector{},
+	cwAgentCollector{},
+	s3Collector{},
+	s3AuditCollector{},
+	storageLensCollector{},
+	albCollector{},
+	nlbCollector{},
+	cloudFrontCollector{},
+	route53Collector{},
+	uptimeCollector{},
+	tlsCollector{},
+	dynamoDBCollector{},
+	rdsCollector{},
+	rdsQueriesCollector{},
+	wafCollector{},
+	vpcFlowLogsCollector{},
+	vpnCollector{},
+	transitGatewayCollector{},
+	lambdaCollector{},
+	appSyncCollector{},
+	sqsCollector{},
+	ecsCollector{},
+	ecrCollector{},
+	eksCollector{},
+	beanstalkCollector{},
+	elastiCacheCollector{},
+	openSearchCollector{},
+	asgCollector{},
+	sesCollector{},
+	stepFunctionsCollector{},
+	kinesisCollector{},
+	mskCollector{},
+	eventBridgeCollector{},
+	guardDutyCollector{},
+	securityHubCollector{},
+	cloudTrailCollector{},
+	shieldCollector{},
+	iamCollector{},
+	cognitoCollector{},
+	xrayCollector{},
+	healthCollector{},
+	customMetricsCollector{},
+	alarmsCollector{},
+	costCollector{},
+	cwLogsCollector{},
+}
+
+// Names service lists can use: the collectors, and "discovered" for the
+// resources found by tag discovery
+func MetricsKeys() []string {
+	keys := make([]string, 0, len(Collectors)+1)
+	for _, collector := range Collectors {
+		keys = append(keys, collector.Name())
+	}
+	return append(keys, "discovered")
+}
+
+// Start and end of the window, as taken by the metric functions
+func windowTimes(window *config.TimeParams) map[string]time.Time {
+	return map[string]time.Time{
+		"startTime": window.StartTime,
+		"endTime":   window.EndTime,
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+const costMetric = "UnblendedCost"
+
+// Cost Explorer dates are UTC days, end date exclusive
+func costDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// Helper function to sum the total cost of the returned periods
+func sumCost(results []types.ResultByTime) (float64, string) {
+	var total float64
+	var unit string
+	for _, result := range results {
+		if metric, exists := result.Total[costMetric]; exists && metric.Amount != nil {
+			amount, _ := strconv.ParseFloat(*metric.Amount, 64)
+			total += amount
+			if metric.Unit != nil {
+				unit = *metric.Unit
+			}
+		}
+	}
+	return total, unit
+}
+
+type ServiceCost struct {
+	Service string
+	Cost    float64
+}
+
+// Yesterday's spend, month-to-date spend and yesterday's top services by cost
+type CostResult struct {
+	Yesterday   float64
+	MonthToDate float64
+	Currency    string
+	TopServices []ServiceCost
+}
+
+func (r *CostResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Yesterday":   r.Yesterday,
+		"MonthToDate": r.MonthToDate,
+	}
+}
+
+func (r *CostResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cost", Title: "Cost"}
+	section.AddLine("Yesterday: %.2f %s%s", r.Yesterday, r.Currency, trend("Yesterday", r.Yesterday))
+	section.AddLine("Month to Date: %.2f %s", r.MonthToDate, r.Currency)
+
+	if len(r.TopServices) > 0 {
+		section.AddLine("Top Services:")
+		for _, service := range r.TopServices {
+			section.AddLine("%s: %.2f %s", service.Service, service.Cost, r.Currency)
+		}
+	}
+	return section
+}
+
+func CostMetrics(ctx context.Context, ceClient *costexplorer.Client, topServices int, timeParams map[string]time.Time) (*CostResult, error) {
+	today := timeParams["endTime"].UTC().Truncate(24 * time.Hour)
+	yesterday := today.AddDate(0, 0, -1)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	yesterdayOutput, err := ceClient.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: aws.String(costDate(yesterday)),
+			End:   aws.String(costDate(today)),
+		},
+		Granularity: types.GranularityDaily,
+		Metrics:     []string{costMetric},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting yesterday's cost: %v", err)
+	}
+	yesterdayCost, currency := sumCost(yesterdayOutput.ResultsByTime)
+
+	// Includes today's partial (estimated) spend, so it is never an empty interval on the 1st
+	monthOutput, err := ceClient.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: aws.String(costDate(monthStart)),
+			End:   aws.String(costDate(today.AddDate(0, 0, 1))),
+		},
+		Granularity: types.GranularityMonthly,
+		Metrics:     []string{costMetric},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting month-to-date cost: %v", err)
+	}
+	monthToDateCost, _ := sumCost(monthOutput.ResultsByTime)
+
+	servicesOutput, err := ceClient.GetCostAndUsage(ctx, &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: aws.String(costDate(yesterday)),
+			End:   aws.String(costDate(today)),
+		},
+		Granularity: types.GranularityDaily,
+		Metrics:     []string{costMetric},
+		GroupBy: []types.GroupDefinition{
+			{
+				Type: types.GroupDefinitionTypeDimension,
+				Key:  aws.String("SERVICE"),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting cost by service: %v", err)
+	}
+
+	var serviceCosts []ServiceCost
+	for _, result := range servicesOutput.ResultsByTime {
+		for _, group := range result.Groups {
+			metric, exists := group.Metrics[costMetric]
+			if !exists || metric.Amount == nil || len(group.Keys) == 0 {
+				continue
+			}
+			amount, _ := strconv.ParseFloat(*metric.Amount, 64)
+			if amount <= 0 {
+				continue
+			}
+			serviceCosts = append(serviceCosts, ServiceCost{
+				Service: group.Keys[0],
+				Cost:    amount,
+			})
+		}
+	}
+
+	sort.SliceStable(serviceCosts, func(i, j int) bool {
+		return serviceCosts[i].Cost > serviceCosts[j].Cost
+	})
+	if len(serviceCosts) > topServices {
+		serviceCosts = serviceCosts[:topServices]
+	}
+
+	return &CostResult{
+		Yesterday:   yesterdayCost,
+		MonthToDate: monthToDateCost,
+		Currency:    currency,
+		TopServices: serviceCosts,
+	}, nil
+}
+
+type costCollector struct{}
+
+func (costCollector) Name() string { return "cost" }
+
+func (costCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Cost.Enabled && window.IsDailyReport
+}
+
+func (costCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (costCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	topServices := cfg.Services.Cost.TopServices
+	if topServices == 0 {
+		topServices = 5
+	}
+
+	return CostMetrics(ctx, clients.CostExplorer, topServices, windowTimes(window))
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Any CloudWatch metric declared in the config, eg: application-emitted metrics
+type CustomMetricResult struct {
+	Metric     config.CustomMetricConfig
+	Value      float64
+	Datapoints float64 // 0 = no data in the window
+}
+
+func (r *CustomMetricResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Value":      r.Value,
+		"Datapoints": r.Datapoints,
+	}
+}
+
+// All metrics share the "Custom Metrics" section, one line each
+func (r *CustomMetricResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "customMetrics", Title: "Custom Metrics"}
+	if r.Datapoints == 0 {
+		section.AddLine("%s: no data", r.Metric.Label)
+		return section
+	}
+
+	unit := ""
+	if r.Metric.Unit != "" {
+		unit = " " + r.Metric.Unit
+	}
+	section.AddLine("%s: %.2f%s (%s)%s", r.Metric.Label, r.Value, unit, strings.ToLower(r.Metric.Statistic), trend("Value", r.Value))
+	return section
+}
+
+func CustomMetric(ctx context.Context, cwClient *cloudwatch.Client, metric config.CustomMetricConfig, timeParams map[string]time.Time) (*CustomMetricResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	// Sorted so the query is the same on every run
+	names := make([]string, 0, len(metric.Dimensions))
+	for name := range metric.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var metricDimensions []types.Dimension
+	for _, name := range names {
+		metricDimensions = append(metricDimensions, types.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(metric.Dimensions[name]),
+		})
+	}
+
+	queries := []metricQuery{
+		{
+			Key:        "Value",
+			Namespace:  metric.Namespace,
+			MetricName: metric.MetricName,
+			Dimensions: metricDimensions,
+			Statistic:  metric.Statistic,
+		},
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting custom metric %s/%s: %v", metric.Namespace, metric.MetricName, err)
+	}
+
+	return &CustomMetricResult{
+		Metric:     metric,
+		Value:      aggregateValues(metric.Statistic, results["Value"]),
+		Datapoints: float64(len(results["Value"])),
+	}, nil
+}
+
+type customMetricsCollector struct{}
+
+func (customMetricsCollector) Name() string { return "customMetrics" }
+
+func (customMetricsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.CustomMetrics.Enabled
+}
+
+func (customMetricsCollector) Resources(cfg *config.Config) []string {
+	labels := make([]string, 0, len(cfg.Services.CustomMetrics.Metrics))
+	for _, metric := range cfg.Services.CustomMetrics.Metrics {
+		labels = append(labels, metric.Label)
+	}
+	return labels
+}
+
+func (customMetricsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, label string) (utils.Result, error) {
+	index := slices.IndexFunc(cfg.Services.CustomMetrics.Metrics, func(metric config.CustomMetricConfig) bool {
+		return metric.Label == label
+	})
+
+	region := config.ResourceRegion(cfg.Services.CustomMetrics.Region, cfg.Services.CustomMetrics.ResourceRegions, label)
+	return CustomMetric(ctx, clients.CloudWatch.Get(region), cfg.Services.CustomMetrics.Metrics[index], windowTimes(window))
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"context"
 	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,50 +12,64 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
-func CWAgentMetrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID string, timeParams map[string]time.Time) (map[string]float64, error) {
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
-	}
+// Usage of a process monitored by the procstat plugin of the agent
+type CWAgentProcess struct {
+	Name     string
+	CPU      float64 // % of one core, average
+	MemoryMB float64 // Resident set size, average
+	NoData   bool    // Not reported by the agent (not running or not configured)
+}
 
-	// Memory metrics (average and maximum)
-	memMetrics := []string{"Average", "Maximum"}
-	for _, stat := range memMetrics {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("CWAgent"),
-			MetricName: aws.String("mem_used_percent"),
-			Dimensions: []types.Dimension{
-				{
-					Name:  aws.String("InstanceId"),
-					Value: aws.String(instanceID),
-				},
-			},
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(stat)},
-		}
+type CWAgentResult struct {
+	InstanceID    string
+	MemoryAverage float64 // %
+	MemoryMaximum float64 // %
+	SwapUsed      float64 // %
+	DiskUsed      float64 // % of the root volume
+	InodesUsed    float64 // % of the root volume, 0 without inode metrics
+	Processes     []CWAgentProcess
+}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("error getting mem_used_percent (%s): %v", stat, err)
+func (r *CWAgentResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"mem_used_percent_Average": r.MemoryAverage,
+		"mem_used_percent_Maximum": r.MemoryMaximum,
+		"swap_used_percent":        r.SwapUsed,
+		"disk_used_percent":        r.DiskUsed,
+		"disk_inodes_used_percent": r.InodesUsed,
+	}
+	for _, process := range r.Processes {
+		if process.NoData {
+			continue
 		}
+		metrics["procstat_cpu_usage_"+process.Name] = process.CPU
+		metrics["procstat_memory_rss_"+process.Name] = process.MemoryMB
+	}
+	return metrics
+}
 
-		metricKey := fmt.Sprintf("mem_used_percent_%s", stat)
-		if len(result.Datapoints) > 0 {
-			if stat == "Average" {
-				metrics[metricKey] = *result.Datapoints[0].Average
-			} else {
-				metrics[metricKey] = *result.Datapoints[0].Maximum
-			}
-		} else {
-			metrics[metricKey] = 0.0
+func (r *CWAgentResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cloudwatchAgent", Title: "CloudWatch Agent", Subtitle: r.InstanceID}
+	section.AddLine("Memory: %.2f%% (avg), %.2f%% (max)", r.MemoryAverage, r.MemoryMaximum)
+	section.AddLine("Swap: %.2f%%", r.SwapUsed)
+	section.AddLine("Disk: %.2f%% (inodes %.2f%%)", r.DiskUsed, r.InodesUsed)
+	for _, process := range r.Processes {
+		if process.NoData {
+			section.AddLine("%s: NO DATA", process.Name)
+			continue
 		}
+		section.AddLine("%s: CPU %.2f%%, Memory %.2f MB", process.Name, process.CPU, process.MemoryMB)
 	}
+	return section
+}
 
-	// Disk metrics (with proper dimensions)
-	// First, discover the device and fstype dimensions
+// Device and fstype dimensions of the root disk, empty when not reported
+type cwAgentDisk struct {
+	Device string
+	FSType string
+}
+
+func discoverDiskDimensions(ctx context.Context, cwClient *cloudwatch.Client, instanceID string) (cwAgentDisk, error) {
 	listInput := &cloudwatch.ListMetricsInput{
 		Namespace:  aws.String("CWAgent"),
 		MetricName: aws.String("disk_used_percent"),
@@ -71,7 +87,7 @@ func CWAgentMetrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID
 
 	listResult, err := cwClient.ListMetrics(ctx, listInput)
 	if err != nil {
-		return nil, fmt.Errorf("error listing disk metrics: %v", err)
+		return cwAgentDisk{}, fmt.Errorf("error listing disk metrics: %v", err)
 	}
 
 	var device, fstype string
@@ -110,44 +126,183 @@ func CWAgentMetrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID
 		}
 	}
 
-	// Get disk_used_percent metric with the discovered dimensions
-	diskInput := &cloudwatch.GetMetricStatisticsInput{
+	return cwAgentDisk{Device: device, FSType: fstype}, nil
+}
+
+// Dimensions of the procstat metrics of a process, matched by the exe, pattern
+// or process_name the agent reports it with. Empty when not reported.
+func discoverProcessDimensions(ctx context.Context, cwClient *cloudwatch.Client, instanceID string, processName string) (map[string]string, error) {
+	listResult, err := cwClient.ListMetrics(ctx, &cloudwatch.ListMetricsInput{
 		Namespace:  aws.String("CWAgent"),
-		MetricName: aws.String("disk_used_percent"),
-		Dimensions: []types.Dimension{
+		MetricName: aws.String("procstat_cpu_usage"),
+		Dimensions: []types.DimensionFilter{
 			{
 				Name:  aws.String("InstanceId"),
 				Value: aws.String(instanceID),
 			},
-			{
-				Name:  aws.String("path"),
-				Value: aws.String("/"),
-			},
-			{
-				Name:  aws.String("device"),
-				Value: aws.String(device),
-			},
-			{
-				Name:  aws.String("fstype"),
-				Value: aws.String(fstype),
-			},
 		},
-		StartTime:  aws.Time(timeParams["startTime"]),
-		EndTime:    aws.Time(timeParams["endTime"]),
-		Period:     period,
-		Statistics: []types.Statistic{types.Statistic("Average")},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing procstat metrics: %v", err)
 	}
 
-	diskResult, err := cwClient.GetMetricStatistics(ctx, diskInput)
+	for _, metric := range listResult.Metrics {
+		dimensions := make(map[string]string, len(metric.Dimensions))
+		matches := false
+		for _, dim := range metric.Dimensions {
+			if dim.Name == nil || dim.Value == nil {
+				continue
+			}
+			dimensions[*dim.Name] = *dim.Value
+			switch *dim.Name {
+			case "exe", "pattern", "process_name":
+				if *dim.Value == processName {
+					matches = true
+				}
+			}
+		}
+		if matches {
+			return dimensions, nil
+		}
+	}
+	return map[string]string{}, nil
+}
+
+func CWAgentMetrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID string, processNames []string, timeParams map[string]time.Time) (*CWAgentResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	// Disk metrics (with proper dimensions)
+	// First, discover the device and fstype dimensions, cached between runs
+	disk, err := cached("cwagent/"+cwClient.Options().Region+"/"+instanceID, func() (cwAgentDisk, error) {
+		return discoverDiskDimensions(ctx, cwClient, instanceID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error getting disk_used_percent: %v", err)
+		return nil, err
+	}
+	device, fstype := disk.Device, disk.FSType
+
+	instanceDimension := types.Dimension{
+		Name:  aws.String("InstanceId"),
+		Value: aws.String(instanceID),
 	}
 
-	if len(diskResult.Datapoints) > 0 {
-		metrics["disk_used_percent"] = *diskResult.Datapoints[0].Average
-	} else {
-		metrics["disk_used_percent"] = 0.0
+	// Memory metrics (average and maximum)
+	var queries []metricQuery
+	for _, stat := range []string{"Average", "Maximum"} {
+		queries = append(queries, metricQuery{
+			Key:        fmt.Sprintf("mem_used_percent_%s", stat),
+			Namespace:  "CWAgent",
+			MetricName: "mem_used_percent",
+			Dimensions: []types.Dimension{instanceDimension},
+			Statistic:  stat,
+		})
+	}
+
+	queries = append(queries, metricQuery{
+		Key:        "swap_used_percent",
+		Namespace:  "CWAgent",
+		MetricName: "swap_used_percent",
+		Dimensions: []types.Dimension{instanceDimension},
+		Statistic:  "Average",
+	})
+
+	// Get the disk metrics with the discovered dimensions. The agent reports
+	// inode counts only, the usage is derived from used and total.
+	diskDimensions := []types.Dimension{
+		instanceDimension,
+		{
+			Name:  aws.String("path"),
+			Value: aws.String("/"),
+		},
+		{
+			Name:  aws.String("device"),
+			Value: aws.String(device),
+		},
+		{
+			Name:  aws.String("fstype"),
+			Value: aws.String(fstype),
+		},
 	}
+	for _, metricName := range []string{"disk_used_percent", "disk_inodes_used", "disk_inodes_total"} {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "CWAgent",
+			MetricName: metricName,
+			Dimensions: diskDimensions,
+			Statistic:  "Average",
+		})
+	}
+
+	// Processes the agent doesn't report are left out of the queries
+	processes := make([]CWAgentProcess, 0, len(processNames))
+	for i, processName := range processNames {
+		dimensionValues, err := cached("cwagent/"+cwClient.Options().Region+"/"+instanceID+"/procstat/"+processName, func() (map[string]string, error) {
+			return discoverProcessDimensions(ctx, cwClient, instanceID, processName)
+		})
+		if err != nil {
+			return nil, err
+		}
+		processes = append(processes, CWAgentProcess{Name: processName, NoData: len(dimensionValues) == 0})
+		if len(dimensionValues) == 0 {
+			continue
+		}
+
+		var dimensions []types.Dimension
+		for name, value := range dimensionValues {
+			dimensions = append(dimensions, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+		}
+		for _, metricName := range []string{"procstat_cpu_usage", "procstat_memory_rss"} {
+			queries = append(queries, metricQuery{
+				Key:        fmt.Sprintf("%s_%d", metricName, i),
+				Namespace:  "CWAgent",
+				MetricName: metricName,
+				Dimensions: dimensions,
+				Statistic:  "Average",
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting CloudWatch Agent metrics: %v", err)
+	}
+
+	result := &CWAgentResult{
+		InstanceID:    instanceID,
+		MemoryAverage: aggregateValues("Average", results["mem_used_percent_Average"]),
+		MemoryMaximum: aggregateValues("Maximum", results["mem_used_percent_Maximum"]),
+		SwapUsed:      aggregateValues("Average", results["swap_used_percent"]),
+		DiskUsed:      aggregateValues("Average", results["disk_used_percent"]),
+		Processes:     processes,
+	}
+	if inodesTotal := aggregateValues("Average", results["disk_inodes_total"]); inodesTotal > 0 {
+		result.InodesUsed = aggregateValues("Average", results["disk_inodes_used"]) / inodesTotal * 100
+	}
+	for i := range result.Processes {
+		process := &result.Processes[i]
+		if process.NoData {
+			continue
+		}
+		process.CPU = aggregateValues("Average", results[fmt.Sprintf("procstat_cpu_usage_%d", i)])
+		process.MemoryMB = aggregateValues("Average", results[fmt.Sprintf("procstat_memory_rss_%d", i)]) / (1024.0 * 1024.0)
+	}
+	return result, nil
+}
+
+type cwAgentCollector struct{}
+
+func (cwAgentCollector) Name() string { return "cloudwatchAgent" }
+
+func (cwAgentCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.CloudWatchAgent.Enabled
+}
+
+func (cwAgentCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.CloudWatchAgent.InstanceIDs
+}
 
-	return metrics, nil
+func (cwAgentCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, instanceID string) (utils.Result, error) {
+	agentConfig := cfg.Services.CloudWatchAgent
+	region := config.ResourceRegion(agentConfig.Region, agentConfig.ResourceRegions, instanceID)
+	return CWAgentMetrics(ctx, clients.CloudWatch.Get(region), instanceID, agentConfig.Processes, windowTimes(window))
 }
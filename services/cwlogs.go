@@ -2,15 +2,96 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
 	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 	"go.uber.org/zap"
 )
 
-func CWLogs(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, timeParams map[string]time.Time) (map[string]int, error) {
+// Helper function to get the readable part of a structured log line, the raw line otherwise
+func errorSampleText(message string) string {
+	var structured map[string]any
+	if err := json.Unmarshal([]byte(message), &structured); err != nil {
+		return message
+	}
+	for _, key := range []string{"msg", "message"} {
+		if text, ok := structured[key].(string); ok && text != "" {
+			return text
+		}
+	}
+	return message
+}
+
+// Longest error sample shown per line, in characters
+const maxErrorSampleLength = 200
+
+// Error samples are shown on a single line, stack traces and long payloads are cut
+func truncateSample(sample string) string {
+	sample = strings.Join(strings.Fields(sample), " ")
+	if runes := []rune(sample); len(runes) > maxErrorSampleLength {
+		return string(runes[:maxErrorSampleLength]) + "…"
+	}
+	return sample
+}
+
+// INFO/WARN/ERROR event counts of a log group
+type CWLogsResult struct {
+	LogGroupName string
+	Info         int
+	Warn         int
+	Error        int
+	// Most recent error messages, newest first
+	ErrorSamples []string
+}
+
+func (r *CWLogsResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"info":  float64(r.Info),
+		"warn":  float64(r.Warn),
+		"error": float64(r.Error),
+	}
+}
+
+// Log groups are grouped in the APPLICATION and LAMBDA sections
+func (r *CWLogsResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "cloudwatchLogs", Title: "APPLICATION"}
+	if strings.Contains(r.LogGroupName, "/aws/lambda/") {
+		section.Title = "LAMBDA"
+	}
+
+	section.AddLine("%s:", r.LogGroupName)
+	section.AddLine("INFO: %d", r.Info)
+	section.AddLine("WARN: %d", r.Warn)
+	section.AddLine("ERROR: %d%s", r.Error, trend("error", float64(r.Error)))
+	for _, sample := range r.ErrorSamples {
+		section.AddLine("> %s", truncateSample(sample))
+	}
+	return section
+}
+
+// Counts INFO/WARN/ERROR events with the given mode (see config.LogsCount*) and
+// keeps up to errorSamples of the most recent error messages
+func CWLogs(ctx context.Context, logsClient *cloudwatchlogs.Client, cwClient *cloudwatch.Client, logGroupName string, mode string, errorSamples int, timeParams map[string]time.Time) (*CWLogsResult, error) {
+	switch mode {
+	case config.LogsCountInsights:
+		return insightsLogCounts(ctx, logsClient, logGroupName, errorSamples, timeParams)
+	case config.LogsCountMetricFilters:
+		return metricFilterLogCounts(ctx, logsClient, cwClient, logGroupName, errorSamples, timeParams)
+	}
+	return filterLogCounts(ctx, logsClient, logGroupName, errorSamples, timeParams)
+}
+
+// Pages through FilterLogEvents once per level, slow and expensive on busy log groups
+func filterLogCounts(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, errorSamples int, timeParams map[string]time.Time) (*CWLogsResult, error) {
 	levels := map[string]string{
 		"error": "{ $.level = \"error\" }",
 		"warn":  "{ $.level = \"warn\" }",
@@ -23,6 +104,8 @@ func CWLogs(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName
 		"info":  0,
 	}
 
+	var errorEvents []types.FilteredLogEvent
+
 	for level, filterPattern := range levels {
 		input := &cloudwatchlogs.FilterLogEventsInput{
 			LogGroupName:  aws.String(logGroupName),
@@ -47,10 +130,186 @@ func CWLogs(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName
 				break
 			}
 			count += len(output.Events)
+
+			// Keep only the newest events while paging
+			if level == "error" && errorSamples > 0 {
+				errorEvents = append(errorEvents, output.Events...)
+				sort.SliceStable(errorEvents, func(i, j int) bool {
+					return aws.ToInt64(errorEvents[i].Timestamp) > aws.ToInt64(errorEvents[j].Timestamp)
+				})
+				if len(errorEvents) > errorSamples {
+					errorEvents = errorEvents[:errorSamples]
+				}
+			}
 		}
 
 		counts[level] = count
 	}
 
-	return counts, nil
+	result := &CWLogsResult{
+		LogGroupName: logGroupName,
+		Info:         counts["info"],
+		Warn:         counts["warn"],
+		Error:        counts["error"],
+	}
+	for _, event := range errorEvents {
+		result.ErrorSamples = append(result.ErrorSamples, errorSampleText(aws.ToString(event.Message)))
+	}
+
+	return result, nil
+}
+
+// Most recent error messages, through a Logs Insights query limited to count rows
+func insightsErrorSamples(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, count int, timeParams map[string]time.Time) []string {
+	if count <= 0 {
+		return nil
+	}
+
+	query := fmt.Sprintf(`filter level = "error" | sort @timestamp desc | limit %d | fields @message`, count)
+	rows, err := runInsightsQuery(ctx, logsClient, logGroupName, query, timeParams)
+	if err != nil {
+		utils.Logger.Error("Failed to get error samples", zap.Error(err), zap.String("logGroup", logGroupName))
+		return nil
+	}
+
+	var samples []string
+	for _, row := range rows {
+		samples = append(samples, errorSampleText(row["@message"]))
+	}
+	return samples
+}
+
+// A single Logs Insights query aggregating the levels, billed per GB scanned
+func insightsLogCounts(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, errorSamples int, timeParams map[string]time.Time) (*CWLogsResult, error) {
+	rows, err := runInsightsQuery(ctx, logsClient, logGroupName,
+		`filter level in ["error", "warn", "info"] | stats count(*) as count by level`, timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error counting log levels: %v", err)
+	}
+
+	result := &CWLogsResult{LogGroupName: logGroupName}
+	for _, row := range rows {
+		count := int(parseInsightsFloat(row["count"]))
+		switch row["level"] {
+		case "error":
+			result.Error = count
+		case "warn":
+			result.Warn = count
+		case "info":
+			result.Info = count
+		}
+	}
+	result.ErrorSamples = insightsErrorSamples(ctx, logsClient, logGroupName, errorSamples, timeParams)
+
+	return result, nil
+}
+
+// Namespace of the metric filters of a log group, eg: Telegraws/Logs/aws/lambda/my-function
+func logsMetricNamespace(logGroupName string) string {
+	return "Telegraws/Logs/" + strings.TrimPrefix(logGroupName, "/")
+}
+
+var logLevels = []string{"error", "warn", "info"}
+
+// Creates the telegraws-<level> metric filters missing on the log group. Checked
+// once per cache TTL.
+func ensureLogMetricFilters(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string) error {
+	_, err := cached("logsMetricFilters/"+logsClient.Options().Region+"/"+logGroupName, func() (bool, error) {
+		output, err := logsClient.DescribeMetricFilters(ctx, &cloudwatchlogs.DescribeMetricFiltersInput{
+			LogGroupName:     aws.String(logGroupName),
+			FilterNamePrefix: aws.String("telegraws-"),
+		})
+		if err != nil {
+			return false, fmt.Errorf("error describing metric filters: %v", err)
+		}
+
+		existing := map[string]bool{}
+		for _, filter := range output.MetricFilters {
+			existing[aws.ToString(filter.FilterName)] = true
+		}
+
+		for _, level := range logLevels {
+			filterName := "telegraws-" + level
+			if existing[filterName] {
+				continue
+			}
+			_, err := logsClient.PutMetricFilter(ctx, &cloudwatchlogs.PutMetricFilterInput{
+				LogGroupName:  aws.String(logGroupName),
+				FilterName:    aws.String(filterName),
+				FilterPattern: aws.String(fmt.Sprintf(`{ $.level = "%s" }`, level)),
+				MetricTransformations: []types.MetricTransformation{
+					{
+						MetricNamespace: aws.String(logsMetricNamespace(logGroupName)),
+						MetricName:      aws.String(level),
+						MetricValue:     aws.String("1"),
+					},
+				},
+			})
+			if err != nil {
+				return false, fmt.Errorf("error creating metric filter %s: %v", filterName, err)
+			}
+			utils.Logger.Info("Created log metric filter", zap.String("logGroup", logGroupName), zap.String("filterName", filterName))
+		}
+		return true, nil
+	})
+	return err
+}
+
+// Reads the counts published by the telegraws-<level> metric filters, which
+// are created on first use and only count events from then on
+func metricFilterLogCounts(ctx context.Context, logsClient *cloudwatchlogs.Client, cwClient *cloudwatch.Client, logGroupName string, errorSamples int, timeParams map[string]time.Time) (*CWLogsResult, error) {
+	if err := ensureLogMetricFilters(ctx, logsClient, logGroupName); err != nil {
+		return nil, err
+	}
+
+	var queries []metricQuery
+	for _, level := range logLevels {
+		queries = append(queries, metricQuery{
+			Key:        level,
+			Namespace:  logsMetricNamespace(logGroupName),
+			MetricName: level,
+			Statistic:  "Sum",
+		})
+	}
+
+	period := metricPeriod(timeParams)
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting log level metrics: %v", err)
+	}
+
+	return &CWLogsResult{
+		LogGroupName: logGroupName,
+		Error:        int(aggregateValues("Sum", results["error"])),
+		Warn:         int(aggregateValues("Sum", results["warn"])),
+		Info:         int(aggregateValues("Sum", results["info"])),
+		ErrorSamples: insightsErrorSamples(ctx, logsClient, logGroupName, errorSamples, timeParams),
+	}, nil
+}
+
+type cwLogsCollector struct{}
+
+func (cwLogsCollector) Name() string { return "cloudwatchLogs" }
+
+func (cwLogsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.CloudWatchLogs.Enabled
+}
+
+func (cwLogsCollector) Resources(cfg *config.Config) []string {
+	// Application log groups first, then Lambda ones
+	var applicationGroups, lambdaGroups []string
+	for _, logGroupName := range cfg.Services.CloudWatchLogs.LogGroupNames {
+		if strings.Contains(logGroupName, "/aws/lambda/") {
+			lambdaGroups = append(lambdaGroups, logGroupName)
+		} else {
+			applicationGroups = append(applicationGroups, logGroupName)
+		}
+	}
+	return append(applicationGroups, lambdaGroups...)
+}
+
+func (cwLogsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, logGroupName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.CloudWatchLogs.Region, cfg.Services.CloudWatchLogs.ResourceRegions, logGroupName)
+	logs := cfg.Services.CloudWatchLogs
+	return CWLogs(ctx, clients.Logs.Get(region), clients.CloudWatch.Get(region), logGroupName, logs.CountMode, logs.ErrorSamples, windowTimes(window))
 }
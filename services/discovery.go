@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+type DiscoveredResources struct {
+	EC2InstanceIDs []string
+	ALBNames       []string // Full LoadBalancer dimension (app/name/id)
+	RDSInstanceIDs []string
+	RDSClusterIDs  []string
+	DynamoDBTables []string
+	S3Buckets      []string
+}
+
+// Finds resources matching the tag filter using the Resource Groups Tagging API
+func DiscoverResources(ctx context.Context, taggingClient *resourcegroupstaggingapi.Client, tagKey string, tagValue string) (*DiscoveredResources, error) {
+	tagFilter := types.TagFilter{Key: aws.String(tagKey)}
+	if tagValue != "" {
+		tagFilter.Values = []string{tagValue}
+	}
+
+	input := &resourcegroupstaggingapi.GetResourcesInput{
+		TagFilters: []types.TagFilter{tagFilter},
+		ResourceTypeFilters: []string{
+			"ec2:instance",
+			"elasticloadbalancing:loadbalancer",
+			"rds:db",
+			"rds:cluster",
+			"dynamodb:table",
+			"s3",
+		},
+	}
+
+	discovered := &DiscoveredResources{}
+
+	paginator := resourcegroupstaggingapi.NewGetResourcesPaginator(taggingClient, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting tagged resources: %v", err)
+		}
+
+		for _, mapping := range output.ResourceTagMappingList {
+			if mapping.ResourceARN != nil {
+				discovered.add(*mapping.ResourceARN)
+			}
+		}
+	}
+
+	return discovered, nil
+}
+
+// ARN format: arn:partition:service:region:account:resource
+func (d *DiscoveredResources) add(arn string) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 {
+		return
+	}
+	service, resource := parts[2], parts[5]
+
+	switch service {
+	case "ec2":
+		if id, ok := strings.CutPrefix(resource, "instance/"); ok {
+			d.EC2InstanceIDs = append(d.EC2InstanceIDs, id)
+		}
+	case "elasticloadbalancing":
+		// Network/gateway load balancers are not supported by the ALB collector
+		if name, ok := strings.CutPrefix(resource, "loadbalancer/"); ok && strings.HasPrefix(name, "app/") {
+			d.ALBNames = append(d.ALBNames, name)
+		}
+	case "rds":
+		if id, ok := strings.CutPrefix(resource, "db:"); ok {
+			d.RDSInstanceIDs = append(d.RDSInstanceIDs, id)
+		} else if id, ok := strings.CutPrefix(resource, "cluster:"); ok {
+			d.RDSClusterIDs = append(d.RDSClusterIDs, id)
+		}
+	case "dynamodb":
+		if name, ok := strings.CutPrefix(resource, "table/"); ok {
+			d.DynamoDBTables = append(d.DynamoDBTables, name)
+		}
+	case "s3":
+		d.S3Buckets = append(d.S3Buckets, resource)
+	}
+}
@@ -3,6 +3,11 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -12,45 +17,235 @@ import (
 	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Metrics collected per global secondary index, as GSI_<index>_<metric>
+var gsiMetricNames = []string{
+	"ConsumedReadCapacityUnits",
+	"ConsumedWriteCapacityUnits",
+	"ReadThrottleEvents",
+	"WriteThrottleEvents",
+}
+
+type DynamoDBOperation struct {
+	Name     string
+	Requests float64
+}
+
+// Global secondary indexes throttle and consume capacity on their own,
+// table-level metrics don't include them
+type DynamoDBIndex struct {
+	IndexName                  string
+	ConsumedReadCapacityUnits  float64
+	ConsumedWriteCapacityUnits float64
+	ReadThrottleEvents         float64
+	WriteThrottleEvents        float64
+}
+
+// Consumed capacity over the window (average) and its busiest period (peak),
+// against the capacity provisioned at the time
+type DynamoDBUtilization struct {
+	Provisioned float64 // Units per second, average over the window
+	Average     float64 // %
+	Peak        float64 // %
+}
+
+type DynamoDBResult struct {
+	TableName                  string
+	OnDemand                   bool
+	ItemCount                  float64 // Approximate, updated by DynamoDB every ~6 hours
+	TableSizeBytes             float64 // Same update interval as ItemCount
+	RequestCount               float64
+	SuccessfulRequestLatency   float64 // ms
+	ReadThrottleEvents         float64
+	WriteThrottleEvents        float64
+	SystemErrors               float64
+	UserErrors                 float64
+	ConsumedReadCapacityUnits  float64
+	ConsumedWriteCapacityUnits float64
+	// Consumed vs provisioned capacity (%), provisioned tables only
+	ReadUtilization      *DynamoDBUtilization
+	WriteUtilization     *DynamoDBUtilization
+	UtilizationThreshold float64 // Utilization above it is flagged, 0 = never
+	// Operations with requests in the window, busiest first
+	Operations []DynamoDBOperation
+	Indexes    []DynamoDBIndex
+	// Storage growth is only shown in daily reports, hourly deltas mostly
+	// compare the same DescribeTable snapshot
+	IsDailyReport bool
+}
+
+// Operations and indexes are flattened as Operation_<operation>_Requests and GSI_<index>_<metric>
+func (r *DynamoDBResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"BillingMode":                0,
+		"ItemCount":                  r.ItemCount,
+		"TableSizeBytes":             r.TableSizeBytes,
+		"RequestCount":               r.RequestCount,
+		"SuccessfulRequestLatency":   r.SuccessfulRequestLatency,
+		"ReadThrottleEvents":         r.ReadThrottleEvents,
+		"WriteThrottleEvents":        r.WriteThrottleEvents,
+		"SystemErrors":               r.SystemErrors,
+		"UserErrors":                 r.UserErrors,
+		"ConsumedReadCapacityUnits":  r.ConsumedReadCapacityUnits,
+		"ConsumedWriteCapacityUnits": r.ConsumedWriteCapacityUnits,
+	}
+	if r.OnDemand {
+		metrics["BillingMode"] = 1
+	}
+	if r.ReadUtilization != nil {
+		metrics["ProvisionedReadCapacityUnits"] = r.ReadUtilization.Provisioned
+		metrics["ReadUtilization"] = r.ReadUtilization.Average
+		metrics["ReadUtilization_Peak"] = r.ReadUtilization.Peak
+	}
+	if r.WriteUtilization != nil {
+		metrics["ProvisionedWriteCapacityUnits"] = r.WriteUtilization.Provisioned
+		metrics["WriteUtilization"] = r.WriteUtilization.Average
+		metrics["WriteUtilization_Peak"] = r.WriteUtilization.Peak
+	}
+	for _, operation := range r.Operations {
+		metrics["Operation_"+operation.Name+"_Requests"] = operation.Requests
+	}
+	for _, index := range r.Indexes {
+		prefix := "GSI_" + index.IndexName + "_"
+		metrics[prefix+"ConsumedReadCapacityUnits"] = index.ConsumedReadCapacityUnits
+		metrics[prefix+"ConsumedWriteCapacityUnits"] = index.ConsumedWriteCapacityUnits
+		metrics[prefix+"ReadThrottleEvents"] = index.ReadThrottleEvents
+		metrics[prefix+"WriteThrottleEvents"] = index.WriteThrottleEvents
+	}
+	return metrics
+}
+
+func (r *DynamoDBResult) Render(trend utils.TrendFunc) utils.Section {
+	return r.RenderDeltas(trend, func(string, float64) (float64, bool) { return 0, false })
+}
+
+// Growth of the table size since the previous daily report, from the history
+func (r *DynamoDBResult) RenderDeltas(trend utils.TrendFunc, delta utils.DeltaFunc) utils.Section {
+	section := utils.Section{Service: "dynamodb", Title: "DynamoDB", Subtitle: r.TableName}
+
+	billingMode := "Provisioned"
+	if r.OnDemand {
+		billingMode = "On-Demand"
+	}
+
+	section.AddLine("Total Requests: %.0f (%s)", r.RequestCount, billingMode)
+	section.AddLine("Latency: %.2f ms", r.SuccessfulRequestLatency)
+	if len(r.Operations) > 0 {
+		counts := make([]string, 0, len(r.Operations))
+		for _, operation := range r.Operations {
+			counts = append(counts, fmt.Sprintf("%s %.0f", operation.Name, operation.Requests))
+		}
+		section.AddLine("Operations: %s", strings.Join(counts, ", "))
+	}
+	section.AddLine("Items: %.0f", r.ItemCount)
+	sizeLine := fmt.Sprintf("Table Size: %.2f MB", r.TableSizeBytes/(1024.0*1024.0))
+	if growth, exists := delta("TableSizeBytes", r.TableSizeBytes); exists && r.IsDailyReport {
+		sizeLine += fmt.Sprintf(" (%+.2f MB/day)", growth/(1024.0*1024.0))
+	}
+	section.AddLine("%s", sizeLine)
+
+	section.AddLine("Read Throttles: %.0f", r.ReadThrottleEvents)
+	section.AddLine("Write Throttles: %.0f", r.WriteThrottleEvents)
+	section.AddLine("Read Capacity: %.0f units", r.ConsumedReadCapacityUnits)
+	section.AddLine("Write Capacity: %.0f units", r.ConsumedWriteCapacityUnits)
+	for _, utilization := range []struct {
+		label string
+		value *DynamoDBUtilization
+	}{
+		{"Read Utilization", r.ReadUtilization},
+		{"Write Utilization", r.WriteUtilization},
+	} {
+		if utilization.value == nil {
+			continue
+		}
+		utilizationLine := fmt.Sprintf("%s: %.1f%% (avg), %.1f%% (peak) of %.0f units/s", utilization.label, utilization.value.Average, utilization.value.Peak, utilization.value.Provisioned)
+		if r.UtilizationThreshold > 0 && utilization.value.Average > r.UtilizationThreshold {
+			utilizationLine += " (HIGH)"
+		}
+		section.AddLine("%s", utilizationLine)
+	}
+	section.AddLine("DB Errors: %.0f", r.UserErrors+r.SystemErrors)
+
+	for _, index := range r.Indexes {
+		section.AddLine("GSI %s: %.0f/%.0f units (r/w), Throttles: %.0f/%.0f (r/w)",
+			index.IndexName,
+			index.ConsumedReadCapacityUnits,
+			index.ConsumedWriteCapacityUnits,
+			index.ReadThrottleEvents,
+			index.WriteThrottleEvents)
+	}
+	return section
+}
+
+// Operations reporting SuccessfulRequestLatency, the only per-request metric DynamoDB publishes
+var dynamoOperations = []string{
+	"GetItem",
+	"BatchGetItem",
+	"Query",
+	"Scan",
+	"PutItem",
+	"UpdateItem",
+	"DeleteItem",
+	"BatchWriteItem",
+	"TransactGetItems",
+	"TransactWriteItems",
+	"ExecuteStatement",
+	"BatchExecuteStatement",
+}
+
+// What the report needs from DescribeTable
+type dynamoDBTable struct {
+	OnDemand       bool
+	ItemCount      float64
+	TableSizeBytes float64
+	IndexNames     []string // Global secondary indexes
+}
+
 func DynamoDBMetrics(
 	ctx context.Context,
 	cwClient *cloudwatch.Client,
 	dynamoClient *dynamodb.Client,
 	timeParams map[string]time.Time,
 	tableName string,
-) (map[string]float64, error) {
+) (*DynamoDBResult, error) {
 
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
-	}
+	result := &DynamoDBResult{TableName: tableName}
+	period := aws.Int32(metricPeriod(timeParams))
 
-	// DescribeTable call
-	out, err := dynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
-		TableName: aws.String(tableName),
+	// DescribeTable call, cached between runs (the item count is only updated every ~6 hours)
+	table, err := cached("dynamodb/"+dynamoClient.Options().Region+"/"+tableName, func() (dynamoDBTable, error) {
+		out, err := dynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+			TableName: aws.String(tableName),
+		})
+		if err != nil {
+			return dynamoDBTable{}, fmt.Errorf("failed to describe table: %w", err)
+		}
+
+		var table dynamoDBTable
+		if out.Table == nil {
+			return table, nil
+		}
+		// Billing mode
+		if out.Table.BillingModeSummary != nil {
+			table.OnDemand = out.Table.BillingModeSummary.BillingMode == dynamodbTypes.BillingModePayPerRequest
+		}
+		// Item count (approximate)
+		if out.Table.ItemCount != nil {
+			table.ItemCount = float64(*out.Table.ItemCount)
+		}
+		if out.Table.TableSizeBytes != nil {
+			table.TableSizeBytes = float64(*out.Table.TableSizeBytes)
+		}
+		for _, index := range out.Table.GlobalSecondaryIndexes {
+			table.IndexNames = append(table.IndexNames, aws.ToString(index.IndexName))
+		}
+		return table, nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe table: %w", err)
-	}
-
-	// Billing mode
-	onDemand := false
-	if out.Table != nil && out.Table.BillingModeSummary != nil {
-		onDemand = out.Table.BillingModeSummary.BillingMode == dynamodbTypes.BillingModePayPerRequest
-	}
-	if onDemand {
-		metrics["BillingMode"] = 1
-	} else {
-		metrics["BillingMode"] = 0
-	}
-
-	// Item count (approximate)
-	if out.Table != nil && out.Table.ItemCount != nil {
-		metrics["ItemCount"] = float64(*out.Table.ItemCount)
-	} else {
-		metrics["ItemCount"] = 0
+		return nil, err
 	}
+	result.OnDemand = table.OnDemand
+	result.ItemCount = table.ItemCount
+	result.TableSizeBytes = table.TableSizeBytes
 
 	// CloudWatch metrics
 	dynamoMetrics := []struct {
@@ -64,58 +259,169 @@ func DynamoDBMetrics(
 		{"ConsumedReadCapacityUnits", "Sum"},
 		{"ConsumedWriteCapacityUnits", "Sum"},
 	}
-
-	if !onDemand {
-		dynamoMetrics = append(dynamoMetrics,
-			struct {
-				Name      string
-				Statistic string
-			}{"RequestCount", "Sum"},
-			struct {
-				Name      string
-				Statistic string
-			}{"SuccessfulRequestLatency", "Average"},
-		)
+	// Follows auto scaling changes over the window, unlike DescribeTable
+	if !table.OnDemand {
+		dynamoMetrics = append(dynamoMetrics, []struct {
+			Name      string
+			Statistic string
+		}{
+			{"ProvisionedReadCapacityUnits", "Average"},
+			{"ProvisionedWriteCapacityUnits", "Average"},
+		}...)
 	}
 
+	var queries []metricQuery
 	for _, metric := range dynamoMetrics {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/DynamoDB"),
-			MetricName: aws.String(metric.Name),
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/DynamoDB",
+			MetricName: metric.Name,
 			Dimensions: []types.Dimension{
 				{
 					Name:  aws.String("TableName"),
 					Value: aws.String(tableName),
 				},
 			},
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	// Request volume (SampleCount) and total latency (Sum) per operation, the
+	// same for provisioned and on-demand tables
+	for _, operation := range dynamoOperations {
+		for _, statistic := range []string{"SampleCount", "Sum"} {
+			queries = append(queries, metricQuery{
+				Key:        fmt.Sprintf("Operation_%s_%s", operation, statistic),
+				Namespace:  "AWS/DynamoDB",
+				MetricName: "SuccessfulRequestLatency",
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("TableName"),
+						Value: aws.String(tableName),
+					},
+					{
+						Name:  aws.String("Operation"),
+						Value: aws.String(operation),
+					},
+				},
+				Statistic: statistic,
+			})
 		}
+	}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("error getting %s: %v", metric.Name, err)
+	for _, indexName := range table.IndexNames {
+		for _, metricName := range gsiMetricNames {
+			queries = append(queries, metricQuery{
+				Key:        fmt.Sprintf("GSI_%s_%s", indexName, metricName),
+				Namespace:  "AWS/DynamoDB",
+				MetricName: metricName,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("TableName"),
+						Value: aws.String(tableName),
+					},
+					{
+						Name:  aws.String("GlobalSecondaryIndexName"),
+						Value: aws.String(indexName),
+					},
+				},
+				Statistic: "Sum",
+			})
 		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting DynamoDB metrics: %v", err)
+	}
+
+	result.ReadThrottleEvents = aggregateValues("Sum", results["ReadThrottleEvents"])
+	result.WriteThrottleEvents = aggregateValues("Sum", results["WriteThrottleEvents"])
+	result.SystemErrors = aggregateValues("Sum", results["SystemErrors"])
+	result.UserErrors = aggregateValues("Sum", results["UserErrors"])
+	result.ConsumedReadCapacityUnits = aggregateValues("Sum", results["ConsumedReadCapacityUnits"])
+	result.ConsumedWriteCapacityUnits = aggregateValues("Sum", results["ConsumedWriteCapacityUnits"])
+	if !table.OnDemand {
+		window := timeParams["endTime"].Sub(timeParams["startTime"]).Seconds()
+		result.ReadUtilization = dynamoDBUtilization(results["ConsumedReadCapacityUnits"], results["ProvisionedReadCapacityUnits"], window, float64(*period))
+		result.WriteUtilization = dynamoDBUtilization(results["ConsumedWriteCapacityUnits"], results["ProvisionedWriteCapacityUnits"], window, float64(*period))
+	}
 
-		if len(result.Datapoints) > 0 {
-			latest := result.Datapoints[0]
-			for _, dp := range result.Datapoints {
-				if dp.Timestamp.After(*latest.Timestamp) {
-					latest = dp
-				}
-			}
-			switch metric.Statistic {
-			case "Average":
-				metrics[metric.Name] = *latest.Average
-			case "Sum":
-				metrics[metric.Name] = *latest.Sum
-			}
-		} else {
-			metrics[metric.Name] = 0.0
+	// Operations without requests are left out
+	var totalLatency float64
+	for _, operation := range dynamoOperations {
+		count := aggregateValues("SampleCount", results[fmt.Sprintf("Operation_%s_SampleCount", operation)])
+		if count == 0 {
+			continue
+		}
+		result.Operations = append(result.Operations, DynamoDBOperation{Name: operation, Requests: count})
+		result.RequestCount += count
+		totalLatency += aggregateValues("Sum", results[fmt.Sprintf("Operation_%s_Sum", operation)])
+	}
+	if result.RequestCount > 0 {
+		result.SuccessfulRequestLatency = totalLatency / result.RequestCount // ms
+	}
+	sort.SliceStable(result.Operations, func(i, j int) bool {
+		if result.Operations[i].Requests != result.Operations[j].Requests {
+			return result.Operations[i].Requests > result.Operations[j].Requests
 		}
+		return result.Operations[i].Name < result.Operations[j].Name
+	})
+
+	indexNames := table.IndexNames
+	sort.Strings(indexNames)
+	for _, indexName := range indexNames {
+		prefix := fmt.Sprintf("GSI_%s_", indexName)
+		result.Indexes = append(result.Indexes, DynamoDBIndex{
+			IndexName:                  indexName,
+			ConsumedReadCapacityUnits:  aggregateValues("Sum", results[prefix+"ConsumedReadCapacityUnits"]),
+			ConsumedWriteCapacityUnits: aggregateValues("Sum", results[prefix+"ConsumedWriteCapacityUnits"]),
+			ReadThrottleEvents:         aggregateValues("Sum", results[prefix+"ReadThrottleEvents"]),
+			WriteThrottleEvents:        aggregateValues("Sum", results[prefix+"WriteThrottleEvents"]),
+		})
+	}
+
+	return result, nil
+}
+
+// Consumed units are summed per period, provisioned ones are per second. Nil
+// without provisioned datapoints.
+func dynamoDBUtilization(consumed []float64, provisioned []float64, window float64, period float64) *DynamoDBUtilization {
+	capacity := aggregateValues("Average", provisioned)
+	if capacity == 0 {
+		return nil
+	}
+
+	var busiest float64
+	if len(consumed) > 0 {
+		busiest = slices.Max(consumed)
+	}
+	return &DynamoDBUtilization{
+		Provisioned: capacity,
+		Average:     aggregateValues("Sum", consumed) / window / capacity * 100,
+		Peak:        busiest / period / capacity * 100,
 	}
+}
 
-	return metrics, nil
+type dynamoDBCollector struct{}
+
+func (dynamoDBCollector) Name() string { return "dynamodb" }
+
+func (dynamoDBCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.DynamoDB.Enabled
+}
+
+func (dynamoDBCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.DynamoDB.TableNames
+}
+
+func (dynamoDBCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, tableName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.DynamoDB.Region, cfg.Services.DynamoDB.ResourceRegions, tableName)
+	result, err := DynamoDBMetrics(ctx, clients.CloudWatch.Get(region), clients.DynamoDB.Get(region), windowTimes(window), tableName)
+	if err != nil {
+		return nil, err
+	}
+	result.IsDailyReport = window.IsDailyReport
+	result.UtilizationThreshold = cfg.Services.DynamoDB.UtilizationThreshold
+	return result, nil
 }
@@ -3,21 +3,115 @@ package services
 import (
 	"context"
 	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 )
 
+// Upcoming reboot, retirement or maintenance of an instance
+type EC2ScheduledEvent struct {
+	Code      string // eg: "system-reboot", "instance-retirement"
+	NotBefore time.Time
+}
+
 // Does NOT track disk read/write metrics (EBS volumes)
+type EC2Result struct {
+	InstanceID        string
+	CPUAverage        float64 // %
+	CPUMaximum        float64 // %
+	StatusCheckFailed float64
+	// Host (AWS hardware, network, power) and guest (OS, kernel, memory) failures
+	StatusCheckFailedSystem   float64
+	StatusCheckFailedInstance float64
+	NetworkIn                 float64 // MB
+	NetworkOut                float64 // MB
+
+	// Current status checks, "ok", "impaired", "initializing"... and empty for
+	// stopped instances. ImpairedSince is set while one of them is impaired.
+	SystemStatus    string
+	InstanceStatus  string
+	ImpairedSince   *time.Time
+	ScheduledEvents []EC2ScheduledEvent
+	Location        *time.Location
+
+	// Only burstable instances (T2/T3/T4g) report credit balances, nil otherwise
+	CPUCreditBalance        *float64
+	CPUSurplusCreditBalance *float64
+}
+
+func (r *EC2Result) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"CPUUtilization_Average":     r.CPUAverage,
+		"CPUUtilization_Maximum":     r.CPUMaximum,
+		"StatusCheckFailed":          r.StatusCheckFailed,
+		"StatusCheckFailed_System":   r.StatusCheckFailedSystem,
+		"StatusCheckFailed_Instance": r.StatusCheckFailedInstance,
+		"ScheduledEvents":            float64(len(r.ScheduledEvents)),
+		"NetworkIn":                  r.NetworkIn,
+		"NetworkOut":                 r.NetworkOut,
+	}
+	if r.CPUCreditBalance != nil {
+		metrics["CPUCreditBalance"] = *r.CPUCreditBalance
+	}
+	if r.CPUSurplusCreditBalance != nil {
+		metrics["CPUSurplusCreditBalance"] = *r.CPUSurplusCreditBalance
+	}
+	return metrics
+}
+
+func (r *EC2Result) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "ec2", Title: "EC2", Subtitle: r.InstanceID}
+	section.AddLine("CPU: %.2f%% (avg), %.2f%% (max)%s", r.CPUAverage, r.CPUMaximum, trend("CPUUtilization_Average", r.CPUAverage))
+	section.AddLine("Status Checks Failed: %.0f (system %.0f, instance %.0f)", r.StatusCheckFailed, r.StatusCheckFailedSystem, r.StatusCheckFailedInstance)
+	if impaired := r.impairedChecks(); len(impaired) > 0 {
+		impairedLine := fmt.Sprintf("IMPAIRED: %s", strings.Join(impaired, ", "))
+		if r.ImpairedSince != nil {
+			impairedLine += fmt.Sprintf(" since %s", r.ImpairedSince.In(r.Location).Format("02/01 15:04"))
+		}
+		section.AddLine("%s", impairedLine)
+	}
+	for _, event := range r.ScheduledEvents {
+		section.AddLine("Scheduled: %s from %s", event.Code, event.NotBefore.In(r.Location).Format("02/01 15:04"))
+	}
+	section.AddLine("Network In: %.2f MB", r.NetworkIn)
+	section.AddLine("Network Out: %.2f MB", r.NetworkOut)
+	if r.CPUCreditBalance != nil {
+		creditsLine := fmt.Sprintf("CPU Credits: %.0f", *r.CPUCreditBalance)
+		if r.CPUSurplusCreditBalance != nil && *r.CPUSurplusCreditBalance > 0 {
+			creditsLine += fmt.Sprintf(", Surplus: %.0f (charged)", *r.CPUSurplusCreditBalance)
+		}
+		section.AddLine("%s", creditsLine)
+	}
+	return section
+}
 
-func EC2Metrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID string, timeParams map[string]time.Time) (map[string]float64, error) {
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
+func (r *EC2Result) Failures() []string {
+	if impaired := r.impairedChecks(); len(impaired) > 0 {
+		return []string{fmt.Sprintf("EC2 %s: %s status check impaired", r.InstanceID, strings.Join(impaired, " and "))}
+	}
+	return nil
+}
+
+func (r *EC2Result) impairedChecks() []string {
+	var impaired []string
+	if r.SystemStatus == string(ec2Types.SummaryStatusImpaired) {
+		impaired = append(impaired, "system")
+	}
+	if r.InstanceStatus == string(ec2Types.SummaryStatusImpaired) {
+		impaired = append(impaired, "instance")
 	}
+	return impaired
+}
+
+func EC2Metrics(ctx context.Context, cwClient *cloudwatch.Client, ec2Client *ec2.Client, instanceID string, timeParams map[string]time.Time, location *time.Location) (*EC2Result, error) {
+	period := aws.Int32(metricPeriod(timeParams))
 
 	ec2Metrics := []struct {
 		Name      string
@@ -27,59 +121,134 @@ func EC2Metrics(ctx context.Context, cwClient *cloudwatch.Client, instanceID str
 		{"CPUUtilization", "Average", "%"},
 		{"CPUUtilization", "Maximum", "%"},
 		{"StatusCheckFailed", "Sum", "count"},
+		{"StatusCheckFailed_System", "Sum", "count"},
+		{"StatusCheckFailed_Instance", "Sum", "count"},
 		{"NetworkIn", "Sum", "MB"},
 		{"NetworkOut", "Sum", "MB"},
+		{"CPUCreditBalance", "Average", "credits"},
+		{"CPUSurplusCreditBalance", "Average", "credits"},
 	}
 
+	var queries []metricQuery
 	for _, metric := range ec2Metrics {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/EC2"),
-			MetricName: aws.String(metric.Name),
+		metricKey := metric.Name
+		if metric.Name == "CPUUtilization" {
+			metricKey = fmt.Sprintf("%s_%s", metric.Name, metric.Statistic)
+		}
+
+		query := metricQuery{
+			Key:        metricKey,
+			Namespace:  "AWS/EC2",
+			MetricName: metric.Name,
 			Dimensions: []types.Dimension{
 				{
 					Name:  aws.String("InstanceId"),
 					Value: aws.String(instanceID),
 				},
 			},
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
+			Statistic: metric.Statistic,
 		}
 
 		if metric.Name == "NetworkIn" || metric.Name == "NetworkOut" {
-			input.Unit = types.StandardUnit("Bytes")
+			query.Unit = "Bytes"
 		}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			return nil, fmt.Errorf("error getting %s: %v", metric.Name, err)
-		}
+		queries = append(queries, query)
+	}
 
-		metricKey := metric.Name
-		if metric.Name == "CPUUtilization" {
-			metricKey = fmt.Sprintf("%s_%s", metric.Name, metric.Statistic)
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting EC2 metrics: %v", err)
+	}
+
+	// Credit balances are gauges, the latest datapoint is the current balance
+	latest := func(key string) *float64 {
+		if values := results[key]; len(values) > 0 {
+			return &values[0]
 		}
+		return nil
+	}
+
+	result := &EC2Result{
+		InstanceID:                instanceID,
+		CPUAverage:                aggregateValues("Average", results["CPUUtilization_Average"]),
+		CPUMaximum:                aggregateValues("Maximum", results["CPUUtilization_Maximum"]),
+		StatusCheckFailed:         aggregateValues("Sum", results["StatusCheckFailed"]),
+		StatusCheckFailedSystem:   aggregateValues("Sum", results["StatusCheckFailed_System"]),
+		StatusCheckFailedInstance: aggregateValues("Sum", results["StatusCheckFailed_Instance"]),
+		NetworkIn:                 aggregateValues("Sum", results["NetworkIn"]) / (1024.0 * 1024.0), // Convert to MB
+		NetworkOut:                aggregateValues("Sum", results["NetworkOut"]) / (1024.0 * 1024.0),
+		CPUCreditBalance:          latest("CPUCreditBalance"),
+		CPUSurplusCreditBalance:   latest("CPUSurplusCreditBalance"),
+		Location:                  location,
+	}
+
+	if err := addEC2InstanceStatus(ctx, ec2Client, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Current status checks and upcoming scheduled events, stopped instances
+// included so their events are still reported
+func addEC2InstanceStatus(ctx context.Context, ec2Client *ec2.Client, result *EC2Result) error {
+	output, err := ec2Client.DescribeInstanceStatus(ctx, &ec2.DescribeInstanceStatusInput{
+		InstanceIds:         []string{result.InstanceID},
+		IncludeAllInstances: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error describing EC2 instance status: %v", err)
+	}
+	if len(output.InstanceStatuses) == 0 {
+		return nil
+	}
+	status := output.InstanceStatuses[0]
 
-		// Process based on statistic type
-		if len(result.Datapoints) > 0 {
-			var value float64
-			switch metric.Statistic {
-			case "Average":
-				value = *result.Datapoints[0].Average
-			case "Maximum":
-				value = *result.Datapoints[0].Maximum
-			case "Sum":
-				value = *result.Datapoints[0].Sum
-				if metric.Name == "NetworkIn" || metric.Name == "NetworkOut" {
-					value = value / (1024.0 * 1024.0) // Convert to MB
-				}
+	for _, summary := range []struct {
+		target  *string
+		summary *ec2Types.InstanceStatusSummary
+	}{
+		{&result.SystemStatus, status.SystemStatus},
+		{&result.InstanceStatus, status.InstanceStatus},
+	} {
+		if summary.summary == nil || summary.summary.Status == ec2Types.SummaryStatusNotApplicable {
+			continue
+		}
+		*summary.target = string(summary.summary.Status)
+		for _, detail := range summary.summary.Details {
+			if detail.ImpairedSince != nil && (result.ImpairedSince == nil || detail.ImpairedSince.Before(*result.ImpairedSince)) {
+				result.ImpairedSince = detail.ImpairedSince
 			}
-			metrics[metricKey] = value
-		} else {
-			metrics[metricKey] = 0.0
 		}
 	}
 
-	return metrics, nil
+	for _, event := range status.Events {
+		// Past events stay listed with their description prefixed
+		description := aws.ToString(event.Description)
+		if strings.HasPrefix(description, "[Completed]") || strings.HasPrefix(description, "[Canceled]") {
+			continue
+		}
+		result.ScheduledEvents = append(result.ScheduledEvents, EC2ScheduledEvent{
+			Code:      string(event.Code),
+			NotBefore: aws.ToTime(event.NotBefore),
+		})
+	}
+	return nil
+}
+
+type ec2Collector struct{}
+
+func (ec2Collector) Name() string { return "ec2" }
+
+func (ec2Collector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.EC2.Enabled
+}
+
+func (ec2Collector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (ec2Collector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	region := cfg.Services.EC2.Region
+	return EC2Metrics(ctx, clients.CloudWatch.Get(region), clients.EC2.Get(region), cfg.Services.EC2.InstanceID, windowTimes(window), window.Location)
 }
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"telegraws/config"
+	"telegraws/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type ECRRepository struct {
+	Name       string
+	ImageCount int
+	// Sum of the image sizes, layers shared between images are counted once
+	// per image
+	SizeMB float64
+	// Latest pushed image and the findings of its scan, Scanned is false when
+	// it has no completed scan
+	LatestTag string
+	Scanned   bool
+	Critical  int
+	High      int
+}
+
+type ECRResult struct {
+	Repositories []ECRRepository // Sorted by name
+}
+
+func (r *ECRResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{}
+	var critical, high int
+	for _, repository := range r.Repositories {
+		prefix := "Repository_" + repository.Name + "_"
+		metrics[prefix+"ImageCount"] = float64(repository.ImageCount)
+		metrics[prefix+"SizeMB"] = repository.SizeMB
+		metrics[prefix+"Critical"] = float64(repository.Critical)
+		metrics[prefix+"High"] = float64(repository.High)
+		critical += repository.Critical
+		high += repository.High
+	}
+	metrics["Critical"] = float64(critical)
+	metrics["High"] = float64(high)
+	return metrics
+}
+
+func (r *ECRResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "ecr", Title: "ECR Repositories"}
+	if len(r.Repositories) == 0 {
+		section.AddLine("No repositories")
+		return section
+	}
+
+	for _, repository := range r.Repositories {
+		repositoryLine := fmt.Sprintf("%s: %d images, %.2f MB", repository.Name, repository.ImageCount, repository.SizeMB)
+		switch {
+		case repository.ImageCount == 0:
+		case !repository.Scanned:
+			repositoryLine += fmt.Sprintf(", %s not scanned", repository.LatestTag)
+		default:
+			repositoryLine += fmt.Sprintf(", %s: %d critical, %d high", repository.LatestTag, repository.Critical, repository.High)
+		}
+		section.AddLine("%s", repositoryLine)
+	}
+	return section
+}
+
+// Images and scan findings of each repository, every repository of the region
+// when repositoryNames is empty
+func ECRMetrics(ctx context.Context, ecrClient *ecr.Client, repositoryNames []string) (*ECRResult, error) {
+	input := &ecr.DescribeRepositoriesInput{}
+	if len(repositoryNames) > 0 {
+		input.RepositoryNames = repositoryNames
+	}
+
+	var names []string
+	paginator := ecr.NewDescribeRepositoriesPaginator(ecrClient, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error describing ECR repositories: %v", err)
+		}
+		for _, repository := range output.Repositories {
+			names = append(names, aws.ToString(repository.RepositoryName))
+		}
+	}
+
+	result := &ECRResult{}
+	for _, name := range names {
+		repository, err := ecrRepositoryImages(ctx, ecrClient, name)
+		if err != nil {
+			return nil, err
+		}
+		result.Repositories = append(result.Repositories, repository)
+	}
+	sort.Slice(result.Repositories, func(i, j int) bool {
+		return result.Repositories[i].Name < result.Repositories[j].Name
+	})
+	return result, nil
+}
+
+func ecrRepositoryImages(ctx context.Context, ecrClient *ecr.Client, repositoryName string) (ECRRepository, error) {
+	repository := ECRRepository{Name: repositoryName}
+
+	var latest *ecrTypes.ImageDetail
+	paginator := ecr.NewDescribeImagesPaginator(ecrClient, &ecr.DescribeImagesInput{
+		RepositoryName: aws.String(repositoryName),
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return repository, fmt.Errorf("error describing images of ECR repository %s: %v", repositoryName, err)
+		}
+		for _, image := range output.ImageDetails {
+			repository.ImageCount++
+			repository.SizeMB += float64(aws.ToInt64(image.ImageSizeInBytes)) / (1024.0 * 1024.0)
+			if latest == nil || aws.ToTime(image.ImagePushedAt).After(aws.ToTime(latest.ImagePushedAt)) {
+				latest = &image
+			}
+		}
+	}
+	if latest == nil {
+		return repository, nil
+	}
+
+	repository.LatestTag = "untagged"
+	if len(latest.ImageTags) > 0 {
+		repository.LatestTag = latest.ImageTags[0]
+	}
+	if summary := latest.ImageScanFindingsSummary; summary != nil {
+		repository.Scanned = true
+		repository.Critical = int(summary.FindingSeverityCounts[string(ecrTypes.FindingSeverityCritical)])
+		repository.High = int(summary.FindingSeverityCounts[string(ecrTypes.FindingSeverityHigh)])
+	}
+	return repository, nil
+}
+
+type ecrCollector struct{}
+
+func (ecrCollector) Name() string { return "ecr" }
+
+func (ecrCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.ECR.Enabled && window.IsDailyReport
+}
+
+func (ecrCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (ecrCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return ECRMetrics(ctx, clients.ECR.Get(cfg.Services.ECR.Region), cfg.Services.ECR.RepositoryNames)
+}
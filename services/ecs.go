@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+)
+
+// Service CPU/memory utilization plus task counts and deployment state
+type ECSResult struct {
+	ClusterName   string
+	ServiceName   string
+	CPUAverage    float64 // %
+	CPUMaximum    float64 // %
+	MemoryAverage float64 // %
+	MemoryMaximum float64 // %
+	RunningCount  float64
+	DesiredCount  float64
+	PendingCount  float64
+	Deployments   float64
+	// State of the primary deployment (COMPLETED, IN_PROGRESS, FAILED)
+	RolloutState string
+}
+
+func (r *ECSResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"CPUUtilization_Average":    r.CPUAverage,
+		"CPUUtilization_Maximum":    r.CPUMaximum,
+		"MemoryUtilization_Average": r.MemoryAverage,
+		"MemoryUtilization_Maximum": r.MemoryMaximum,
+		"RunningCount":              r.RunningCount,
+		"DesiredCount":              r.DesiredCount,
+		"PendingCount":              r.PendingCount,
+		"Deployments":               r.Deployments,
+	}
+}
+
+func (r *ECSResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "ecs", Title: "ECS", Subtitle: r.ClusterName + " / " + r.ServiceName}
+	section.AddLine("CPU: %.2f%% (avg), %.2f%% (max)", r.CPUAverage, r.CPUMaximum)
+	section.AddLine("Memory: %.2f%% (avg), %.2f%% (max)", r.MemoryAverage, r.MemoryMaximum)
+
+	tasksLine := fmt.Sprintf("Tasks: %.0f/%.0f running, %.0f pending", r.RunningCount, r.DesiredCount, r.PendingCount)
+	if r.RunningCount < r.DesiredCount {
+		tasksLine += " (BELOW DESIRED)"
+	}
+	section.AddLine("%s", tasksLine)
+
+	if r.RolloutState != "" {
+		section.AddLine("Deployment: %s (%.0f active)", r.RolloutState, r.Deployments)
+	}
+	return section
+}
+
+func ECSMetrics(ctx context.Context, cwClient *cloudwatch.Client, ecsClient *ecs.Client, clusterName string, serviceName string, timeParams map[string]time.Time) (*ECSResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	output, err := ecsClient.DescribeServices(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(clusterName),
+		Services: []string{serviceName},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing ECS service: %v", err)
+	}
+	if len(output.Services) == 0 {
+		return nil, fmt.Errorf("ECS service %s not found in cluster %s", serviceName, clusterName)
+	}
+
+	service := output.Services[0]
+	result := &ECSResult{
+		ClusterName:  clusterName,
+		ServiceName:  serviceName,
+		RunningCount: float64(service.RunningCount),
+		DesiredCount: float64(service.DesiredCount),
+		PendingCount: float64(service.PendingCount),
+		Deployments:  float64(len(service.Deployments)),
+	}
+	for _, deployment := range service.Deployments {
+		if aws.ToString(deployment.Status) == "PRIMARY" {
+			result.RolloutState = string(deployment.RolloutState)
+		}
+	}
+
+	var queries []metricQuery
+	for _, metricName := range []string{"CPUUtilization", "MemoryUtilization"} {
+		for _, statistic := range []string{"Average", "Maximum"} {
+			queries = append(queries, metricQuery{
+				Key:        fmt.Sprintf("%s_%s", metricName, statistic),
+				Namespace:  "AWS/ECS",
+				MetricName: metricName,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("ClusterName"),
+						Value: aws.String(clusterName),
+					},
+					{
+						Name:  aws.String("ServiceName"),
+						Value: aws.String(serviceName),
+					},
+				},
+				Statistic: statistic,
+				Unit:      "Percent",
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ECS metrics: %v", err)
+	}
+
+	result.CPUAverage = aggregateValues("Average", results["CPUUtilization_Average"])
+	result.CPUMaximum = aggregateValues("Maximum", results["CPUUtilization_Maximum"])
+	result.MemoryAverage = aggregateValues("Average", results["MemoryUtilization_Average"])
+	result.MemoryMaximum = aggregateValues("Maximum", results["MemoryUtilization_Maximum"])
+
+	return result, nil
+}
+
+type ecsCollector struct{}
+
+func (ecsCollector) Name() string { return "ecs" }
+
+func (ecsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.ECS.Enabled
+}
+
+func (ecsCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.ECS.ServiceNames
+}
+
+func (ecsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, serviceName string) (utils.Result, error) {
+	region := cfg.Services.ECS.Region
+	return ECSMetrics(ctx, clients.CloudWatch.Get(region), clients.ECS.Get(region), cfg.Services.ECS.ClusterName, serviceName, windowTimes(window))
+}
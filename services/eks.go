@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Pods listed next to the restart count, the ones restarting most
+const maxEKSRestartingPods = 3
+
+type EKSPodRestarts struct {
+	Pod      string // namespace/name
+	Restarts float64
+}
+
+type EKSResult struct {
+	ClusterName   string
+	NodeCount     float64
+	FailedNodes   float64 // Peak over the window
+	CPUAverage    float64 // % of the node capacity
+	CPUMaximum    float64 // %
+	MemoryAverage float64 // %
+	MemoryMaximum float64 // %
+	// Container restarts during the window, all pods and the top ones
+	Restarts       float64
+	RestartingPods []EKSPodRestarts
+	PendingPods    float64 // Peak, needs enhanced observability
+}
+
+func (r *EKSResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"cluster_node_count":               r.NodeCount,
+		"cluster_failed_node_count":        r.FailedNodes,
+		"node_cpu_utilization_Average":     r.CPUAverage,
+		"node_cpu_utilization_Maximum":     r.CPUMaximum,
+		"node_memory_utilization_Average":  r.MemoryAverage,
+		"node_memory_utilization_Maximum":  r.MemoryMaximum,
+		"pod_number_of_container_restarts": r.Restarts,
+		"pod_status_pending":               r.PendingPods,
+	}
+}
+
+func (r *EKSResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "eks", Title: "EKS", Subtitle: r.ClusterName}
+	nodesLine := fmt.Sprintf("Nodes: %.0f", r.NodeCount)
+	if r.FailedNodes > 0 {
+		nodesLine += fmt.Sprintf(" (%.0f FAILED)", r.FailedNodes)
+	}
+	section.AddLine("%s", nodesLine)
+	section.AddLine("Node CPU: %.2f%% (avg), %.2f%% (max)%s", r.CPUAverage, r.CPUMaximum, trend("node_cpu_utilization_Average", r.CPUAverage))
+	section.AddLine("Node Memory: %.2f%% (avg), %.2f%% (max)", r.MemoryAverage, r.MemoryMaximum)
+
+	restartsLine := fmt.Sprintf("Container Restarts: %.0f", r.Restarts)
+	if len(r.RestartingPods) > 0 {
+		pods := make([]string, 0, len(r.RestartingPods))
+		for _, pod := range r.RestartingPods {
+			pods = append(pods, fmt.Sprintf("%s %.0f", pod.Pod, pod.Restarts))
+		}
+		restartsLine += " (" + strings.Join(pods, ", ") + ")"
+	}
+	section.AddLine("%s%s", restartsLine, trend("pod_number_of_container_restarts", r.Restarts))
+	section.AddLine("Pending Pods: %.0f", r.PendingPods)
+	return section
+}
+
+func (r *EKSResult) Failures() []string {
+	if r.FailedNodes > 0 {
+		return []string{fmt.Sprintf("EKS %s: %.0f failed nodes", r.ClusterName, r.FailedNodes)}
+	}
+	return nil
+}
+
+// Namespace and pod name of the pods reporting restart counts, pods come and
+// go so they aren't cached
+func listEKSPods(ctx context.Context, cwClient *cloudwatch.Client, clusterName string) ([][2]string, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("ContainerInsights"),
+		MetricName: aws.String("pod_number_of_container_restarts"),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String("ClusterName"),
+				Value: aws.String(clusterName),
+			},
+		},
+		RecentlyActive: types.RecentlyActivePt3h,
+	})
+
+	var pods [][2]string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Container Insights pod metrics: %v", err)
+		}
+
+		// Skip the FullPodName variants
+		for _, metric := range output.Metrics {
+			if len(metric.Dimensions) != 3 {
+				continue
+			}
+			var pod [2]string
+			for _, dimension := range metric.Dimensions {
+				switch aws.ToString(dimension.Name) {
+				case "Namespace":
+					pod[0] = aws.ToString(dimension.Value)
+				case "PodName":
+					pod[1] = aws.ToString(dimension.Value)
+				}
+			}
+			if pod[0] != "" && pod[1] != "" {
+				pods = append(pods, pod)
+			}
+		}
+	}
+	return pods, nil
+}
+
+// Reads the Container Insights metrics of a cluster. Restart counts are
+// cumulative per pod, so the restarts of the window are the increase of each.
+func EKSMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterName string, timeParams map[string]time.Time) (*EKSResult, error) {
+	clusterDimension := []types.Dimension{
+		{
+			Name:  aws.String("ClusterName"),
+			Value: aws.String(clusterName),
+		},
+	}
+
+	clusterMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"cluster_node_count", "Average"},
+		{"cluster_failed_node_count", "Maximum"},
+		{"node_cpu_utilization", "Average"},
+		{"node_cpu_utilization", "Maximum"},
+		{"node_memory_utilization", "Average"},
+		{"node_memory_utilization", "Maximum"},
+		{"pod_status_pending", "Maximum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range clusterMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name + "_" + metric.Statistic,
+			Namespace:  "ContainerInsights",
+			MetricName: metric.Name,
+			Dimensions: clusterDimension,
+			Statistic:  metric.Statistic,
+		})
+	}
+
+	pods, err := listEKSPods(ctx, cwClient, clusterName)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		for _, statistic := range []string{"Minimum", "Maximum"} {
+			queries = append(queries, metricQuery{
+				Key:        pod[0] + "/" + pod[1] + "/" + statistic,
+				Namespace:  "ContainerInsights",
+				MetricName: "pod_number_of_container_restarts",
+				Dimensions: []types.Dimension{
+					clusterDimension[0],
+					{Name: aws.String("Namespace"), Value: aws.String(pod[0])},
+					{Name: aws.String("PodName"), Value: aws.String(pod[1])},
+				},
+				Statistic: statistic,
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting Container Insights metrics: %v", err)
+	}
+
+	result := &EKSResult{
+		ClusterName:   clusterName,
+		NodeCount:     aggregateValues("Average", results["cluster_node_count_Average"]),
+		FailedNodes:   aggregateValues("Maximum", results["cluster_failed_node_count_Maximum"]),
+		CPUAverage:    aggregateValues("Average", results["node_cpu_utilization_Average"]),
+		CPUMaximum:    aggregateValues("Maximum", results["node_cpu_utilization_Maximum"]),
+		MemoryAverage: aggregateValues("Average", results["node_memory_utilization_Average"]),
+		MemoryMaximum: aggregateValues("Maximum", results["node_memory_utilization_Maximum"]),
+		PendingPods:   aggregateValues("Maximum", results["pod_status_pending_Maximum"]),
+	}
+
+	for _, pod := range pods {
+		key := pod[0] + "/" + pod[1]
+		restarts := aggregateValues("Maximum", results[key+"/Maximum"]) - aggregateValues("Minimum", results[key+"/Minimum"])
+		if restarts <= 0 {
+			continue
+		}
+		result.Restarts += restarts
+		result.RestartingPods = append(result.RestartingPods, EKSPodRestarts{Pod: key, Restarts: restarts})
+	}
+	sort.Slice(result.RestartingPods, func(i, j int) bool {
+		return result.RestartingPods[i].Restarts > result.RestartingPods[j].Restarts
+	})
+	if len(result.RestartingPods) > maxEKSRestartingPods {
+		result.RestartingPods = result.RestartingPods[:maxEKSRestartingPods]
+	}
+	return result, nil
+}
+
+type eksCollector struct{}
+
+func (eksCollector) Name() string { return "eks" }
+
+func (eksCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.EKS.Enabled
+}
+
+func (eksCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (eksCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return EKSMetrics(ctx, clients.CloudWatch.Get(cfg.Services.EKS.Region), cfg.Services.EKS.ClusterName, windowTimes(window))
+}
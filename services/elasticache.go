@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type ElastiCacheResult struct {
+	CacheClusterID                string
+	CPUUtilization                float64 // %
+	EngineCPUUtilization          float64 // %
+	DatabaseMemoryUsagePercentage float64
+	CacheHits                     float64
+	CacheMisses                   float64
+	Evictions                     float64
+	CurrConnections               float64
+	HitRate                       *float64 // % over the whole period, nil = no lookups
+}
+
+func (r *ElastiCacheResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"CPUUtilization":                r.CPUUtilization,
+		"EngineCPUUtilization":          r.EngineCPUUtilization,
+		"DatabaseMemoryUsagePercentage": r.DatabaseMemoryUsagePercentage,
+		"CacheHits":                     r.CacheHits,
+		"CacheMisses":                   r.CacheMisses,
+		"Evictions":                     r.Evictions,
+		"CurrConnections":               r.CurrConnections,
+	}
+	if r.HitRate != nil {
+		metrics["HitRate"] = *r.HitRate
+	}
+	return metrics
+}
+
+func (r *ElastiCacheResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "elasticache", Title: "ElastiCache", Subtitle: r.CacheClusterID}
+	section.AddLine("CPU: %.2f%%, Engine CPU: %.2f%%", r.CPUUtilization, r.EngineCPUUtilization)
+	section.AddLine("Memory: %.2f%% (max)", r.DatabaseMemoryUsagePercentage)
+	if r.HitRate != nil {
+		section.AddLine("Hits: %.0f, Misses: %.0f (%.2f%% hit rate)", r.CacheHits, r.CacheMisses, *r.HitRate)
+	} else {
+		section.AddLine("Hits: 0, Misses: 0")
+	}
+	section.AddLine("Evictions: %.0f", r.Evictions)
+	section.AddLine("Connections: %.0f (max)", r.CurrConnections)
+	return section
+}
+
+func ElastiCacheMetrics(ctx context.Context, cwClient *cloudwatch.Client, cacheClusterID string, timeParams map[string]time.Time) (*ElastiCacheResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	elastiCacheMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"CPUUtilization", "Average"},
+		{"EngineCPUUtilization", "Average"},
+		{"DatabaseMemoryUsagePercentage", "Maximum"},
+		{"CacheHits", "Sum"},
+		{"CacheMisses", "Sum"},
+		{"Evictions", "Sum"},
+		{"CurrConnections", "Maximum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range elastiCacheMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/ElastiCache",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("CacheClusterId"),
+					Value: aws.String(cacheClusterID),
+				},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting ElastiCache metrics: %v", err)
+	}
+
+	result := &ElastiCacheResult{
+		CacheClusterID:                cacheClusterID,
+		CPUUtilization:                aggregateValues("Average", results["CPUUtilization"]),
+		EngineCPUUtilization:          aggregateValues("Average", results["EngineCPUUtilization"]),
+		DatabaseMemoryUsagePercentage: aggregateValues("Maximum", results["DatabaseMemoryUsagePercentage"]),
+		CacheHits:                     aggregateValues("Sum", results["CacheHits"]),
+		CacheMisses:                   aggregateValues("Sum", results["CacheMisses"]),
+		Evictions:                     aggregateValues("Sum", results["Evictions"]),
+		CurrConnections:               aggregateValues("Maximum", results["CurrConnections"]),
+	}
+
+	if lookups := result.CacheHits + result.CacheMisses; lookups > 0 {
+		hitRate := result.CacheHits / lookups * 100
+		result.HitRate = &hitRate
+	}
+
+	return result, nil
+}
+
+type elastiCacheCollector struct{}
+
+func (elastiCacheCollector) Name() string { return "elasticache" }
+
+func (elastiCacheCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.ElastiCache.Enabled
+}
+
+func (elastiCacheCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.ElastiCache.CacheClusterIDs
+}
+
+func (elastiCacheCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, cacheClusterID string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.ElastiCache.Region, cfg.Services.ElastiCache.ResourceRegions, cacheClusterID)
+	return ElastiCacheMetrics(ctx, clients.CloudWatch.Get(region), cacheClusterID, windowTimes(window))
+}
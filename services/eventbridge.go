@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+// Helper function to get the names of the dead-letter queues of the rule targets
+func getRuleDLQNames(ctx context.Context, eventsClient *eventbridge.Client, ruleName string, eventBusName string) ([]string, error) {
+	var dlqNames []string
+	input := &eventbridge.ListTargetsByRuleInput{
+		Rule: aws.String(ruleName),
+	}
+	if eventBusName != "" {
+		input.EventBusName = aws.String(eventBusName)
+	}
+
+	for {
+		output, err := eventsClient.ListTargetsByRule(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list rule targets: %w", err)
+		}
+
+		for _, target := range output.Targets {
+			if target.DeadLetterConfig == nil || target.DeadLetterConfig.Arn == nil {
+				continue
+			}
+			// arn:aws:sqs:region:account:name
+			dlqArn := *target.DeadLetterConfig.Arn
+			dlqName := dlqArn[strings.LastIndex(dlqArn, ":")+1:]
+			if !slices.Contains(dlqNames, dlqName) {
+				dlqNames = append(dlqNames, dlqName)
+			}
+		}
+
+		if output.NextToken == nil {
+			return dlqNames, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+// Invocations of a rule and the messages waiting in the dead-letter queues of its targets
+type EventBridgeResult struct {
+	RuleName              string
+	Invocations           float64
+	FailedInvocations     float64
+	DeadLetterInvocations float64
+	// Messages across the target DLQs, nil = no target has a DLQ configured
+	DLQMessages *float64
+}
+
+func (r *EventBridgeResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"Invocations":           r.Invocations,
+		"FailedInvocations":     r.FailedInvocations,
+		"DeadLetterInvocations": r.DeadLetterInvocations,
+	}
+	if r.DLQMessages != nil {
+		metrics["DLQMessages"] = *r.DLQMessages
+	}
+	return metrics
+}
+
+func (r *EventBridgeResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "eventBridge", Title: "EventBridge", Subtitle: r.RuleName}
+	section.AddLine("Invocations: %.0f%s", r.Invocations, trend("Invocations", r.Invocations))
+	section.AddLine("Failed: %.0f%s, Sent to DLQ: %.0f", r.FailedInvocations, trend("FailedInvocations", r.FailedInvocations), r.DeadLetterInvocations)
+	if r.DLQMessages != nil {
+		section.AddLine("DLQ Messages: %.0f", *r.DLQMessages)
+	} else {
+		section.AddLine("DLQ: not configured")
+	}
+	return section
+}
+
+func EventBridgeMetrics(ctx context.Context, cwClient *cloudwatch.Client, eventsClient *eventbridge.Client, ruleName string, eventBusName string, timeParams map[string]time.Time) (*EventBridgeResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	dlqNames, err := getRuleDLQNames(ctx, eventsClient, ruleName, eventBusName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting DLQs for %s: %v", ruleName, err)
+	}
+
+	// Rules on the default bus only have the RuleName dimension
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("RuleName"),
+			Value: aws.String(ruleName),
+		},
+	}
+	if eventBusName != "" && eventBusName != "default" {
+		dimensions = append(dimensions, types.Dimension{
+			Name:  aws.String("EventBusName"),
+			Value: aws.String(eventBusName),
+		})
+	}
+
+	var queries []metricQuery
+	for _, metricName := range []string{"Invocations", "FailedInvocations", "DeadLetterInvocations"} {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "AWS/Events",
+			MetricName: metricName,
+			Dimensions: dimensions,
+			Statistic:  "Sum",
+		})
+	}
+	for _, dlqName := range dlqNames {
+		queries = append(queries, metricQuery{
+			Key:        "DLQ/" + dlqName,
+			Namespace:  "AWS/SQS",
+			MetricName: "ApproximateNumberOfMessagesVisible",
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("QueueName"),
+					Value: aws.String(dlqName),
+				},
+			},
+			Statistic: "Maximum",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting EventBridge metrics: %v", err)
+	}
+
+	result := &EventBridgeResult{
+		RuleName:              ruleName,
+		Invocations:           aggregateValues("Sum", results["Invocations"]),
+		FailedInvocations:     aggregateValues("Sum", results["FailedInvocations"]),
+		DeadLetterInvocations: aggregateValues("Sum", results["DeadLetterInvocations"]),
+	}
+
+	if len(dlqNames) > 0 {
+		var dlqMessages float64
+		for _, dlqName := range dlqNames {
+			// Queue depth is a gauge, the latest datapoint is the current state
+			if values := results["DLQ/"+dlqName]; len(values) > 0 {
+				dlqMessages += values[0]
+			}
+		}
+		result.DLQMessages = &dlqMessages
+	}
+
+	return result, nil
+}
+
+type eventBridgeCollector struct{}
+
+func (eventBridgeCollector) Name() string { return "eventBridge" }
+
+func (eventBridgeCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.EventBridge.Enabled
+}
+
+func (eventBridgeCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.EventBridge.RuleNames
+}
+
+func (eventBridgeCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, ruleName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.EventBridge.Region, cfg.Services.EventBridge.ResourceRegions, ruleName)
+	return EventBridgeMetrics(ctx, clients.CloudWatch.Get(region), clients.EventBridge.Get(region), ruleName, cfg.Services.EventBridge.EventBusName, windowTimes(window))
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty"
+	"github.com/aws/aws-sdk-go-v2/service/guardduty/types"
+)
+
+// GetFindings accepts up to 50 finding IDs per request
+const maxGuardDutyFindings = 50
+
+// Helper function to get the detector of the region when none is configured
+func getDetectorID(ctx context.Context, gdClient *guardduty.Client) (string, error) {
+	output, err := gdClient.ListDetectors(ctx, &guardduty.ListDetectorsInput{})
+	if err != nil {
+		return "", fmt.Errorf("error listing GuardDuty detectors: %v", err)
+	}
+	if len(output.DetectorIds) == 0 {
+		return "", fmt.Errorf("GuardDuty is not enabled in this region")
+	}
+	return output.DetectorIds[0], nil
+}
+
+// Findings sharing a title, with the highest severity among them
+type GuardDutyFinding struct {
+	Title    string
+	Severity float64
+	Count    float64
+}
+
+// Findings created in the window, grouped by severity: High (7+, including
+// Critical), Medium (4-6.9) and Low. TopFindings are the most severe titles.
+type GuardDutyResult struct {
+	High        float64
+	Medium      float64
+	Low         float64
+	TopFindings []GuardDutyFinding
+}
+
+func (r *GuardDutyResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"High":   r.High,
+		"Medium": r.Medium,
+		"Low":    r.Low,
+	}
+}
+
+func (r *GuardDutyResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "guardduty", Title: "GuardDuty"}
+	section.AddLine("New Findings: High: %.0f, Medium: %.0f, Low: %.0f", r.High, r.Medium, r.Low)
+
+	if len(r.TopFindings) > 0 {
+		section.AddLine("Top Findings:")
+		for _, finding := range r.TopFindings {
+			section.AddLine("%s (severity %.1f, x%.0f)", finding.Title, finding.Severity, finding.Count)
+		}
+	}
+	return section
+}
+
+func GuardDutyMetrics(ctx context.Context, gdClient *guardduty.Client, detectorID string, topFindings int, timeParams map[string]time.Time) (*GuardDutyResult, error) {
+	if detectorID == "" {
+		var err error
+		if detectorID, err = getDetectorID(ctx, gdClient); err != nil {
+			return nil, err
+		}
+	}
+
+	// updatedAt also catches recurring findings, new ones are filtered on createdAt below
+	input := &guardduty.ListFindingsInput{
+		DetectorId: aws.String(detectorID),
+		FindingCriteria: &types.FindingCriteria{
+			Criterion: map[string]types.Condition{
+				"updatedAt": {
+					GreaterThanOrEqual: aws.Int64(timeParams["startTime"].UnixMilli()),
+				},
+				"service.archived": {
+					Equals: []string{"false"},
+				},
+			},
+		},
+		MaxResults: aws.Int32(maxGuardDutyFindings),
+	}
+
+	var findingIDs []string
+	paginator := guardduty.NewListFindingsPaginator(gdClient, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing GuardDuty findings: %v", err)
+		}
+		findingIDs = append(findingIDs, output.FindingIds...)
+	}
+
+	result := &GuardDutyResult{}
+	titles := map[string]*GuardDutyFinding{}
+
+	for batchStart := 0; batchStart < len(findingIDs); batchStart += maxGuardDutyFindings {
+		batchEnd := min(batchStart+maxGuardDutyFindings, len(findingIDs))
+
+		output, err := gdClient.GetFindings(ctx, &guardduty.GetFindingsInput{
+			DetectorId: aws.String(detectorID),
+			FindingIds: findingIDs[batchStart:batchEnd],
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting GuardDuty findings: %v", err)
+		}
+
+		for _, finding := range output.Findings {
+			createdAt, err := time.Parse(time.RFC3339, aws.ToString(finding.CreatedAt))
+			if err == nil && createdAt.Before(timeParams["startTime"]) {
+				continue
+			}
+
+			severity := aws.ToFloat64(finding.Severity)
+			switch {
+			case severity >= 7:
+				result.High++
+			case severity >= 4:
+				result.Medium++
+			default:
+				result.Low++
+			}
+
+			title := aws.ToString(finding.Title)
+			summary, exists := titles[title]
+			if !exists {
+				summary = &GuardDutyFinding{Title: title}
+				titles[title] = summary
+			}
+			summary.Severity = max(summary.Severity, severity)
+			summary.Count++
+		}
+	}
+
+	// Most severe first, then most frequent
+	summaries := make([]GuardDutyFinding, 0, len(titles))
+	for _, summary := range titles {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Severity != summaries[j].Severity {
+			return summaries[i].Severity > summaries[j].Severity
+		}
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Title < summaries[j].Title
+	})
+	if len(summaries) > topFindings {
+		summaries = summaries[:topFindings]
+	}
+	result.TopFindings = summaries
+
+	return result, nil
+}
+
+type guardDutyCollector struct{}
+
+func (guardDutyCollector) Name() string { return "guardduty" }
+
+func (guardDutyCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.GuardDuty.Enabled && window.IsDailyReport
+}
+
+func (guardDutyCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (guardDutyCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	topFindings := cfg.Services.GuardDuty.TopFindings
+	if topFindings == 0 {
+		topFindings = 5
+	}
+
+	return GuardDutyMetrics(ctx, clients.GuardDuty.Get(cfg.Services.GuardDuty.Region), cfg.Services.GuardDuty.DetectorID, topFindings, windowTimes(window))
+}
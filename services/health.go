@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/health"
+	"github.com/aws/aws-sdk-go-v2/service/health/types"
+)
+
+const (
+	// DescribeAffectedEntities accepts up to 10 event ARNs per request
+	maxHealthEventArns = 10
+	// Affected resources listed under each event
+	maxHealthEntities = 3
+	// Region of the events of global services (IAM, Route53...)
+	healthGlobalRegion = "global"
+)
+
+// An open or upcoming AWS Health event of the account, eg: an EC2 instance
+// retirement or an RDS mandatory upgrade
+type HealthEvent struct {
+	Service       string
+	EventTypeCode string // eg: AWS_EC2_INSTANCE_RETIREMENT_SCHEDULED
+	Category      string // "issue" or "scheduledChange"
+	Region        string
+	Status        string // "open" or "upcoming"
+	StartTime     time.Time
+	// Affected resources (up to 3), eg: instance IDs
+	Entities      []string
+	TotalEntities int
+}
+
+type HealthResult struct {
+	Issues           float64
+	ScheduledChanges float64
+	// Events in report order: issues first, then changes by start time
+	Events   []HealthEvent
+	Location *time.Location
+}
+
+func (r *HealthResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Issues":           r.Issues,
+		"ScheduledChanges": r.ScheduledChanges,
+	}
+}
+
+func (r *HealthResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "health", Title: "AWS Health"}
+	section.AddLine("Open Issues: %.0f", r.Issues)
+	section.AddLine("Scheduled Changes: %.0f", r.ScheduledChanges)
+
+	for _, event := range r.Events {
+		section.AddLine("")
+		when := "since"
+		if event.Status == string(types.EventStatusCodeUpcoming) {
+			when = "from"
+		}
+		section.AddLine("%s %s (%s), %s %s", event.Service, event.EventTypeCode, event.Region, when, event.StartTime.In(r.Location).Format("02/01 15:04"))
+		if len(event.Entities) > 0 {
+			entities := strings.Join(event.Entities, ", ")
+			if more := event.TotalEntities - len(event.Entities); more > 0 {
+				entities += fmt.Sprintf(" +%d more", more)
+			}
+			section.AddLine("Affected: %s", entities)
+		}
+	}
+	return section
+}
+
+// Open and upcoming issues and scheduled changes in the given regions (plus
+// the global ones). The Health API requires a Business, Enterprise On-Ramp or
+// Enterprise support plan.
+func HealthMetrics(ctx context.Context, healthClient *health.Client, regions []string, topEvents int, location *time.Location) (*HealthResult, error) {
+	filter := &types.EventFilter{
+		EventStatusCodes:    []types.EventStatusCode{types.EventStatusCodeOpen, types.EventStatusCodeUpcoming},
+		EventTypeCategories: []types.EventTypeCategory{types.EventTypeCategoryIssue, types.EventTypeCategoryScheduledChange},
+		Regions:             append(append([]string{}, regions...), healthGlobalRegion),
+	}
+
+	result := &HealthResult{Location: location}
+	var events []HealthEvent
+	var arns []string
+
+	paginator := health.NewDescribeEventsPaginator(healthClient, &health.DescribeEventsInput{Filter: filter})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error describing AWS Health events: %v", err)
+		}
+
+		for _, event := range output.Events {
+			switch event.EventTypeCategory {
+			case types.EventTypeCategoryIssue:
+				result.Issues++
+			case types.EventTypeCategoryScheduledChange:
+				result.ScheduledChanges++
+			}
+			events = append(events, HealthEvent{
+				Service:       aws.ToString(event.Service),
+				EventTypeCode: aws.ToString(event.EventTypeCode),
+				Category:      string(event.EventTypeCategory),
+				Region:        aws.ToString(event.Region),
+				Status:        string(event.StatusCode),
+				StartTime:     aws.ToTime(event.StartTime),
+			})
+			arns = append(arns, aws.ToString(event.Arn))
+		}
+	}
+
+	// Issues first, then the changes coming soonest
+	indexes := make([]int, len(events))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := events[indexes[i]], events[indexes[j]]
+		if a.Category != b.Category {
+			return a.Category == string(types.EventTypeCategoryIssue)
+		}
+		return a.StartTime.Before(b.StartTime)
+	})
+	if len(indexes) > topEvents {
+		indexes = indexes[:topEvents]
+	}
+
+	topArns := make([]string, 0, len(indexes))
+	for _, index := range indexes {
+		result.Events = append(result.Events, events[index])
+		topArns = append(topArns, arns[index])
+	}
+
+	if err := addHealthEntities(ctx, healthClient, topArns, result.Events); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Fills the affected resources of the events, in the order of their ARNs
+func addHealthEntities(ctx context.Context, healthClient *health.Client, arns []string, events []HealthEvent) error {
+	indexByArn := make(map[string]int, len(arns))
+	for i, arn := range arns {
+		indexByArn[arn] = i
+	}
+
+	for batchStart := 0; batchStart < len(arns); batchStart += maxHealthEventArns {
+		batchEnd := min(batchStart+maxHealthEventArns, len(arns))
+
+		paginator := health.NewDescribeAffectedEntitiesPaginator(healthClient, &health.DescribeAffectedEntitiesInput{
+			Filter: &types.EntityFilter{EventArns: arns[batchStart:batchEnd]},
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return fmt.Errorf("error describing AWS Health affected entities: %v", err)
+			}
+
+			for _, entity := range output.Entities {
+				index, exists := indexByArn[aws.ToString(entity.EventArn)]
+				if !exists || aws.ToString(entity.EntityValue) == "" {
+					continue
+				}
+				event := &events[index]
+				event.TotalEntities++
+				if len(event.Entities) < maxHealthEntities {
+					event.Entities = append(event.Entities, aws.ToString(entity.EntityValue))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type healthCollector struct{}
+
+func (healthCollector) Name() string { return "health" }
+
+func (healthCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Health.Enabled && window.IsDailyReport
+}
+
+func (healthCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (healthCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	regions := cfg.Services.Health.Regions
+	if len(regions) == 0 {
+		regions = []string{clients.Region}
+	}
+
+	topEvents := cfg.Services.Health.TopEvents
+	if topEvents == 0 {
+		topEvents = 10
+	}
+
+	return HealthMetrics(ctx, clients.Health, regions, topEvents, window.Location)
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// Name of the root user in the credential report
+const iamRootUser = "<root_account>"
+
+type IAMAccessKey struct {
+	User    string
+	Key     int // 1 or 2, as numbered in the credential report
+	AgeDays float64
+}
+
+type IAMResult struct {
+	AccessKeyMaxAgeDays int
+	// Active keys not rotated for more than AccessKeyMaxAgeDays
+	OldAccessKeys []IAMAccessKey
+	// Console users (and the root user) without an MFA device
+	UsersWithoutMFA []string
+	// Created in the window
+	NewUsers []string
+	NewRoles []string
+}
+
+func (r *IAMResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"OldAccessKeys":   float64(len(r.OldAccessKeys)),
+		"UsersWithoutMFA": float64(len(r.UsersWithoutMFA)),
+		"NewUsers":        float64(len(r.NewUsers)),
+		"NewRoles":        float64(len(r.NewRoles)),
+	}
+}
+
+func (r *IAMResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "iam", Title: "IAM"}
+	section.AddLine("Access Keys older than %d days: %d", r.AccessKeyMaxAgeDays, len(r.OldAccessKeys))
+	for _, key := range r.OldAccessKeys {
+		section.AddLine("- %s (key %d, %.0f days)", key.User, key.Key, key.AgeDays)
+	}
+	section.AddLine("Users without MFA: %d", len(r.UsersWithoutMFA))
+	if len(r.UsersWithoutMFA) > 0 {
+		section.AddLine("- %s", strings.Join(r.UsersWithoutMFA, ", "))
+	}
+	if len(r.NewUsers) > 0 {
+		section.AddLine("New Users: %s", strings.Join(r.NewUsers, ", "))
+	}
+	if len(r.NewRoles) > 0 {
+		section.AddLine("New Roles: %s", strings.Join(r.NewRoles, ", "))
+	}
+	return section
+}
+
+// Generates the credential report (IAM reuses one generated in the last 4
+// hours) and waits for it
+func getCredentialReport(ctx context.Context, iamClient *iam.Client) ([]map[string]string, error) {
+	for {
+		output, err := iamClient.GenerateCredentialReport(ctx, &iam.GenerateCredentialReportInput{})
+		if err != nil {
+			return nil, fmt.Errorf("error generating IAM credential report: %v", err)
+		}
+		if output.State == iamTypes.ReportStateTypeComplete {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
+	}
+
+	output, err := iamClient.GetCredentialReport(ctx, &iam.GetCredentialReportInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting IAM credential report: %v", err)
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(output.Content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing IAM credential report: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	// One row per user, keyed by the header columns
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(records[0]))
+		for i, column := range records[0] {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Service-linked roles are left out of the new roles, AWS creates them
+func IAMMetrics(ctx context.Context, iamClient *iam.Client, accessKeyMaxAgeDays int, timeParams map[string]time.Time) (*IAMResult, error) {
+	rows, err := getCredentialReport(ctx, iamClient)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IAMResult{AccessKeyMaxAgeDays: accessKeyMaxAgeDays}
+	for _, row := range rows {
+		user := row["user"]
+
+		for key := 1; key <= 2; key++ {
+			prefix := fmt.Sprintf("access_key_%d_", key)
+			if row[prefix+"active"] != "true" {
+				continue
+			}
+			// "N/A" for keys never rotated, which then fail to parse
+			rotated, err := time.Parse(time.RFC3339, row[prefix+"last_rotated"])
+			if err != nil {
+				continue
+			}
+			ageDays := timeParams["endTime"].Sub(rotated).Hours() / 24
+			if ageDays > float64(accessKeyMaxAgeDays) {
+				result.OldAccessKeys = append(result.OldAccessKeys, IAMAccessKey{User: user, Key: key, AgeDays: ageDays})
+			}
+		}
+
+		if (user == iamRootUser || row["password_enabled"] == "true") && row["mfa_active"] != "true" {
+			result.UsersWithoutMFA = append(result.UsersWithoutMFA, user)
+		}
+
+		created, err := time.Parse(time.RFC3339, row["user_creation_time"])
+		if user != iamRootUser && err == nil && !created.Before(timeParams["startTime"]) {
+			result.NewUsers = append(result.NewUsers, user)
+		}
+	}
+
+	paginator := iam.NewListRolesPaginator(iamClient, &iam.ListRolesInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing IAM roles: %v", err)
+		}
+		for _, role := range output.Roles {
+			if strings.HasPrefix(aws.ToString(role.Path), "/aws-service-role/") {
+				continue
+			}
+			if !aws.ToTime(role.CreateDate).Before(timeParams["startTime"]) {
+				result.NewRoles = append(result.NewRoles, aws.ToString(role.RoleName))
+			}
+		}
+	}
+
+	return result, nil
+}
+
+type iamCollector struct{}
+
+func (iamCollector) Name() string { return "iam" }
+
+func (iamCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.IAM.Enabled && window.IsDailyReport
+}
+
+func (iamCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (iamCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	accessKeyMaxAgeDays := cfg.Services.IAM.AccessKeyMaxAgeDays
+	if accessKeyMaxAgeDays == 0 {
+		accessKeyMaxAgeDays = 90
+	}
+
+	return IAMMetrics(ctx, clients.IAM, accessKeyMaxAgeDays, windowTimes(window))
+}
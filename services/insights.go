@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// Runs a Logs Insights query and waits for it to finish.
+// Each row is returned as a field -> value map.
+func runInsightsQuery(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, query string, timeParams map[string]time.Time) ([]map[string]string, error) {
+	startOutput, err := logsClient.StartQuery(ctx, &cloudwatchlogs.StartQueryInput{
+		LogGroupName: aws.String(logGroupName),
+		QueryString:  aws.String(query),
+		StartTime:    aws.Int64(timeParams["startTime"].Unix()),
+		EndTime:      aws.Int64(timeParams["endTime"].Unix()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error starting insights query: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(1 * time.Second):
+		}
+
+		output, err := logsClient.GetQueryResults(ctx, &cloudwatchlogs.GetQueryResultsInput{
+			QueryId: startOutput.QueryId,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting insights query results: %v", err)
+		}
+
+		switch output.Status {
+		case types.QueryStatusComplete:
+			rows := make([]map[string]string, 0, len(output.Results))
+			for _, result := range output.Results {
+				row := map[string]string{}
+				for _, field := range result {
+					if field.Field != nil && field.Value != nil {
+						row[*field.Field] = *field.Value
+					}
+				}
+				rows = append(rows, row)
+			}
+			return rows, nil
+		case types.QueryStatusFailed, types.QueryStatusCancelled, types.QueryStatusTimeout:
+			return nil, fmt.Errorf("insights query ended with status %s", output.Status)
+		}
+	}
+}
+
+func parseInsightsFloat(value string) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0.0
+	}
+	return parsed
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type KinesisResult struct {
+	StreamName      string
+	IncomingRecords float64
+	IncomingBytes   float64 // MB
+	// Consumer lag of the slowest consumer in the window
+	IteratorAgeMilliseconds            float64
+	ReadProvisionedThroughputExceeded  float64
+	WriteProvisionedThroughputExceeded float64
+	// Consumers are flagged as lagging above it, 0 = never
+	IteratorAgeThresholdMs float64
+}
+
+func (r *KinesisResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"IncomingRecords":                    r.IncomingRecords,
+		"IncomingBytes":                      r.IncomingBytes,
+		"IteratorAgeMilliseconds":            r.IteratorAgeMilliseconds,
+		"ReadProvisionedThroughputExceeded":  r.ReadProvisionedThroughputExceeded,
+		"WriteProvisionedThroughputExceeded": r.WriteProvisionedThroughputExceeded,
+	}
+}
+
+func (r *KinesisResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "kinesis", Title: "Kinesis", Subtitle: r.StreamName}
+	section.AddLine("Incoming: %.0f records%s, %.2f MB", r.IncomingRecords, trend("IncomingRecords", r.IncomingRecords), r.IncomingBytes)
+
+	iteratorAgeLine := fmt.Sprintf("Iterator Age: %.0f ms (max)", r.IteratorAgeMilliseconds)
+	if r.IteratorAgeThresholdMs > 0 && r.IteratorAgeMilliseconds > r.IteratorAgeThresholdMs {
+		iteratorAgeLine += " (CONSUMERS LAGGING)"
+	}
+	section.AddLine("%s", iteratorAgeLine)
+	section.AddLine("Throughput Exceeded: %.0f read, %.0f write", r.ReadProvisionedThroughputExceeded, r.WriteProvisionedThroughputExceeded)
+	return section
+}
+
+func KinesisMetrics(ctx context.Context, cwClient *cloudwatch.Client, streamName string, iteratorAgeThresholdMs float64, timeParams map[string]time.Time) (*KinesisResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	kinesisMetrics := []struct {
+		Key       string
+		Name      string
+		Statistic string
+	}{
+		{"IncomingRecords", "IncomingRecords", "Sum"},
+		{"IncomingBytes", "IncomingBytes", "Sum"},
+		{"IteratorAgeMilliseconds", "GetRecords.IteratorAgeMilliseconds", "Maximum"},
+		{"ReadProvisionedThroughputExceeded", "ReadProvisionedThroughputExceeded", "Sum"},
+		{"WriteProvisionedThroughputExceeded", "WriteProvisionedThroughputExceeded", "Sum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range kinesisMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Key,
+			Namespace:  "AWS/Kinesis",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("StreamName"),
+					Value: aws.String(streamName),
+				},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Kinesis metrics: %v", err)
+	}
+
+	return &KinesisResult{
+		StreamName:                         streamName,
+		IncomingRecords:                    aggregateValues("Sum", results["IncomingRecords"]),
+		IncomingBytes:                      aggregateValues("Sum", results["IncomingBytes"]) / (1024.0 * 1024.0), // Convert to MB
+		IteratorAgeMilliseconds:            aggregateValues("Maximum", results["IteratorAgeMilliseconds"]),
+		ReadProvisionedThroughputExceeded:  aggregateValues("Sum", results["ReadProvisionedThroughputExceeded"]),
+		WriteProvisionedThroughputExceeded: aggregateValues("Sum", results["WriteProvisionedThroughputExceeded"]),
+		IteratorAgeThresholdMs:             iteratorAgeThresholdMs,
+	}, nil
+}
+
+type kinesisCollector struct{}
+
+func (kinesisCollector) Name() string { return "kinesis" }
+
+func (kinesisCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Kinesis.Enabled
+}
+
+func (kinesisCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Kinesis.StreamNames
+}
+
+func (kinesisCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, streamName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.Kinesis.Region, cfg.Services.Kinesis.ResourceRegions, streamName)
+	return KinesisMetrics(ctx, clients.CloudWatch.Get(region), streamName, cfg.Services.Kinesis.IteratorAgeThresholdMs, windowTimes(window))
+}
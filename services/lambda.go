@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type LambdaResult struct {
+	FunctionName         string
+	Invocations          float64
+	Errors               float64
+	Throttles            float64
+	DurationAverage      float64 // ms
+	DurationP95          float64 // ms
+	ConcurrentExecutions float64
+}
+
+func (r *LambdaResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Invocations":          r.Invocations,
+		"Errors":               r.Errors,
+		"Throttles":            r.Throttles,
+		"Duration_Average":     r.DurationAverage,
+		"Duration_p95":         r.DurationP95,
+		"ConcurrentExecutions": r.ConcurrentExecutions,
+	}
+}
+
+// All functions share the "Lambda Functions" section
+func (r *LambdaResult) Render(trend utils.TrendFunc) utils.Section {
+	var errorRate float64
+	if r.Invocations > 0 {
+		errorRate = r.Errors / r.Invocations * 100
+	}
+
+	section := utils.Section{Service: "lambda", Title: "Lambda Functions"}
+	section.AddLine("%s:", r.FunctionName)
+	section.AddLine("Invocations: %.0f%s", r.Invocations, trend("Invocations", r.Invocations))
+	section.AddLine("Errors: %.0f (%.2f%%)%s", r.Errors, errorRate, trend("Errors", r.Errors))
+	section.AddLine("Throttles: %.0f", r.Throttles)
+	section.AddLine("Duration: %.0f ms (avg), %.0f ms (p95)", r.DurationAverage, r.DurationP95)
+	section.AddLine("Concurrent Executions: %.0f (max)", r.ConcurrentExecutions)
+	return section
+}
+
+func LambdaMetrics(ctx context.Context, cwClient *cloudwatch.Client, functionName string, timeParams map[string]time.Time) (*LambdaResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	lambdaMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"Invocations", "Sum"},
+		{"Errors", "Sum"},
+		{"Throttles", "Sum"},
+		{"Duration", "Average"},
+		{"Duration", "p95"},
+		{"ConcurrentExecutions", "Maximum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range lambdaMetrics {
+		metricKey := metric.Name
+		if metric.Name == "Duration" {
+			metricKey = fmt.Sprintf("%s_%s", metric.Name, metric.Statistic)
+		}
+
+		queries = append(queries, metricQuery{
+			Key:        metricKey,
+			Namespace:  "AWS/Lambda",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("FunctionName"),
+					Value: aws.String(functionName),
+				},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Lambda metrics: %v", err)
+	}
+
+	return &LambdaResult{
+		FunctionName:         functionName,
+		Invocations:          aggregateValues("Sum", results["Invocations"]),
+		Errors:               aggregateValues("Sum", results["Errors"]),
+		Throttles:            aggregateValues("Sum", results["Throttles"]),
+		DurationAverage:      aggregateValues("Average", results["Duration_Average"]),
+		DurationP95:          aggregateValues("p95", results["Duration_p95"]),
+		ConcurrentExecutions: aggregateValues("Maximum", results["ConcurrentExecutions"]),
+	}, nil
+}
+
+type lambdaCollector struct{}
+
+func (lambdaCollector) Name() string { return "lambda" }
+
+func (lambdaCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Lambda.Enabled
+}
+
+func (lambdaCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Lambda.FunctionNames
+}
+
+func (lambdaCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, functionName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.Lambda.Region, cfg.Services.Lambda.ResourceRegions, functionName)
+	return LambdaMetrics(ctx, clients.CloudWatch.Get(region), functionName, windowTimes(window))
+}
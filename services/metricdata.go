@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"go.uber.org/zap"
+)
+
+// GetMetricData accepts up to 500 queries per request
+const maxMetricDataQueries = 500
+
+type metricQuery struct {
+	Key        string // Result key
+	Namespace  string
+	MetricName string
+	Dimensions []types.Dimension
+	Statistic  string
+	Unit       string // Optional
+}
+
+// Aggregates the datapoints of the whole window: sums are added up, maximums and
+// minimums keep the extreme and everything else (averages, percentiles) is averaged
+func aggregateValues(statistic string, values []float64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+
+	var value float64
+	switch statistic {
+	case "Sum", "SampleCount":
+		for _, v := range values {
+			value += v
+		}
+	case "Maximum":
+		value = slices.Max(values)
+	case "Minimum":
+		value = slices.Min(values)
+	default:
+		for _, v := range values {
+			value += v
+		}
+		value = value / float64(len(values))
+	}
+	return value
+}
+
+// CloudWatch period of a report window: 5 minute datapoints for sub-hourly
+// windows, hourly ones up to a day and daily ones beyond
+func metricPeriod(timeParams map[string]time.Time) int32 {
+	window := timeParams["endTime"].Sub(timeParams["startTime"])
+	switch {
+	case window >= 24*time.Hour:
+		return 86400
+	case window >= time.Hour:
+		return 3600
+	}
+	return 300
+}
+
+// Datapoints of a single query, newest first
+type metricSeries struct {
+	Timestamps []time.Time
+	Values     []float64
+}
+
+// Fetches all queries with batched GetMetricData calls.
+// Values are returned per query key, newest datapoint first.
+func getMetricData(
+	ctx context.Context,
+	cwClient *cloudwatch.Client,
+	queries []metricQuery,
+	startTime time.Time,
+	endTime time.Time,
+	period int32,
+) (map[string][]float64, error) {
+	series, err := getMetricSeries(ctx, cwClient, queries, startTime, endTime, period)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]float64, len(series))
+	for key, s := range series {
+		results[key] = s.Values
+	}
+	return results, nil
+}
+
+// Same as getMetricData, but a failed batch is fetched again one query at a
+// time, so a failing metric only loses its own values. Failed queries are
+// logged and left out of the results, the error is kept for when all fail.
+func getMetricDataPartial(
+	ctx context.Context,
+	cwClient *cloudwatch.Client,
+	queries []metricQuery,
+	startTime time.Time,
+	endTime time.Time,
+	period int32,
+) (map[string][]float64, error) {
+	results, err := getMetricData(ctx, cwClient, queries, startTime, endTime, period)
+	if err == nil || ctx.Err() != nil {
+		return results, err
+	}
+
+	results = make(map[string][]float64, len(queries))
+	for _, query := range queries {
+		values, queryErr := getMetricData(ctx, cwClient, []metricQuery{query}, startTime, endTime, period)
+		if queryErr != nil {
+			utils.Logger.Error("Failed to get metric",
+				zap.Error(queryErr),
+				zap.String("namespace", query.Namespace),
+				zap.String("metricName", query.MetricName),
+				zap.String("statistic", query.Statistic),
+			)
+			continue
+		}
+		results[query.Key] = values[query.Key]
+	}
+	if len(results) == 0 {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Same as getMetricData, keeping the timestamp of each datapoint
+func getMetricSeries(
+	ctx context.Context,
+	cwClient *cloudwatch.Client,
+	queries []metricQuery,
+	startTime time.Time,
+	endTime time.Time,
+	period int32,
+) (map[string]metricSeries, error) {
+	results := make(map[string]metricSeries, len(queries))
+
+	for batchStart := 0; batchStart < len(queries); batchStart += maxMetricDataQueries {
+		batchEnd := min(batchStart+maxMetricDataQueries, len(queries))
+
+		// Query IDs must start with a lowercase letter, so keys are mapped to m0, m1, ...
+		idToKey := make(map[string]string, batchEnd-batchStart)
+		dataQueries := make([]types.MetricDataQuery, 0, batchEnd-batchStart)
+		for i, query := range queries[batchStart:batchEnd] {
+			id := fmt.Sprintf("m%d", i)
+			idToKey[id] = query.Key
+
+			metricStat := &types.MetricStat{
+				Metric: &types.Metric{
+					Namespace:  aws.String(query.Namespace),
+					MetricName: aws.String(query.MetricName),
+					Dimensions: query.Dimensions,
+				},
+				Period: aws.Int32(period),
+				Stat:   aws.String(query.Statistic),
+			}
+			if query.Unit != "" {
+				metricStat.Unit = types.StandardUnit(query.Unit)
+			}
+
+			dataQueries = append(dataQueries, types.MetricDataQuery{
+				Id:         aws.String(id),
+				MetricStat: metricStat,
+			})
+		}
+
+		input := &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: dataQueries,
+			StartTime:         aws.Time(startTime),
+			EndTime:           aws.Time(endTime),
+			ScanBy:            types.ScanByTimestampDescending,
+		}
+
+		paginator := cloudwatch.NewGetMetricDataPaginator(cwClient, input)
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error getting metric data: %v", err)
+			}
+
+			for _, result := range output.MetricDataResults {
+				if result.Id == nil {
+					continue
+				}
+				key := idToKey[*result.Id]
+				series := results[key]
+				series.Timestamps = append(series.Timestamps, result.Timestamps...)
+				series.Values = append(series.Values, result.Values...)
+				results[key] = series
+			}
+		}
+	}
+
+	return results, nil
+}
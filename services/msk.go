@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type MSKBroker struct {
+	ID         string
+	CPUAverage float64 // % user + system
+	DiskUsed   float64 // % of the data logs volume, peak
+}
+
+type MSKConsumerGroup struct {
+	Name   string
+	MaxLag float64 // Messages, worst partition of all its topics
+}
+
+type MSKResult struct {
+	ClusterName string
+	// One active controller is healthy, 0 or several means a split cluster
+	ActiveControllerCount  float64
+	OfflinePartitionsCount float64
+	Brokers                []MSKBroker        // Sorted by ID
+	ConsumerGroups         []MSKConsumerGroup // Highest lag first, only when enabled
+}
+
+func (r *MSKResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"ActiveControllerCount":  r.ActiveControllerCount,
+		"OfflinePartitionsCount": r.OfflinePartitionsCount,
+	}
+	for _, broker := range r.Brokers {
+		metrics["Broker_"+broker.ID+"_CpuUtilization"] = broker.CPUAverage
+		metrics["Broker_"+broker.ID+"_KafkaDataLogsDiskUsed"] = broker.DiskUsed
+	}
+	for _, group := range r.ConsumerGroups {
+		metrics["ConsumerGroup_"+group.Name+"_MaxOffsetLag"] = group.MaxLag
+	}
+	return metrics
+}
+
+func (r *MSKResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "msk", Title: "MSK", Subtitle: r.ClusterName}
+	section.AddLine("Active Controllers: %.0f", r.ActiveControllerCount)
+	section.AddLine("Offline Partitions: %.0f", r.OfflinePartitionsCount)
+	for _, broker := range r.Brokers {
+		section.AddLine("Broker %s: CPU %.2f%%, Disk %.2f%%", broker.ID, broker.CPUAverage, broker.DiskUsed)
+	}
+	for _, group := range r.ConsumerGroups {
+		section.AddLine("Lag %s: %.0f%s", group.Name, group.MaxLag, trend("ConsumerGroup_"+group.Name+"_MaxOffsetLag", group.MaxLag))
+	}
+	return section
+}
+
+func (r *MSKResult) Failures() []string {
+	var failures []string
+	// No brokers found means no metrics at all, not a missing controller
+	if r.ActiveControllerCount != 1 && len(r.Brokers) > 0 {
+		failures = append(failures, fmt.Sprintf("MSK %s: %.0f active controllers", r.ClusterName, r.ActiveControllerCount))
+	}
+	if r.OfflinePartitionsCount > 0 {
+		failures = append(failures, fmt.Sprintf("MSK %s: %.0f offline partitions", r.ClusterName, r.OfflinePartitionsCount))
+	}
+	return failures
+}
+
+// Dimensions (name -> value) of the metrics of a cluster, eg: one per broker.
+// Metrics with another number of dimensions than size are skipped.
+func listMSKMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterName string, metricName string, size int) ([]map[string]string, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/Kafka"),
+		MetricName: aws.String(metricName),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String("Cluster Name"),
+				Value: aws.String(clusterName),
+			},
+		},
+	})
+
+	var metrics []map[string]string
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing MSK %s metrics: %v", metricName, err)
+		}
+		for _, metric := range output.Metrics {
+			if len(metric.Dimensions) != size {
+				continue
+			}
+			dimensions := make(map[string]string, size)
+			for _, dimension := range metric.Dimensions {
+				dimensions[aws.ToString(dimension.Name)] = aws.ToString(dimension.Value)
+			}
+			metrics = append(metrics, dimensions)
+		}
+	}
+	return metrics, nil
+}
+
+// Brokers are found from their CloudWatch metrics. With consumerLag, the
+// groups are too, from the offset lag metrics MSK publishes per topic.
+func MSKMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterName string, consumerLag bool, timeParams map[string]time.Time) (*MSKResult, error) {
+	clusterDimension := types.Dimension{
+		Name:  aws.String("Cluster Name"),
+		Value: aws.String(clusterName),
+	}
+
+	queries := []metricQuery{
+		{Key: "ActiveControllerCount", Namespace: "AWS/Kafka", MetricName: "ActiveControllerCount", Dimensions: []types.Dimension{clusterDimension}, Statistic: "Sum"},
+		{Key: "OfflinePartitionsCount", Namespace: "AWS/Kafka", MetricName: "OfflinePartitionsCount", Dimensions: []types.Dimension{clusterDimension}, Statistic: "Maximum"},
+	}
+
+	brokers, err := listMSKMetrics(ctx, cwClient, clusterName, "CpuUser", 2)
+	if err != nil {
+		return nil, err
+	}
+	var brokerIDs []string
+	for _, dimensions := range brokers {
+		brokerIDs = append(brokerIDs, dimensions["Broker ID"])
+	}
+	sort.Slice(brokerIDs, func(i, j int) bool {
+		left, _ := strconv.Atoi(brokerIDs[i])
+		right, _ := strconv.Atoi(brokerIDs[j])
+		return left < right
+	})
+	for _, brokerID := range brokerIDs {
+		dimensions := []types.Dimension{clusterDimension, {Name: aws.String("Broker ID"), Value: aws.String(brokerID)}}
+		for _, metric := range []struct {
+			Name      string
+			Statistic string
+		}{
+			{"CpuUser", "Average"},
+			{"CpuSystem", "Average"},
+			{"KafkaDataLogsDiskUsed", "Maximum"},
+		} {
+			queries = append(queries, metricQuery{
+				Key:        brokerID + "/" + metric.Name,
+				Namespace:  "AWS/Kafka",
+				MetricName: metric.Name,
+				Dimensions: dimensions,
+				Statistic:  metric.Statistic,
+			})
+		}
+	}
+
+	// Lag is published per group and topic, a group's lag is its worst topic
+	var groupTopics []map[string]string
+	if consumerLag {
+		if groupTopics, err = listMSKMetrics(ctx, cwClient, clusterName, "MaxOffsetLag", 3); err != nil {
+			return nil, err
+		}
+		for _, dimensions := range groupTopics {
+			queries = append(queries, metricQuery{
+				Key:        "lag/" + dimensions["Consumer Group"] + "/" + dimensions["Topic"],
+				Namespace:  "AWS/Kafka",
+				MetricName: "MaxOffsetLag",
+				Dimensions: []types.Dimension{
+					clusterDimension,
+					{Name: aws.String("Consumer Group"), Value: aws.String(dimensions["Consumer Group"])},
+					{Name: aws.String("Topic"), Value: aws.String(dimensions["Topic"])},
+				},
+				Statistic: "Maximum",
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting MSK metrics: %v", err)
+	}
+
+	result := &MSKResult{
+		ClusterName:            clusterName,
+		OfflinePartitionsCount: aggregateValues("Maximum", results["OfflinePartitionsCount"]),
+	}
+	// Each broker reports whether it is the controller, the latest sum is the
+	// current count
+	if values := results["ActiveControllerCount"]; len(values) > 0 {
+		result.ActiveControllerCount = values[0]
+	}
+	for _, brokerID := range brokerIDs {
+		result.Brokers = append(result.Brokers, MSKBroker{
+			ID:         brokerID,
+			CPUAverage: aggregateValues("Average", results[brokerID+"/CpuUser"]) + aggregateValues("Average", results[brokerID+"/CpuSystem"]),
+			DiskUsed:   aggregateValues("Maximum", results[brokerID+"/KafkaDataLogsDiskUsed"]),
+		})
+	}
+
+	groupLag := map[string]float64{}
+	for _, dimensions := range groupTopics {
+		group := dimensions["Consumer Group"]
+		groupLag[group] = max(groupLag[group], aggregateValues("Maximum", results["lag/"+group+"/"+dimensions["Topic"]]))
+	}
+	for group, lag := range groupLag {
+		result.ConsumerGroups = append(result.ConsumerGroups, MSKConsumerGroup{Name: group, MaxLag: lag})
+	}
+	sort.Slice(result.ConsumerGroups, func(i, j int) bool {
+		if result.ConsumerGroups[i].MaxLag != result.ConsumerGroups[j].MaxLag {
+			return result.ConsumerGroups[i].MaxLag > result.ConsumerGroups[j].MaxLag
+		}
+		return result.ConsumerGroups[i].Name < result.ConsumerGroups[j].Name
+	})
+	return result, nil
+}
+
+type mskCollector struct{}
+
+func (mskCollector) Name() string { return "msk" }
+
+func (mskCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.MSK.Enabled
+}
+
+func (mskCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.MSK.ClusterNames
+}
+
+func (mskCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, clusterName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.MSK.Region, cfg.Services.MSK.ResourceRegions, clusterName)
+	return MSKMetrics(ctx, clients.CloudWatch.Get(region), clusterName, cfg.Services.MSK.ConsumerLag, windowTimes(window))
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// NLBs publish host counts per target group only
+type NLBTargetGroup struct {
+	Name               string
+	HealthyHostCount   float64
+	UnHealthyHostCount float64
+}
+
+type NLBResult struct {
+	NLBName         string
+	ActiveFlowCount float64 // Average concurrent TCP/UDP flows
+	NewFlowCount    float64
+	ProcessedBytes  float64
+	// Resets sent by the targets, eg: a backend refusing connections
+	TargetResetCount float64
+	TargetGroups     []NLBTargetGroup // Sorted by name
+}
+
+// Target groups are flattened as TargetGroup_<name>_<metric>, like the ALB ones
+func (r *NLBResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"ActiveFlowCount":        r.ActiveFlowCount,
+		"NewFlowCount":           r.NewFlowCount,
+		"ProcessedBytes":         r.ProcessedBytes,
+		"TCP_Target_Reset_Count": r.TargetResetCount,
+	}
+	for _, targetGroup := range r.TargetGroups {
+		prefix := "TargetGroup_" + targetGroup.Name + "_"
+		metrics[prefix+"HealthyHostCount"] = targetGroup.HealthyHostCount
+		metrics[prefix+"UnHealthyHostCount"] = targetGroup.UnHealthyHostCount
+	}
+	return metrics
+}
+
+func (r *NLBResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "nlb", Title: "NLB", Subtitle: r.NLBName}
+	section.AddLine("Active Flows: %.0f, New Flows: %.0f%s", r.ActiveFlowCount, r.NewFlowCount, trend("NewFlowCount", r.NewFlowCount))
+	section.AddLine("Processed: %.2f MB", r.ProcessedBytes/(1024.0*1024.0))
+	section.AddLine("Target Resets: %.0f%s", r.TargetResetCount, trend("TCP_Target_Reset_Count", r.TargetResetCount))
+	for _, targetGroup := range r.TargetGroups {
+		section.AddLine("TG %s: Healthy: %.0f, Unhealthy: %.0f",
+			targetGroup.Name,
+			targetGroup.HealthyHostCount,
+			targetGroup.UnHealthyHostCount)
+	}
+	return section
+}
+
+func NLBMetrics(ctx context.Context, cwClient *cloudwatch.Client, nlbName string, timeParams map[string]time.Time) (*NLBResult, error) {
+	loadBalancerDimension, err := resolveLoadBalancerDimension(ctx, cwClient, nlbType, nlbName)
+	if err != nil {
+		return nil, err
+	}
+
+	targetGroups, err := listTargetGroupDimensions(ctx, cwClient, nlbType, loadBalancerDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancer := types.Dimension{
+		Name:  aws.String("LoadBalancer"),
+		Value: aws.String(loadBalancerDimension),
+	}
+
+	nlbMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"ActiveFlowCount", "Average"},
+		{"NewFlowCount", "Sum"},
+		{"ProcessedBytes", "Sum"},
+		{"TCP_Target_Reset_Count", "Sum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range nlbMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  nlbType.Namespace,
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{loadBalancer},
+			Statistic:  metric.Statistic,
+		})
+	}
+	for _, targetGroup := range targetGroups {
+		for _, metricName := range []string{"HealthyHostCount", "UnHealthyHostCount"} {
+			queries = append(queries, metricQuery{
+				Key:        targetGroup + "/" + metricName,
+				Namespace:  nlbType.Namespace,
+				MetricName: metricName,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("TargetGroup"),
+						Value: aws.String(targetGroup),
+					},
+					loadBalancer,
+				},
+				Statistic: "Average",
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting NLB metrics: %v", err)
+	}
+
+	result := &NLBResult{
+		NLBName:          nlbName,
+		ActiveFlowCount:  aggregateValues("Average", results["ActiveFlowCount"]),
+		NewFlowCount:     aggregateValues("Sum", results["NewFlowCount"]),
+		ProcessedBytes:   aggregateValues("Sum", results["ProcessedBytes"]),
+		TargetResetCount: aggregateValues("Sum", results["TCP_Target_Reset_Count"]),
+	}
+	for _, targetGroup := range targetGroups {
+		prefix := targetGroup + "/"
+		result.TargetGroups = append(result.TargetGroups, NLBTargetGroup{
+			// targetgroup/name/id
+			Name:               strings.Split(targetGroup, "/")[1],
+			HealthyHostCount:   aggregateValues("Average", results[prefix+"HealthyHostCount"]),
+			UnHealthyHostCount: aggregateValues("Average", results[prefix+"UnHealthyHostCount"]),
+		})
+	}
+	sort.Slice(result.TargetGroups, func(i, j int) bool {
+		return result.TargetGroups[i].Name < result.TargetGroups[j].Name
+	})
+
+	return result, nil
+}
+
+type nlbCollector struct{}
+
+func (nlbCollector) Name() string { return "nlb" }
+
+func (nlbCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.NLB.Enabled
+}
+
+func (nlbCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.NLB.NLBNames
+}
+
+func (nlbCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, nlbName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.NLB.Region, cfg.Services.NLB.ResourceRegions, nlbName)
+	return NLBMetrics(ctx, clients.CloudWatch.Get(region), nlbName, windowTimes(window))
+}
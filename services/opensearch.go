@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Cluster health colors, as published by the ClusterStatus.<color> metrics
+const (
+	openSearchGreen  = "green"
+	openSearchYellow = "yellow"
+	openSearchRed    = "red"
+)
+
+type OpenSearchResult struct {
+	DomainName string
+	// Latest health color, empty without datapoints. Red: a primary shard is
+	// unassigned, yellow: a replica is.
+	ClusterStatus     string
+	FreeStorageSpace  float64 // MB, lowest node
+	JVMMemoryPressure float64 // %, peak
+	CPUAverage        float64 // %
+	CPUMaximum        float64 // %
+	SearchLatency     float64 // ms
+	IndexingLatency   float64 // ms
+}
+
+func (r *OpenSearchResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"FreeStorageSpace":       r.FreeStorageSpace,
+		"JVMMemoryPressure":      r.JVMMemoryPressure,
+		"CPUUtilization_Average": r.CPUAverage,
+		"CPUUtilization_Maximum": r.CPUMaximum,
+		"SearchLatency":          r.SearchLatency,
+		"IndexingLatency":        r.IndexingLatency,
+	}
+	for _, color := range []string{openSearchGreen, openSearchYellow, openSearchRed} {
+		if r.ClusterStatus == color {
+			metrics["ClusterStatus."+color] = 1
+		} else {
+			metrics["ClusterStatus."+color] = 0
+		}
+	}
+	return metrics
+}
+
+func (r *OpenSearchResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "opensearch", Title: "OpenSearch", Subtitle: r.DomainName}
+	status := "NO DATA"
+	if r.ClusterStatus != "" {
+		status = strings.ToUpper(r.ClusterStatus)
+	}
+	section.AddLine("Cluster Status: %s", status)
+	section.AddLine("Free Storage: %.2f GB", r.FreeStorageSpace/1024)
+	section.AddLine("JVM Memory Pressure: %.2f%%", r.JVMMemoryPressure)
+	section.AddLine("CPU: %.2f%% (avg), %.2f%% (max)%s", r.CPUAverage, r.CPUMaximum, trend("CPUUtilization_Average", r.CPUAverage))
+	section.AddLine("Latency: %.2f ms search, %.2f ms indexing", r.SearchLatency, r.IndexingLatency)
+	return section
+}
+
+// The section icon follows the cluster color
+func (r *OpenSearchResult) Status() string {
+	switch r.ClusterStatus {
+	case openSearchRed:
+		return utils.StatusCritical
+	case openSearchYellow:
+		return utils.StatusWarning
+	case openSearchGreen:
+		return utils.StatusHealthy
+	}
+	return ""
+}
+
+func (r *OpenSearchResult) Failures() []string {
+	if r.ClusterStatus == openSearchRed {
+		return []string{fmt.Sprintf("OpenSearch %s: cluster status red", r.DomainName)}
+	}
+	return nil
+}
+
+// OpenSearch (and legacy Elasticsearch) domains publish in AWS/ES, addressed by
+// domain name and the account ID (ClientId)
+func OpenSearchMetrics(ctx context.Context, cwClient *cloudwatch.Client, domainName string, accountID string, timeParams map[string]time.Time) (*OpenSearchResult, error) {
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("DomainName"),
+			Value: aws.String(domainName),
+		},
+		{
+			Name:  aws.String("ClientId"),
+			Value: aws.String(accountID),
+		},
+	}
+
+	openSearchMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"ClusterStatus.green", "Maximum"},
+		{"ClusterStatus.yellow", "Maximum"},
+		{"ClusterStatus.red", "Maximum"},
+		{"FreeStorageSpace", "Minimum"},
+		{"JVMMemoryPressure", "Maximum"},
+		{"CPUUtilization", "Average"},
+		{"CPUUtilization", "Maximum"},
+		{"SearchLatency", "Average"},
+		{"IndexingLatency", "Average"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range openSearchMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name + "_" + metric.Statistic,
+			Namespace:  "AWS/ES",
+			MetricName: metric.Name,
+			Dimensions: dimensions,
+			Statistic:  metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting OpenSearch metrics: %v", err)
+	}
+
+	result := &OpenSearchResult{
+		DomainName:        domainName,
+		FreeStorageSpace:  aggregateValues("Minimum", results["FreeStorageSpace_Minimum"]),
+		JVMMemoryPressure: aggregateValues("Maximum", results["JVMMemoryPressure_Maximum"]),
+		CPUAverage:        aggregateValues("Average", results["CPUUtilization_Average"]),
+		CPUMaximum:        aggregateValues("Maximum", results["CPUUtilization_Maximum"]),
+		SearchLatency:     aggregateValues("Average", results["SearchLatency_Average"]),
+		IndexingLatency:   aggregateValues("Average", results["IndexingLatency_Average"]),
+	}
+
+	// Values are newest first, the worst color of the latest datapoint wins
+	for _, color := range []string{openSearchRed, openSearchYellow, openSearchGreen} {
+		if values := results["ClusterStatus."+color+"_Maximum"]; len(values) > 0 && values[0] >= 1 {
+			result.ClusterStatus = color
+			break
+		}
+	}
+	return result, nil
+}
+
+type openSearchCollector struct{}
+
+func (openSearchCollector) Name() string { return "opensearch" }
+
+func (openSearchCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.OpenSearch.Enabled
+}
+
+func (openSearchCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.OpenSearch.DomainNames
+}
+
+func (openSearchCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, domainName string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.OpenSearch.Region, cfg.Services.OpenSearch.ResourceRegions, domainName)
+	return OpenSearchMetrics(ctx, clients.CloudWatch.Get(region), domainName, clients.AccountID, windowTimes(window))
+}
@@ -3,26 +3,247 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
+	"telegraws/config"
 	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
-	"go.uber.org/zap"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdsTypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
 )
 
-func RDSMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterID string, instanceID string, timeParams map[string]time.Time) (map[string]float64, error) {
+// Helper function to detect Aurora instances from their engine (aurora-mysql, aurora-postgresql)
+func isAuroraInstance(ctx context.Context, rdsClient *rds.Client, instanceID string) (bool, error) {
+	output, err := rdsClient.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(instanceID),
+	})
+	if err != nil {
+		return false, fmt.Errorf("error describing DB instance: %v", err)
+	}
+	if len(output.DBInstances) == 0 {
+		return false, fmt.Errorf("DB instance %s not found", instanceID)
+	}
+	return strings.HasPrefix(aws.ToString(output.DBInstances[0].Engine), "aurora"), nil
+}
+
+// Memory is in GB, latencies in ms
+type RDSInstanceMetrics struct {
+	CPUAverage          float64 // %
+	CPUMaximum          float64 // %
+	FreeableMemory      float64
+	DatabaseConnections float64
+	ReadLatency         float64
+	WriteLatency        float64
+	// Storage of standard instances (GB), Aurora storage is managed by the cluster volume
+	FreeStorageSpace *float64
+	DiskQueueDepth   *float64
+	// Only gp2 volumes and burstable instances report it
+	BurstBalance *float64
+	// Aurora readers and read replicas only (ms, max)
+	ReplicaLag *float64
+}
+
+// One row of the per-instance table of a cluster (writer and readers)
+type RDSMemberMetrics struct {
+	InstanceID          string
+	CPUAverage          float64 // %
+	DatabaseConnections float64
+	// Aurora readers and read replicas only (ms, max), nil for writers
+	ReplicaLag *float64
+}
+
+type RDSClusterMetrics struct {
+	VolumeBytesUsed float64 // GB
+	VolumeReadIOPs  float64
+	VolumeWriteIOPs float64
+}
+
+// A maintenance action waiting on the cluster or one of its instances
+type RDSMaintenanceAction struct {
+	ResourceID  string // Instance or cluster identifier
+	Action      string // eg: "system-update", "db-upgrade"
+	Description string
+	// When AWS applies it regardless of the maintenance window, nil if optional
+	ForcedApplyDate *time.Time
+	// Next maintenance window it will be applied in, nil if not scheduled
+	CurrentApplyDate *time.Time
+}
+
+// Daily report only
+type RDSDailyStatus struct {
+	Maintenance []RDSMaintenanceAction
+	// Age of the latest available automated snapshot (hours), nil without one
+	SnapshotAge *float64
+	Location    *time.Location
+}
+
+// Metrics of a cluster and/or one of its instances, nil when not monitored
+type RDSResult struct {
+	ClusterID  string
+	InstanceID string
+	Instance   *RDSInstanceMetrics
+	Cluster    *RDSClusterMetrics
+	Members    []RDSMemberMetrics
+	Daily      *RDSDailyStatus
+}
+
+// Flattened as Instance_<metric>, Cluster_<metric> and Member_<instance>_<metric>
+func (r *RDSResult) Metrics() map[string]float64 {
 	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
+	if instance := r.Instance; instance != nil {
+		metrics["Instance_CPUUtilization_Average"] = instance.CPUAverage
+		metrics["Instance_CPUUtilization_Maximum"] = instance.CPUMaximum
+		metrics["Instance_FreeableMemory"] = instance.FreeableMemory
+		metrics["Instance_DatabaseConnections"] = instance.DatabaseConnections
+		metrics["Instance_ReadLatency"] = instance.ReadLatency
+		metrics["Instance_WriteLatency"] = instance.WriteLatency
+		if instance.FreeStorageSpace != nil {
+			metrics["Instance_FreeStorageSpace"] = *instance.FreeStorageSpace
+		}
+		if instance.DiskQueueDepth != nil {
+			metrics["Instance_DiskQueueDepth"] = *instance.DiskQueueDepth
+		}
+		if instance.BurstBalance != nil {
+			metrics["Instance_BurstBalance"] = *instance.BurstBalance
+		}
+		if instance.ReplicaLag != nil {
+			metrics["Instance_ReplicaLag"] = *instance.ReplicaLag
+		}
+	}
+	if cluster := r.Cluster; cluster != nil {
+		metrics["Cluster_VolumeBytesUsed"] = cluster.VolumeBytesUsed
+		metrics["Cluster_VolumeReadIOPs"] = cluster.VolumeReadIOPs
+		metrics["Cluster_VolumeWriteIOPs"] = cluster.VolumeWriteIOPs
+	}
+	for _, member := range r.Members {
+		prefix := "Member_" + member.InstanceID + "_"
+		metrics[prefix+"CPUUtilization_Average"] = member.CPUAverage
+		metrics[prefix+"DatabaseConnections"] = member.DatabaseConnections
+		if member.ReplicaLag != nil {
+			metrics[prefix+"ReplicaLag"] = *member.ReplicaLag
+		}
+	}
+	if daily := r.Daily; daily != nil {
+		metrics["PendingMaintenance"] = float64(len(daily.Maintenance))
+		if daily.SnapshotAge != nil {
+			metrics["SnapshotAgeHours"] = *daily.SnapshotAge
+		}
+	}
+	return metrics
+}
+
+func (r *RDSResult) Render(trend utils.TrendFunc) utils.Section {
+	var section utils.Section
+	if r.ClusterID != "" && r.InstanceID != "" {
+		section = utils.Section{Title: "RDS", Subtitle: r.ClusterID + " / " + r.InstanceID}
+	} else if r.ClusterID != "" {
+		section = utils.Section{Title: "RDS Cluster", Subtitle: r.ClusterID}
+	} else if r.InstanceID == "" {
+		section = utils.Section{Title: "RDS Instances"}
+	} else {
+		section = utils.Section{Title: "RDS Instance", Subtitle: r.InstanceID}
+	}
+	section.Service = "rds"
+
+	if instance := r.Instance; instance != nil {
+		section.AddLine("CPU: %.2f%% (avg), %.2f%% (max)%s", instance.CPUAverage, instance.CPUMaximum, trend("Instance_CPUUtilization_Average", instance.CPUAverage))
+		section.AddLine("Free Memory: %.2f GB", instance.FreeableMemory)
+		section.AddLine("Connections: %.0f", instance.DatabaseConnections)
+		section.AddLine("Read Latency: %.2f ms", instance.ReadLatency)
+		section.AddLine("Write Latency: %.2f ms", instance.WriteLatency)
+		if instance.FreeStorageSpace != nil {
+			section.AddLine("Free Storage: %.2f GB (min)", *instance.FreeStorageSpace)
+		}
+		if instance.BurstBalance != nil {
+			section.AddLine("Burst Balance: %.2f%% (min)", *instance.BurstBalance)
+		}
+		if instance.DiskQueueDepth != nil {
+			section.AddLine("Disk Queue Depth: %.2f", *instance.DiskQueueDepth)
+		}
+		if instance.ReplicaLag != nil {
+			section.AddLine("Replica Lag: %.0f ms (max)", *instance.ReplicaLag)
+		}
+	}
+
+	if cluster := r.Cluster; cluster != nil {
+		section.AddLine("Volume Size: %.2f GB", cluster.VolumeBytesUsed)
+		section.AddLine("Read IOPS: %.0f", cluster.VolumeReadIOPs)
+		section.AddLine("Write IOPS: %.0f", cluster.VolumeWriteIOPs)
+	}
+
+	// Own block, so the instance names align as a table
+	if len(r.Members) > 0 {
+		section.AddLine("")
+		for _, member := range r.Members {
+			memberLine := fmt.Sprintf("%s: CPU %.2f%%, %.0f conn", member.InstanceID, member.CPUAverage, member.DatabaseConnections)
+			if member.ReplicaLag != nil {
+				memberLine += fmt.Sprintf(", lag %.0f ms", *member.ReplicaLag)
+			}
+			section.AddLine("%s", memberLine)
+		}
+	}
+
+	if daily := r.Daily; daily != nil {
+		section.AddLine("")
+		switch {
+		case daily.SnapshotAge == nil:
+			section.AddLine("Latest Snapshot: NONE (automated backups disabled?)")
+		case *daily.SnapshotAge > rdsSnapshotMaxAge:
+			section.AddLine("Latest Snapshot: %.0fh ago (OVERDUE)", *daily.SnapshotAge)
+		default:
+			section.AddLine("Latest Snapshot: %.0fh ago", *daily.SnapshotAge)
+		}
+
+		if len(daily.Maintenance) == 0 {
+			section.AddLine("Pending Maintenance: none")
+		}
+		for _, action := range daily.Maintenance {
+			maintenanceLine := fmt.Sprintf("Maintenance: %s %s", action.ResourceID, action.Action)
+			switch {
+			case action.ForcedApplyDate != nil:
+				maintenanceLine += fmt.Sprintf(", FORCED on %s", action.ForcedApplyDate.In(daily.Location).Format("02/01 15:04"))
+			case action.CurrentApplyDate != nil:
+				maintenanceLine += fmt.Sprintf(", on %s", action.CurrentApplyDate.In(daily.Location).Format("02/01 15:04"))
+			}
+			section.AddLine("%s", maintenanceLine)
+			if action.Description != "" {
+				section.AddLine("  %s", action.Description)
+			}
+		}
 	}
 
-	if clusterID == "" && instanceID == "" {
-		return nil, fmt.Errorf("both clusterID and instanceID are empty - at least one is required")
+	return section
+}
+
+// Replica lag of an instance in ms, whichever of the Aurora (ms) or standard
+// read replica (s) metric has datapoints. Writers report neither.
+func replicaLag(results map[string][]float64, prefix string) *float64 {
+	if values := results[prefix+"AuroraReplicaLag"]; len(values) > 0 {
+		lag := aggregateValues("Maximum", values)
+		return &lag
 	}
+	if values := results[prefix+"ReplicaLag"]; len(values) > 0 {
+		lag := aggregateValues("Maximum", values) * 1000.0
+		return &lag
+	}
+	return nil
+}
+
+// engine is "aurora" or "standard", empty = detected from the instance.
+// memberIDs adds a per-instance table of the writer and readers of the cluster.
+func RDSMetrics(ctx context.Context, cwClient *cloudwatch.Client, rdsClient *rds.Client, clusterID string, instanceID string, memberIDs []string, engine string, timeParams map[string]time.Time) (*RDSResult, error) {
+	metrics := map[string]float64{}
+	period := aws.Int32(metricPeriod(timeParams))
+
+	if clusterID == "" && instanceID == "" && len(memberIDs) == 0 {
+		return nil, fmt.Errorf("clusterID, instanceID and memberIDs are empty - at least one is required")
+	}
+
+	var queries []metricQuery
 
 	// Instance-level metrics (per database instance)
 	if instanceID != "" {
@@ -38,64 +259,49 @@ func RDSMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterID stri
 			{"DatabaseConnections", "Maximum", "count"},
 			{"ReadLatency", "Average", "seconds"},
 			{"WriteLatency", "Average", "seconds"},
+			{"ReplicaLag", "Maximum", "seconds"},
+			{"AuroraReplicaLag", "Maximum", "ms"},
 		}
 
-		for _, metric := range instanceMetrics {
-			input := &cloudwatch.GetMetricStatisticsInput{
-				Namespace:  aws.String("AWS/RDS"),
-				MetricName: aws.String(metric.Name),
-				Dimensions: []types.Dimension{
-					{
-						Name:  aws.String("DBInstanceIdentifier"),
-						Value: aws.String(instanceID),
-					},
-				},
-				StartTime:  aws.Time(timeParams["startTime"]),
-				EndTime:    aws.Time(timeParams["endTime"]),
-				Period:     period,
-				Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
+		aurora := engine == "aurora"
+		if engine == "" {
+			var err error
+			if aurora, err = isAuroraInstance(ctx, rdsClient, instanceID); err != nil {
+				return nil, err
 			}
+		}
 
-			result, err := cwClient.GetMetricStatistics(ctx, input)
-			if err != nil {
-				utils.Logger.Error("Failed to get Aurora instance metric",
-					zap.Error(err),
-					zap.String("metricName", metric.Name),
-					zap.String("statistic", metric.Statistic),
-					zap.String("clusterID", clusterID),
-					zap.Int32("period", *period),
-				)
-				continue
-			}
+		// Aurora storage is managed by the cluster volume, standard instances have their own EBS storage
+		if !aurora {
+			instanceMetrics = append(instanceMetrics, []struct {
+				Name      string
+				Statistic string
+				Unit      string
+			}{
+				{"FreeStorageSpace", "Minimum", "bytes"},
+				{"BurstBalance", "Minimum", "%"},
+				{"DiskQueueDepth", "Average", "count"},
+			}...)
+		}
 
+		for _, metric := range instanceMetrics {
 			metricKey := fmt.Sprintf("Instance_%s", metric.Name)
 			if metric.Name == "CPUUtilization" {
 				metricKey = fmt.Sprintf("Instance_CPUUtilization_%s", metric.Statistic)
 			}
 
-			if len(result.Datapoints) > 0 {
-				var value float64
-				switch metric.Statistic {
-				case "Average":
-					value = *result.Datapoints[0].Average
-				case "Maximum":
-					value = *result.Datapoints[0].Maximum
-				case "Sum":
-					value = *result.Datapoints[0].Sum
-				}
-
-				if metric.Name == "FreeableMemory" {
-					value = value / (1024.0 * 1024.0 * 1024.0)
-				}
-
-				if metric.Name == "ReadLatency" || metric.Name == "WriteLatency" {
-					value = value * 1000.0
-				}
-
-				metrics[metricKey] = value
-			} else {
-				metrics[metricKey] = 0.0
-			}
+			queries = append(queries, metricQuery{
+				Key:        metricKey,
+				Namespace:  "AWS/RDS",
+				MetricName: metric.Name,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("DBInstanceIdentifier"),
+						Value: aws.String(instanceID),
+					},
+				},
+				Statistic: metric.Statistic,
+			})
 		}
 	}
 
@@ -113,58 +319,261 @@ func RDSMetrics(ctx context.Context, cwClient *cloudwatch.Client, clusterID stri
 		}
 
 		for _, metric := range clusterMetrics {
-			input := &cloudwatch.GetMetricStatisticsInput{
-				Namespace:  aws.String("AWS/RDS"),
-				MetricName: aws.String(metric.Name),
+			queries = append(queries, metricQuery{
+				Key:        fmt.Sprintf("Cluster_%s", metric.Name),
+				Namespace:  "AWS/RDS",
+				MetricName: metric.Name,
 				Dimensions: []types.Dimension{
 					{
 						Name:  aws.String("DBClusterIdentifier"),
 						Value: aws.String(clusterID),
 					},
 				},
-				StartTime:  aws.Time(timeParams["startTime"]),
-				EndTime:    aws.Time(timeParams["endTime"]),
-				Period:     period,
-				Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
+				Statistic: metric.Statistic,
+			})
+		}
+	}
+
+	// Per-instance table of the cluster members
+	var memberQueries []metricQuery
+	for _, memberID := range memberIDs {
+		for _, metric := range []struct {
+			Name      string
+			Statistic string
+		}{
+			{"CPUUtilization", "Average"},
+			{"DatabaseConnections", "Maximum"},
+			{"ReplicaLag", "Maximum"},
+			{"AuroraReplicaLag", "Maximum"},
+		} {
+			memberQueries = append(memberQueries, metricQuery{
+				Key:        fmt.Sprintf("Member_%s_%s", memberID, metric.Name),
+				Namespace:  "AWS/RDS",
+				MetricName: metric.Name,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("DBInstanceIdentifier"),
+						Value: aws.String(memberID),
+					},
+				},
+				Statistic: metric.Statistic,
+			})
+		}
+	}
+
+	// A failing metric is skipped, the others are still reported
+	results, err := getMetricDataPartial(ctx, cwClient, append(queries, memberQueries...), timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting RDS metrics: %v", err)
+	}
+
+	for _, query := range queries {
+		if _, fetched := results[query.Key]; !fetched {
+			continue
+		}
+		// Only gp2 volumes and burstable instances report BurstBalance
+		if query.MetricName == "BurstBalance" && len(results[query.Key]) == 0 {
+			continue
+		}
+		// Read from whichever replica lag metric has datapoints below
+		if query.MetricName == "ReplicaLag" || query.MetricName == "AuroraReplicaLag" {
+			continue
+		}
+
+		value := aggregateValues(query.Statistic, results[query.Key])
+
+		if query.MetricName == "FreeableMemory" {
+			value = value / (1024.0 * 1024.0 * 1024.0)
+		}
+
+		if query.MetricName == "ReadLatency" || query.MetricName == "WriteLatency" {
+			value = value * 1000.0
+		}
+
+		if strings.Contains(query.MetricName, "Storage") || query.MetricName == "VolumeBytesUsed" {
+			value = value / (1024.0 * 1024.0 * 1024.0)
+		}
+
+		if strings.Contains(query.MetricName, "Throughput") {
+			value = value / (1024.0 * 1024.0)
+		}
+
+		metrics[query.Key] = value
+	}
+
+	result := &RDSResult{ClusterID: clusterID, InstanceID: instanceID}
+
+	optional := func(key string) *float64 {
+		if value, exists := metrics[key]; exists {
+			return &value
+		}
+		return nil
+	}
+
+	if instanceID != "" {
+		result.Instance = &RDSInstanceMetrics{
+			CPUAverage:          metrics["Instance_CPUUtilization_Average"],
+			CPUMaximum:          metrics["Instance_CPUUtilization_Maximum"],
+			FreeableMemory:      metrics["Instance_FreeableMemory"],
+			DatabaseConnections: metrics["Instance_DatabaseConnections"],
+			ReadLatency:         metrics["Instance_ReadLatency"],
+			WriteLatency:        metrics["Instance_WriteLatency"],
+			FreeStorageSpace:    optional("Instance_FreeStorageSpace"),
+			DiskQueueDepth:      optional("Instance_DiskQueueDepth"),
+			BurstBalance:        optional("Instance_BurstBalance"),
+			ReplicaLag:          replicaLag(results, "Instance_"),
+		}
+	}
+
+	if clusterID != "" {
+		result.Cluster = &RDSClusterMetrics{
+			VolumeBytesUsed: metrics["Cluster_VolumeBytesUsed"],
+			VolumeReadIOPs:  metrics["Cluster_VolumeReadIOPs"],
+			VolumeWriteIOPs: metrics["Cluster_VolumeWriteIOPs"],
+		}
+	}
+
+	for _, memberID := range memberIDs {
+		prefix := "Member_" + memberID + "_"
+		result.Members = append(result.Members, RDSMemberMetrics{
+			InstanceID:          memberID,
+			CPUAverage:          aggregateValues("Average", results[prefix+"CPUUtilization"]),
+			DatabaseConnections: aggregateValues("Maximum", results[prefix+"DatabaseConnections"]),
+			ReplicaLag:          replicaLag(results, prefix),
+		})
+	}
+
+	return result, nil
+}
+
+// Automated backups run once a day, older snapshots mean a missed backup
+const rdsSnapshotMaxAge = 26.0
+
+// Pending maintenance of the monitored cluster and instances, and the latest
+// automated snapshot of the cluster (Aurora) or of the instance
+func RDSDailyMetrics(ctx context.Context, rdsClient *rds.Client, clusterID string, instanceIDs []string, location *time.Location) (*RDSDailyStatus, error) {
+	daily := &RDSDailyStatus{Location: location}
+
+	var filters []rdsTypes.Filter
+	if clusterID != "" {
+		filters = append(filters, rdsTypes.Filter{Name: aws.String("db-cluster-id"), Values: []string{clusterID}})
+	}
+	if len(instanceIDs) > 0 {
+		filters = append(filters, rdsTypes.Filter{Name: aws.String("db-instance-id"), Values: instanceIDs})
+	}
+
+	// Filters are ANDed, the cluster and its instances are looked up separately
+	for _, filter := range filters {
+		paginator := rds.NewDescribePendingMaintenanceActionsPaginator(rdsClient, &rds.DescribePendingMaintenanceActionsInput{
+			Filters: []rdsTypes.Filter{filter},
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error describing RDS pending maintenance: %v", err)
 			}
+			for _, resource := range output.PendingMaintenanceActions {
+				// Resource ARN, eg: arn:aws:rds:us-east-1:123456789012:db:my-instance
+				resourceARN := aws.ToString(resource.ResourceIdentifier)
+				resourceID := resourceARN[strings.LastIndex(resourceARN, ":")+1:]
+				for _, action := range resource.PendingMaintenanceActionDetails {
+					daily.Maintenance = append(daily.Maintenance, RDSMaintenanceAction{
+						ResourceID:       resourceID,
+						Action:           aws.ToString(action.Action),
+						Description:      aws.ToString(action.Description),
+						ForcedApplyDate:  action.ForcedApplyDate,
+						CurrentApplyDate: action.CurrentApplyDate,
+					})
+				}
+			}
+		}
+	}
 
-			result, err := cwClient.GetMetricStatistics(ctx, input)
+	var snapshotTimes []time.Time
+	if clusterID != "" {
+		paginator := rds.NewDescribeDBClusterSnapshotsPaginator(rdsClient, &rds.DescribeDBClusterSnapshotsInput{
+			DBClusterIdentifier: aws.String(clusterID),
+			SnapshotType:        aws.String("automated"),
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
 			if err != nil {
-				utils.Logger.Error("Failed to get Aurora cluster metric",
-					zap.Error(err),
-					zap.String("metricName", metric.Name),
-					zap.String("statistic", metric.Statistic),
-					zap.String("clusterID", clusterID),
-					zap.Int32("period", *period),
-				)
-				continue
+				return nil, fmt.Errorf("error describing RDS cluster snapshots: %v", err)
 			}
+			for _, snapshot := range output.DBClusterSnapshots {
+				if aws.ToString(snapshot.Status) == "available" && snapshot.SnapshotCreateTime != nil {
+					snapshotTimes = append(snapshotTimes, *snapshot.SnapshotCreateTime)
+				}
+			}
+		}
+	} else if len(instanceIDs) > 0 {
+		paginator := rds.NewDescribeDBSnapshotsPaginator(rdsClient, &rds.DescribeDBSnapshotsInput{
+			DBInstanceIdentifier: aws.String(instanceIDs[0]),
+			SnapshotType:         aws.String("automated"),
+		})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error describing RDS snapshots: %v", err)
+			}
+			for _, snapshot := range output.DBSnapshots {
+				if aws.ToString(snapshot.Status) == "available" && snapshot.SnapshotCreateTime != nil {
+					snapshotTimes = append(snapshotTimes, *snapshot.SnapshotCreateTime)
+				}
+			}
+		}
+	}
 
-			metricKey := fmt.Sprintf("Cluster_%s", metric.Name)
+	if len(snapshotTimes) > 0 {
+		age := time.Since(slices.MaxFunc(snapshotTimes, time.Time.Compare)).Hours()
+		daily.SnapshotAge = &age
+	}
+	return daily, nil
+}
 
-			if len(result.Datapoints) > 0 {
-				var value float64
-				switch metric.Statistic {
-				case "Average":
-					value = *result.Datapoints[0].Average
-				case "Maximum":
-					value = *result.Datapoints[0].Maximum
-				}
+type rdsCollector struct{}
 
-				if strings.Contains(metric.Name, "Storage") || metric.Name == "VolumeBytesUsed" {
-					value = value / (1024.0 * 1024.0 * 1024.0)
-				}
+func (rdsCollector) Name() string { return "rds" }
 
-				if strings.Contains(metric.Name, "Throughput") {
-					value = value / (1024.0 * 1024.0)
-				}
+func (rdsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.RDS.Enabled
+}
 
-				metrics[metricKey] = value
-			} else {
-				metrics[metricKey] = 0.0
-			}
+func (rdsCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (rdsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	rdsConfig := cfg.Services.RDS
+	rdsClient := clients.RDS.Get(rdsConfig.Region)
+
+	result, err := RDSMetrics(
+		ctx,
+		clients.CloudWatch.Get(rdsConfig.Region),
+		rdsClient,
+		rdsConfig.ClusterID,
+		rdsConfig.DBInstanceIdentifier,
+		rdsConfig.DBInstanceIdentifiers,
+		rdsConfig.Engine,
+		windowTimes(window),
+	)
+	if err != nil || !window.IsDailyReport {
+		return result, err
+	}
+
+	var instanceIDs []string
+	if rdsConfig.DBInstanceIdentifier != "" {
+		instanceIDs = append(instanceIDs, rdsConfig.DBInstanceIdentifier)
+	}
+	for _, instanceID := range rdsConfig.DBInstanceIdentifiers {
+		if !slices.Contains(instanceIDs, instanceID) {
+			instanceIDs = append(instanceIDs, instanceID)
 		}
 	}
 
-	return metrics, nil
+	result.Daily, err = RDSDailyMetrics(ctx, rdsClient, rdsConfig.ClusterID, instanceIDs, window.Location)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
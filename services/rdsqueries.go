@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/pi"
+	piTypes "github.com/aws/aws-sdk-go-v2/service/pi/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// Statements are shortened to keep one line per query in the chat
+const maxRDSStatementLength = 120
+
+type RDSQuery struct {
+	Statement string  // Tokenized SQL, literals replaced by "?"
+	Load      float64 // Average active sessions running it over the window
+}
+
+type RDSQueriesResult struct {
+	InstanceID string
+	// Performance Insights is disabled on the instance, nothing else is set
+	Disabled bool
+	// Average and peak active sessions (DBLoad), all statements included
+	LoadAverage float64
+	LoadMaximum float64
+	Queries     []RDSQuery
+}
+
+func (r *RDSQueriesResult) Metrics() map[string]float64 {
+	if r.Disabled {
+		return map[string]float64{}
+	}
+	return map[string]float64{
+		"DBLoad_Average": r.LoadAverage,
+		"DBLoad_Maximum": r.LoadMaximum,
+	}
+}
+
+func (r *RDSQueriesResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "rdsTopQueries", Title: "RDS Top Queries", Subtitle: r.InstanceID}
+	if r.Disabled {
+		section.AddLine("Performance Insights is not enabled on the instance")
+		return section
+	}
+
+	section.AddLine("Active Sessions: %.2f (avg), %.2f (max)%s", r.LoadAverage, r.LoadMaximum, trend("DBLoad_Average", r.LoadAverage))
+	for i, query := range r.Queries {
+		section.AddLine("%d. %.2f AAS: %s", i+1, query.Load, query.Statement)
+	}
+	return section
+}
+
+// Top statements by DB load from Performance Insights, which is addressed by
+// the resource ID of the instance (db-...), not by its identifier
+func RDSQueriesMetrics(ctx context.Context, cwClient *cloudwatch.Client, rdsClient *rds.Client, piClient *pi.Client, instanceID string, topQueries int, timeParams map[string]time.Time) (*RDSQueriesResult, error) {
+	output, err := rdsClient.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(instanceID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing DB instance: %v", err)
+	}
+	if len(output.DBInstances) == 0 {
+		return nil, fmt.Errorf("DB instance %s not found", instanceID)
+	}
+	instance := output.DBInstances[0]
+
+	result := &RDSQueriesResult{InstanceID: instanceID}
+	if !aws.ToBool(instance.PerformanceInsightsEnabled) {
+		result.Disabled = true
+		return result, nil
+	}
+
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("DBInstanceIdentifier"),
+			Value: aws.String(instanceID),
+		},
+	}
+	results, err := getMetricData(ctx, cwClient, []metricQuery{
+		{Key: "DBLoad_Average", Namespace: "AWS/RDS", MetricName: "DBLoad", Dimensions: dimensions, Statistic: "Average"},
+		{Key: "DBLoad_Maximum", Namespace: "AWS/RDS", MetricName: "DBLoad", Dimensions: dimensions, Statistic: "Maximum"},
+	}, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting RDS DB load: %v", err)
+	}
+	result.LoadAverage = aggregateValues("Average", results["DBLoad_Average"])
+	result.LoadMaximum = aggregateValues("Maximum", results["DBLoad_Maximum"])
+
+	keys, err := piClient.DescribeDimensionKeys(ctx, &pi.DescribeDimensionKeysInput{
+		ServiceType: piTypes.ServiceTypeRds,
+		Identifier:  instance.DbiResourceId,
+		Metric:      aws.String("db.load.avg"),
+		StartTime:   aws.Time(timeParams["startTime"]),
+		EndTime:     aws.Time(timeParams["endTime"]),
+		GroupBy: &piTypes.DimensionGroup{
+			Group:      aws.String("db.sql_tokenized"),
+			Dimensions: []string{"db.sql_tokenized.statement"},
+			Limit:      aws.Int32(int32(topQueries)),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting Performance Insights top queries: %v", err)
+	}
+
+	// Keys are sorted by load, highest first
+	for _, key := range keys.Keys {
+		statement := strings.Join(strings.Fields(key.Dimensions["db.sql_tokenized.statement"]), " ")
+		if runes := []rune(statement); len(runes) > maxRDSStatementLength {
+			statement = string(runes[:maxRDSStatementLength-3]) + "..."
+		}
+		result.Queries = append(result.Queries, RDSQuery{
+			Statement: statement,
+			Load:      aws.ToFloat64(key.Total),
+		})
+	}
+	return result, nil
+}
+
+type rdsQueriesCollector struct{}
+
+func (rdsQueriesCollector) Name() string { return "rdsTopQueries" }
+
+func (rdsQueriesCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	rdsConfig := cfg.Services.RDS
+	return rdsConfig.Enabled && rdsConfig.TopQueries > 0 && rdsConfig.DBInstanceIdentifier != "" && window.IsDailyReport
+}
+
+func (rdsQueriesCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (rdsQueriesCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	region := cfg.Services.RDS.Region
+	return RDSQueriesMetrics(
+		ctx,
+		clients.CloudWatch.Get(region),
+		clients.RDS.Get(region),
+		clients.PI.Get(region),
+		cfg.Services.RDS.DBInstanceIdentifier,
+		cfg.Services.RDS.TopQueries,
+		windowTimes(window),
+	)
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// The current status is read from the last minutes of the window, health
+// checks publishing every minute
+const route53StatusWindow = 10 * time.Minute
+
+type Route53Result struct {
+	HealthCheckID string
+	// Last reported status, false while failing
+	Healthy bool
+	// Latest and lowest share of the Route53 checkers seeing the endpoint healthy (%)
+	PercentageHealthy    float64
+	MinPercentageHealthy float64
+	// No datapoints in the status window: deleted or disabled health check
+	NoData bool
+}
+
+func (r *Route53Result) Metrics() map[string]float64 {
+	status := 0.0
+	if r.Healthy {
+		status = 1
+	}
+	return map[string]float64{
+		"HealthCheckStatus":                    status,
+		"HealthCheckPercentageHealthy":         r.PercentageHealthy,
+		"HealthCheckPercentageHealthy_Minimum": r.MinPercentageHealthy,
+	}
+}
+
+// All health checks share the "Route53 Health Checks" section
+func (r *Route53Result) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "route53", Title: "Route53 Health Checks"}
+	switch {
+	case r.NoData:
+		section.AddLine("%s: NO DATA", r.HealthCheckID)
+	case r.Healthy:
+		section.AddLine("%s: Healthy, %.0f%% checkers (min %.0f%%)", r.HealthCheckID, r.PercentageHealthy, r.MinPercentageHealthy)
+	default:
+		section.AddLine("%s: FAILING, %.0f%% checkers (min %.0f%%)", r.HealthCheckID, r.PercentageHealthy, r.MinPercentageHealthy)
+	}
+	return section
+}
+
+func (r *Route53Result) Failures() []string {
+	if r.NoData || r.Healthy {
+		return nil
+	}
+	return []string{fmt.Sprintf("Route53 health check %s: %.0f%% checkers healthy", r.HealthCheckID, r.PercentageHealthy)}
+}
+
+// Route53 health check metrics are only published in us-east-1
+func Route53Metrics(ctx context.Context, cwClient *cloudwatch.Client, healthCheckID string, timeParams map[string]time.Time) (*Route53Result, error) {
+	dimensions := []types.Dimension{
+		{
+			Name:  aws.String("HealthCheckId"),
+			Value: aws.String(healthCheckID),
+		},
+	}
+
+	windowResults, err := getMetricData(ctx, cwClient, []metricQuery{
+		{
+			Key:        "MinPercentageHealthy",
+			Namespace:  "AWS/Route53",
+			MetricName: "HealthCheckPercentageHealthy",
+			Dimensions: dimensions,
+			Statistic:  "Minimum",
+		},
+	}, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting Route53 metrics: %v", err)
+	}
+
+	endTime := timeParams["endTime"]
+	statusResults, err := getMetricData(ctx, cwClient, []metricQuery{
+		{
+			Key:        "HealthCheckStatus",
+			Namespace:  "AWS/Route53",
+			MetricName: "HealthCheckStatus",
+			Dimensions: dimensions,
+			Statistic:  "Minimum",
+		},
+		{
+			Key:        "PercentageHealthy",
+			Namespace:  "AWS/Route53",
+			MetricName: "HealthCheckPercentageHealthy",
+			Dimensions: dimensions,
+			Statistic:  "Average",
+		},
+	}, endTime.Add(-route53StatusWindow), endTime, 60)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Route53 health check status: %v", err)
+	}
+
+	result := &Route53Result{
+		HealthCheckID:        healthCheckID,
+		MinPercentageHealthy: aggregateValues("Minimum", windowResults["MinPercentageHealthy"]),
+	}
+
+	// Datapoints are newest first
+	status := statusResults["HealthCheckStatus"]
+	if len(status) == 0 {
+		result.NoData = true
+		return result, nil
+	}
+	result.Healthy = status[0] >= 1
+	if percentage := statusResults["PercentageHealthy"]; len(percentage) > 0 {
+		result.PercentageHealthy = percentage[0]
+	}
+	return result, nil
+}
+
+type route53Collector struct{}
+
+func (route53Collector) Name() string { return "route53" }
+
+func (route53Collector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Route53.Enabled
+}
+
+func (route53Collector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Route53.HealthCheckIDs
+}
+
+func (route53Collector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, healthCheckID string) (utils.Result, error) {
+	return Route53Metrics(ctx, clients.CloudWatch.Get(globalRegion), healthCheckID, windowTimes(window))
+}
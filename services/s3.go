@@ -2,6 +2,10 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -9,88 +13,285 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 )
 
-func S3Metrics(ctx context.Context, cwClient *cloudwatch.Client, bucketName string, timeParams map[string]time.Time) (map[string]float64, error) {
-	metrics := map[string]float64{}
-	period := aws.Int32(86400) // S3 publishes storage metrics once per day
+// Request metrics of a bucket filter, only published once they are enabled on
+// the bucket
+type S3Requests struct {
+	AllRequests      float64
+	GetRequests      float64
+	PutRequests      float64
+	Errors4xx        float64
+	Errors5xx        float64
+	FirstByteLatency float64 // ms
+}
 
-	// BucketSizeBytes can be broken down by StorageType
-	storageTypes := []string{
-		"StandardStorage",
-		"StandardIAStorage",
-		"ReducedRedundancyStorage",
-		"GlacierStorage",
-		"DeepArchiveStorage",
-		"IntelligentTieringFAStorage",
-		"IntelligentTieringIAStorage",
-		"IntelligentTieringAAStorage",
-		"IntelligentTieringAIAStorage",
-		"IntelligentTieringDAAStorage",
-	}
+// Storage classes the bucket size is broken down into, in render order. Each
+// groups the BucketSizeBytes storage types billed alike.
+var s3StorageClasses = []struct {
+	Name         string
+	StorageTypes []string
+}{
+	{"Standard", []string{"StandardStorage", "ReducedRedundancyStorage", "IntelligentTieringFAStorage"}},
+	{"IA", []string{"StandardIAStorage", "OneZoneIAStorage", "IntelligentTieringIAStorage", "IntelligentTieringAIAStorage"}},
+	{"Glacier", []string{"GlacierInstantRetrievalStorage", "GlacierStorage", "DeepArchiveStorage", "IntelligentTieringAAStorage", "IntelligentTieringDAAStorage"}},
+}
 
-	var totalSize float64
-	for _, storageType := range storageTypes {
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/S3"),
-			MetricName: aws.String("BucketSizeBytes"),
-			Dimensions: []types.Dimension{
-				{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
-				{Name: aws.String("StorageType"), Value: aws.String(storageType)},
-			},
-			StartTime:  aws.Time(timeParams["startTime"].AddDate(0, 0, -1)), // widen by 1 day
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.StatisticAverage},
+// Growth periods compared against the sizes of previous daily reports
+var s3GrowthDays = []int{1, 7, 30}
+
+// Size change of a bucket since the daily report of Days ago
+type S3Growth struct {
+	Days     int
+	ChangeMB float64
+}
+
+type S3Result struct {
+	BucketName string
+	// Storage metrics are published once a day, so only daily reports set them
+	Storage      bool
+	BucketSizeMB float64
+	// A BucketSizeBytes datapoint was found, missing ones are reported as 0
+	SizeReported    bool
+	ClassSizesMB    map[string]float64 // By s3StorageClasses name
+	NumberOfObjects float64
+	Requests        *S3Requests
+	// Render the object count change since the previous daily report
+	ObjectDeltas bool
+	// Periods with a recorded size only, set from the history
+	Growth []S3Growth
+}
+
+func (r *S3Result) Metrics() map[string]float64 {
+	metrics := map[string]float64{}
+	if r.Storage {
+		metrics["BucketSizeMB"] = r.BucketSizeMB
+		metrics["NumberOfObjects"] = r.NumberOfObjects
+		for class, size := range r.ClassSizesMB {
+			metrics["SizeMB_"+class] = size
+		}
+		for _, growth := range r.Growth {
+			metrics[fmt.Sprintf("SizeGrowthMB_%dd", growth.Days)] = growth.ChangeMB
 		}
+	}
+	if requests := r.Requests; requests != nil {
+		metrics["AllRequests"] = requests.AllRequests
+		metrics["GetRequests"] = requests.GetRequests
+		metrics["PutRequests"] = requests.PutRequests
+		metrics["4xxErrors"] = requests.Errors4xx
+		metrics["5xxErrors"] = requests.Errors5xx
+		metrics["FirstByteLatency"] = requests.FirstByteLatency
+	}
+	return metrics
+}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil || len(result.Datapoints) == 0 {
-			continue
+func (r *S3Result) Render(trend utils.TrendFunc) utils.Section {
+	return r.RenderDeltas(trend, func(string, float64) (float64, bool) { return 0, false })
+}
+
+// Storage is only collected daily, so the previous value is yesterday's
+func (r *S3Result) RenderDeltas(trend utils.TrendFunc, delta utils.DeltaFunc) utils.Section {
+	section := utils.Section{Service: "s3", Title: "S3", Subtitle: r.BucketName}
+	if r.Storage {
+		sizeLine := fmt.Sprintf("Size: %.2f MB", r.BucketSizeMB)
+		var classes []string
+		for _, class := range s3StorageClasses {
+			if size := r.ClassSizesMB[class.Name]; size > 0 {
+				classes = append(classes, fmt.Sprintf("%s %.2f", class.Name, size))
+			}
+		}
+		// A single class is the total already
+		if len(classes) > 1 {
+			sizeLine += " (" + strings.Join(classes, ", ") + ")"
 		}
+		section.AddLine("%s", sizeLine)
 
-		// pick latest datapoint
-		latest := result.Datapoints[0]
-		for _, dp := range result.Datapoints {
-			if dp.Timestamp.After(*latest.Timestamp) {
-				latest = dp
+		if len(r.Growth) > 0 {
+			changes := make([]string, 0, len(r.Growth))
+			for _, growth := range r.Growth {
+				changes = append(changes, fmt.Sprintf("%+.2f MB (%dd)", growth.ChangeMB, growth.Days))
 			}
+			section.AddLine("Growth: %s", strings.Join(changes, ", "))
 		}
 
-		if latest.Average != nil {
-			totalSize += *latest.Average
+		objectsLine := fmt.Sprintf("Objects: %.0f", r.NumberOfObjects)
+		if change, exists := delta("NumberOfObjects", r.NumberOfObjects); exists && r.ObjectDeltas {
+			objectsLine += fmt.Sprintf(" (%+.0f vs yesterday)", change)
+		}
+		section.AddLine("%s", objectsLine)
+	}
+	if requests := r.Requests; requests != nil {
+		errorRate := func(errors float64) float64 {
+			if requests.AllRequests == 0 {
+				return 0
+			}
+			return errors / requests.AllRequests * 100
 		}
+		section.AddLine("Requests: %.0f (GET %.0f, PUT %.0f)%s", requests.AllRequests, requests.GetRequests, requests.PutRequests, trend("AllRequests", requests.AllRequests))
+		section.AddLine("4xx Errors: %.0f (%.2f%%)", requests.Errors4xx, errorRate(requests.Errors4xx))
+		section.AddLine("5xx Errors: %.0f (%.2f%%)", requests.Errors5xx, errorRate(requests.Errors5xx))
+		section.AddLine("First Byte Latency: %.0f ms", requests.FirstByteLatency)
 	}
+	return section
+}
+
+func S3Metrics(ctx context.Context, cwClient *cloudwatch.Client, bucketName string, timeParams map[string]time.Time) (*S3Result, error) {
+	result := &S3Result{BucketName: bucketName, Storage: true}
+	period := aws.Int32(86400) // S3 publishes storage metrics once per day
 
-	// convert to MB
-	metrics["BucketSizeMB"] = totalSize / (1024.0 * 1024.0)
+	// BucketSizeBytes is broken down by StorageType
+	var queries []metricQuery
+	for _, class := range s3StorageClasses {
+		for _, storageType := range class.StorageTypes {
+			queries = append(queries, metricQuery{
+				Key:        "BucketSizeBytes_" + storageType,
+				Namespace:  "AWS/S3",
+				MetricName: "BucketSizeBytes",
+				Dimensions: []types.Dimension{
+					{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
+					{Name: aws.String("StorageType"), Value: aws.String(storageType)},
+				},
+				Statistic: "Average",
+			})
+		}
+	}
 
 	// --- NumberOfObjects ---
-	input := &cloudwatch.GetMetricStatisticsInput{
-		Namespace:  aws.String("AWS/S3"),
-		MetricName: aws.String("NumberOfObjects"),
+	queries = append(queries, metricQuery{
+		Key:        "NumberOfObjects",
+		Namespace:  "AWS/S3",
+		MetricName: "NumberOfObjects",
 		Dimensions: []types.Dimension{
 			{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
 			{Name: aws.String("StorageType"), Value: aws.String("AllStorageTypes")},
 		},
-		StartTime:  aws.Time(timeParams["startTime"].AddDate(0, 0, -1)), // widen by 1 day
-		EndTime:    aws.Time(timeParams["endTime"]),
-		Period:     period,
-		Statistics: []types.Statistic{types.StatisticAverage},
+		Statistic: "Average",
+	})
+
+	// Missing storage metrics are reported as 0
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"].AddDate(0, 0, -1), timeParams["endTime"], *period) // widen by 1 day
+	if err != nil {
+		return nil, fmt.Errorf("error getting S3 storage metrics: %v", err)
+	}
+
+	// Values are newest first, so the first one is the latest datapoint
+	result.ClassSizesMB = make(map[string]float64, len(s3StorageClasses))
+	for _, class := range s3StorageClasses {
+		var classSize float64
+		for _, storageType := range class.StorageTypes {
+			if values := results["BucketSizeBytes_"+storageType]; len(values) > 0 {
+				classSize += values[0]
+				result.SizeReported = true
+			}
+		}
+		// convert to MB
+		result.ClassSizesMB[class.Name] = classSize / (1024.0 * 1024.0)
+		result.BucketSizeMB += result.ClassSizesMB[class.Name]
+	}
+
+	if values := results["NumberOfObjects"]; len(values) > 0 {
+		result.NumberOfObjects = values[0]
 	}
 
-	result, err := cwClient.GetMetricStatistics(ctx, input)
-	if err == nil && len(result.Datapoints) > 0 {
-		latest := result.Datapoints[0]
-		for _, dp := range result.Datapoints {
-			if dp.Timestamp.After(*latest.Timestamp) {
-				latest = dp
+	return result, nil
+}
+
+// Sets the growth of the bucket results from the sizes recorded on previous
+// daily reports (date -> bucket -> MB) and returns the sizes of this one.
+// Buckets without a reported size are left out, a 0 would skew the growth.
+func ApplyS3Growth(results map[string]any, sizes map[string]map[string]float64, date time.Time) map[string]float64 {
+	current := make(map[string]float64)
+	for bucketName, value := range results {
+		result, ok := value.(*S3Result)
+		if !ok || !result.Storage || !result.SizeReported {
+			continue
+		}
+		current[bucketName] = result.BucketSizeMB
+
+		for _, days := range s3GrowthDays {
+			past, exists := sizes[date.AddDate(0, 0, -days).Format(time.DateOnly)][bucketName]
+			if exists {
+				result.Growth = append(result.Growth, S3Growth{Days: days, ChangeMB: result.BucketSizeMB - past})
 			}
 		}
-		if latest.Average != nil {
-			metrics["NumberOfObjects"] = *latest.Average
+	}
+	return current
+}
+
+// filterID is the ID of the request metrics configuration of the bucket, eg:
+// "EntireBucket"
+func S3RequestMetrics(ctx context.Context, cwClient *cloudwatch.Client, bucketName string, filterID string, timeParams map[string]time.Time) (*S3Requests, error) {
+	requestMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"AllRequests", "Sum"},
+		{"GetRequests", "Sum"},
+		{"PutRequests", "Sum"},
+		{"4xxErrors", "Sum"},
+		{"5xxErrors", "Sum"},
+		{"FirstByteLatency", "Average"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range requestMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/S3",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{Name: aws.String("BucketName"), Value: aws.String(bucketName)},
+				{Name: aws.String("FilterId"), Value: aws.String(filterID)},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting S3 request metrics: %v", err)
+	}
+
+	return &S3Requests{
+		AllRequests:      aggregateValues("Sum", results["AllRequests"]),
+		GetRequests:      aggregateValues("Sum", results["GetRequests"]),
+		PutRequests:      aggregateValues("Sum", results["PutRequests"]),
+		Errors4xx:        aggregateValues("Sum", results["4xxErrors"]),
+		Errors5xx:        aggregateValues("Sum", results["5xxErrors"]),
+		FirstByteLatency: aggregateValues("Average", results["FirstByteLatency"]),
+	}, nil
+}
+
+type s3Collector struct{}
+
+func (s3Collector) Name() string { return "s3" }
+
+// Every report with request metrics, daily reports only otherwise
+func (s3Collector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.S3.Enabled && (window.IsDailyReport || cfg.Services.S3.RequestMetricsFilter != "")
+}
+
+func (s3Collector) Resources(cfg *config.Config) []string {
+	return cfg.Services.S3.BucketNames
+}
+
+func (s3Collector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, bucketName string) (utils.Result, error) {
+	s3Config := cfg.Services.S3
+	cwClient := clients.CloudWatch.Get(config.ResourceRegion(s3Config.Region, s3Config.ResourceRegions, bucketName))
+
+	result := &S3Result{BucketName: bucketName}
+	if window.IsDailyReport {
+		var err error
+		if result, err = S3Metrics(ctx, cwClient, bucketName, windowTimes(window)); err != nil {
+			return nil, err
 		}
-	} else {
-		metrics["NumberOfObjects"] = 0.0
+		result.ObjectDeltas = s3Config.ObjectDeltas
 	}
 
-	return metrics, nil
+	if s3Config.RequestMetricsFilter != "" {
+		requests, err := S3RequestMetrics(ctx, cwClient, bucketName, s3Config.RequestMetricsFilter, windowTimes(window))
+		if err != nil {
+			return nil, err
+		}
+		result.Requests = requests
+	}
+	return result, nil
 }
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+func TestS3StorageFetchFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cwClient := cloudwatch.New(cloudwatch.Options{
+		Region:           "us-east-1",
+		BaseEndpoint:     aws.String(server.URL),
+		Credentials:      aws.AnonymousCredentials{},
+		RetryMaxAttempts: 1,
+	})
+	endTime := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	timeParams := map[string]time.Time{"startTime": endTime.AddDate(0, 0, -1), "endTime": endTime}
+
+	result, err := S3Metrics(context.Background(), cwClient, "bucket", timeParams)
+	if err == nil {
+		t.Fatalf("S3Metrics = %+v, want an error", result)
+	}
+
+	// The collector drops the bucket, a result without datapoints is skipped too
+	buckets := map[string]any{
+		"empty": &S3Result{BucketName: "empty", Storage: true},
+		"sized": &S3Result{BucketName: "sized", Storage: true, SizeReported: true, BucketSizeMB: 12},
+	}
+	sizes := map[string]map[string]float64{
+		endTime.AddDate(0, 0, -1).Format(time.DateOnly): {"empty": 40, "sized": 10},
+	}
+
+	current := ApplyS3Growth(buckets, sizes, endTime)
+	if _, exists := current["empty"]; exists || current["sized"] != 12 {
+		t.Errorf("ApplyS3Growth sizes = %v, want sized only", current)
+	}
+	if growth := buckets["empty"].(*S3Result).Growth; len(growth) != 0 {
+		t.Errorf("growth without a reported size = %v", growth)
+	}
+}
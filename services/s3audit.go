@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// Grantees of a public ACL
+const (
+	s3AllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	s3AuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+type S3AuditBucket struct {
+	Name       string
+	Violations []string
+}
+
+type S3AuditResult struct {
+	Audited int
+	Buckets []S3AuditBucket // With at least one violation
+}
+
+func (r *S3AuditResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Audited":   float64(r.Audited),
+		"Violating": float64(len(r.Buckets)),
+	}
+}
+
+func (r *S3AuditResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "s3Audit", Title: "S3 Audit"}
+	section.AddLine("Buckets: %d audited, %d with violations", r.Audited, len(r.Buckets))
+	for _, bucket := range r.Buckets {
+		section.AddLine("%s: %s", bucket.Name, strings.Join(bucket.Violations, ", "))
+	}
+	return section
+}
+
+func (r *S3AuditResult) Failures() []string {
+	failures := make([]string, 0, len(r.Buckets))
+	for _, bucket := range r.Buckets {
+		failures = append(failures, fmt.Sprintf("S3 %s: %s", bucket.Name, strings.Join(bucket.Violations, ", ")))
+	}
+	return failures
+}
+
+// Missing configurations are errors with these codes rather than empty outputs
+func isS3ErrorCode(err error, code string) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == code
+}
+
+// Violations of one bucket: public access block not fully on, public bucket
+// policy, ACL granting access to everyone, no default encryption
+func auditS3Bucket(ctx context.Context, s3Client *s3.Client, bucketName string) ([]string, error) {
+	var violations []string
+
+	accessBlock, err := s3Client.GetPublicAccessBlock(ctx, &s3.GetPublicAccessBlockInput{Bucket: aws.String(bucketName)})
+	switch {
+	case isS3ErrorCode(err, "NoSuchPublicAccessBlockConfiguration"):
+		violations = append(violations, "no public access block")
+	case err != nil:
+		return nil, fmt.Errorf("error getting public access block: %v", err)
+	default:
+		block := accessBlock.PublicAccessBlockConfiguration
+		if block == nil || !aws.ToBool(block.BlockPublicAcls) || !aws.ToBool(block.IgnorePublicAcls) ||
+			!aws.ToBool(block.BlockPublicPolicy) || !aws.ToBool(block.RestrictPublicBuckets) {
+			violations = append(violations, "public access block partially off")
+		}
+	}
+
+	policyStatus, err := s3Client.GetBucketPolicyStatus(ctx, &s3.GetBucketPolicyStatusInput{Bucket: aws.String(bucketName)})
+	switch {
+	case isS3ErrorCode(err, "NoSuchBucketPolicy"):
+	case err != nil:
+		return nil, fmt.Errorf("error getting bucket policy status: %v", err)
+	case policyStatus.PolicyStatus != nil && aws.ToBool(policyStatus.PolicyStatus.IsPublic):
+		violations = append(violations, "public bucket policy")
+	}
+
+	acl, err := s3Client.GetBucketAcl(ctx, &s3.GetBucketAclInput{Bucket: aws.String(bucketName)})
+	if err != nil {
+		return nil, fmt.Errorf("error getting bucket ACL: %v", err)
+	}
+	for _, grant := range acl.Grants {
+		if grant.Grantee == nil {
+			continue
+		}
+		if uri := aws.ToString(grant.Grantee.URI); uri == s3AllUsers || uri == s3AuthenticatedUsers {
+			violations = append(violations, "public ACL")
+			break
+		}
+	}
+
+	// Buckets created since 2023 are encrypted by default, older ones may not be
+	_, err = s3Client.GetBucketEncryption(ctx, &s3.GetBucketEncryptionInput{Bucket: aws.String(bucketName)})
+	switch {
+	case isS3ErrorCode(err, "ServerSideEncryptionConfigurationNotFoundError"):
+		violations = append(violations, "no default encryption")
+	case err != nil:
+		return nil, fmt.Errorf("error getting bucket encryption: %v", err)
+	}
+
+	return violations, nil
+}
+
+// Buckets of the account with their region
+func listS3Buckets(ctx context.Context, s3Client *s3.Client) (map[string]string, error) {
+	buckets := map[string]string{}
+	paginator := s3.NewListBucketsPaginator(s3Client, &s3.ListBucketsInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing S3 buckets: %v", err)
+		}
+		for _, bucket := range output.Buckets {
+			buckets[aws.ToString(bucket.Name)] = aws.ToString(bucket.BucketRegion)
+		}
+	}
+	return buckets, nil
+}
+
+type s3AuditCollector struct{}
+
+func (s3AuditCollector) Name() string { return "s3Audit" }
+
+func (s3AuditCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.S3Audit.Enabled && window.IsDailyReport
+}
+
+func (s3AuditCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+// Audits the monitored S3 buckets, or every bucket of the account with allBuckets
+func (s3AuditCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	s3Config := cfg.Services.S3
+
+	buckets := map[string]string{}
+	if cfg.Services.S3Audit.AllBuckets {
+		var err error
+		if buckets, err = listS3Buckets(ctx, clients.S3.Get(s3Config.Region)); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, bucketName := range s3Config.BucketNames {
+			buckets[bucketName] = config.ResourceRegion(s3Config.Region, s3Config.ResourceRegions, bucketName)
+		}
+	}
+
+	result := &S3AuditResult{Audited: len(buckets)}
+	for _, bucketName := range slices.Sorted(maps.Keys(buckets)) {
+		violations, err := auditS3Bucket(ctx, clients.S3.Get(buckets[bucketName]), bucketName)
+		if err != nil {
+			return nil, fmt.Errorf("error auditing S3 bucket '%s': %v", bucketName, err)
+		}
+		if len(violations) > 0 {
+			result.Buckets = append(result.Buckets, S3AuditBucket{Name: bucketName, Violations: violations})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/securityhub"
+	securityHubTypes "github.com/aws/aws-sdk-go-v2/service/securityhub/types"
+)
+
+// GetFindings returns up to 100 findings per page
+const maxSecurityHubFindings = 100
+
+// Rank of the severity labels, to list the most severe findings first
+var securityHubSeverities = map[securityHubTypes.SeverityLabel]int{
+	securityHubTypes.SeverityLabelCritical:      4,
+	securityHubTypes.SeverityLabelHigh:          3,
+	securityHubTypes.SeverityLabelMedium:        2,
+	securityHubTypes.SeverityLabelLow:           1,
+	securityHubTypes.SeverityLabelInformational: 0,
+}
+
+// Findings sharing a title, with the highest severity among them
+type SecurityHubFinding struct {
+	Title    string
+	Severity securityHubTypes.SeverityLabel
+	Count    float64
+}
+
+// New findings of a standard (eg: "cis-aws-foundations-benchmark/v/1.4.0"),
+// or of the integrated product (GuardDuty, Inspector...) for other findings
+type SecurityHubSource struct {
+	Name  string
+	Count float64
+}
+
+// Findings created in the window, passed controls left out
+type SecurityHubResult struct {
+	Critical      float64
+	High          float64
+	Medium        float64
+	Low           float64
+	Informational float64
+	Sources       []SecurityHubSource  // Most findings first
+	TopFindings   []SecurityHubFinding // Most severe titles
+}
+
+func (r *SecurityHubResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Critical":      r.Critical,
+		"High":          r.High,
+		"Medium":        r.Medium,
+		"Low":           r.Low,
+		"Informational": r.Informational,
+	}
+}
+
+func (r *SecurityHubResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "securityhub", Title: "Security Hub"}
+	section.AddLine("New Findings: Critical: %.0f, High: %.0f, Medium: %.0f, Low: %.0f, Informational: %.0f",
+		r.Critical, r.High, r.Medium, r.Low, r.Informational)
+
+	if len(r.Sources) > 0 {
+		section.AddLine("By Standard:")
+		for _, source := range r.Sources {
+			section.AddLine("%s: %.0f", source.Name, source.Count)
+		}
+	}
+	if len(r.TopFindings) > 0 {
+		section.AddLine("Top Findings:")
+		for _, finding := range r.TopFindings {
+			section.AddLine("%s (%s, x%.0f)", finding.Title, finding.Severity, finding.Count)
+		}
+	}
+	return section
+}
+
+// Standards a finding belongs to, eg: "standards/cis-aws-foundations-benchmark/v/1.4.0"
+// becomes "cis-aws-foundations-benchmark/v/1.4.0". A control enabled in several
+// standards counts in each of them.
+func securityHubSources(finding securityHubTypes.AwsSecurityFinding) []string {
+	var sources []string
+	if finding.Compliance != nil {
+		for _, standard := range finding.Compliance.AssociatedStandards {
+			standardID := aws.ToString(standard.StandardsId)
+			standardID = strings.TrimPrefix(standardID, "standards/")
+			standardID = strings.TrimPrefix(standardID, "ruleset/")
+			sources = append(sources, standardID)
+		}
+	}
+	if len(sources) == 0 {
+		sources = append(sources, aws.ToString(finding.ProductName))
+	}
+	return sources
+}
+
+// Called in the aggregation region, findings of the linked regions are included
+func SecurityHubMetrics(ctx context.Context, shClient *securityhub.Client, topFindings int, timeParams map[string]time.Time) (*SecurityHubResult, error) {
+	input := &securityhub.GetFindingsInput{
+		Filters: &securityHubTypes.AwsSecurityFindingFilters{
+			CreatedAt: []securityHubTypes.DateFilter{
+				{
+					Start: aws.String(timeParams["startTime"].Format(time.RFC3339)),
+					End:   aws.String(timeParams["endTime"].Format(time.RFC3339)),
+				},
+			},
+			RecordState: []securityHubTypes.StringFilter{
+				{
+					Value:      aws.String(string(securityHubTypes.RecordStateActive)),
+					Comparison: securityHubTypes.StringFilterComparisonEquals,
+				},
+			},
+			WorkflowStatus: []securityHubTypes.StringFilter{
+				{
+					Value:      aws.String(string(securityHubTypes.WorkflowStatusSuppressed)),
+					Comparison: securityHubTypes.StringFilterComparisonNotEquals,
+				},
+			},
+		},
+		MaxResults: aws.Int32(maxSecurityHubFindings),
+	}
+
+	result := &SecurityHubResult{}
+	sources := map[string]float64{}
+	titles := map[string]*SecurityHubFinding{}
+
+	paginator := securityhub.NewGetFindingsPaginator(shClient, input)
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting Security Hub findings: %v", err)
+		}
+
+		for _, finding := range output.Findings {
+			// Control checks also report the resources that pass them
+			if finding.Compliance != nil && finding.Compliance.Status == securityHubTypes.ComplianceStatusPassed {
+				continue
+			}
+
+			var severity securityHubTypes.SeverityLabel
+			if finding.Severity != nil {
+				severity = finding.Severity.Label
+			}
+			switch severity {
+			case securityHubTypes.SeverityLabelCritical:
+				result.Critical++
+			case securityHubTypes.SeverityLabelHigh:
+				result.High++
+			case securityHubTypes.SeverityLabelMedium:
+				result.Medium++
+			case securityHubTypes.SeverityLabelLow:
+				result.Low++
+			default:
+				severity = securityHubTypes.SeverityLabelInformational
+				result.Informational++
+			}
+
+			for _, source := range securityHubSources(finding) {
+				sources[source]++
+			}
+
+			title := aws.ToString(finding.Title)
+			summary, exists := titles[title]
+			if !exists {
+				summary = &SecurityHubFinding{Title: title, Severity: severity}
+				titles[title] = summary
+			}
+			if securityHubSeverities[severity] > securityHubSeverities[summary.Severity] {
+				summary.Severity = severity
+			}
+			summary.Count++
+		}
+	}
+
+	for source, count := range sources {
+		result.Sources = append(result.Sources, SecurityHubSource{Name: source, Count: count})
+	}
+	sort.Slice(result.Sources, func(i, j int) bool {
+		if result.Sources[i].Count != result.Sources[j].Count {
+			return result.Sources[i].Count > result.Sources[j].Count
+		}
+		return result.Sources[i].Name < result.Sources[j].Name
+	})
+
+	// Most severe first, then most frequent
+	summaries := make([]SecurityHubFinding, 0, len(titles))
+	for _, summary := range titles {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		left, right := securityHubSeverities[summaries[i].Severity], securityHubSeverities[summaries[j].Severity]
+		if left != right {
+			return left > right
+		}
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+		return summaries[i].Title < summaries[j].Title
+	})
+	if len(summaries) > topFindings {
+		summaries = summaries[:topFindings]
+	}
+	result.TopFindings = summaries
+
+	return result, nil
+}
+
+type securityHubCollector struct{}
+
+func (securityHubCollector) Name() string { return "securityhub" }
+
+func (securityHubCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.SecurityHub.Enabled && window.IsDailyReport
+}
+
+func (securityHubCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (securityHubCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	topFindings := cfg.Services.SecurityHub.TopFindings
+	if topFindings == 0 {
+		topFindings = 5
+	}
+
+	return SecurityHubMetrics(ctx, clients.SecurityHub.Get(cfg.Services.SecurityHub.Region), topFindings, windowTimes(window))
+}
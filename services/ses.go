@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// SES puts accounts under review and then pauses sending above these rates (%)
+const (
+	sesBounceReviewRate        = 5.0
+	sesBounceSuspensionRate    = 10.0
+	sesComplaintReviewRate     = 0.1
+	sesComplaintSuspensionRate = 0.5
+)
+
+// Warns from 80% of the review rate so there's time to react
+func reputationWarning(rate float64, reviewRate float64, suspensionRate float64) string {
+	switch {
+	case rate >= suspensionRate:
+		return fmt.Sprintf(" (SENDING AT RISK, above %.1f%%)", suspensionRate)
+	case rate >= reviewRate:
+		return fmt.Sprintf(" (WARNING, above %.1f%% review rate)", reviewRate)
+	case rate >= reviewRate*0.8:
+		return fmt.Sprintf(" (approaching %.1f%% review rate)", reviewRate)
+	}
+	return ""
+}
+
+// Sending counts of the window are sums
+type SESSendingCounts struct {
+	Send      float64
+	Delivery  float64
+	Bounce    float64
+	Complaint float64
+	Reject    float64
+}
+
+func (c SESSendingCounts) metrics(prefix string) map[string]float64 {
+	return map[string]float64{
+		prefix + "Send":      c.Send,
+		prefix + "Delivery":  c.Delivery,
+		prefix + "Bounce":    c.Bounce,
+		prefix + "Complaint": c.Complaint,
+		prefix + "Reject":    c.Reject,
+	}
+}
+
+type SESConfigurationSet struct {
+	Name string
+	SESSendingCounts
+}
+
+// Account-wide sending counts plus the latest reputation rates (%).
+// Configuration sets only report when they publish events to CloudWatch.
+type SESResult struct {
+	SESSendingCounts
+	BounceRate        float64
+	ComplaintRate     float64
+	ConfigurationSets []SESConfigurationSet
+}
+
+func (r *SESResult) Metrics() map[string]float64 {
+	metrics := r.SESSendingCounts.metrics("")
+	metrics["BounceRate"] = r.BounceRate
+	metrics["ComplaintRate"] = r.ComplaintRate
+	for _, configurationSet := range r.ConfigurationSets {
+		maps.Copy(metrics, configurationSet.metrics("ConfigurationSets/"+configurationSet.Name+"/"))
+	}
+	return metrics
+}
+
+func (r *SESResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "ses", Title: "SES"}
+	section.AddLine("Sent: %.0f%s, Delivered: %.0f", r.Send, trend("Send", r.Send), r.Delivery)
+	section.AddLine("Bounces: %.0f, Complaints: %.0f, Rejects: %.0f", r.Bounce, r.Complaint, r.Reject)
+	section.AddLine("Bounce Rate: %.2f%%%s", r.BounceRate,
+		reputationWarning(r.BounceRate, sesBounceReviewRate, sesBounceSuspensionRate))
+	section.AddLine("Complaint Rate: %.3f%%%s", r.ComplaintRate,
+		reputationWarning(r.ComplaintRate, sesComplaintReviewRate, sesComplaintSuspensionRate))
+
+	for _, configurationSet := range r.ConfigurationSets {
+		section.AddLine("%s: Sent %.0f, Delivered %.0f, Bounces %.0f, Complaints %.0f",
+			configurationSet.Name,
+			configurationSet.Send,
+			configurationSet.Delivery,
+			configurationSet.Bounce,
+			configurationSet.Complaint)
+	}
+	return section
+}
+
+func SESMetrics(ctx context.Context, cwClient *cloudwatch.Client, configurationSets []string, timeParams map[string]time.Time) (*SESResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	sendingMetrics := []string{"Send", "Delivery", "Bounce", "Complaint", "Reject"}
+
+	var queries []metricQuery
+	for _, metricName := range sendingMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "AWS/SES",
+			MetricName: metricName,
+			Statistic:  "Sum",
+		})
+	}
+	for _, metricName := range []string{"Reputation.BounceRate", "Reputation.ComplaintRate"} {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "AWS/SES",
+			MetricName: metricName,
+			Statistic:  "Average",
+		})
+	}
+	for _, configurationSet := range configurationSets {
+		for _, metricName := range sendingMetrics {
+			queries = append(queries, metricQuery{
+				Key:        configurationSet + "/" + metricName,
+				Namespace:  "AWS/SES",
+				MetricName: metricName,
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("ses:configuration-set"),
+						Value: aws.String(configurationSet),
+					},
+				},
+				Statistic: "Sum",
+			})
+		}
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SES metrics: %v", err)
+	}
+
+	sendingCounts := func(prefix string) SESSendingCounts {
+		return SESSendingCounts{
+			Send:      aggregateValues("Sum", results[prefix+"Send"]),
+			Delivery:  aggregateValues("Sum", results[prefix+"Delivery"]),
+			Bounce:    aggregateValues("Sum", results[prefix+"Bounce"]),
+			Complaint: aggregateValues("Sum", results[prefix+"Complaint"]),
+			Reject:    aggregateValues("Sum", results[prefix+"Reject"]),
+		}
+	}
+
+	// Reputation rates are gauges (0-1), the latest datapoint is the current rate
+	latestRate := func(metricName string) float64 {
+		if values := results[metricName]; len(values) > 0 {
+			return values[0] * 100
+		}
+		return 0
+	}
+
+	result := &SESResult{
+		SESSendingCounts: sendingCounts(""),
+		BounceRate:       latestRate("Reputation.BounceRate"),
+		ComplaintRate:    latestRate("Reputation.ComplaintRate"),
+	}
+	for _, configurationSet := range configurationSets {
+		result.ConfigurationSets = append(result.ConfigurationSets, SESConfigurationSet{
+			Name:             configurationSet,
+			SESSendingCounts: sendingCounts(configurationSet + "/"),
+		})
+	}
+
+	return result, nil
+}
+
+type sesCollector struct{}
+
+func (sesCollector) Name() string { return "ses" }
+
+func (sesCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.SES.Enabled
+}
+
+func (sesCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (sesCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return SESMetrics(ctx, clients.CloudWatch.Get(cfg.Services.SES.Region), cfg.Services.SES.ConfigurationSets, windowTimes(window))
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/shield"
+	shieldTypes "github.com/aws/aws-sdk-go-v2/service/shield/types"
+)
+
+type ShieldAttack struct {
+	Resource  string // Resource part of the ARN, eg: "distribution/E2QWRUHAPOMQZL"
+	StartTime time.Time
+	EndTime   time.Time // Zero while the attack is ongoing
+	Vectors   []string  // eg: "SYN_FLOOD", "UDP_REFLECTION"
+}
+
+// Attacks detected by Shield Advanced that started in the window
+type ShieldResult struct {
+	Attacks  []ShieldAttack // Most recent first
+	Location *time.Location
+}
+
+func (r *ShieldResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"Attacks": float64(len(r.Attacks)),
+	}
+}
+
+func (r *ShieldResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "shield", Title: "Shield"}
+	section.AddLine("DDoS Attacks: %d", len(r.Attacks))
+	for _, attack := range r.Attacks {
+		duration := "ongoing"
+		if !attack.EndTime.IsZero() {
+			duration = fmt.Sprintf("%.0f min", attack.EndTime.Sub(attack.StartTime).Minutes())
+		}
+		section.AddLine("%s at %s (%s): %s", attack.Resource, attack.StartTime.In(r.Location).Format("02/01 15:04"), duration, strings.Join(attack.Vectors, ", "))
+	}
+	return section
+}
+
+func (r *ShieldResult) Failures() []string {
+	var failures []string
+	for _, attack := range r.Attacks {
+		if attack.EndTime.IsZero() {
+			failures = append(failures, fmt.Sprintf("Shield: DDoS attack ongoing on %s", attack.Resource))
+		}
+	}
+	return failures
+}
+
+// Needs a Shield Advanced subscription, the API errors without one
+func ShieldMetrics(ctx context.Context, shieldClient *shield.Client, timeParams map[string]time.Time, location *time.Location) (*ShieldResult, error) {
+	paginator := shield.NewListAttacksPaginator(shieldClient, &shield.ListAttacksInput{
+		StartTime: &shieldTypes.TimeRange{
+			FromInclusive: aws.Time(timeParams["startTime"]),
+			ToExclusive:   aws.Time(timeParams["endTime"]),
+		},
+	})
+
+	result := &ShieldResult{Location: location}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing Shield attacks: %v", err)
+		}
+
+		for _, summary := range output.AttackSummaries {
+			attack := ShieldAttack{
+				Resource:  aws.ToString(summary.ResourceArn),
+				StartTime: aws.ToTime(summary.StartTime),
+				EndTime:   aws.ToTime(summary.EndTime),
+			}
+			if resourceArn, err := arn.Parse(attack.Resource); err == nil {
+				attack.Resource = resourceArn.Resource
+			}
+			for _, vector := range summary.AttackVectors {
+				attack.Vectors = append(attack.Vectors, aws.ToString(vector.VectorType))
+			}
+			result.Attacks = append(result.Attacks, attack)
+		}
+	}
+
+	sort.Slice(result.Attacks, func(i, j int) bool {
+		return result.Attacks[i].StartTime.After(result.Attacks[j].StartTime)
+	})
+	return result, nil
+}
+
+type shieldCollector struct{}
+
+func (shieldCollector) Name() string { return "shield" }
+
+func (shieldCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Shield.Enabled
+}
+
+func (shieldCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (shieldCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	return ShieldMetrics(ctx, clients.Shield, windowTimes(window), window.Location)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqsTypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Helper function to get the dead-letter queue name from the queue redrive policy
+func getDLQName(ctx context.Context, sqsClient *sqs.Client, queue string) (string, error) {
+	queueURL := queue
+	if !strings.HasPrefix(queue, "https://") {
+		urlOutput, err := sqsClient.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+			QueueName: aws.String(queue),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to get queue URL: %w", err)
+		}
+		queueURL = *urlOutput.QueueUrl
+	}
+
+	attributes, err := sqsClient.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(queueURL),
+		AttributeNames: []sqsTypes.QueueAttributeName{sqsTypes.QueueAttributeNameRedrivePolicy},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get queue attributes: %w", err)
+	}
+
+	redrivePolicy, exists := attributes.Attributes[string(sqsTypes.QueueAttributeNameRedrivePolicy)]
+	if !exists {
+		return "", nil
+	}
+
+	var policy struct {
+		DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	}
+	if err := json.Unmarshal([]byte(redrivePolicy), &policy); err != nil {
+		return "", fmt.Errorf("failed to parse redrive policy: %w", err)
+	}
+
+	// arn:aws:sqs:region:account:name
+	if idx := strings.LastIndex(policy.DeadLetterTargetArn, ":"); idx != -1 {
+		return policy.DeadLetterTargetArn[idx+1:], nil
+	}
+	return "", nil
+}
+
+type SQSResult struct {
+	QueueName                          string
+	ApproximateNumberOfMessagesVisible float64
+	ApproximateAgeOfOldestMessage      float64 // s
+	NumberOfMessagesSent               float64
+	NumberOfMessagesReceived           float64
+	// Messages in the dead-letter queue, nil = no DLQ configured
+	DLQMessagesVisible *float64
+}
+
+func (r *SQSResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"ApproximateNumberOfMessagesVisible": r.ApproximateNumberOfMessagesVisible,
+		"ApproximateAgeOfOldestMessage":      r.ApproximateAgeOfOldestMessage,
+		"NumberOfMessagesSent":               r.NumberOfMessagesSent,
+		"NumberOfMessagesReceived":           r.NumberOfMessagesReceived,
+	}
+	if r.DLQMessagesVisible != nil {
+		metrics["DLQ_ApproximateNumberOfMessagesVisible"] = *r.DLQMessagesVisible
+	}
+	return metrics
+}
+
+func (r *SQSResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "sqs", Title: "SQS", Subtitle: r.QueueName}
+	section.AddLine("Visible Messages: %.0f", r.ApproximateNumberOfMessagesVisible)
+	section.AddLine("Oldest Message Age: %.0f s", r.ApproximateAgeOfOldestMessage)
+	section.AddLine("Sent: %.0f, Received: %.0f", r.NumberOfMessagesSent, r.NumberOfMessagesReceived)
+	if r.DLQMessagesVisible != nil {
+		section.AddLine("DLQ Messages: %.0f", *r.DLQMessagesVisible)
+	}
+	return section
+}
+
+func SQSMetrics(ctx context.Context, cwClient *cloudwatch.Client, sqsClient *sqs.Client, queue string, timeParams map[string]time.Time) (*SQSResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	// Accepts a queue name or URL (https://sqs.region.amazonaws.com/account/name)
+	queueName := path.Base(queue)
+
+	dlqName, err := getDLQName(ctx, sqsClient, queue)
+	if err != nil {
+		return nil, fmt.Errorf("error getting DLQ for %s: %v", queueName, err)
+	}
+
+	sqsMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"ApproximateNumberOfMessagesVisible", "Maximum"},
+		{"ApproximateAgeOfOldestMessage", "Maximum"},
+		{"NumberOfMessagesSent", "Sum"},
+		{"NumberOfMessagesReceived", "Sum"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range sqsMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/SQS",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("QueueName"),
+					Value: aws.String(queueName),
+				},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	if dlqName != "" {
+		queries = append(queries, metricQuery{
+			Key:        "DLQ_ApproximateNumberOfMessagesVisible",
+			Namespace:  "AWS/SQS",
+			MetricName: "ApproximateNumberOfMessagesVisible",
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("QueueName"),
+					Value: aws.String(dlqName),
+				},
+			},
+			Statistic: "Maximum",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting SQS metrics: %v", err)
+	}
+
+	// Queue depth and age are gauges, the latest datapoint is the current state
+	latest := func(key string) float64 {
+		if values := results[key]; len(values) > 0 {
+			return values[0]
+		}
+		return 0
+	}
+
+	result := &SQSResult{
+		QueueName:                          queueName,
+		ApproximateNumberOfMessagesVisible: latest("ApproximateNumberOfMessagesVisible"),
+		ApproximateAgeOfOldestMessage:      latest("ApproximateAgeOfOldestMessage"),
+		NumberOfMessagesSent:               aggregateValues("Sum", results["NumberOfMessagesSent"]),
+		NumberOfMessagesReceived:           aggregateValues("Sum", results["NumberOfMessagesReceived"]),
+	}
+
+	if dlqName != "" {
+		dlqMessages := latest("DLQ_ApproximateNumberOfMessagesVisible")
+		result.DLQMessagesVisible = &dlqMessages
+	}
+
+	return result, nil
+}
+
+type sqsCollector struct{}
+
+func (sqsCollector) Name() string { return "sqs" }
+
+func (sqsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.SQS.Enabled
+}
+
+func (sqsCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.SQS.Queues
+}
+
+func (sqsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, queue string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.SQS.Region, cfg.Services.SQS.ResourceRegions, queue)
+	return SQSMetrics(ctx, clients.CloudWatch.Get(region), clients.SQS.Get(region), queue, windowTimes(window))
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	sfnTypes "github.com/aws/aws-sdk-go-v2/service/sfn/types"
+)
+
+// Most recent failed executions listed per state machine
+const maxFailedExecutions = 5
+
+// Helper function to get the most recent executions with the given status started in the window
+func listExecutions(ctx context.Context, sfnClient *sfn.Client, stateMachineArn string, status sfnTypes.ExecutionStatus, startTime time.Time) ([]string, error) {
+	executions := []string{}
+	paginator := sfn.NewListExecutionsPaginator(sfnClient, &sfn.ListExecutionsInput{
+		StateMachineArn: aws.String(stateMachineArn),
+		StatusFilter:    status,
+	})
+
+	// Newest first
+	for paginator.HasMorePages() && len(executions) < maxFailedExecutions {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s executions: %v", status, err)
+		}
+
+		for _, execution := range output.Executions {
+			if execution.StartDate == nil || execution.StartDate.Before(startTime) || len(executions) == maxFailedExecutions {
+				return executions, nil
+			}
+			executions = append(executions, aws.ToString(execution.Name))
+		}
+	}
+
+	return executions, nil
+}
+
+// Execution counts and average execution time of a state machine
+type StepFunctionsResult struct {
+	StateMachineArn     string
+	ExecutionsStarted   float64
+	ExecutionsSucceeded float64
+	ExecutionsFailed    float64
+	ExecutionsTimedOut  float64
+	ExecutionsAborted   float64
+	ExecutionTime       float64 // ms
+	// Failed and timed out executions started in the window, only listed when the metrics report some
+	FailedExecutions []string
+}
+
+func (r *StepFunctionsResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"ExecutionsStarted":   r.ExecutionsStarted,
+		"ExecutionsSucceeded": r.ExecutionsSucceeded,
+		"ExecutionsFailed":    r.ExecutionsFailed,
+		"ExecutionsTimedOut":  r.ExecutionsTimedOut,
+		"ExecutionsAborted":   r.ExecutionsAborted,
+		"ExecutionTime":       r.ExecutionTime,
+	}
+}
+
+func (r *StepFunctionsResult) Render(trend utils.TrendFunc) utils.Section {
+	// arn:aws:states:region:account:stateMachine:name
+	name := r.StateMachineArn[strings.LastIndex(r.StateMachineArn, ":")+1:]
+	section := utils.Section{Service: "stepFunctions", Title: "Step Functions", Subtitle: name}
+	section.AddLine("Started: %.0f, Succeeded: %.0f", r.ExecutionsStarted, r.ExecutionsSucceeded)
+	section.AddLine("Failed: %.0f%s, Timed Out: %.0f, Aborted: %.0f",
+		r.ExecutionsFailed,
+		trend("ExecutionsFailed", r.ExecutionsFailed),
+		r.ExecutionsTimedOut,
+		r.ExecutionsAborted)
+	section.AddLine("Execution Time: %.0f ms (avg)", r.ExecutionTime)
+
+	if len(r.FailedExecutions) > 0 {
+		section.AddLine("Failed Executions:")
+		for _, execution := range r.FailedExecutions {
+			section.AddLine("%s", execution)
+		}
+	}
+	return section
+}
+
+func StepFunctionsMetrics(ctx context.Context, cwClient *cloudwatch.Client, sfnClient *sfn.Client, stateMachineArn string, timeParams map[string]time.Time) (*StepFunctionsResult, error) {
+	period := aws.Int32(metricPeriod(timeParams))
+
+	stepFunctionsMetrics := []struct {
+		Name      string
+		Statistic string
+	}{
+		{"ExecutionsStarted", "Sum"},
+		{"ExecutionsSucceeded", "Sum"},
+		{"ExecutionsFailed", "Sum"},
+		{"ExecutionsTimedOut", "Sum"},
+		{"ExecutionsAborted", "Sum"},
+		{"ExecutionTime", "Average"},
+	}
+
+	var queries []metricQuery
+	for _, metric := range stepFunctionsMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/States",
+			MetricName: metric.Name,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("StateMachineArn"),
+					Value: aws.String(stateMachineArn),
+				},
+			},
+			Statistic: metric.Statistic,
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Step Functions metrics: %v", err)
+	}
+
+	result := &StepFunctionsResult{
+		StateMachineArn:     stateMachineArn,
+		ExecutionsStarted:   aggregateValues("Sum", results["ExecutionsStarted"]),
+		ExecutionsSucceeded: aggregateValues("Sum", results["ExecutionsSucceeded"]),
+		ExecutionsFailed:    aggregateValues("Sum", results["ExecutionsFailed"]),
+		ExecutionsTimedOut:  aggregateValues("Sum", results["ExecutionsTimedOut"]),
+		ExecutionsAborted:   aggregateValues("Sum", results["ExecutionsAborted"]),
+		ExecutionTime:       aggregateValues("Average", results["ExecutionTime"]),
+	}
+
+	for _, failure := range []struct {
+		Status sfnTypes.ExecutionStatus
+		Count  float64
+	}{
+		{sfnTypes.ExecutionStatusFailed, result.ExecutionsFailed},
+		{sfnTypes.ExecutionStatusTimedOut, result.ExecutionsTimedOut},
+	} {
+		if failure.Count == 0 {
+			continue
+		}
+		executions, err := listExecutions(ctx, sfnClient, stateMachineArn, failure.Status, timeParams["startTime"])
+		if err != nil {
+			return nil, err
+		}
+		for _, execution := range executions {
+			result.FailedExecutions = append(result.FailedExecutions, fmt.Sprintf("%s (%s)", execution, failure.Status))
+		}
+	}
+
+	return result, nil
+}
+
+type stepFunctionsCollector struct{}
+
+func (stepFunctionsCollector) Name() string { return "stepFunctions" }
+
+func (stepFunctionsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.StepFunctions.Enabled
+}
+
+func (stepFunctionsCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.StepFunctions.StateMachineArns
+}
+
+func (stepFunctionsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, stateMachineArn string) (utils.Result, error) {
+	// Validated when the config is loaded
+	parsedArn, _ := arn.Parse(stateMachineArn)
+	return StepFunctionsMetrics(ctx, clients.CloudWatch.Get(parsedArn.Region), clients.StepFunctions.Get(parsedArn.Region), stateMachineArn, windowTimes(window))
+}
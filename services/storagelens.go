@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Storage Lens publishes once a day, up to 48 hours late
+const storageLensDelay = 48 * time.Hour
+
+// Sizes are in GB. Account totals when BucketName is empty.
+type StorageLensResult struct {
+	BucketName                string
+	Storage                   float64
+	IncompleteMultipartUpload float64 // Parts of uploads never completed nor aborted
+	NonCurrentVersion         float64 // Overwritten and deleted versions kept by versioning
+	Replicated                float64 // Source of replication rules
+	// No datapoints: CloudWatch publishing is off on the configuration, or the
+	// bucket isn't in its scope
+	NoData bool
+}
+
+func (r *StorageLensResult) Metrics() map[string]float64 {
+	if r.NoData {
+		return map[string]float64{}
+	}
+	return map[string]float64{
+		"StorageGB":                   r.Storage,
+		"IncompleteMultipartUploadGB": r.IncompleteMultipartUpload,
+		"NonCurrentVersionGB":         r.NonCurrentVersion,
+		"ReplicatedGB":                r.Replicated,
+	}
+}
+
+func (r *StorageLensResult) Render(trend utils.TrendFunc) utils.Section {
+	subtitle := r.BucketName
+	if subtitle == "" {
+		subtitle = "Account"
+	}
+	section := utils.Section{Service: "storageLens", Title: "S3 Storage Lens", Subtitle: subtitle}
+	if r.NoData {
+		section.AddLine("NO DATA (is CloudWatch publishing enabled?)")
+		return section
+	}
+
+	share := func(value float64) float64 {
+		if r.Storage == 0 {
+			return 0
+		}
+		return value / r.Storage * 100
+	}
+	section.AddLine("Storage: %.2f GB%s", r.Storage, trend("StorageGB", r.Storage))
+	section.AddLine("Incomplete Multipart: %.2f GB (%.1f%%)", r.IncompleteMultipartUpload, share(r.IncompleteMultipartUpload))
+	section.AddLine("Non-current Versions: %.2f GB (%.1f%%)", r.NonCurrentVersion, share(r.NonCurrentVersion))
+	section.AddLine("Replicated: %.1f%%", share(r.Replicated))
+	return section
+}
+
+// Reads the metrics a Storage Lens configuration publishes to CloudWatch (an
+// advanced metrics option) in its home region. bucketName and bucketRegion are
+// empty for the account totals.
+func StorageLensMetrics(ctx context.Context, cwClient *cloudwatch.Client, configurationID string, accountID string, bucketName string, bucketRegion string, timeParams map[string]time.Time) (*StorageLensResult, error) {
+	dimensions := []types.Dimension{
+		{Name: aws.String("configuration_id"), Value: aws.String(configurationID)},
+		{Name: aws.String("metrics_version"), Value: aws.String("1.0")},
+		{Name: aws.String("aws_account_number"), Value: aws.String(accountID)},
+	}
+	if bucketName == "" {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String("record_type"), Value: aws.String("ACCOUNT")})
+	} else {
+		dimensions = append(dimensions,
+			types.Dimension{Name: aws.String("record_type"), Value: aws.String("BUCKET")},
+			types.Dimension{Name: aws.String("aws_region"), Value: aws.String(bucketRegion)},
+			types.Dimension{Name: aws.String("bucket_name"), Value: aws.String(bucketName)},
+		)
+	}
+
+	var queries []metricQuery
+	for _, metricName := range []string{
+		"StorageBytes",
+		"IncompleteMultipartUploadStorageBytes",
+		"NonCurrentVersionStorageBytes",
+		"ReplicatedStorageBytes",
+	} {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "AWS/S3/Storage-Lens",
+			MetricName: metricName,
+			Dimensions: dimensions,
+			Statistic:  "Average",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"].Add(-storageLensDelay), timeParams["endTime"], 86400)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Storage Lens metrics: %v", err)
+	}
+
+	result := &StorageLensResult{BucketName: bucketName}
+	if len(results["StorageBytes"]) == 0 {
+		result.NoData = true
+		return result, nil
+	}
+
+	// Values are newest first, the latest day is the current state
+	latest := func(key string) float64 {
+		if values := results[key]; len(values) > 0 {
+			return values[0] / (1024.0 * 1024.0 * 1024.0)
+		}
+		return 0
+	}
+	result.Storage = latest("StorageBytes")
+	result.IncompleteMultipartUpload = latest("IncompleteMultipartUploadStorageBytes")
+	result.NonCurrentVersion = latest("NonCurrentVersionStorageBytes")
+	result.Replicated = latest("ReplicatedStorageBytes")
+	return result, nil
+}
+
+type storageLensCollector struct{}
+
+func (storageLensCollector) Name() string { return "storageLens" }
+
+func (storageLensCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.StorageLens.Enabled && window.IsDailyReport
+}
+
+// Account totals without buckets
+func (storageLensCollector) Resources(cfg *config.Config) []string {
+	if len(cfg.Services.StorageLens.BucketNames) == 0 {
+		return nil
+	}
+	return cfg.Services.StorageLens.BucketNames
+}
+
+func (storageLensCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, bucketName string) (utils.Result, error) {
+	lens := cfg.Services.StorageLens
+	cwClient := clients.CloudWatch.Get(lens.Region)
+
+	var bucketRegion string
+	if bucketName != "" {
+		bucketRegion = config.ResourceRegion(lens.Region, lens.ResourceRegions, bucketName)
+		if bucketRegion == "" {
+			bucketRegion = clients.Region
+		}
+	}
+	return StorageLensMetrics(ctx, cwClient, lens.ConfigurationID, clients.AccountID, bucketName, bucketRegion, windowTimes(window))
+}
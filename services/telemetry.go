@@ -0,0 +1,29 @@
+package services
+
+import (
+	"context"
+	"telegraws/utils"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+var throttleCheck = retry.IsErrorThrottles(retry.DefaultThrottles)
+
+// SDK middleware counting every attempt (retries included) and the throttled
+// ones in the telemetry of the run. Added after the retry middleware, so it
+// runs once per attempt.
+func RecordAPICalls(stack *middleware.Stack) error {
+	return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("TelegrawsTelemetry",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (middleware.FinalizeOutput, middleware.Metadata, error) {
+			out, metadata, err := next.HandleFinalize(ctx, in)
+			if telemetry := utils.TelemetryFrom(ctx); telemetry != nil {
+				throttled := err != nil && throttleCheck.IsErrorThrottle(err) == aws.TrueTernary
+				telemetry.RecordAPICall(awsmiddleware.GetServiceID(ctx), throttled)
+			}
+			return out, metadata, err
+		},
+	), middleware.After)
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+)
+
+type TLSResult struct {
+	Hostname string // host:port as configured
+	// Days until the first certificate of the chain expires
+	ExpiryDays float64
+	// Intermediate expiring before the leaf, eg: "CN=R3,O=Let's Encrypt", empty otherwise
+	ExpiringSubject string
+	Issuer          string // Issuer of the leaf certificate
+	// Chain or hostname verification error, empty when the chain is trusted
+	VerifyError string
+	Error       string // Connection or handshake error
+	// Certificates expiring sooner are flagged, from the config
+	ExpiryWarnDays float64
+}
+
+func (r *TLSResult) Metrics() map[string]float64 {
+	if r.Error != "" {
+		return map[string]float64{"Valid": 0}
+	}
+	valid := 0.0
+	if r.VerifyError == "" && r.ExpiryDays > 0 {
+		valid = 1
+	}
+	return map[string]float64{
+		"Valid":      valid,
+		"ExpiryDays": r.ExpiryDays,
+	}
+}
+
+// All hostnames share the "TLS Certificates" section
+func (r *TLSResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "tls", Title: "TLS Certificates"}
+	section.AddLine("%s:", r.Hostname)
+	if r.Error != "" {
+		section.AddLine("UNREACHABLE: %s", r.Error)
+		return section
+	}
+
+	expiryLine := fmt.Sprintf("Expires in %.0f days", r.ExpiryDays)
+	switch {
+	case r.ExpiryDays <= 0:
+		expiryLine = fmt.Sprintf("EXPIRED %.0f days ago", -r.ExpiryDays)
+	case r.ExpiryDays < r.ExpiryWarnDays:
+		expiryLine += " (RENEW)"
+	}
+	section.AddLine("%s", expiryLine)
+	if r.ExpiringSubject != "" {
+		section.AddLine("Expiring First: %s", r.ExpiringSubject)
+	}
+	section.AddLine("Issuer: %s", r.Issuer)
+	if r.VerifyError != "" {
+		section.AddLine("UNTRUSTED: %s", r.VerifyError)
+	}
+	return section
+}
+
+func (r *TLSResult) Failures() []string {
+	switch {
+	case r.Error != "":
+		return []string{fmt.Sprintf("TLS %s: %s", r.Hostname, r.Error)}
+	case r.ExpiryDays <= 0:
+		return []string{fmt.Sprintf("TLS %s: certificate expired", r.Hostname)}
+	case r.VerifyError != "":
+		return []string{fmt.Sprintf("TLS %s: %s", r.Hostname, r.VerifyError)}
+	}
+	return nil
+}
+
+// Handshakes with the hostname (host or host:port, 443 by default) and checks
+// the chain it presents against the system roots. The chain is read even when
+// it isn't trusted, so its expiry is still reported.
+func TLSCheck(ctx context.Context, hostname string, timeout time.Duration, expiryWarnDays float64) (*TLSResult, error) {
+	result := &TLSResult{Hostname: hostname, ExpiryWarnDays: expiryWarnDays}
+
+	address := hostname
+	if _, _, err := net.SplitHostPort(hostname); err != nil {
+		address = net.JoinHostPort(hostname, "443")
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS hostname '%s': %v", hostname, err)
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{ServerName: host, InsecureSkipVerify: true},
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		// A failed handshake is a result, not a collection error
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer conn.Close()
+
+	certificates := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certificates) == 0 {
+		result.Error = "no certificate presented"
+		return result, nil
+	}
+
+	leaf := certificates[0]
+	result.Issuer = leaf.Issuer.String()
+	expiring := leaf
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+		if certificate.NotAfter.Before(expiring.NotAfter) {
+			expiring = certificate
+		}
+	}
+	result.ExpiryDays = time.Until(expiring.NotAfter).Hours() / 24
+	if expiring != leaf {
+		result.ExpiringSubject = expiring.Subject.String()
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: host, Intermediates: intermediates})
+	if err != nil {
+		result.VerifyError = err.Error()
+	}
+	return result, nil
+}
+
+type tlsCollector struct{}
+
+func (tlsCollector) Name() string { return "tls" }
+
+func (tlsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.TLS.Enabled
+}
+
+func (tlsCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.TLS.Hostnames
+}
+
+func (tlsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, hostname string) (utils.Result, error) {
+	checks := cfg.Services.TLS
+	return TLSCheck(ctx, hostname, time.Duration(checks.TimeoutSeconds)*time.Second, float64(checks.ExpiryDays))
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+type TransitGatewayResult struct {
+	TransitGatewayID string
+	BytesIn          float64 // MB
+	BytesOut         float64 // MB
+	// Packets dropped on a blackhole route, and for lack of any route
+	PacketDropCountBlackhole float64
+	PacketDropCountNoRoute   float64
+}
+
+func (r *TransitGatewayResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"BytesIn":                  r.BytesIn,
+		"BytesOut":                 r.BytesOut,
+		"PacketDropCountBlackhole": r.PacketDropCountBlackhole,
+		"PacketDropCountNoRoute":   r.PacketDropCountNoRoute,
+		"PacketDropCount":          r.PacketDropCountBlackhole + r.PacketDropCountNoRoute,
+	}
+}
+
+func (r *TransitGatewayResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "transitGateway", Title: "Transit Gateway", Subtitle: r.TransitGatewayID}
+	section.AddLine("Bytes In: %.2f MB%s", r.BytesIn, trend("BytesIn", r.BytesIn))
+	section.AddLine("Bytes Out: %.2f MB", r.BytesOut)
+	drops := r.PacketDropCountBlackhole + r.PacketDropCountNoRoute
+	section.AddLine("Packet Drops: %.0f (blackhole %.0f, no route %.0f)%s", drops, r.PacketDropCountBlackhole, r.PacketDropCountNoRoute, trend("PacketDropCount", drops))
+	return section
+}
+
+func TransitGatewayMetrics(ctx context.Context, cwClient *cloudwatch.Client, transitGatewayID string, timeParams map[string]time.Time) (*TransitGatewayResult, error) {
+	var queries []metricQuery
+	for _, metricName := range []string{"BytesIn", "BytesOut", "PacketDropCountBlackhole", "PacketDropCountNoRoute"} {
+		queries = append(queries, metricQuery{
+			Key:        metricName,
+			Namespace:  "AWS/TransitGateway",
+			MetricName: metricName,
+			Dimensions: []types.Dimension{
+				{
+					Name:  aws.String("TransitGateway"),
+					Value: aws.String(transitGatewayID),
+				},
+			},
+			Statistic: "Sum",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting Transit Gateway metrics: %v", err)
+	}
+
+	return &TransitGatewayResult{
+		TransitGatewayID:         transitGatewayID,
+		BytesIn:                  aggregateValues("Sum", results["BytesIn"]) / (1024.0 * 1024.0),
+		BytesOut:                 aggregateValues("Sum", results["BytesOut"]) / (1024.0 * 1024.0),
+		PacketDropCountBlackhole: aggregateValues("Sum", results["PacketDropCountBlackhole"]),
+		PacketDropCountNoRoute:   aggregateValues("Sum", results["PacketDropCountNoRoute"]),
+	}, nil
+}
+
+type transitGatewayCollector struct{}
+
+func (transitGatewayCollector) Name() string { return "transitGateway" }
+
+func (transitGatewayCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.TransitGateway.Enabled
+}
+
+func (transitGatewayCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.TransitGateway.TransitGatewayIDs
+}
+
+func (transitGatewayCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, transitGatewayID string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.TransitGateway.Region, cfg.Services.TransitGateway.ResourceRegions, transitGatewayID)
+	return TransitGatewayMetrics(ctx, clients.CloudWatch.Get(region), transitGatewayID, windowTimes(window))
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+)
+
+// Bodies are read so the latency covers the whole response, up to this size
+const maxUptimeBodyBytes = 1 << 20
+
+type UptimeResult struct {
+	URL        string
+	Up         bool
+	StatusCode int
+	Latency    float64 // ms
+	// Days until the certificate expires, nil for plain HTTP or failed requests
+	TLSExpiryDays *float64
+	Error         string // Request error, eg: a timeout or a refused connection
+	// Thresholds flagging the response, from the config
+	SlowMs        float64
+	TLSExpiryWarn float64
+}
+
+func (r *UptimeResult) Metrics() map[string]float64 {
+	up := 0.0
+	if r.Up {
+		up = 1
+	}
+	metrics := map[string]float64{
+		"Up":         up,
+		"StatusCode": float64(r.StatusCode),
+		"LatencyMs":  r.Latency,
+	}
+	if r.TLSExpiryDays != nil {
+		metrics["TLSExpiryDays"] = *r.TLSExpiryDays
+	}
+	return metrics
+}
+
+// All URLs share the "Uptime" section
+func (r *UptimeResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "uptime", Title: "Uptime"}
+	section.AddLine("%s:", r.URL)
+
+	switch {
+	case r.Error != "":
+		section.AddLine("DOWN: %s", r.Error)
+	case !r.Up:
+		section.AddLine("DOWN: status %d, %.0f ms", r.StatusCode, r.Latency)
+	default:
+		latencyLine := fmt.Sprintf("Status %d, %.0f ms%s", r.StatusCode, r.Latency, trend("LatencyMs", r.Latency))
+		if r.SlowMs > 0 && r.Latency > r.SlowMs {
+			latencyLine += " (SLOW)"
+		}
+		section.AddLine("%s", latencyLine)
+	}
+
+	if r.TLSExpiryDays != nil {
+		expiryLine := fmt.Sprintf("Certificate expires in %.0f days", *r.TLSExpiryDays)
+		if *r.TLSExpiryDays < r.TLSExpiryWarn {
+			expiryLine += " (RENEW)"
+		}
+		section.AddLine("%s", expiryLine)
+	}
+	return section
+}
+
+func (r *UptimeResult) Failures() []string {
+	switch {
+	case r.Error != "":
+		return []string{fmt.Sprintf("%s: %s", r.URL, r.Error)}
+	case !r.Up:
+		return []string{fmt.Sprintf("%s: status %d", r.URL, r.StatusCode)}
+	}
+	return nil
+}
+
+// Probes the URL once with a GET. The endpoint is up on the expected status,
+// any 2xx or 3xx when expectedStatus is 0. Redirects aren't followed, the
+// status, latency and certificate are those of the configured URL.
+func UptimeCheck(ctx context.Context, endpoint string, expectedStatus int, timeout time.Duration, slowMs float64, tlsExpiryWarn float64) (*UptimeResult, error) {
+	result := &UptimeResult{URL: endpoint, SlowMs: slowMs, TLSExpiryWarn: tlsExpiryWarn}
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(probeCtx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request for '%s': %v", endpoint, err)
+	}
+	req.Header.Set("User-Agent", "telegraws-uptime")
+
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		// The collection ran out of time, which says nothing about the
+		// endpoint. The report lists the probe as timed out instead.
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("error probing '%s': %v", endpoint, ctx.Err())
+		}
+
+		// A failed probe is a result, not a collection error. The URL is
+		// already shown, only the cause is kept.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		result.Error = err.Error()
+		return result, nil
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxUptimeBodyBytes))
+	result.Latency = float64(time.Since(start).Milliseconds())
+
+	result.StatusCode = resp.StatusCode
+	if expectedStatus > 0 {
+		result.Up = resp.StatusCode == expectedStatus
+	} else {
+		result.Up = resp.StatusCode >= 200 && resp.StatusCode < 400
+	}
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		days := time.Until(resp.TLS.PeerCertificates[0].NotAfter).Hours() / 24
+		result.TLSExpiryDays = &days
+	}
+	return result, nil
+}
+
+type uptimeCollector struct{}
+
+func (uptimeCollector) Name() string { return "uptime" }
+
+func (uptimeCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.Uptime.Enabled
+}
+
+func (uptimeCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.Uptime.URLs
+}
+
+func (uptimeCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, endpoint string) (utils.Result, error) {
+	uptime := cfg.Services.Uptime
+	return UptimeCheck(ctx, endpoint, uptime.ExpectedStatus, time.Duration(uptime.TimeoutSeconds)*time.Second, uptime.SlowMs, float64(uptime.TLSExpiryDays))
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+type FlowTalker struct {
+	Address string
+	Bytes   float64 // MB
+}
+
+// Traffic volumes are in MB, BytesIn/BytesOut only count traffic crossing the VPC boundary
+type VPCFlowLogsResult struct {
+	LogGroupName string
+	TotalBytes   float64
+	BytesIn      float64
+	BytesOut     float64
+	Rejected     float64
+	TopTalkers   []FlowTalker
+}
+
+func (r *VPCFlowLogsResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"TotalBytes": r.TotalBytes,
+		"BytesIn":    r.BytesIn,
+		"BytesOut":   r.BytesOut,
+		"Rejected":   r.Rejected,
+	}
+}
+
+func (r *VPCFlowLogsResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "vpcFlowLogs", Title: "VPC Flow Logs", Subtitle: r.LogGroupName}
+	section.AddLine("Total: %.2f MB", r.TotalBytes)
+	section.AddLine("In: %.2f MB, Out: %.2f MB", r.BytesIn, r.BytesOut)
+	section.AddLine("Rejected: %.0f", r.Rejected)
+
+	if len(r.TopTalkers) > 0 {
+		section.AddLine("Top Talkers:")
+		for _, talker := range r.TopTalkers {
+			section.AddLine("%s: %.2f MB", talker.Address, talker.Bytes)
+		}
+	}
+	return section
+}
+
+func VPCFlowLogsMetrics(ctx context.Context, logsClient *cloudwatchlogs.Client, logGroupName string, vpcCidr string, topTalkers int, timeParams map[string]time.Time) (*VPCFlowLogsResult, error) {
+	result := &VPCFlowLogsResult{LogGroupName: logGroupName}
+
+	// Totals and rejected connections, grouped by action (ACCEPT/REJECT)
+	actionRows, err := runInsightsQuery(ctx, logsClient, logGroupName,
+		"stats sum(bytes) as totalBytes, count(*) as flows by action", timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error querying flow totals: %v", err)
+	}
+
+	var totalBytes, rejected float64
+	for _, row := range actionRows {
+		totalBytes += parseInsightsFloat(row["totalBytes"])
+		if row["action"] == "REJECT" {
+			rejected += parseInsightsFloat(row["flows"])
+		}
+	}
+	result.TotalBytes = totalBytes / (1024.0 * 1024.0) // MB
+	result.Rejected = rejected
+
+	// Traffic crossing the VPC boundary in each direction
+	directions := []struct {
+		Name  string
+		Query string
+		Value *float64
+	}{
+		{"BytesIn", fmt.Sprintf("filter isIpv4InSubnet(dstAddr, \"%s\") and not isIpv4InSubnet(srcAddr, \"%s\") | stats sum(bytes) as totalBytes", vpcCidr, vpcCidr), &result.BytesIn},
+		{"BytesOut", fmt.Sprintf("filter isIpv4InSubnet(srcAddr, \"%s\") and not isIpv4InSubnet(dstAddr, \"%s\") | stats sum(bytes) as totalBytes", vpcCidr, vpcCidr), &result.BytesOut},
+	}
+
+	for _, direction := range directions {
+		rows, err := runInsightsQuery(ctx, logsClient, logGroupName, direction.Query, timeParams)
+		if err != nil {
+			return nil, fmt.Errorf("error querying %s: %v", direction.Name, err)
+		}
+
+		var value float64
+		if len(rows) > 0 {
+			value = parseInsightsFloat(rows[0]["totalBytes"])
+		}
+		*direction.Value = value / (1024.0 * 1024.0) // MB
+	}
+
+	// Top talkers by source address
+	talkerRows, err := runInsightsQuery(ctx, logsClient, logGroupName,
+		fmt.Sprintf("stats sum(bytes) as totalBytes by srcAddr | sort totalBytes desc | limit %d", topTalkers), timeParams)
+	if err != nil {
+		return nil, fmt.Errorf("error querying top talkers: %v", err)
+	}
+
+	for _, row := range talkerRows {
+		result.TopTalkers = append(result.TopTalkers, FlowTalker{
+			Address: row["srcAddr"],
+			Bytes:   parseInsightsFloat(row["totalBytes"]) / (1024.0 * 1024.0), // MB
+		})
+	}
+
+	return result, nil
+}
+
+type vpcFlowLogsCollector struct{}
+
+func (vpcFlowLogsCollector) Name() string { return "vpcFlowLogs" }
+
+func (vpcFlowLogsCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.VPCFlowLogs.Enabled
+}
+
+func (vpcFlowLogsCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (vpcFlowLogsCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	topTalkers := cfg.Services.VPCFlowLogs.TopTalkers
+	if topTalkers == 0 {
+		topTalkers = 5
+	}
+
+	return VPCFlowLogsMetrics(ctx, clients.Logs.Get(cfg.Services.VPCFlowLogs.Region), cfg.Services.VPCFlowLogs.LogGroupName, cfg.Services.VPCFlowLogs.VPCCidr, topTalkers, windowTimes(window))
+}
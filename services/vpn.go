@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+type VPNTunnel struct {
+	OutsideIP string
+	Status    string // Current state, "UP" or "DOWN"
+	Message   string // eg: "2 BGP ROUTES"
+	// Lowest TunnelState over the window, below 1 when the tunnel went down
+	// even if it is up again (a flap)
+	LowestState      float64
+	LastStatusChange *time.Time
+}
+
+type VPNResult struct {
+	VPNConnectionID string
+	Tunnels         []VPNTunnel // In the order AWS lists them
+	DataIn          float64     // MB
+	DataOut         float64     // MB
+	Location        *time.Location
+}
+
+func (r *VPNResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{
+		"TunnelDataIn":  r.DataIn,
+		"TunnelDataOut": r.DataOut,
+	}
+	var tunnelsDown float64
+	for _, tunnel := range r.Tunnels {
+		if tunnel.Status != string(ec2Types.TelemetryStatusUp) {
+			tunnelsDown++
+		}
+		metrics["Tunnel_"+tunnel.OutsideIP+"_TunnelState"] = tunnel.LowestState
+	}
+	metrics["TunnelsDown"] = tunnelsDown
+	return metrics
+}
+
+func (r *VPNResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "vpn", Title: "VPN", Subtitle: r.VPNConnectionID}
+	for _, tunnel := range r.Tunnels {
+		tunnelLine := fmt.Sprintf("Tunnel %s: %s", tunnel.OutsideIP, tunnel.Status)
+		if tunnel.Status == string(ec2Types.TelemetryStatusUp) && tunnel.LowestState < 1 {
+			tunnelLine += " (went down in the window)"
+		}
+		if tunnel.LastStatusChange != nil {
+			tunnelLine += fmt.Sprintf(", since %s", tunnel.LastStatusChange.In(r.Location).Format("02/01 15:04"))
+		}
+		if tunnel.Message != "" {
+			tunnelLine += fmt.Sprintf(", %s", tunnel.Message)
+		}
+		section.AddLine("%s", tunnelLine)
+	}
+	section.AddLine("Data In: %.2f MB%s", r.DataIn, trend("TunnelDataIn", r.DataIn))
+	section.AddLine("Data Out: %.2f MB", r.DataOut)
+	return section
+}
+
+func (r *VPNResult) Failures() []string {
+	var failures []string
+	for _, tunnel := range r.Tunnels {
+		if tunnel.Status != string(ec2Types.TelemetryStatusUp) {
+			failures = append(failures, fmt.Sprintf("VPN %s: tunnel %s is %s", r.VPNConnectionID, tunnel.OutsideIP, tunnel.Status))
+		}
+	}
+	return failures
+}
+
+// Tunnels come from the connection telemetry, their state over the window
+// from CloudWatch (TunnelState is 1 while up, 0 while down)
+func VPNMetrics(ctx context.Context, cwClient *cloudwatch.Client, ec2Client *ec2.Client, vpnConnectionID string, timeParams map[string]time.Time, location *time.Location) (*VPNResult, error) {
+	output, err := ec2Client.DescribeVpnConnections(ctx, &ec2.DescribeVpnConnectionsInput{
+		VpnConnectionIds: []string{vpnConnectionID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing VPN connection: %v", err)
+	}
+	if len(output.VpnConnections) == 0 {
+		return nil, fmt.Errorf("VPN connection %s not found", vpnConnectionID)
+	}
+
+	result := &VPNResult{VPNConnectionID: vpnConnectionID, Location: location}
+	for _, telemetry := range output.VpnConnections[0].VgwTelemetry {
+		result.Tunnels = append(result.Tunnels, VPNTunnel{
+			OutsideIP:        aws.ToString(telemetry.OutsideIpAddress),
+			Status:           string(telemetry.Status),
+			Message:          aws.ToString(telemetry.StatusMessage),
+			LastStatusChange: telemetry.LastStatusChange,
+		})
+	}
+
+	vpnDimension := types.Dimension{
+		Name:  aws.String("VpnId"),
+		Value: aws.String(vpnConnectionID),
+	}
+	queries := []metricQuery{
+		{Key: "TunnelDataIn", Namespace: "AWS/VPN", MetricName: "TunnelDataIn", Dimensions: []types.Dimension{vpnDimension}, Statistic: "Sum"},
+		{Key: "TunnelDataOut", Namespace: "AWS/VPN", MetricName: "TunnelDataOut", Dimensions: []types.Dimension{vpnDimension}, Statistic: "Sum"},
+	}
+	for _, tunnel := range result.Tunnels {
+		queries = append(queries, metricQuery{
+			Key:        "TunnelState_" + tunnel.OutsideIP,
+			Namespace:  "AWS/VPN",
+			MetricName: "TunnelState",
+			Dimensions: []types.Dimension{
+				vpnDimension,
+				{
+					Name:  aws.String("TunnelIpAddress"),
+					Value: aws.String(tunnel.OutsideIP),
+				},
+			},
+			Statistic: "Minimum",
+		})
+	}
+
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], metricPeriod(timeParams))
+	if err != nil {
+		return nil, fmt.Errorf("error getting VPN metrics: %v", err)
+	}
+
+	result.DataIn = aggregateValues("Sum", results["TunnelDataIn"]) / (1024.0 * 1024.0)
+	result.DataOut = aggregateValues("Sum", results["TunnelDataOut"]) / (1024.0 * 1024.0)
+	for i := range result.Tunnels {
+		tunnel := &result.Tunnels[i]
+		// Without datapoints, the current status is all there is
+		if values := results["TunnelState_"+tunnel.OutsideIP]; len(values) > 0 {
+			tunnel.LowestState = aggregateValues("Minimum", values)
+		} else if tunnel.Status == string(ec2Types.TelemetryStatusUp) {
+			tunnel.LowestState = 1
+		}
+	}
+	return result, nil
+}
+
+type vpnCollector struct{}
+
+func (vpnCollector) Name() string { return "vpn" }
+
+func (vpnCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.VPN.Enabled
+}
+
+func (vpnCollector) Resources(cfg *config.Config) []string {
+	return cfg.Services.VPN.VPNConnectionIDs
+}
+
+func (vpnCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, vpnConnectionID string) (utils.Result, error) {
+	region := config.ResourceRegion(cfg.Services.VPN.Region, cfg.Services.VPN.ResourceRegions, vpnConnectionID)
+	return VPNMetrics(ctx, clients.CloudWatch.Get(region), clients.EC2.Get(region), vpnConnectionID, windowTimes(window), window.Location)
+}
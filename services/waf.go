@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"telegraws/config"
 	"telegraws/utils"
 	"time"
 
@@ -14,26 +18,16 @@ import (
 	"go.uber.org/zap"
 )
 
-// Helper function to get ALB ARN from WAF
-func getALBARNFromWAF(ctx context.Context, wafClient *wafv2.Client, webACLName, webACLId string, scope wafTypes.Scope) (string, error) {
-	webACLInput := &wafv2.GetWebACLInput{
-		Name:  aws.String(webACLName),
-		Scope: scope,
-		Id:    aws.String(webACLId),
-	}
-
-	webACL, err := wafClient.GetWebACL(ctx, webACLInput)
-	if err != nil {
-		return "", fmt.Errorf("failed to get WAF details: %w", err)
-	}
+// WAF only keeps sampled requests of the last 3 hours
+const wafSampleWindow = 3 * time.Hour
 
-	// CloudFront scope has no ALB associations
-	if scope == wafTypes.ScopeCloudfront {
-		return "", nil
-	}
+// GetSampledRequests returns up to 500 requests per rule
+const maxWAFSampledRequests = 500
 
+// Helper function to get ALB ARN from WAF
+func getALBARNFromWAF(ctx context.Context, wafClient *wafv2.Client, webACLArn *string) (string, error) {
 	resourcesInput := &wafv2.ListResourcesForWebACLInput{
-		WebACLArn:    webACL.WebACL.ARN,
+		WebACLArn:    webACLArn,
 		ResourceType: wafTypes.ResourceTypeApplicationLoadBalancer,
 	}
 
@@ -53,6 +47,172 @@ func getALBARNFromWAF(ctx context.Context, wafClient *wafv2.Client, webACLName,
 	return resourcesOutput.ResourceArns[0], nil
 }
 
+// Helper function to build the BlockedRequests query of every rule of a web ACL.
+// Dimensions differ per scope (Region is only set for REGIONAL), so they are
+// taken as listed by CloudWatch.
+func wafRuleQueries(ctx context.Context, cwClient *cloudwatch.Client, webACLMetricName string) ([]metricQuery, error) {
+	paginator := cloudwatch.NewListMetricsPaginator(cwClient, &cloudwatch.ListMetricsInput{
+		Namespace:  aws.String("AWS/WAFV2"),
+		MetricName: aws.String("BlockedRequests"),
+		Dimensions: []types.DimensionFilter{
+			{
+				Name:  aws.String("WebACL"),
+				Value: aws.String(webACLMetricName),
+			},
+		},
+	})
+
+	var queries []metricQuery
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error listing WAF rule metrics: %v", err)
+		}
+
+		for _, metric := range output.Metrics {
+			// Rules inside rule groups (extra RuleGroup/ManagedRuleGroup dimensions)
+			// are already counted by the rule referencing the group
+			if slices.ContainsFunc(metric.Dimensions, func(dimension types.Dimension) bool {
+				return *dimension.Name != "Region" && *dimension.Name != "Rule" && *dimension.Name != "WebACL"
+			}) {
+				continue
+			}
+
+			for _, dimension := range metric.Dimensions {
+				// "ALL" is the web ACL total
+				if *dimension.Name != "Rule" || *dimension.Value == "ALL" {
+					continue
+				}
+				queries = append(queries, metricQuery{
+					Key:        "Rule/" + *dimension.Value,
+					Namespace:  "AWS/WAFV2",
+					MetricName: "BlockedRequests",
+					Dimensions: metric.Dimensions,
+					Statistic:  "Sum",
+				})
+			}
+		}
+	}
+
+	return queries, nil
+}
+
+// Helper function to count the blocked requests per client IP and country in
+// the sampled requests of the given rules, weighted by sample
+func wafBlockedSamples(ctx context.Context, wafClient *wafv2.Client, webACLArn *string, scope wafTypes.Scope, ruleMetricNames []string, timeParams map[string]time.Time) (map[string]float64, map[string]float64, error) {
+	startTime := timeParams["startTime"]
+	if sampleStart := timeParams["endTime"].Add(-wafSampleWindow); sampleStart.After(startTime) {
+		startTime = sampleStart
+	}
+
+	ips := map[string]float64{}
+	countries := map[string]float64{}
+	for _, ruleMetricName := range ruleMetricNames {
+		output, err := wafClient.GetSampledRequests(ctx, &wafv2.GetSampledRequestsInput{
+			WebAclArn:      webACLArn,
+			RuleMetricName: aws.String(ruleMetricName),
+			Scope:          scope,
+			TimeWindow: &wafTypes.TimeWindow{
+				StartTime: aws.Time(startTime),
+				EndTime:   aws.Time(timeParams["endTime"]),
+			},
+			MaxItems: aws.Int64(maxWAFSampledRequests),
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting sampled requests of %s: %v", ruleMetricName, err)
+		}
+
+		for _, sample := range output.SampledRequests {
+			if aws.ToString(sample.Action) != "BLOCK" || sample.Request == nil {
+				continue
+			}
+			ips[aws.ToString(sample.Request.ClientIP)] += float64(sample.Weight)
+			countries[aws.ToString(sample.Request.Country)] += float64(sample.Weight)
+		}
+	}
+
+	return ips, countries, nil
+}
+
+type RequestCount struct {
+	Name     string
+	Requests float64
+}
+
+// Allowed and blocked requests of a web ACL, with the rules, client IPs and
+// countries blocking the most
+type WAFResult struct {
+	WebACLName      string
+	AllowedRequests float64
+	BlockedRequests float64
+	TopRules        []RequestCount
+	// IPs and countries are counted from sampled requests
+	TopIPs       []RequestCount
+	TopCountries []RequestCount
+}
+
+func (r *WAFResult) Metrics() map[string]float64 {
+	return map[string]float64{
+		"AllowedRequests": r.AllowedRequests,
+		"BlockedRequests": r.BlockedRequests,
+	}
+}
+
+func (r *WAFResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "waf", Title: "WAF", Subtitle: r.WebACLName}
+	section.AddLine("Allowed Requests: %.0f%s", r.AllowedRequests, trend("AllowedRequests", r.AllowedRequests))
+	section.AddLine("Blocked Requests: %.0f%s", r.BlockedRequests, trend("BlockedRequests", r.BlockedRequests))
+	addTopRequestLines(&section, "Top Blocking Rules:", r.TopRules)
+	addTopRequestLines(&section, "Top Blocked IPs (sampled):", r.TopIPs)
+	addTopRequestLines(&section, "Top Blocked Countries (sampled):", r.TopCountries)
+	return section
+}
+
+func addTopRequestLines(section *utils.Section, title string, top []RequestCount) {
+	if len(top) == 0 {
+		return
+	}
+	section.AddLine("%s", title)
+	for _, entry := range top {
+		section.AddLine("%s: %.0f", entry.Name, entry.Requests)
+	}
+}
+
+// Helper function to get the highest counts, highest first
+func topRequests(counts map[string]float64, limit int) []RequestCount {
+	names := make([]string, 0, len(counts))
+	for name, count := range counts {
+		if name != "" && count > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	top := make([]RequestCount, 0, len(names))
+	for _, name := range names {
+		top = append(top, RequestCount{
+			Name:     name,
+			Requests: counts[name],
+		})
+	}
+	return top
+}
+
+// What the report needs from GetWebACL and the associated resource
+type wafWebACL struct {
+	ARN         string
+	MetricName  string
+	ResourceARN string // ALB or CloudFront distribution
+}
+
 func WAFMetrics(
 	ctx context.Context,
 	wafClient *wafv2.Client,
@@ -62,7 +222,8 @@ func WAFMetrics(
 	timeParams map[string]time.Time,
 	accountID string,
 	distributionID string,
-) (map[string]float64, error) {
+	topBlocked int,
+) (*WAFResult, error) {
 
 	// default -> REGIONAL
 	var scope wafTypes.Scope
@@ -73,25 +234,40 @@ func WAFMetrics(
 		scope = wafTypes.ScopeRegional
 	}
 
-	var resourceARN string
-	var err error
-
-	if scope == wafTypes.ScopeCloudfront {
-		// Build CloudFront distribution ARN
-		resourceARN = fmt.Sprintf("arn:aws:cloudfront::%s:distribution/%s", accountID, distributionID)
-	} else {
-		// Regional WAF (ALB)
-		resourceARN, err = getALBARNFromWAF(ctx, wafClient, webACLName, webACLId, scope)
+	// The web ACL and its resource are cached between runs
+	webACL, err := cached(fmt.Sprintf("waf/%s/%s/%s/%s", wafClient.Options().Region, scope, webACLId, distributionID), func() (wafWebACL, error) {
+		output, err := wafClient.GetWebACL(ctx, &wafv2.GetWebACLInput{
+			Name:  aws.String(webACLName),
+			Scope: scope,
+			Id:    aws.String(webACLId),
+		})
 		if err != nil {
-			return nil, fmt.Errorf("failed to get ALB ARN from WAF: %w", err)
+			return wafWebACL{}, fmt.Errorf("failed to get WAF details: %w", err)
+		}
+
+		webACL := wafWebACL{ARN: aws.ToString(output.WebACL.ARN), MetricName: webACLName}
+		if output.WebACL.VisibilityConfig != nil {
+			webACL.MetricName = aws.ToString(output.WebACL.VisibilityConfig.MetricName)
 		}
-	}
 
-	metrics := map[string]float64{}
-	period := aws.Int32(3600)
-	if timeParams["endTime"].Sub(timeParams["startTime"]) >= 24*time.Hour {
-		period = aws.Int32(86400)
+		if scope == wafTypes.ScopeCloudfront {
+			// Build CloudFront distribution ARN
+			webACL.ResourceARN = fmt.Sprintf("arn:aws:cloudfront::%s:distribution/%s", accountID, distributionID)
+		} else {
+			// Regional WAF (ALB)
+			webACL.ResourceARN, err = getALBARNFromWAF(ctx, wafClient, output.WebACL.ARN)
+			if err != nil {
+				return wafWebACL{}, fmt.Errorf("failed to get ALB ARN from WAF: %w", err)
+			}
+		}
+		return webACL, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	resourceARN := webACL.ResourceARN
+
+	period := aws.Int32(metricPeriod(timeParams))
 
 	wafMetrics := []struct {
 		Name      string
@@ -101,61 +277,130 @@ func WAFMetrics(
 		{"BlockedRequests", "Sum"},
 	}
 
-	for _, metric := range wafMetrics {
-		var dimensions []types.Dimension
-
-		if scope == wafTypes.ScopeCloudfront {
-			// CloudFront WAF metrics -> Resource + CF
-			dimensions = []types.Dimension{
-				{Name: aws.String("Resource"), Value: aws.String(resourceARN)},
-				{Name: aws.String("ResourceType"), Value: aws.String("CF")},
-			}
-		} else {
-			// Regional WAF (ALB, etc.)
-			dimensions = []types.Dimension{
-				{Name: aws.String("Resource"), Value: aws.String(resourceARN)},
-				{Name: aws.String("ResourceType"), Value: aws.String("ALB")},
-			}
+	var dimensions []types.Dimension
+	if scope == wafTypes.ScopeCloudfront {
+		// CloudFront WAF metrics -> Resource + CF
+		dimensions = []types.Dimension{
+			{Name: aws.String("Resource"), Value: aws.String(resourceARN)},
+			{Name: aws.String("ResourceType"), Value: aws.String("CF")},
 		}
+	} else {
+		// Regional WAF (ALB, etc.)
+		dimensions = []types.Dimension{
+			{Name: aws.String("Resource"), Value: aws.String(resourceARN)},
+			{Name: aws.String("ResourceType"), Value: aws.String("ALB")},
+		}
+	}
 
-		input := &cloudwatch.GetMetricStatisticsInput{
-			Namespace:  aws.String("AWS/WAFV2"),
-			MetricName: aws.String(metric.Name),
+	var queries []metricQuery
+	for _, metric := range wafMetrics {
+		queries = append(queries, metricQuery{
+			Key:        metric.Name,
+			Namespace:  "AWS/WAFV2",
+			MetricName: metric.Name,
 			Dimensions: dimensions,
-			StartTime:  aws.Time(timeParams["startTime"]),
-			EndTime:    aws.Time(timeParams["endTime"]),
-			Period:     period,
-			Statistics: []types.Statistic{types.Statistic(metric.Statistic)},
-		}
+			Statistic:  metric.Statistic,
+		})
+	}
 
-		result, err := cwClient.GetMetricStatistics(ctx, input)
-		if err != nil {
-			utils.Logger.Error("Failed to get WAF metric",
-				zap.Error(err),
-				zap.String("metricName", metric.Name),
-				zap.String("statistic", metric.Statistic),
-				zap.String("webACLId", webACLId),
-				zap.String("webACLName", webACLName),
-				zap.String("scope", scopeStr),
-				zap.Int32("period", *period),
-			)
-			metrics[metric.Name] = 0.0
-			continue
-		}
+	ruleQueries, err := wafRuleQueries(ctx, cwClient, webACL.MetricName)
+	if err != nil {
+		utils.Logger.Error("Failed to list WAF rule metrics",
+			zap.Error(err),
+			zap.String("webACLName", webACLName),
+		)
+	}
+	queries = append(queries, ruleQueries...)
 
-		if len(result.Datapoints) > 0 {
-			// latest datapoint
-			latest := result.Datapoints[0]
-			for _, dp := range result.Datapoints {
-				if dp.Timestamp.After(*latest.Timestamp) {
-					latest = dp
-				}
-			}
-			metrics[metric.Name] = *latest.Sum
-		} else {
-			metrics[metric.Name] = 0.0
-		}
+	results, err := getMetricData(ctx, cwClient, queries, timeParams["startTime"], timeParams["endTime"], *period)
+	if err != nil {
+		utils.Logger.Error("Failed to get WAF metrics",
+			zap.Error(err),
+			zap.String("webACLId", webACLId),
+			zap.String("webACLName", webACLName),
+			zap.String("scope", scopeStr),
+			zap.Int32("period", *period),
+		)
+	}
+
+	result := &WAFResult{
+		WebACLName:      webACLName,
+		AllowedRequests: aggregateValues("Sum", results["AllowedRequests"]),
+		BlockedRequests: aggregateValues("Sum", results["BlockedRequests"]),
+	}
+
+	ruleBlocked := map[string]float64{}
+	for _, query := range ruleQueries {
+		rule := strings.TrimPrefix(query.Key, "Rule/")
+		ruleBlocked[rule] += aggregateValues(query.Statistic, results[query.Key])
+	}
+	result.TopRules = topRequests(ruleBlocked, topBlocked)
+
+	// Samples of the rules that blocked the most requests
+	ruleMetricNames := make([]string, 0, len(result.TopRules))
+	for _, rule := range result.TopRules {
+		ruleMetricNames = append(ruleMetricNames, rule.Name)
+	}
+	ips, countries, err := wafBlockedSamples(ctx, wafClient, aws.String(webACL.ARN), scope, ruleMetricNames, timeParams)
+	if err != nil {
+		utils.Logger.Error("Failed to get WAF sampled requests",
+			zap.Error(err),
+			zap.String("webACLName", webACLName),
+		)
+	}
+	result.TopIPs = topRequests(ips, topBlocked)
+	result.TopCountries = topRequests(countries, topBlocked)
+
+	return result, nil
+}
+
+type wafCollector struct{}
+
+func (wafCollector) Name() string { return "waf" }
+
+func (wafCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.WAF.Enabled
+}
+
+func (wafCollector) Resources(cfg *config.Config) []string {
+	webACLNames := make([]string, 0, len(cfg.Services.WAF.WebACLs))
+	for _, webACL := range cfg.Services.WAF.WebACLs {
+		webACLNames = append(webACLNames, webACL.WebACLName)
+	}
+	return webACLNames
+}
+
+func (wafCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, webACLName string) (utils.Result, error) {
+	index := slices.IndexFunc(cfg.Services.WAF.WebACLs, func(webACL config.WebACLConfig) bool {
+		return webACL.WebACLName == webACLName
+	})
+	webACL := cfg.Services.WAF.WebACLs[index]
+
+	region := config.ResourceRegion(cfg.Services.WAF.Region, cfg.Services.WAF.ResourceRegions, webACL.WebACLName)
+	if webACL.Scope == "CLOUDFRONT" {
+		region = globalRegion
+	}
+
+	distributionID := webACL.DistributionID
+	if distributionID == "" {
+		distributionID = cfg.Services.CloudFront.DistributionID
+	}
+
+	topBlocked := cfg.Services.WAF.TopBlocked
+	if topBlocked == 0 {
+		topBlocked = 5
 	}
 
-	return metrics, nil
+	return WAFMetrics(
+		ctx,
+		clients.WAF.Get(region),
+		clients.CloudWatch.Get(region),
+		webACL.WebACLID,
+		webACL.WebACLName,
+		webACL.Scope,
+		windowTimes(window),
+		clients.AccountID,
+		distributionID,
+		topBlocked,
+	)
 }
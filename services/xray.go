@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"telegraws/config"
+	"telegraws/utils"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/xray"
+	xrayTypes "github.com/aws/aws-sdk-go-v2/service/xray/types"
+)
+
+// Long windows are read in slices, each service graph covers at most 6 hours
+const xrayGraphSlice = 6 * time.Hour
+
+type XRayService struct {
+	Name     string
+	Type     string // eg: "AWS::Lambda::Function", "AWS::DynamoDB::Table"
+	Requests float64
+	Faults   float64 // 5xx
+	Errors   float64 // 4xx
+	P95      float64 // ms, from the response time histogram
+}
+
+func (s XRayService) FaultRate() float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.Faults / s.Requests * 100
+}
+
+type XRayResult struct {
+	GroupName string // Empty for the default group
+	// Top services by fault rate, then p95 latency
+	Services []XRayService
+}
+
+func (r *XRayResult) Metrics() map[string]float64 {
+	metrics := map[string]float64{}
+	for _, service := range r.Services {
+		prefix := "Service_" + service.Name + "_"
+		metrics[prefix+"FaultRate"] = service.FaultRate()
+		metrics[prefix+"ResponseTime_p95"] = service.P95
+	}
+	return metrics
+}
+
+func (r *XRayResult) Render(trend utils.TrendFunc) utils.Section {
+	section := utils.Section{Service: "xray", Title: "X-Ray", Subtitle: r.GroupName}
+	if len(r.Services) == 0 {
+		section.AddLine("No traced requests")
+		return section
+	}
+	for _, service := range r.Services {
+		section.AddLine("%s: %.2f%% faults (%.0f of %.0f), %.0f errors, p95 %.0f ms",
+			service.Name,
+			service.FaultRate(),
+			service.Faults,
+			service.Requests,
+			service.Errors,
+			service.P95)
+	}
+	return section
+}
+
+// Response time histograms are merged across slices, keyed by value (s)
+type xrayServiceTotals struct {
+	service   XRayService
+	histogram map[float64]int32
+}
+
+// Services of the service graph over the window, client nodes excluded
+func XRayMetrics(ctx context.Context, xrayClient *xray.Client, groupName string, topServices int, timeParams map[string]time.Time) (*XRayResult, error) {
+	totals := map[string]*xrayServiceTotals{}
+	for sliceStart := timeParams["startTime"]; sliceStart.Before(timeParams["endTime"]); sliceStart = sliceStart.Add(xrayGraphSlice) {
+		sliceEnd := sliceStart.Add(xrayGraphSlice)
+		if sliceEnd.After(timeParams["endTime"]) {
+			sliceEnd = timeParams["endTime"]
+		}
+
+		input := &xray.GetServiceGraphInput{
+			StartTime: aws.Time(sliceStart),
+			EndTime:   aws.Time(sliceEnd),
+		}
+		if groupName != "" {
+			input.GroupName = aws.String(groupName)
+		}
+
+		paginator := xray.NewGetServiceGraphPaginator(xrayClient, input)
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("error getting X-Ray service graph: %v", err)
+			}
+			for _, service := range output.Services {
+				addXRayService(totals, service)
+			}
+		}
+	}
+
+	result := &XRayResult{GroupName: groupName}
+	for _, total := range totals {
+		if total.service.Requests == 0 {
+			continue
+		}
+		total.service.P95 = histogramPercentile(total.histogram, 0.95) * 1000
+		result.Services = append(result.Services, total.service)
+	}
+	sort.Slice(result.Services, func(i, j int) bool {
+		left, right := result.Services[i], result.Services[j]
+		if left.FaultRate() != right.FaultRate() {
+			return left.FaultRate() > right.FaultRate()
+		}
+		return left.P95 > right.P95
+	})
+	if len(result.Services) > topServices {
+		result.Services = result.Services[:topServices]
+	}
+	return result, nil
+}
+
+func addXRayService(totals map[string]*xrayServiceTotals, service xrayTypes.Service) {
+	statistics := service.SummaryStatistics
+	if aws.ToString(service.Type) == "client" || statistics == nil {
+		return
+	}
+
+	key := aws.ToString(service.Type) + "/" + aws.ToString(service.Name)
+	total, exists := totals[key]
+	if !exists {
+		total = &xrayServiceTotals{
+			service:   XRayService{Name: aws.ToString(service.Name), Type: aws.ToString(service.Type)},
+			histogram: map[float64]int32{},
+		}
+		totals[key] = total
+	}
+
+	total.service.Requests += float64(aws.ToInt64(statistics.TotalCount))
+	if statistics.FaultStatistics != nil {
+		total.service.Faults += float64(aws.ToInt64(statistics.FaultStatistics.TotalCount))
+	}
+	if statistics.ErrorStatistics != nil {
+		total.service.Errors += float64(aws.ToInt64(statistics.ErrorStatistics.TotalCount))
+	}
+	for _, entry := range service.ResponseTimeHistogram {
+		total.histogram[entry.Value] += entry.Count
+	}
+}
+
+// Smallest value at or above the percentile (0-1) of the counts, 0 when empty
+func histogramPercentile(histogram map[float64]int32, percentile float64) float64 {
+	values := make([]float64, 0, len(histogram))
+	var count int32
+	for value, entries := range histogram {
+		values = append(values, value)
+		count += entries
+	}
+	sort.Float64s(values)
+
+	var seen int32
+	for _, value := range values {
+		seen += histogram[value]
+		if float64(seen) >= percentile*float64(count) {
+			return value
+		}
+	}
+	return 0
+}
+
+type xrayCollector struct{}
+
+func (xrayCollector) Name() string { return "xray" }
+
+func (xrayCollector) Enabled(cfg *config.Config, window *config.TimeParams) bool {
+	return cfg.Services.XRay.Enabled
+}
+
+func (xrayCollector) Resources(cfg *config.Config) []string {
+	return nil
+}
+
+func (xrayCollector) Collect(ctx context.Context, cfg *config.Config, clients *Clients, window *config.TimeParams, _ string) (utils.Result, error) {
+	topServices := cfg.Services.XRay.TopServices
+	if topServices == 0 {
+		topServices = 5
+	}
+
+	return XRayMetrics(ctx, clients.XRay.Get(cfg.Services.XRay.Region), cfg.Services.XRay.GroupName, topServices, windowTimes(window))
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const anomaliesService = "anomalies"
+
+// Minimum baseline windows holding a metric before it can be flagged
+const minBaselineWindows = 3
+
+// Metrics checked for anomalies by default: 5xx, error and throttle counts
+var defaultAnomalyMetrics = []string{
+	"HTTPCode_Target_5XX_Count",
+	"HTTPCode_ELB_5XX_Count",
+	"Errors",
+	"error",
+	"Throttles",
+	"ReadThrottleEvents",
+	"WriteThrottleEvents",
+	"SystemErrors",
+	"ExecutionsFailed",
+	"FailedInvocations",
+}
+
+// A metric well above its same-hour average of the previous days
+type Anomaly struct {
+	Service string
+	Metric  string // Flattened path, eg: alb/my-alb/HTTPCode_Target_5XX_Count
+	Value   float64
+	Average float64
+	// Same hour one week ago, nil when that window is missing
+	WeekAgo *float64
+}
+
+// Helper function to match a flattened path against the checked metric names.
+// Prefixed names (TargetGroup_<name>_HTTPCode_Target_5XX_Count) also match.
+func isAnomalyMetric(path string, metricNames []string) bool {
+	name := path[strings.LastIndex(path, "/")+1:]
+	return slices.ContainsFunc(metricNames, func(metricName string) bool {
+		return name == metricName || strings.HasSuffix(name, "_"+metricName)
+	})
+}
+
+// Flags metrics at least factor times their average over the baseline windows
+// (same hour on the previous days, index 6 = one week ago)
+func DetectAnomalies(current map[string]float64, baseline []map[string]float64, factor float64, metricNames []string) []Anomaly {
+	if len(metricNames) == 0 {
+		metricNames = defaultAnomalyMetrics
+	}
+
+	var anomalies []Anomaly
+	for path, value := range current {
+		if !isAnomalyMetric(path, metricNames) {
+			continue
+		}
+
+		var sum float64
+		var windows int
+		for _, window := range baseline {
+			if previous, exists := window[path]; exists {
+				sum += previous
+				windows++
+			}
+		}
+		if windows < minBaselineWindows || sum == 0 {
+			continue
+		}
+
+		average := sum / float64(windows)
+		if value < average*factor {
+			continue
+		}
+
+		anomaly := Anomaly{
+			Service: strings.Split(path, "/")[0],
+			Metric:  path,
+			Value:   value,
+			Average: average,
+		}
+		if len(baseline) >= 7 {
+			if weekAgo, exists := baseline[6][path]; exists {
+				anomaly.WeekAgo = &weekAgo
+			}
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Metric < anomalies[j].Metric
+	})
+	return anomalies
+}
+
+func anomaliesSection(anomalies []Anomaly) Section {
+	section := Section{Service: anomaliesService, Title: "ANOMALIES"}
+	for _, anomaly := range anomalies {
+		line := fmt.Sprintf("%s: %.0f (%.1fx the 7-day average of %.1f)", anomaly.Metric, anomaly.Value, anomaly.Value/anomaly.Average, anomaly.Average)
+		if anomaly.WeekAgo != nil {
+			line += fmt.Sprintf(", %.0f a week ago", *anomaly.WeekAgo)
+		}
+		section.AddLine("%s", line)
+	}
+	return section
+}
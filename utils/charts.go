@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/wcharczuk/go-chart/v2"
+)
+
+// Renders a time series as a small PNG line chart
+func RenderChart(title string, timestamps []time.Time, values []float64, location *time.Location) ([]byte, error) {
+	localTimestamps := make([]time.Time, len(timestamps))
+	for i, timestamp := range timestamps {
+		localTimestamps[i] = timestamp.In(location)
+	}
+
+	graph := chart.Chart{
+		Title:  title,
+		Width:  800,
+		Height: 400,
+		Background: chart.Style{
+			Padding: chart.Box{Top: 50, Left: 20, Right: 20, Bottom: 20},
+		},
+		XAxis: chart.XAxis{
+			ValueFormatter: chart.TimeHourValueFormatter,
+		},
+		YAxis: chart.YAxis{
+			ValueFormatter: func(v any) string {
+				return fmt.Sprintf("%.0f", v)
+			},
+		},
+		Series: []chart.Series{
+			chart.TimeSeries{
+				XValues: localTimestamps,
+				YValues: values,
+			},
+		},
+	}
+
+	buffer := bytes.Buffer{}
+	if err := graph.Render(chart.PNG, &buffer); err != nil {
+		return nil, fmt.Errorf("error rendering chart %s: %v", title, err)
+	}
+	return buffer.Bytes(), nil
+}
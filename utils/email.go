@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sesv2"
+	sesTypes "github.com/aws/aws-sdk-go-v2/service/sesv2/types"
+)
+
+const (
+	emailTableStyle = `style="border-collapse:collapse;margin:0 0 16px 0;font-family:monospace;font-size:13px"`
+	emailCellStyle  = `style="border:1px solid #ddd;padding:4px 8px"`
+)
+
+// Subject of the report email (title of push notifications), eg: "Telegraws
+// Daily report 01/06/2024", prefixed with the severity banner when there is one
+func reportSubject(report Report) string {
+	subject := "Scheduled report " + report.Timestamp.Format("02/01/2006 15:04")
+	switch {
+	case report.Rollup != "":
+		subject = strings.ToUpper(report.Rollup[:1]) + report.Rollup[1:] + " report " + report.Period()
+	case report.IsDailyReport:
+		subject = "Daily report " + report.Timestamp.Format("02/01/2006")
+	}
+
+	if banner := report.Banner(); banner != "" {
+		subject = banner + " - " + subject
+	}
+	return "Telegraws " + subject
+}
+
+// Renders the section lines as a two column table, "label: value" lines split
+// across the columns. Blank lines are kept as empty rows.
+func emailTable(lines []string) string {
+	builder := strings.Builder{}
+	builder.WriteString("<table " + emailTableStyle + ">\n")
+	for _, line := range lines {
+		if label, value, found := strings.Cut(line, ": "); found && utf8.RuneCountInString(label) <= maxAlignedLabel {
+			fmt.Fprintf(&builder, "<tr><td %s>%s</td><td %s>%s</td></tr>\n",
+				emailCellStyle, escapeHTML(label), emailCellStyle, escapeHTML(value))
+			continue
+		}
+		if line == "" {
+			line = " "
+		}
+		fmt.Fprintf(&builder, "<tr><td %s colspan=\"2\">%s</td></tr>\n", emailCellStyle, escapeHTML(line))
+	}
+	builder.WriteString("</table>\n")
+	return builder.String()
+}
+
+// Renders the report as an HTML document with one table per section
+func RenderEmailHTML(report Report) string {
+	builder := strings.Builder{}
+	builder.WriteString("<!DOCTYPE html>\n<html><body style=\"font-family:sans-serif\">\n")
+	fmt.Fprintf(&builder, "<h2>%s</h2>\n", escapeHTML(reportSubject(report)))
+
+	for _, section := range report.Sections {
+		if section.Title != "" {
+			title := escapeHTML(section.Title)
+			if icon := statusIcon(section.Status); icon != "" {
+				title = icon + " " + title
+			}
+			if section.Subtitle != "" {
+				title += " <code>" + escapeHTML(section.Subtitle) + "</code>"
+			}
+			fmt.Fprintf(&builder, "<h3>%s</h3>\n", title)
+		}
+		if len(section.Lines) > 0 {
+			builder.WriteString(emailTable(section.Lines))
+		}
+	}
+
+	builder.WriteString("</body></html>\n")
+	return builder.String()
+}
+
+// Sections as plain text, separated by blank lines
+func plainSections(report Report) string {
+	builder := strings.Builder{}
+	for i, section := range report.Sections {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		if section.Title != "" {
+			title := section.Title
+			if icon := statusIcon(section.Status); icon != "" {
+				title = icon + " " + title
+			}
+			if section.Subtitle != "" {
+				title += " " + section.Subtitle
+			}
+			builder.WriteString(title + "\n")
+		}
+		for _, line := range section.Lines {
+			builder.WriteString(line + "\n")
+		}
+	}
+	return builder.String()
+}
+
+// Plain text alternative of the email, for clients not rendering HTML
+func renderEmailText(report Report) string {
+	return reportSubject(report) + "\n\n" + plainSections(report)
+}
+
+// Sends the report as an HTML email through SES. From must be a verified
+// identity, so must the recipients while the account is in the SES sandbox.
+type EmailNotifier struct {
+	Client *sesv2.Client
+	From   string
+	To     []string
+}
+
+func (n *EmailNotifier) Name() string { return "email" }
+
+func (n *EmailNotifier) Send(ctx context.Context, report Report) error {
+	_, err := n.Client.SendEmail(ctx, &sesv2.SendEmailInput{
+		FromEmailAddress: aws.String(n.From),
+		Destination:      &sesTypes.Destination{ToAddresses: n.To},
+		Content: &sesTypes.EmailContent{
+			Simple: &sesTypes.Message{
+				Subject: &sesTypes.Content{Data: aws.String(reportSubject(report)), Charset: aws.String("UTF-8")},
+				Body: &sesTypes.Body{
+					Html: &sesTypes.Content{Data: aws.String(RenderEmailHTML(report)), Charset: aws.String("UTF-8")},
+					Text: &sesTypes.Content{Data: aws.String(renderEmailText(report)), Charset: aws.String("UTF-8")},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error sending SES email: %v", err)
+	}
+	return nil
+}
@@ -2,248 +2,271 @@ package utils
 
 import (
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"telegraws/config"
+	"time"
 )
 
-// Helper function to escape Telegram markdown characters
-func escapeMarkdown(text string) string {
-	text = strings.ReplaceAll(text, "_", "\\_")
-	text = strings.ReplaceAll(text, "*", "\\*")
-	return text
+// Intermediate representation of a report, rendered per notifier
+type Report struct {
+	IsDailyReport bool
+	Rollup        string // "weekly" or "monthly", empty otherwise
+	StartTime     time.Time
+	Timestamp     time.Time
+	Breaches      []Breach
+	Anomalies     []Anomaly
+	Sections      []Section
+	// Collected results by service key, exposed to report templates
+	Metrics map[string]any
+	// Collections that timed out, eg: "rds", "dynamodb/my-table", "ec2 charts"
+	TimedOut []string
+	// Sent without a notification sound where supported (quiet hours, routine reports)
+	Silent bool
+	// SeverityRoutine, SeverityWarning or SeverityCritical, and why
+	Severity        string
+	SeverityReasons []string
+
+	severity config.SeverityConfig
+	muted    bool
 }
 
-func BuildMessage(cfg *config.Config, timeParams *config.TimeParams, allMetrics map[string]any) string {
-	messageBuilder := strings.Builder{}
+const partialService = "partial"
 
-	scheduleSeparator := "- - - - - - - - - - - - - - -"
-	dailySeparator := "= = = = = = = = = = = = = = ="
+// Marks a report built before every collection finished, noted at the top
+func (r *Report) MarkPartial(timedOut []string) {
+	r.TimedOut = slices.Sorted(slices.Values(timedOut))
+	r.Sections = append([]Section{partialSection(r.TimedOut)}, r.Sections...)
+}
 
-	if timeParams.IsDailyReport {
-		messageBuilder.WriteString("\n" + dailySeparator + "\n\n")
-	} else {
-		messageBuilder.WriteString("\n" + scheduleSeparator + "\n\n")
-	}
+func partialSection(timedOut []string) Section {
+	section := Section{Service: partialService, Title: "PARTIAL REPORT"}
+	section.AddLine("Timed out (%d): %s", len(timedOut), strings.Join(timedOut, ", "))
+	return section
+}
 
-	messageBuilder.WriteString(fmt.Sprintf("%s\n\n", timeParams.EndTime.Format("02/01/2006 15:04:05")))
+// Window covered by the report, eg: "01/06/2024 - 08/06/2024"
+func (r Report) Period() string {
+	return r.StartTime.Format("02/01/2006") + " - " + r.Timestamp.Format("02/01/2006")
+}
 
-	if cfg.Services.EC2.Enabled {
-		if ec2Data, exists := allMetrics["ec2"]; exists {
-			ec2Metrics := ec2Data.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("*EC2*: %s\n", cfg.Services.EC2.InstanceID))
-			messageBuilder.WriteString(fmt.Sprintf("CPU: %.2f%% (avg), %.2f%% (max)\n",
-				ec2Metrics["CPUUtilization_Average"],
-				ec2Metrics["CPUUtilization_Maximum"]))
-			messageBuilder.WriteString(fmt.Sprintf("Status Checks Failed: %.0f\n", ec2Metrics["StatusCheckFailed"]))
-			messageBuilder.WriteString(fmt.Sprintf("Network In: %.2f MB\n", ec2Metrics["NetworkIn"]))
-			messageBuilder.WriteString(fmt.Sprintf("Network Out: %.2f MB\n", ec2Metrics["NetworkOut"]))
-		}
+// Flattened metric by path (eg: "ec2/CPUUtilization_Maximum"), 0 when not collected
+func (r Report) Metric(path string) float64 {
+	return FlattenMetrics(r.Metrics)[path]
+}
+
+// Title is rendered bold. Subtitle (resource name) and lines are raw text and
+// escaped by each renderer. Empty lines are kept as blank lines.
+// Sections without lines are headers for the sections that follow.
+type Section struct {
+	Service  string // Metrics key, used to route sections to chats
+	Title    string
+	Subtitle string
+	Lines    []string
+	Status   string // StatusHealthy, StatusWarning or StatusCritical, empty without status indicators
+}
+
+func (s *Section) AddLine(format string, args ...any) {
+	s.Lines = append(s.Lines, fmt.Sprintf(format, args...))
+}
+
+// Returns a copy of the report holding only the sections and breaches of the given services
+func (r Report) ForServices(services []string) Report {
+	filtered := Report{
+		IsDailyReport: r.IsDailyReport,
+		Rollup:        r.Rollup,
+		StartTime:     r.StartTime,
+		Timestamp:     r.Timestamp,
+		Metrics:       make(map[string]any),
+		muted:         r.muted,
 	}
 
-	if cfg.Services.CloudWatchAgent.Enabled {
-		if cwAgentData, exists := allMetrics["cloudwatchAgent"]; exists {
-			cwAgentMetrics := cwAgentData.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("Memory: %.2f%% (avg), %.2f%% (max)\n",
-				cwAgentMetrics["mem_used_percent_Average"],
-				cwAgentMetrics["mem_used_percent_Maximum"]))
-			messageBuilder.WriteString(fmt.Sprintf("Disk: %.2f%%\n",
-				cwAgentMetrics["disk_used_percent"]))
-			messageBuilder.WriteString("\n")
+	for _, service := range services {
+		if metrics, exists := r.Metrics[service]; exists {
+			filtered.Metrics[service] = metrics
 		}
 	}
 
-	if cfg.Services.S3.Enabled && timeParams.IsDailyReport {
-		if s3Data, exists := allMetrics["s3"]; exists {
-			s3Metrics := s3Data.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("*S3* %s\n", escapeMarkdown(cfg.Services.S3.BucketName)))
-			messageBuilder.WriteString(fmt.Sprintf("Size: %.2f MB\n", s3Metrics["BucketSizeMB"]))
-			messageBuilder.WriteString(fmt.Sprintf("Objects: %.0f\n", s3Metrics["NumberOfObjects"]))
-			messageBuilder.WriteString("\n")
+	for _, label := range r.TimedOut {
+		service, _, _ := strings.Cut(strings.Fields(label)[0], "/")
+		if slices.Contains(services, service) {
+			filtered.TimedOut = append(filtered.TimedOut, label)
 		}
 	}
+	if len(filtered.TimedOut) > 0 {
+		filtered.Sections = append(filtered.Sections, partialSection(filtered.TimedOut))
+	}
 
-	if cfg.Services.ALB.Enabled {
-		if albData, exists := allMetrics["alb"]; exists {
-			albMetrics := albData.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("*ALB* %s\n", escapeMarkdown(cfg.Services.ALB.ALBName)))
-			messageBuilder.WriteString(fmt.Sprintf("Requests: %.0f\n", albMetrics["RequestCount"]))
-			messageBuilder.WriteString(fmt.Sprintf("Response Time: %.3f s\n", albMetrics["TargetResponseTime"]))
-			messageBuilder.WriteString(fmt.Sprintf("2xx: %.0f, 4xx: %.0f, 5xx: %.0f\n",
-				albMetrics["HTTPCode_Target_2XX_Count"],
-				albMetrics["HTTPCode_Target_4XX_Count"],
-				albMetrics["HTTPCode_Target_5XX_Count"]))
-
-			messageBuilder.WriteString(fmt.Sprintf("Healthy: %.0f, Unhealthy: %.0f\n",
-				albMetrics["HealthyHostCount"],
-				albMetrics["UnHealthyHostCount"]))
-
-			elbErrors := albMetrics["HTTPCode_ELB_4XX_Count"] + albMetrics["HTTPCode_ELB_5XX_Count"]
-			messageBuilder.WriteString(fmt.Sprintf("ALB Errors: %.0f\n", elbErrors))
-
-			messageBuilder.WriteString("\n")
+	for _, breach := range r.Breaches {
+		if slices.Contains(services, breach.Threshold.Service) {
+			filtered.Breaches = append(filtered.Breaches, breach)
 		}
 	}
-
-	if cfg.Services.CloudFront.Enabled {
-		if cfData, exists := allMetrics["cloudfront"]; exists {
-			cfMetrics := cfData.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("*CloudFront* %s\n", cfg.Services.CloudFront.DistributionID))
-			messageBuilder.WriteString(fmt.Sprintf("Requests: %.0f\n", cfMetrics["Requests"]))
-			messageBuilder.WriteString(fmt.Sprintf("4xx Error Rate: %.2f%%\n", cfMetrics["4xxErrorRate"]))
-			messageBuilder.WriteString(fmt.Sprintf("5xx Error Rate: %.2f%%\n", cfMetrics["5xxErrorRate"]))
-			messageBuilder.WriteString(fmt.Sprintf(" Uploaded: %.2f MB\n", cfMetrics["BytesUploaded"]))
-			messageBuilder.WriteString(fmt.Sprintf(" Downloaded: %.2f MB\n", cfMetrics["BytesDownloaded"]))
-			messageBuilder.WriteString("\n")
+	if len(filtered.Breaches) > 0 {
+		filtered.Sections = append(filtered.Sections, alertsSection(filtered.Breaches))
+	}
+	for _, anomaly := range r.Anomalies {
+		if slices.Contains(services, anomaly.Service) {
+			filtered.Anomalies = append(filtered.Anomalies, anomaly)
 		}
 	}
+	if len(filtered.Anomalies) > 0 {
+		filtered.Sections = append(filtered.Sections, anomaliesSection(filtered.Anomalies))
+	}
 
-	if cfg.Services.DynamoDB.Enabled {
-		if dynamoData, exists := allMetrics["dynamodb"]; exists {
-			dynamoMetrics := dynamoData.(map[string]any)
-			for _, tableName := range cfg.Services.DynamoDB.TableNames {
-				if tableData, tableExists := dynamoMetrics[tableName]; tableExists {
-					tableMetrics := tableData.(map[string]float64)
-
-					messageBuilder.WriteString(fmt.Sprintf("*DynamoDB* %s\n", escapeMarkdown(tableName)))
+	filtered.classify(r.severity)
+
+	// Headers are only kept when at least one of their sections is
+	var header *Section
+	for i, section := range r.Sections {
+		switch {
+		case section.Service == alertsService || section.Service == anomaliesService || section.Service == partialService:
+			continue
+		case len(section.Lines) == 0:
+			header = &r.Sections[i]
+		case slices.Contains(services, section.Service):
+			if header != nil {
+				filtered.Sections = append(filtered.Sections, *header)
+				header = nil
+			}
+			filtered.Sections = append(filtered.Sections, section)
+		}
+	}
 
-					billingMode := tableMetrics["BillingMode"]
+	return filtered
+}
 
-					if billingMode == 0 { // PROVISIONED
-						messageBuilder.WriteString(fmt.Sprintf("Total Requests: %.0f\n", tableMetrics["RequestCount"]))
-						messageBuilder.WriteString(fmt.Sprintf("Latency: %.2f ms\n", tableMetrics["SuccessfulRequestLatency"]))
-					} else { // ON-DEMAND
-						messageBuilder.WriteString("Total Requests: N/A (On-Demand)\n")
-						messageBuilder.WriteString("Latency: N/A\n")
-					}
-					messageBuilder.WriteString(fmt.Sprintf("Items: %.0f\n", tableMetrics["ItemCount"]))
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
 
-					messageBuilder.WriteString(fmt.Sprintf("Read Throttles: %.0f\n", tableMetrics["ReadThrottleEvents"]))
-					messageBuilder.WriteString(fmt.Sprintf("Write Throttles: %.0f\n", tableMetrics["WriteThrottleEvents"]))
-					messageBuilder.WriteString(fmt.Sprintf("Read Capacity: %.0f units\n", tableMetrics["ConsumedReadCapacityUnits"]))
-					messageBuilder.WriteString(fmt.Sprintf("Write Capacity: %.0f units\n", tableMetrics["ConsumedWriteCapacityUnits"]))
+// A collected service in report order. Resources lists the resources of
+// per-resource services in report order, nil for single-resource services.
+type ReportService struct {
+	Name      string
+	Resources []string
+}
 
-					totalErrors := tableMetrics["UserErrors"] + tableMetrics["SystemErrors"]
-					messageBuilder.WriteString(fmt.Sprintf("DB Errors: %.0f\n", totalErrors))
-					messageBuilder.WriteString("\n")
-				}
-			}
-		}
+// previousMetrics are the flattened metrics of the previous report (nil = no trends),
+// baseline those of the same hour on the previous days (nil = no anomaly detection)
+func BuildReport(cfg *config.Config, timeParams *config.TimeParams, services []ReportService, allMetrics map[string]any, previousMetrics map[string]float64, baseline []map[string]float64) Report {
+	report := Report{
+		IsDailyReport: timeParams.IsDailyReport,
+		Rollup:        timeParams.Rollup,
+		StartTime:     timeParams.StartTime,
+		Timestamp:     timeParams.EndTime,
+		Breaches:      CheckThresholds(cfg.Global.Monitoring.Thresholds, allMetrics),
+		Metrics:       allMetrics,
 	}
 
-	if cfg.Services.RDS.Enabled {
-		if rdsData, exists := allMetrics["rds"]; exists {
-			rdsMetrics := rdsData.(map[string]float64)
-
-			var rdsHeader string
-			if cfg.Services.RDS.ClusterID != "" && cfg.Services.RDS.DBInstanceIdentifier != "" {
-				rdsHeader = fmt.Sprintf("*RDS* %s / %s",
-					escapeMarkdown(cfg.Services.RDS.ClusterID),
-					escapeMarkdown(cfg.Services.RDS.DBInstanceIdentifier))
-			} else if cfg.Services.RDS.ClusterID != "" {
-				rdsHeader = fmt.Sprintf("*RDS Cluster* %s", escapeMarkdown(cfg.Services.RDS.ClusterID))
-			} else {
-				rdsHeader = fmt.Sprintf("*RDS Instance* %s", escapeMarkdown(cfg.Services.RDS.DBInstanceIdentifier))
-			}
+	if len(report.Breaches) > 0 {
+		report.Sections = append(report.Sections, alertsSection(report.Breaches))
+	}
 
-			messageBuilder.WriteString(fmt.Sprintf("%s\n", rdsHeader))
+	if baseline != nil {
+		history := cfg.Global.History
+		report.Anomalies = DetectAnomalies(FlattenMetrics(allMetrics), baseline, history.AnomalyFactor, history.AnomalyMetrics)
+	}
+	if len(report.Anomalies) > 0 {
+		report.Sections = append(report.Sections, anomaliesSection(report.Anomalies))
+	}
 
-			if cfg.Services.RDS.DBInstanceIdentifier != "" {
-				if cpu, exists := rdsMetrics["Instance_CPUUtilization_Average"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("CPU: %.2f%% (avg)", cpu))
-					if cpuMax, maxExists := rdsMetrics["Instance_CPUUtilization_Maximum"]; maxExists {
-						messageBuilder.WriteString(fmt.Sprintf(", %.2f%% (max)", cpuMax))
-					}
-					messageBuilder.WriteString("\n")
-				}
-				if mem, exists := rdsMetrics["Instance_FreeableMemory"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Free Memory: %.2f GB\n", mem))
-				}
-				if conn, exists := rdsMetrics["Instance_DatabaseConnections"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Connections: %.0f\n", conn))
-				}
-				if readLat, exists := rdsMetrics["Instance_ReadLatency"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Read Latency: %.2f ms\n", readLat))
-				}
-				if writeLat, exists := rdsMetrics["Instance_WriteLatency"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Write Latency: %.2f ms\n", writeLat))
+	// Failing resources of each service, in report order
+	var failing []Section
+	indicators := cfg.Global.Monitoring.StatusIndicators
+	render := func(result Result, service string, resource string, path ...string) Section {
+		var section Section
+		if renderer, ok := result.(DeltaRenderer); ok {
+			section = renderer.RenderDeltas(trendsFor(previousMetrics, path...), deltasFor(previousMetrics, path...))
+		} else {
+			section = result.Render(trendsFor(previousMetrics, path...))
+		}
+		if indicators.Enabled {
+			section.Status = resultStatus(indicators.Rules, service, resource, result)
+		}
+		if reporter, ok := result.(StatusReporter); ok {
+			section.Status = worseStatus(section.Status, reporter.Status())
+		}
+		if reporter, ok := result.(FailureReporter); ok {
+			if failures := reporter.Failures(); len(failures) > 0 {
+				if last := len(failing) - 1; last >= 0 && failing[last].Service == service {
+					failing[last].Lines = append(failing[last].Lines, failures...)
+				} else {
+					failing = append(failing, Section{Service: service, Title: "FAILING", Lines: failures})
 				}
 			}
+		}
+		return section
+	}
 
-			// Show cluster metrics if available
-			if cfg.Services.RDS.ClusterID != "" {
-				if volume, exists := rdsMetrics["Cluster_VolumeBytesUsed"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Volume Size: %.2f GB\n", volume))
-				}
-				if readIOPS, exists := rdsMetrics["Cluster_VolumeReadIOPs"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Read IOPS: %.0f\n", readIOPS))
+	for _, service := range services {
+		if service.Resources != nil {
+			results, _ := allMetrics[service.Name].(map[string]any)
+			var sections []Section
+			for _, resource := range service.Resources {
+				result, exists := results[resource].(Result)
+				if !exists {
+					continue
 				}
-				if writeIOPS, exists := rdsMetrics["Cluster_VolumeWriteIOPs"]; exists {
-					messageBuilder.WriteString(fmt.Sprintf("Write IOPS: %.0f\n", writeIOPS))
+				section := render(result, service.Name, resource, service.Name, resource)
+
+				// Agent metrics are shown under the section of their EC2 instance
+				// when there is one
+				index := slices.IndexFunc(report.Sections, func(ec2Section Section) bool {
+					return ec2Section.Service == "ec2" && ec2Section.Subtitle == resource
+				})
+				if service.Name == "cloudwatchAgent" && index != -1 {
+					report.Sections[index].Lines = append(report.Sections[index].Lines, section.Lines...)
+					report.Sections[index].Status = worseStatus(report.Sections[index].Status, section.Status)
+					continue
 				}
+				sections = append(sections, section)
 			}
-
-			messageBuilder.WriteString("\n")
+			report.Sections = append(report.Sections, mergeSections(sections)...)
+			continue
 		}
-	}
 
-	if cfg.Services.WAF.Enabled {
-		if wafData, exists := allMetrics["waf"]; exists {
-			wafMetrics := wafData.(map[string]float64)
-			messageBuilder.WriteString(fmt.Sprintf("*WAF* %s\n", escapeMarkdown(cfg.Services.WAF.WebACLName)))
-			messageBuilder.WriteString(fmt.Sprintf("Allowed Requests: %.0f\n", wafMetrics["AllowedRequests"]))
-			messageBuilder.WriteString(fmt.Sprintf("Blocked Requests: %.0f\n", wafMetrics["BlockedRequests"]))
-			messageBuilder.WriteString("\n")
+		result, exists := allMetrics[service.Name].(Result)
+		if !exists {
+			continue
 		}
+		report.Sections = append(report.Sections, render(result, service.Name, "", service.Name))
 	}
 
-	if cfg.Services.CloudWatchLogs.Enabled {
-		if logsData, exists := allMetrics["cloudwatchLogs"]; exists {
-			logsMetrics := logsData.(map[string]any)
-
-			applicationLogs := make(map[string]any)
-			lambdaLogs := make(map[string]any)
+	if discoveredData, exists := allMetrics["discovered"]; exists {
+		discovered := discoveredData.(map[string]map[string]any)
+		if len(discovered) > 0 {
+			report.Sections = append(report.Sections, Section{Title: "DISCOVERED"})
+		}
 
-			for _, logGroupName := range cfg.Services.CloudWatchLogs.LogGroupNames {
-				if logData, logExists := logsMetrics[logGroupName]; logExists {
-					if strings.Contains(logGroupName, "/aws/lambda/") {
-						lambdaLogs[logGroupName] = logData
-					} else {
-						applicationLogs[logGroupName] = logData
-					}
-				}
+		for _, service := range []string{"ec2", "s3", "alb", "dynamodb", "rdsCluster", "rdsInstance"} {
+			// S3 storage metrics are only published daily
+			if service == "s3" && !timeParams.IsDailyReport {
+				continue
 			}
-
-			if len(applicationLogs) > 0 {
-				messageBuilder.WriteString("*APPLICATION*\n")
-				for logGroup, logData := range applicationLogs {
-					logCounts := logData.(map[string]int)
-					messageBuilder.WriteString(fmt.Sprintf("%s:\n", escapeMarkdown(logGroup)))
-					messageBuilder.WriteString(fmt.Sprintf("INFO: %d\n", logCounts["info"]))
-					messageBuilder.WriteString(fmt.Sprintf("WARN: %d\n", logCounts["warn"]))
-					messageBuilder.WriteString(fmt.Sprintf("ERROR: %d\n", logCounts["error"]))
-					messageBuilder.WriteString("\n")
-				}
+			for _, resource := range sortedKeys(discovered[service]) {
+				result := discovered[service][resource].(Result)
+				report.Sections = append(report.Sections, render(result, service, resource, "discovered", service, resource))
 			}
+		}
+	}
 
-			if len(lambdaLogs) > 0 {
-				messageBuilder.WriteString("*LAMBDA*\n")
-				for logGroup, logData := range lambdaLogs {
-					logCounts := logData.(map[string]int)
-					messageBuilder.WriteString(fmt.Sprintf("%s:\n", escapeMarkdown(logGroup)))
-					messageBuilder.WriteString(fmt.Sprintf("INFO: %d\n", logCounts["info"]))
-					messageBuilder.WriteString(fmt.Sprintf("WARN: %d\n", logCounts["warn"]))
-					messageBuilder.WriteString(fmt.Sprintf("ERROR: %d\n", logCounts["error"]))
-					messageBuilder.WriteString("\n")
-				}
+	if len(failing) > 0 {
+		if indicators.Enabled {
+			for i := range failing {
+				failing[i].Status = StatusCritical
 			}
 		}
+		report.Sections = append(failing, report.Sections...)
 	}
 
-	if timeParams.IsDailyReport {
-		messageBuilder.WriteString(dailySeparator + "\n")
-	} else {
-		messageBuilder.WriteString(scheduleSeparator + "\n")
-	}
+	report.classify(cfg.Global.Monitoring.Severity)
 
-	return messageBuilder.String()
+	return report
 }
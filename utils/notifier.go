@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// A destination of the report, rendering it in its own format
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, report Report) error
+}
+
+// Sends the report to every notifier concurrently. A failing notifier is
+// logged and never prevents the others from receiving the report.
+func NotifyAll(ctx context.Context, notifiers []Notifier, report Report) error {
+	errs := make([]error, len(notifiers))
+
+	var wg sync.WaitGroup
+	for i, notifier := range notifiers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := notifier.Send(ctx, report)
+			if telemetry := TelemetryFrom(ctx); telemetry != nil {
+				telemetry.RecordSend(notifier.Name(), time.Since(start), err != nil)
+			}
+			if err != nil {
+				Logger.Error("Failed to send report", zap.Error(err), zap.String("notifier", notifier.Name()))
+				errs[i] = fmt.Errorf("%s: %w", notifier.Name(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+type PagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type PagerDutyEvent struct {
+	RoutingKey  string           `json:"routing_key"`
+	EventAction string           `json:"event_action"`
+	DedupKey    string           `json:"dedup_key"`
+	Payload     PagerDutyPayload `json:"payload"`
+}
+
+// One incident per breached metric: repeated breaches of the same metric are
+// grouped under the open incident instead of paging again
+func pagerDutyDedupKey(breach Breach) string {
+	parts := []string{"telegraws", breach.Threshold.Service}
+	if breach.Resource != "" {
+		parts = append(parts, breach.Resource)
+	}
+	return strings.Join(append(parts, breach.Threshold.Metric), "/")
+}
+
+// Trigger events of the breaches of a critical report, none otherwise
+func PagerDutyEvents(report Report, routingKey string, source string) []PagerDutyEvent {
+	if report.Severity != SeverityCritical {
+		return nil
+	}
+
+	var events []PagerDutyEvent
+	for _, breach := range report.Breaches {
+		target := breach.Threshold.Service
+		if breach.Resource != "" {
+			target += " " + breach.Resource
+		}
+		events = append(events, PagerDutyEvent{
+			RoutingKey:  routingKey,
+			EventAction: "trigger",
+			DedupKey:    pagerDutyDedupKey(breach),
+			Payload: PagerDutyPayload{
+				Summary: fmt.Sprintf("%s %s: %.2f (%s %.2f)",
+					target, breach.Threshold.Metric, breach.Value, breach.Threshold.Operator, breach.Threshold.Value),
+				Source:    source,
+				Severity:  SeverityCritical,
+				Component: target,
+				Class:     breach.Threshold.Metric,
+				CustomDetails: map[string]any{
+					"value":     breach.Value,
+					"threshold": fmt.Sprintf("%s %.2f", breach.Threshold.Operator, breach.Threshold.Value),
+					"report":    report.Timestamp.Format(time.RFC3339),
+				},
+			},
+		})
+	}
+	return events
+}
+
+// Pages through the PagerDuty Events API v2 when the report is critical. Other
+// reports are ignored, incidents are resolved in PagerDuty.
+type PagerDutyNotifier struct {
+	RoutingKey string
+	Source     string // Shown as the source of the incidents, eg: the function name
+}
+
+func (n *PagerDutyNotifier) Name() string { return "pagerduty" }
+
+func (n *PagerDutyNotifier) Send(ctx context.Context, report Report) error {
+	client := &http.Client{Timeout: 20 * time.Second}
+
+	var errs []error
+	for _, event := range PagerDutyEvents(report, n.RoutingKey, n.Source) {
+		if err := sendPagerDutyEvent(ctx, client, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", event.DedupKey, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sendPagerDutyEvent(ctx context.Context, client *http.Client, event PagerDutyEvent) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("error marshaling PagerDuty event: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", pagerDutyEventsURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending PagerDuty event: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("PagerDuty returned status %d (%s)", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
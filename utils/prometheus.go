@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const prometheusPrefix = "telegraws_"
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// A collected metric as a Prometheus series: dynamodb/my-table/RequestCount
+// becomes telegraws_RequestCount{service="dynamodb",resource="my-table"}
+type PrometheusSeries struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+// Series of every collected metric, sorted by name and labels
+func PrometheusMetrics(report Report, extraLabels map[string]string) []PrometheusSeries {
+	var series []PrometheusSeries
+	for path, value := range FlattenMetrics(report.Metrics) {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+		parts := strings.Split(path, "/")
+		if len(parts) < 2 {
+			continue
+		}
+
+		labels := maps.Clone(extraLabels)
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["service"] = parts[0]
+		if len(parts) > 2 {
+			labels["resource"] = strings.Join(parts[1:len(parts)-1], "/")
+		}
+
+		series = append(series, PrometheusSeries{
+			Name:   prometheusPrefix + invalidPrometheusChars.ReplaceAllString(parts[len(parts)-1], "_"),
+			Labels: labels,
+			Value:  value,
+		})
+	}
+
+	slices.SortFunc(series, func(a, b PrometheusSeries) int {
+		return strings.Compare(a.Name+formatPrometheusLabels(a.Labels), b.Name+formatPrometheusLabels(b.Labels))
+	})
+	return series
+}
+
+// {a="1",b="2"} with sorted label names
+func formatPrometheusLabels(labels map[string]string) string {
+	pairs := []string{}
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name+"="+strconv.Quote(labels[name]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Pushes the metrics of every report to a Prometheus remote-write endpoint
+// (Prometheus, Mimir, Grafana Cloud, Amazon Managed Prometheus with a proxy...)
+type PrometheusNotifier struct {
+	RemoteWriteURL string
+	BearerToken    string // Optional, takes precedence over basic auth
+	Username       string
+	Password       string
+	Labels         map[string]string // Added to every series, eg: {"job": "telegraws"}
+}
+
+func (p *PrometheusNotifier) Name() string {
+	return "prometheus"
+}
+
+// Encodes a remote-write WriteRequest:
+// WriteRequest{timeseries=1}, TimeSeries{labels=1, samples=2}, Label{name=1, value=2},
+// Sample{value=1, timestamp=2}
+func encodeWriteRequest(series []PrometheusSeries, timestamp time.Time) []byte {
+	var request []byte
+	for _, s := range series {
+		labels := maps.Clone(s.Labels)
+		labels["__name__"] = s.Name
+
+		var timeSeries []byte
+		// Remote write requires labels sorted by name
+		for _, name := range slices.Sorted(maps.Keys(labels)) {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, labels[name])
+
+			timeSeries = protowire.AppendTag(timeSeries, 1, protowire.BytesType)
+			timeSeries = protowire.AppendBytes(timeSeries, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestamp.UnixMilli()))
+
+		timeSeries = protowire.AppendTag(timeSeries, 2, protowire.BytesType)
+		timeSeries = protowire.AppendBytes(timeSeries, sample)
+
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, timeSeries)
+	}
+	return request
+}
+
+func (p *PrometheusNotifier) Send(ctx context.Context, report Report) error {
+	series := PrometheusMetrics(report, p.Labels)
+	if len(series) == 0 {
+		return nil
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(series, report.Timestamp))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.RemoteWriteURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating remote-write request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	switch {
+	case p.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+p.BearerToken)
+	case p.Username != "":
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending remote-write request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("remote-write endpoint returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// Keeps the metrics of the last report and serves them in the Prometheus text
+// format, for scraping in daemon mode
+type PrometheusExporter struct {
+	Labels map[string]string
+
+	mu     sync.Mutex
+	series []PrometheusSeries
+}
+
+func (p *PrometheusExporter) Name() string {
+	return "prometheus-exporter"
+}
+
+func (p *PrometheusExporter) Send(ctx context.Context, report Report) error {
+	series := PrometheusMetrics(report, p.Labels)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.series = series
+	return nil
+}
+
+func (p *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	series := p.series
+	p.mu.Unlock()
+
+	output := strings.Builder{}
+	lastName := ""
+	for _, s := range series {
+		if s.Name != lastName {
+			fmt.Fprintf(&output, "# TYPE %s gauge\n", s.Name)
+			lastName = s.Name
+		}
+		fmt.Fprintf(&output, "%s%s %s\n", s.Name, formatPrometheusLabels(s.Labels), strconv.FormatFloat(s.Value, 'g', -1, 64))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	io.WriteString(w, output.String())
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxNtfyMessageLength     = 4096 // Longer messages become attachments
+	maxPushoverMessageLength = 1024 // Pushover rejects longer messages
+	maxPushoverTitleLength   = 250
+	pushoverMessagesURL      = "https://api.pushover.net/1/messages.json"
+)
+
+// Cuts text to at most limit characters, marking the cut with "..."
+func truncateText(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit-3]) + "..."
+}
+
+// Body of a push notification, the title alone when there are no sections
+func pushMessage(report Report, limit int) string {
+	message := plainSections(report)
+	if message == "" {
+		message = reportSubject(report)
+	}
+	return truncateText(message, limit)
+}
+
+// Silent reports are low priority, critical ones high, the rest default. Both
+// services use a scale centered on the default priority.
+func pushPriority(report Report) int {
+	switch {
+	case report.Silent:
+		return -1
+	case report.Severity == SeverityCritical:
+		return 1
+	}
+	return 0
+}
+
+func postPush(req *http.Request, service string) error {
+	client := &http.Client{Timeout: 20 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending %s notification: %v", service, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s returned non-200 status: %d (%s)", service, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Publishes the report as plain text to an ntfy topic
+type NtfyNotifier struct {
+	Server string // eg: https://ntfy.sh
+	Topic  string
+	Token  string // Access token of protected topics, empty for public ones
+}
+
+func (n *NtfyNotifier) Name() string { return "ntfy" }
+
+func (n *NtfyNotifier) Send(ctx context.Context, report Report) error {
+	message := pushMessage(report, maxNtfyMessageLength)
+	topicURL := strings.TrimSuffix(n.Server, "/") + "/" + url.PathEscape(n.Topic)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", topicURL, strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	// Headers are ASCII, the severity banner is sent RFC 2047 encoded
+	req.Header.Set("Title", mime.BEncoding.Encode("utf-8", reportSubject(report)))
+	// ntfy priorities go from 1 (min) to 5 (max), 3 being the default
+	req.Header.Set("Priority", strconv.Itoa(3+pushPriority(report)))
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+
+	return postPush(req, "ntfy")
+}
+
+// Sends the report to a Pushover user (or group). Pushover messages are short,
+// long reports are cut.
+type PushoverNotifier struct {
+	AppToken string
+	UserKey  string
+}
+
+func (n *PushoverNotifier) Name() string { return "pushover" }
+
+func (n *PushoverNotifier) Send(ctx context.Context, report Report) error {
+	form := url.Values{
+		"token":    {n.AppToken},
+		"user":     {n.UserKey},
+		"title":    {truncateText(reportSubject(report), maxPushoverTitleLength)},
+		"message":  {pushMessage(report, maxPushoverMessageLength)},
+		"priority": {strconv.Itoa(pushPriority(report))},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", pushoverMessagesURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return postPush(req, "pushover")
+}
@@ -0,0 +1,68 @@
+package utils
+
+import "slices"
+
+// Typed result of a collected service (or of one of its resources)
+type Result interface {
+	// Numeric metrics by name, used for thresholds, trends and history
+	Metrics() map[string]float64
+	// Renders the result as a format-neutral section, each notifier renders
+	// sections in its own format (Telegram Markdown, Slack mrkdwn)
+	Render(trend TrendFunc) Section
+}
+
+// Implemented by results with failing resources (health checks, endpoints),
+// listed at the top of the report so they aren't missed
+type FailureReporter interface {
+	// One line per failing resource, none when everything is healthy
+	Failures() []string
+}
+
+// Implemented by results with a health of their own (eg: a cluster color),
+// combined with the status rules for the section icon
+type StatusReporter interface {
+	// StatusHealthy, StatusWarning, StatusCritical or empty when unknown
+	Status() string
+}
+
+// Implemented by results showing absolute changes since the previous report
+// (eg: storage growth), rendered with RenderDeltas instead of Render
+type DeltaRenderer interface {
+	RenderDeltas(trend TrendFunc, delta DeltaFunc) Section
+}
+
+// Merges the sections of resources grouped under one title (eg: all Lambda
+// functions under "Lambda Functions") in order of first appearance. Resources
+// rendering several lines are separated by a blank line.
+func mergeSections(sections []Section) []Section {
+	var groups [][]Section
+	for _, section := range sections {
+		index := slices.IndexFunc(groups, func(group []Section) bool {
+			return group[0].Service == section.Service && group[0].Title == section.Title && group[0].Subtitle == section.Subtitle
+		})
+		if index == -1 {
+			groups = append(groups, []Section{section})
+		} else {
+			groups[index] = append(groups[index], section)
+		}
+	}
+
+	merged := make([]Section, 0, len(groups))
+	for _, group := range groups {
+		spaced := slices.ContainsFunc(group, func(section Section) bool {
+			return len(section.Lines) > 1
+		})
+
+		section := group[0]
+		section.Lines = slices.Clone(section.Lines)
+		for _, next := range group[1:] {
+			if spaced {
+				section.Lines = append(section.Lines, "")
+			}
+			section.Lines = append(section.Lines, next.Lines...)
+			section.Status = worseStatus(section.Status, next.Status)
+		}
+		merged = append(merged, section)
+	}
+	return merged
+}
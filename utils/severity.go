@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"telegraws/config"
+)
+
+const (
+	SeverityRoutine  = "routine"
+	SeverityWarning  = "warning"  // Anomalies
+	SeverityCritical = "critical" // Breached thresholds or too many log errors
+)
+
+// Errors counted by the CloudWatch Logs collector across the log groups of the report
+func (r Report) logErrors() int {
+	errors := 0
+	for path, value := range FlattenMetrics(r.Metrics) {
+		if strings.HasPrefix(path, "cloudwatchLogs/") && strings.HasSuffix(path, "/error") {
+			errors += int(value)
+		}
+	}
+	return errors
+}
+
+// Sets the severity of the report and whether it is sent silently. Without
+// severity levels every report is loud (unless muted) and has no banner.
+func (r *Report) classify(severity config.SeverityConfig) {
+	r.severity = severity
+	r.Severity, r.SeverityReasons = SeverityRoutine, nil
+
+	if severity.Enabled {
+		if len(r.Breaches) > 0 {
+			r.Severity = SeverityCritical
+			r.SeverityReasons = append(r.SeverityReasons, fmt.Sprintf("%d threshold(s) breached", len(r.Breaches)))
+		}
+		if errors := r.logErrors(); severity.ErrorCount > 0 && errors >= severity.ErrorCount {
+			r.Severity = SeverityCritical
+			r.SeverityReasons = append(r.SeverityReasons, fmt.Sprintf("%d log errors", errors))
+		}
+		if len(r.Anomalies) > 0 {
+			if r.Severity == SeverityRoutine {
+				r.Severity = SeverityWarning
+			}
+			r.SeverityReasons = append(r.SeverityReasons, fmt.Sprintf("%d anomalies", len(r.Anomalies)))
+		}
+	}
+
+	r.Silent = r.muted || (severity.Enabled && r.Severity == SeverityRoutine)
+}
+
+// Sends the report silently whatever its severity (quiet hours)
+func (r *Report) Mute() {
+	r.muted = true
+	r.Silent = true
+}
+
+// Line prefixing non-routine reports, eg: "🔴 CRITICAL: 2 threshold(s) breached".
+// Empty for routine reports.
+func (r Report) Banner() string {
+	icon := "🔴"
+	switch r.Severity {
+	case SeverityCritical:
+	case SeverityWarning:
+		icon = "🟠"
+	default:
+		return ""
+	}
+	return fmt.Sprintf("%s %s: %s", icon, strings.ToUpper(r.Severity), strings.Join(r.SeverityReasons, ", "))
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"telegraws/config"
+	"time"
+)
+
+// Headers of the signed requests: the signature covers "<timestamp>.<body>",
+// so receivers can reject replayed requests by their timestamp
+const (
+	webhookSignatureHeader = "X-Telegraws-Signature"
+	webhookTimestampHeader = "X-Telegraws-Timestamp"
+)
+
+// Structured report POSTed to the webhook
+type WebhookPayload struct {
+	Timestamp       time.Time      `json:"timestamp"`
+	StartTime       time.Time      `json:"startTime"`
+	IsDailyReport   bool           `json:"isDailyReport"`
+	Rollup          string         `json:"rollup,omitempty"`
+	Severity        string         `json:"severity"`
+	SeverityReasons []string       `json:"severityReasons,omitempty"`
+	Breaches        []Breach       `json:"breaches"`
+	Anomalies       []Anomaly      `json:"anomalies"`
+	TimedOut        []string       `json:"timedOut,omitempty"`
+	Sections        []Section      `json:"sections"`
+	Metrics         map[string]any `json:"metrics"`
+	Text            string         `json:"text,omitempty"` // Plain text report, when enabled
+}
+
+func NewWebhookPayload(report Report, includeText bool) WebhookPayload {
+	payload := WebhookPayload{
+		Timestamp:       report.Timestamp,
+		StartTime:       report.StartTime,
+		IsDailyReport:   report.IsDailyReport,
+		Rollup:          report.Rollup,
+		Severity:        report.Severity,
+		SeverityReasons: report.SeverityReasons,
+		Breaches:        report.Breaches,
+		Anomalies:       report.Anomalies,
+		TimedOut:        report.TimedOut,
+		Sections:        report.Sections,
+		Metrics:         report.Metrics,
+	}
+	if includeText {
+		payload.Text = RenderTelegram(report, config.ParseModeNone)
+	}
+	return payload
+}
+
+// Hex HMAC-SHA256 of "<timestamp>.<body>", sent as "sha256=<hex>"
+func webhookSignature(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// POSTs the structured report to a URL, signed when a secret is configured
+type WebhookNotifier struct {
+	URL         string
+	Secret      string
+	IncludeText bool
+}
+
+func (n *WebhookNotifier) Name() string { return "webhook" }
+
+func (n *WebhookNotifier) Send(ctx context.Context, report Report) error {
+	jsonData, err := json.Marshal(NewWebhookPayload(report, n.IncludeText))
+	if err != nil {
+		return fmt.Errorf("error marshaling webhook payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(webhookTimestampHeader, timestamp)
+		req.Header.Set(webhookSignatureHeader, webhookSignature(n.Secret, timestamp, jsonData))
+	}
+
+	client := &http.Client{Timeout: 40 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook returned non-2xx status: %d (%s)", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
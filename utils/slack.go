@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	maxSlackBlocks    = 50   // Slack rejects messages with more blocks
+	maxSlackBlockText = 3000 // Section block text limit
+)
+
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type SlackBlock struct {
+	Type string     `json:"type"`
+	Text *SlackText `json:"text,omitempty"`
+}
+
+type SlackMessage struct {
+	Text   string       `json:"text"` // Notification fallback
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// Helper function to escape Slack mrkdwn control characters
+func escapeSlack(text string) string {
+	text = strings.ReplaceAll(text, "&", "&amp;")
+	text = strings.ReplaceAll(text, "<", "&lt;")
+	text = strings.ReplaceAll(text, ">", "&gt;")
+	return text
+}
+
+// Renders the report as Block Kit blocks: a header followed by one mrkdwn section per report section
+func RenderSlack(report Report) []SlackBlock {
+	reportType := "Scheduled report"
+	reportTime := report.Timestamp.Format("02/01/2006 15:04:05")
+	switch {
+	case report.Rollup != "":
+		reportType = strings.ToUpper(report.Rollup[:1]) + report.Rollup[1:] + " report"
+		reportTime = report.Period()
+	case report.IsDailyReport:
+		reportType = "Daily report"
+	}
+
+	blocks := []SlackBlock{
+		{
+			Type: "header",
+			Text: &SlackText{
+				Type: "plain_text",
+				Text: fmt.Sprintf("%s %s", reportType, reportTime),
+			},
+		},
+	}
+
+	if banner := report.Banner(); banner != "" {
+		blocks = append(blocks, SlackBlock{
+			Type: "section",
+			Text: &SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*", escapeSlack(banner))},
+		})
+	}
+
+	for _, section := range report.Sections {
+		textBuilder := strings.Builder{}
+		if section.Title != "" {
+			if icon := statusIcon(section.Status); icon != "" {
+				textBuilder.WriteString(icon + " ")
+			}
+			textBuilder.WriteString(fmt.Sprintf("*%s*", escapeSlack(section.Title)))
+			if section.Subtitle != "" {
+				textBuilder.WriteString(" " + escapeSlack(section.Subtitle))
+			}
+			textBuilder.WriteString("\n")
+		}
+		for _, line := range section.Lines {
+			textBuilder.WriteString(escapeSlack(line) + "\n")
+		}
+
+		text := textBuilder.String()
+		if len(text) > maxSlackBlockText {
+			text = text[:maxSlackBlockText-3] + "..."
+		}
+
+		blocks = append(blocks, SlackBlock{
+			Type: "section",
+			Text: &SlackText{Type: "mrkdwn", Text: text},
+		})
+	}
+
+	return blocks
+}
+
+// Posts the report to a Slack incoming webhook, split across several messages if needed
+func SendToSlack(ctx context.Context, report Report, webhookURL string) error {
+	blocks := RenderSlack(report)
+	fallbackText := blocks[0].Text.Text
+	if banner := report.Banner(); banner != "" {
+		fallbackText = banner + " - " + fallbackText
+	}
+
+	client := &http.Client{Timeout: 40 * time.Second}
+
+	for start := 0; start < len(blocks); start += maxSlackBlocks {
+		end := min(start+maxSlackBlocks, len(blocks))
+
+		jsonData, err := json.Marshal(SlackMessage{
+			Text:   fallbackText,
+			Blocks: blocks[start:end],
+		})
+		if err != nil {
+			return fmt.Errorf("error marshaling Slack message: %v", err)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return fmt.Errorf("error creating request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("error sending slack message: %v", err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("slack webhook returned non-200 status: %d (%s)", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+	}
+
+	return nil
+}
+
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+func (n *SlackNotifier) Name() string { return "slack" }
+
+func (n *SlackNotifier) Send(ctx context.Context, report Report) error {
+	return SendToSlack(ctx, report, n.WebhookURL)
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+const (
+	fallbackService     = "fallback"
+	maxSNSSubjectLength = 99  // SNS rejects subjects of 100 characters or more
+	maxSNSSMSLength     = 160 // A single SMS, the plain text report would be split in dozens
+)
+
+// SNS subjects must be printable ASCII, the severity icons are dropped
+func snsSubject(report Report) string {
+	subject := strings.Map(func(r rune) rune {
+		if r < ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, reportSubject(report))
+	return truncateText(strings.TrimSpace(subject), maxSNSSubjectLength)
+}
+
+// Publishes the report to an SNS topic: email subscribers get the plain text
+// report, SMS subscribers only its subject
+func PublishToSNS(ctx context.Context, snsClient *sns.Client, topicARN string, report Report) error {
+	subject := snsSubject(report)
+	message, err := json.Marshal(map[string]string{
+		"default": renderEmailText(report),
+		"sms":     truncateText(reportSubject(report), maxSNSSMSLength),
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling SNS message: %v", err)
+	}
+
+	_, err = snsClient.Publish(ctx, &sns.PublishInput{
+		TopicArn:         aws.String(topicARN),
+		Subject:          aws.String(subject),
+		Message:          aws.String(string(message)),
+		MessageStructure: aws.String("json"),
+	})
+	if err != nil {
+		return fmt.Errorf("error publishing to SNS topic '%s': %v", topicARN, err)
+	}
+	return nil
+}
+
+// Notes the earlier reports that only reached the SNS fallback
+func fallbackSection(fallbacks []time.Time, location *time.Location) Section {
+	times := make([]string, 0, len(fallbacks))
+	for _, fallback := range fallbacks {
+		times = append(times, fallback.In(location).Format("02/01 15:04"))
+	}
+	section := Section{Service: fallbackService, Title: "DELIVERY"}
+	section.AddLine("%d report(s) failed to reach Telegram and went to SNS: %s", len(fallbacks), strings.Join(times, ", "))
+	return section
+}
@@ -0,0 +1,63 @@
+package utils
+
+import "telegraws/config"
+
+// Section statuses, from best to worst. Sections without a matching rule have none.
+const (
+	StatusHealthy  = "healthy"
+	StatusWarning  = "warning"
+	StatusCritical = "critical"
+)
+
+var statusRank = map[string]int{"": 0, StatusHealthy: 1, StatusWarning: 2, StatusCritical: 3}
+
+var statusIcons = map[string]string{
+	StatusHealthy:  "🟢",
+	StatusWarning:  "🟡",
+	StatusCritical: "🔴",
+}
+
+func worseStatus(a string, b string) string {
+	if statusRank[b] > statusRank[a] {
+		return b
+	}
+	return a
+}
+
+// Icon of a section status, empty without one
+func statusIcon(status string) string {
+	return statusIcons[status]
+}
+
+func statusReached(operator string, value float64, level float64) bool {
+	if operator == "<" {
+		return value < level
+	}
+	return value > level
+}
+
+// Status of a service (or one of its resources) from the rules matching it:
+// the worst level reached, healthy when none is
+func resultStatus(rules []config.StatusRuleConfig, service string, resource string, result Result) string {
+	status := ""
+	metrics := result.Metrics()
+	for _, rule := range rules {
+		if rule.Service != service || (rule.Resource != "" && rule.Resource != resource) {
+			continue
+		}
+		value, exists := metrics[rule.Metric]
+		if !exists {
+			continue
+		}
+
+		switch {
+		case statusReached(rule.Operator, value, *rule.Critical):
+			status = worseStatus(status, StatusCritical)
+		case statusReached(rule.Operator, value, *rule.Warning):
+			status = worseStatus(status, StatusWarning)
+		default:
+			status = worseStatus(status, StatusHealthy)
+		}
+	}
+	return status
+}
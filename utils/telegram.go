@@ -4,24 +4,315 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"strings"
+	"telegraws/config"
+	"text/template"
 	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxTelegramMessageLength = 4096 // Telegram rejects longer messages
+	maxTelegramAttempts      = 4
+	telegramBaseBackoff      = 1 * time.Second
+)
+
+// Helper function to escape Telegram legacy Markdown characters
+func escapeMarkdown(text string) string {
+	text = strings.ReplaceAll(text, "_", "\\_")
+	text = strings.ReplaceAll(text, "*", "\\*")
+	return text
+}
+
+// Every MarkdownV2 reserved character has to be escaped outside of entities
+var markdownV2Replacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-",
+	"=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
 )
 
+func escapeMarkdownV2(text string) string {
+	return markdownV2Replacer.Replace(text)
+}
+
+// HTML only requires <, > and & to be escaped, whatever the resource names hold
+var htmlReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeHTML(text string) string {
+	return htmlReplacer.Replace(text)
+}
+
+// Labels longer than this aren't aligned, eg: error samples
+const maxAlignedLabel = 24
+
+// Longest <pre> block, tags included. Leaves room for the section title that
+// shares the message part of the first block.
+const maxPreBlockLength = maxTelegramMessageLength - 256
+
+// Cuts an escaped line into pieces of at most limit runes, without splitting
+// an HTML entity
+func cutEscapedLine(line string, limit int) []string {
+	var pieces []string
+	current := strings.Builder{}
+	length := 0
+	for _, r := range line {
+		escaped := escapeHTML(string(r))
+		if length+utf8.RuneCountInString(escaped) > limit {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			length = 0
+		}
+		current.WriteString(escaped)
+		length += utf8.RuneCountInString(escaped)
+	}
+	return append(pieces, current.String())
+}
+
+// Renders section lines as <pre> blocks with the values of "label: value"
+// lines aligned. Blank lines start a new block, and blocks over
+// maxPreBlockLength are continued in a new one, so split messages never cut a
+// block in half.
+func htmlPreBlocks(lines []string) string {
+	var blocks [][]string
+	var current []string
+	for _, line := range lines {
+		if line == "" {
+			if len(current) > 0 {
+				blocks = append(blocks, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+
+	rendered := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		width := 0
+		for _, line := range block {
+			if label, _, found := strings.Cut(line, ": "); found && utf8.RuneCountInString(label) <= maxAlignedLabel {
+				width = max(width, utf8.RuneCountInString(label))
+			}
+		}
+
+		// Lines of the block, escaped and cut to fit between the tags
+		var escaped []string
+		for _, line := range block {
+			if label, value, found := strings.Cut(line, ": "); found && utf8.RuneCountInString(label) <= maxAlignedLabel {
+				line = label + ":" + strings.Repeat(" ", width-utf8.RuneCountInString(label)+1) + value
+			}
+			escaped = append(escaped, cutEscapedLine(line, maxPreBlockLength-len("<pre></pre>"))...)
+		}
+
+		builder := strings.Builder{}
+		length := 0
+		for _, line := range escaped {
+			lineLength := utf8.RuneCountInString(line)
+			if length > 0 && length+1+lineLength+len("</pre>") > maxPreBlockLength {
+				builder.WriteString("</pre>")
+				rendered = append(rendered, builder.String())
+				builder.Reset()
+				length = 0
+			}
+			if length == 0 {
+				builder.WriteString("<pre>")
+				length = len("<pre>")
+			} else {
+				builder.WriteString("\n")
+				length++
+			}
+			builder.WriteString(line)
+			length += lineLength
+		}
+		builder.WriteString("</pre>")
+		rendered = append(rendered, builder.String())
+	}
+	return strings.Join(rendered, "\n\n")
+}
+
+// Helper function to get the escape and bold formatters of a Telegram parse mode
+func telegramFormatters(parseMode string) (func(string) string, func(string) string) {
+	switch parseMode {
+	case config.ParseModeMarkdownV2:
+		return escapeMarkdownV2, func(text string) string { return "*" + escapeMarkdownV2(text) + "*" }
+	case config.ParseModeMarkdown:
+		return escapeMarkdown, func(text string) string { return "*" + text + "*" }
+	case config.ParseModeHTML:
+		return escapeHTML, func(text string) string { return "<b>" + escapeHTML(text) + "</b>" }
+	}
+	plain := func(text string) string { return text }
+	return plain, plain
+}
+
+// Renders the report for the given Telegram parse mode. HTML renders resource
+// names as code and the lines of each section as aligned <pre> blocks.
+func RenderTelegram(report Report, parseMode string) string {
+	escape, bold := telegramFormatters(parseMode)
+	html := parseMode == config.ParseModeHTML
+
+	messageBuilder := strings.Builder{}
+
+	scheduleSeparator := "- - - - - - - - - - - - - - -"
+	dailySeparator := "= = = = = = = = = = = = = = ="
+
+	separator := scheduleSeparator
+	if report.IsDailyReport {
+		separator = dailySeparator
+	}
+	separator = escape(separator)
+
+	if banner := report.Banner(); banner != "" {
+		messageBuilder.WriteString("\n" + bold(banner) + "\n")
+	}
+	messageBuilder.WriteString("\n" + separator + "\n\n")
+	if report.Rollup != "" {
+		messageBuilder.WriteString(bold(strings.ToUpper(report.Rollup)+" REPORT") + "\n")
+		messageBuilder.WriteString(fmt.Sprintf("%s\n\n", escape(report.Period())))
+	} else {
+		messageBuilder.WriteString(fmt.Sprintf("%s\n\n", escape(report.Timestamp.Format("02/01/2006 15:04:05"))))
+	}
+
+	for _, section := range report.Sections {
+		if section.Title != "" {
+			if icon := statusIcon(section.Status); icon != "" {
+				messageBuilder.WriteString(icon + " ")
+			}
+			messageBuilder.WriteString(bold(section.Title))
+			if section.Subtitle != "" && html {
+				messageBuilder.WriteString(" <code>" + escape(section.Subtitle) + "</code>")
+			} else if section.Subtitle != "" {
+				messageBuilder.WriteString(" " + escape(section.Subtitle))
+			}
+			messageBuilder.WriteString("\n")
+		}
+		if html {
+			if blocks := htmlPreBlocks(section.Lines); blocks != "" {
+				messageBuilder.WriteString(blocks + "\n")
+			}
+		} else {
+			for _, line := range section.Lines {
+				messageBuilder.WriteString(escape(line) + "\n")
+			}
+		}
+		messageBuilder.WriteString("\n")
+	}
+
+	messageBuilder.WriteString(separator + "\n")
+
+	return messageBuilder.String()
+}
+
 type TelegramMessage struct {
-	ChatID    string `json:"chat_id"`
-	Text      string `json:"text"`
-	ParseMode string `json:"parse_mode"`
+	ChatID              string               `json:"chat_id"`
+	Text                string               `json:"text"`
+	ParseMode           string               `json:"parse_mode,omitempty"`           // Empty = plain text
+	DisableNotification bool                 `json:"disable_notification,omitempty"` // Delivered without sound
+	ReplyMarkup         *TelegramReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// Splits the message on section (blank line) boundaries so each chunk fits
+// in a single Telegram message. Oversized sections are split by line.
+func splitMessage(message string, limit int) []string {
+	var chunks []string
+	current := strings.Builder{}
+
+	flush := func() {
+		if chunk := strings.Trim(current.String(), "\n"); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+	}
+
+	appendPart := func(part string, separator string) {
+		if current.Len() > 0 && utf8.RuneCountInString(current.String()+separator+part) > limit {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(separator)
+		}
+		current.WriteString(part)
+	}
+
+	for _, section := range strings.Split(message, "\n\n") {
+		if utf8.RuneCountInString(section) <= limit {
+			appendPart(section, "\n\n")
+			continue
+		}
+
+		separator := "\n\n"
+		for _, line := range strings.Split(section, "\n") {
+			// A single line over the limit is cut as a last resort
+			runes := []rune(line)
+			for len(runes) > limit {
+				// Don't separate an escape backslash from the escaped character
+				cut := limit
+				if runes[cut-1] == '\\' {
+					cut--
+				}
+				flush()
+				chunks = append(chunks, string(runes[:cut]))
+				runes = runes[cut:]
+			}
+			appendPart(string(runes), separator)
+			separator = "\n"
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// Sends the message, split across several sequential messages if it exceeds the Telegram limit.
+// Silent messages are delivered without a notification sound. markup (nil =
+// none) is attached to the last message.
+func SendToTelegram(ctx context.Context, message string, botToken string, chatID string, parseMode string, silent bool, markup *TelegramReplyMarkup) error {
+	if parseMode == config.ParseModeNone {
+		parseMode = ""
+	}
+
+	chunks := splitMessage(message, maxTelegramMessageLength)
+	for i, chunk := range chunks {
+		var chunkMarkup *TelegramReplyMarkup
+		if i == len(chunks)-1 {
+			chunkMarkup = markup
+		}
+		if err := sendTelegramMessage(ctx, chunk, botToken, chatID, parseMode, silent, chunkMarkup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Telegram error payload. retry_after is set on 429 responses.
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"` // Seconds
+	} `json:"parameters"`
 }
 
-func SendToTelegram(ctx context.Context, message string, botToken string, chatID string) error {
+// Sends a single message
+func sendTelegramMessage(ctx context.Context, message string, botToken string, chatID string, parseMode string, silent bool, markup *TelegramReplyMarkup) error {
 	telegramAPI := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", botToken)
 
 	telegramMsg := TelegramMessage{
-		ChatID:    chatID,
-		Text:      message,
-		ParseMode: "Markdown",
+		ChatID:              chatID,
+		Text:                message,
+		ParseMode:           parseMode,
+		DisableNotification: silent,
+		ReplyMarkup:         markup,
 	}
 
 	jsonData, err := json.Marshal(telegramMsg)
@@ -29,22 +320,226 @@ func SendToTelegram(ctx context.Context, message string, botToken string, chatID
 		return fmt.Errorf("error marshaling Telegram message: %v", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", telegramAPI, bytes.NewBuffer(jsonData))
+	return postTelegramWithRetry(ctx, telegramAPI, "application/json", jsonData)
+}
+
+// Posts the request body, retrying with exponential backoff on 429, 5xx and network errors
+func postTelegramWithRetry(ctx context.Context, telegramAPI string, contentType string, body []byte) error {
+	client := &http.Client{Timeout: 40 * time.Second}
+
+	for attempt := 1; ; attempt++ {
+		retryAfter, retryable, err := postTelegram(ctx, client, telegramAPI, contentType, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt == maxTelegramAttempts {
+			return err
+		}
+
+		wait := telegramBaseBackoff << (attempt - 1)
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+
+		Logger.Warn("Retrying Telegram request",
+			zap.Error(err),
+			zap.Int("attempt", attempt),
+			zap.Duration("wait", wait),
+		)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Returns the wait requested by Telegram (if any) and whether the error is worth retrying
+func postTelegram(ctx context.Context, client *http.Client, telegramAPI string, contentType string, body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", telegramAPI, bytes.NewBuffer(body))
 	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+		return 0, false, fmt.Errorf("error creating request: %v", err)
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
-	client := &http.Client{Timeout: 40 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("error sending telegram message: %v", err)
+		return 0, ctx.Err() == nil, fmt.Errorf("error sending telegram message: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("telegram API returned non-200 status: %d", resp.StatusCode)
+	if resp.StatusCode == http.StatusOK {
+		return 0, false, nil
+	}
+
+	var telegramResp telegramResponse
+	respBody, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(respBody, &telegramResp); err != nil || telegramResp.Description == "" {
+		telegramResp.Description = strings.TrimSpace(string(respBody))
 	}
 
+	retryAfter := time.Duration(telegramResp.Parameters.RetryAfter) * time.Second
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+
+	return retryAfter, retryable, fmt.Errorf("telegram API returned non-200 status: %d (%s)", resp.StatusCode, telegramResp.Description)
+}
+
+// Telegram albums hold up to 10 photos
+const maxTelegramMediaGroup = 10
+
+type TelegramPhoto struct {
+	Caption string
+	PNG     []byte
+}
+
+type telegramInputMedia struct {
+	Type    string `json:"type"`
+	Media   string `json:"media"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// Sends the photos as albums of up to 10 photos (a single photo is sent with sendPhoto)
+func SendPhotosToTelegram(ctx context.Context, photos []TelegramPhoto, botToken string, chatID string, silent bool) error {
+	for start := 0; start < len(photos); start += maxTelegramMediaGroup {
+		group := photos[start:min(start+maxTelegramMediaGroup, len(photos))]
+
+		body := bytes.Buffer{}
+		writer := multipart.NewWriter(&body)
+		writer.WriteField("chat_id", chatID)
+		if silent {
+			writer.WriteField("disable_notification", "true")
+		}
+
+		method := "sendMediaGroup"
+		if len(group) == 1 {
+			method = "sendPhoto"
+			writer.WriteField("caption", group[0].Caption)
+		} else {
+			media := make([]telegramInputMedia, len(group))
+			for i, photo := range group {
+				media[i] = telegramInputMedia{
+					Type:    "photo",
+					Media:   fmt.Sprintf("attach://photo%d", i),
+					Caption: photo.Caption,
+				}
+			}
+			mediaJSON, err := json.Marshal(media)
+			if err != nil {
+				return fmt.Errorf("error marshaling Telegram media group: %v", err)
+			}
+			writer.WriteField("media", string(mediaJSON))
+		}
+
+		for i, photo := range group {
+			fieldName := fmt.Sprintf("photo%d", i)
+			if method == "sendPhoto" {
+				fieldName = "photo"
+			}
+			part, err := writer.CreateFormFile(fieldName, fieldName+".png")
+			if err != nil {
+				return fmt.Errorf("error creating Telegram photo part: %v", err)
+			}
+			part.Write(photo.PNG)
+		}
+
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("error closing Telegram multipart body: %v", err)
+		}
+
+		telegramAPI := fmt.Sprintf("https://api.telegram.org/bot%s/%s", botToken, method)
+		if err := postTelegramWithRetry(ctx, telegramAPI, writer.FormDataContentType(), body.Bytes()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// Sends the report to the configured chats, then the charts. Routed chats
+// only get the sections of their services.
+type TelegramNotifier struct {
+	BotToken  string
+	ChatIDs   []string
+	ParseMode string
+	// Replaces the built-in layout, nil = built-in layout
+	Template *template.Template
+	Routes   []config.ChatRouteConfig
+	// Routed chats are skipped without breaches or anomalies of their own
+	AlertsOnly bool
+	Photos     []TelegramPhoto
+	// Drill-down buttons under the report of ChatIDs, nil = none
+	Buttons *TelegramReplyMarkup
+	// Called once when the report can't be sent to a chat of ChatIDs, so it
+	// isn't lost. Earlier reports that needed it are noted in Fallbacks.
+	Fallback  func(ctx context.Context, report Report) error
+	Fallbacks []time.Time
+	delivered bool
+	fellBack  bool
+}
+
+func (n *TelegramNotifier) Name() string { return "telegram" }
+
+// A failing template falls back to the built-in layout so the report is never lost
+func (n *TelegramNotifier) Render(report Report) string {
+	if n.Template != nil {
+		message, err := RenderTemplate(n.Template, report)
+		if err == nil {
+			return message
+		}
+		Logger.Error("Failed to render report template, using the built-in layout", zap.Error(err))
+	}
+	return RenderTelegram(report, n.ParseMode)
+}
+
+// Whether the last Send reached every chat of ChatIDs, Fallbacks included
+func (n *TelegramNotifier) Delivered() bool { return n.delivered }
+
+// Whether the last Send handed the report to the fallback
+func (n *TelegramNotifier) FellBack() bool { return n.fellBack }
+
+// Every chat is attempted, failures are joined
+func (n *TelegramNotifier) Send(ctx context.Context, report Report) error {
+	var errs []error
+
+	fullReport := report
+	if len(n.Fallbacks) > 0 {
+		fullReport.Sections = append([]Section{fallbackSection(n.Fallbacks, report.Timestamp.Location())}, report.Sections...)
+	}
+
+	n.delivered, n.fellBack = true, false
+	fallbackAttempted := false
+	for _, chatID := range n.ChatIDs {
+		if err := SendToTelegram(ctx, n.Render(fullReport), n.BotToken, chatID, n.ParseMode, report.Silent, n.Buttons); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", chatID, err))
+			n.delivered = false
+			if n.Fallback != nil && !fallbackAttempted {
+				fallbackAttempted = true
+				if err := n.Fallback(ctx, report); err != nil {
+					errs = append(errs, fmt.Errorf("fallback: %w", err))
+				} else {
+					Logger.Warn("Report sent to the fallback", zap.String("chatId", chatID))
+					n.fellBack = true
+				}
+			}
+			continue
+		}
+
+		if len(n.Photos) > 0 {
+			if err := SendPhotosToTelegram(ctx, n.Photos, n.BotToken, chatID, report.Silent); err != nil {
+				errs = append(errs, fmt.Errorf("charts to chat %s: %w", chatID, err))
+			}
+		}
+	}
+
+	for _, route := range n.Routes {
+		routedReport := report.ForServices(route.Services)
+		if len(routedReport.Sections) == 0 || (n.AlertsOnly && len(routedReport.Breaches) == 0 && len(routedReport.Anomalies) == 0) {
+			continue
+		}
+		if err := SendToTelegram(ctx, n.Render(routedReport), n.BotToken, route.ChatID, n.ParseMode, routedReport.Silent, nil); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", route.ChatID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
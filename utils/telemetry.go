@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CloudWatch namespace of the self-telemetry metrics
+const telemetryNamespace = "Telegraws"
+
+// Counters of a single run, written to the function logs in the CloudWatch
+// embedded metric format (EMF) so CloudWatch extracts them as metrics
+type Telemetry struct {
+	mu           sync.Mutex
+	start        time.Time
+	apiCalls     map[string]int // AWS service ID -> calls (retries included)
+	throttles    map[string]int
+	sendLatency  map[string]time.Duration // Notifier -> Send duration
+	sendFailures map[string]int
+}
+
+type telemetryKey struct{}
+
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		start:        time.Now(),
+		apiCalls:     map[string]int{},
+		throttles:    map[string]int{},
+		sendLatency:  map[string]time.Duration{},
+		sendFailures: map[string]int{},
+	}
+}
+
+func WithTelemetry(ctx context.Context, telemetry *Telemetry) context.Context {
+	return context.WithValue(ctx, telemetryKey{}, telemetry)
+}
+
+// Telemetry of the current run, nil outside of a run
+func TelemetryFrom(ctx context.Context) *Telemetry {
+	telemetry, _ := ctx.Value(telemetryKey{}).(*Telemetry)
+	return telemetry
+}
+
+func (t *Telemetry) RecordAPICall(service string, throttled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.apiCalls[service]++
+	if throttled {
+		t.throttles[service]++
+	}
+}
+
+func (t *Telemetry) RecordSend(notifier string, latency time.Duration, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sendLatency[notifier] = latency
+	if failed {
+		t.sendFailures[notifier]++
+	}
+}
+
+type emfMetric struct {
+	Name  string
+	Unit  string
+	Value float64
+}
+
+// Writes one EMF log line with the metrics under the given dimensions
+func writeEMF(dimensions map[string]string, metrics []emfMetric) {
+	definitions := []map[string]string{}
+	entry := map[string]any{}
+	for _, metric := range metrics {
+		definitions = append(definitions, map[string]string{"Name": metric.Name, "Unit": metric.Unit})
+		entry[metric.Name] = metric.Value
+	}
+	for name, value := range dimensions {
+		entry[name] = value
+	}
+	entry["_aws"] = map[string]any{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]any{
+			{
+				"Namespace":  telemetryNamespace,
+				"Dimensions": [][]string{slices.Sorted(maps.Keys(dimensions))},
+				"Metrics":    definitions,
+			},
+		},
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		Logger.Error("Failed to marshal EMF metrics", zap.Error(err))
+		return
+	}
+	fmt.Fprintln(os.Stdout, string(line))
+}
+
+// Writes the run metrics: duration and failure, API calls and throttles per
+// service, send latency and failures per notifier
+func (t *Telemetry) Flush(functionName string, runErr error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	failed := 0.0
+	if runErr != nil {
+		failed = 1
+	}
+	writeEMF(map[string]string{"FunctionName": functionName}, []emfMetric{
+		{"RunDuration", "Milliseconds", float64(time.Since(t.start).Milliseconds())},
+		{"RunFailures", "Count", failed},
+	})
+
+	for _, service := range slices.Sorted(maps.Keys(t.apiCalls)) {
+		writeEMF(map[string]string{"FunctionName": functionName, "Service": service}, []emfMetric{
+			{"APICalls", "Count", float64(t.apiCalls[service])},
+			{"Throttles", "Count", float64(t.throttles[service])},
+		})
+	}
+
+	for _, notifier := range slices.Sorted(maps.Keys(t.sendLatency)) {
+		writeEMF(map[string]string{"FunctionName": functionName, "Notifier": notifier}, []emfMetric{
+			{"SendLatency", "Milliseconds", float64(t.sendLatency[notifier].Milliseconds())},
+			{"SendFailures", "Count", float64(t.sendFailures[notifier])},
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Parses a user report template. escape and bold follow the Telegram parse
+// mode so resource names never break the message formatting.
+func ParseReportTemplate(text string, parseMode string) (*template.Template, error) {
+	escape, bold := telegramFormatters(parseMode)
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		"escape": escape,
+		"bold":   bold,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing report template: %v", err)
+	}
+	return tmpl, nil
+}
+
+func RenderTemplate(tmpl *template.Template, report Report) (string, error) {
+	builder := strings.Builder{}
+	if err := tmpl.Execute(&builder, report); err != nil {
+		return "", fmt.Errorf("error executing report template: %v", err)
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,81 @@
+package utils
+
+import "telegraws/config"
+
+// Service key of the breached thresholds section
+const alertsService = "alerts"
+
+type Breach struct {
+	Threshold config.ThresholdConfig
+	Resource  string // Empty for single-resource services
+	Value     float64
+}
+
+// Compares the collected metrics against the configured thresholds
+func CheckThresholds(thresholds []config.ThresholdConfig, allMetrics map[string]any) []Breach {
+	var breaches []Breach
+
+	for _, threshold := range thresholds {
+		serviceData, exists := allMetrics[threshold.Service]
+		if !exists {
+			continue
+		}
+
+		// Service level metrics (ec2, cloudfront, vpcFlowLogs...)
+		if value, ok := metricValue(serviceData, threshold.Metric); ok {
+			if isBreached(threshold, value) {
+				breaches = append(breaches, Breach{Threshold: threshold, Value: value})
+			}
+			continue
+		}
+
+		// Per-resource metrics (alb, dynamodb, lambda, cloudwatchLogs...)
+		resources, ok := serviceData.(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, resource := range sortedKeys(resources) {
+			if threshold.Resource != "" && threshold.Resource != resource {
+				continue
+			}
+			if value, ok := metricValue(resources[resource], threshold.Metric); ok && isBreached(threshold, value) {
+				breaches = append(breaches, Breach{Threshold: threshold, Resource: resource, Value: value})
+			}
+		}
+	}
+
+	return breaches
+}
+
+func metricValue(data any, metric string) (float64, bool) {
+	result, ok := data.(Result)
+	if !ok {
+		return 0, false
+	}
+	value, exists := result.Metrics()[metric]
+	return value, exists
+}
+
+func isBreached(threshold config.ThresholdConfig, value float64) bool {
+	if threshold.Operator == "<" {
+		return value < threshold.Value
+	}
+	return value > threshold.Value
+}
+
+func alertsSection(breaches []Breach) Section {
+	section := Section{Service: alertsService, Title: "ALERTS"}
+	for _, breach := range breaches {
+		target := breach.Threshold.Service
+		if breach.Resource != "" {
+			target += " " + breach.Resource
+		}
+		section.AddLine("%s %s: %.2f (%s %.2f)",
+			target,
+			breach.Threshold.Metric,
+			breach.Value,
+			breach.Threshold.Operator,
+			breach.Threshold.Value)
+	}
+	return section
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Joins a metric path, eg: dynamodb/my-table/RequestCount
+func metricPath(parts ...string) string {
+	return strings.Join(parts, "/")
+}
+
+// Flattens the collected metrics into metric path -> value so they can be
+// persisted and compared with the next report.
+func FlattenMetrics(allMetrics map[string]any) map[string]float64 {
+	flat := map[string]float64{}
+	for key, value := range allMetrics {
+		flattenInto(flat, key, value)
+	}
+	return flat
+}
+
+func flattenInto(flat map[string]float64, prefix string, value any) {
+	switch metrics := value.(type) {
+	case Result:
+		for key, v := range metrics.Metrics() {
+			flat[metricPath(prefix, key)] = v
+		}
+	case map[string]any:
+		for key, v := range metrics {
+			flattenInto(flat, metricPath(prefix, key), v)
+		}
+	case map[string]map[string]any:
+		for key, v := range metrics {
+			flattenInto(flat, metricPath(prefix, key), v)
+		}
+	}
+}
+
+// Returns the trend of a metric against the previous report, eg: " ▲ +12%"
+type TrendFunc func(metric string, current float64) string
+
+// Trends of the metrics under the given path. Without previous values it renders nothing.
+func trendsFor(previous map[string]float64, path ...string) TrendFunc {
+	return func(metric string, current float64) string {
+		last, exists := previous[metricPath(metricPath(path...), metric)]
+		if !exists || last == 0 {
+			return ""
+		}
+
+		change := (current - last) / math.Abs(last) * 100
+		switch {
+		case change >= 1:
+			return fmt.Sprintf(" ▲ +%.0f%%", change)
+		case change <= -1:
+			return fmt.Sprintf(" ▼ %.0f%%", change)
+		}
+		return ""
+	}
+}
+
+// Returns the absolute change of a metric since the previous report, false
+// without a previous value
+type DeltaFunc func(metric string, current float64) (float64, bool)
+
+func deltasFor(previous map[string]float64, path ...string) DeltaFunc {
+	return func(metric string, current float64) (float64, bool) {
+		last, exists := previous[metricPath(metricPath(path...), metric)]
+		if !exists {
+			return 0, false
+		}
+		return current - last, true
+	}
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"telegraws/config"
+)
+
+// Callback data of the drill-down buttons, eg: "button:2" (Telegram allows 64 bytes)
+const buttonCallbackPrefix = "button:"
+
+type TelegramReplyMarkup struct {
+	InlineKeyboard [][]TelegramInlineButton `json:"inline_keyboard"`
+}
+
+type TelegramInlineButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data"`
+}
+
+// Keyboard of the drill-down buttons, two per row. nil without buttons.
+func ButtonsMarkup(buttons []config.ButtonConfig) *TelegramReplyMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+
+	markup := &TelegramReplyMarkup{}
+	for i, button := range buttons {
+		if i%2 == 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, nil)
+		}
+		row := len(markup.InlineKeyboard) - 1
+		markup.InlineKeyboard[row] = append(markup.InlineKeyboard[row], TelegramInlineButton{
+			Text:         button.Text,
+			CallbackData: buttonCallbackPrefix + strconv.Itoa(i),
+		})
+	}
+	return markup
+}
+
+// Subset of a Telegram webhook update, only button taps are handled
+type TelegramUpdate struct {
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query"`
+}
+
+type TelegramCallbackQuery struct {
+	ID      string `json:"id"`
+	Data    string `json:"data"`
+	Message *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Chat the button was tapped in, empty when the message is too old to be known
+func (q *TelegramCallbackQuery) ChatID() string {
+	if q.Message == nil {
+		return ""
+	}
+	return strconv.FormatInt(q.Message.Chat.ID, 10)
+}
+
+// Index of the tapped drill-down button, false for unknown callback data
+func (q *TelegramCallbackQuery) ButtonIndex(buttons []config.ButtonConfig) (int, bool) {
+	value, found := strings.CutPrefix(q.Data, buttonCallbackPrefix)
+	if !found {
+		return 0, false
+	}
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= len(buttons) {
+		return 0, false
+	}
+	return index, true
+}
+
+// Stops the loading indicator of the tapped button and shows text as a toast
+func AnswerCallbackQuery(ctx context.Context, botToken string, callbackQueryID string, text string) error {
+	jsonData, err := json.Marshal(map[string]string{
+		"callback_query_id": callbackQueryID,
+		"text":              text,
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling callback answer: %v", err)
+	}
+
+	telegramAPI := fmt.Sprintf("https://api.telegram.org/bot%s/answerCallbackQuery", botToken)
+	return postTelegramWithRetry(ctx, telegramAPI, "application/json", jsonData)
+}
+
+// Points the bot webhook at url. Telegram sends secretToken back in the
+// X-Telegram-Bot-Api-Secret-Token header of every request.
+func SetTelegramWebhook(ctx context.Context, botToken string, url string, secretToken string) error {
+	jsonData, err := json.Marshal(map[string]any{
+		"url":             url,
+		"secret_token":    secretToken,
+		"allowed_updates": []string{"callback_query"},
+	})
+	if err != nil {
+		return fmt.Errorf("error marshaling webhook: %v", err)
+	}
+
+	telegramAPI := fmt.Sprintf("https://api.telegram.org/bot%s/setWebhook", botToken)
+	return postTelegramWithRetry(ctx, telegramAPI, "application/json", jsonData)
+}